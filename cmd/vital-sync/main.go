@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
 	"github.com/erkinov-wtf/vital-sync/internal/api/routes"
@@ -27,11 +28,35 @@ func main() {
 	// svc init
 	authSvc := services.NewAuthService(cfg, db.DB)
 	orgSvc := services.NewOrganizationService(db.DB)
-	checkinSvc := services.NewCheckinService(db.DB, cfg)
+	vitalCatalog := services.NewVitalCatalog(db.DB)
+	if err := vitalCatalog.Seed(); err != nil {
+		lgr.Error("couldn't seed vital type catalog", "error", err)
+		return
+	}
+	thresholdRuleSvc := services.NewThresholdRuleService(db.DB, vitalCatalog)
 	checkinScheduleSvc := services.NewCheckinScheduleService(db.DB)
-	vitalReadingSvc := services.NewVitalReadingService(db.DB)
+	vitalReadingSvc := services.NewVitalReadingService(db.DB, cfg, vitalCatalog, thresholdRuleSvc)
+	checkinSvc := services.NewCheckinService(db.DB, cfg, thresholdRuleSvc, vitalReadingSvc)
 	alertSvc := services.NewAlertService(db.DB)
-	userSvc := services.NewUserService(db.DB, lgr)
+	userSvc := services.NewUserService(db.DB, lgr, vitalCatalog)
+	patternSvc := services.NewPatternService(db.DB, cfg, vitalCatalog)
+	fhirSvc := services.NewFHIRService(db.DB, vitalCatalog)
+	hl7Svc := services.NewHL7Service(db.DB, cfg, lgr, vitalReadingSvc)
+	questionnaireSvc := services.NewQuestionnaireService(db.DB, vitalCatalog)
+	instrumentSvc := services.NewInstrumentService(db.DB)
+	if err := instrumentSvc.SeedTemplates(); err != nil {
+		lgr.Error("couldn't seed instrument questionnaire templates", "error", err)
+		return
+	}
+	reviewQueueSvc := services.NewReviewQueueService(db.DB, cfg)
+	searchSvc := services.NewSearchService(db.DB)
+	analysisSvc, err := services.NewAnalysisService(db.DB, cfg, lgr, checkinSvc)
+	if err != nil {
+		lgr.Error("couldn't set up checkin analysis", "error", err)
+		return
+	}
+	checkinSvc.OnCompleted(analysisSvc.Enqueue)
+	idempotencySvc := services.NewIdempotencyService(db.DB, time.Duration(cfg.Internal.Idempotency.RetentionHours)*time.Hour)
 
 	// hnr init
 	orgHnr := handlers.NewOrganizationHandler(orgSvc)
@@ -40,14 +65,31 @@ func main() {
 	vitalReadingHnr := handlers.NewVitalReadingHandler(vitalReadingSvc)
 	alertHnr := handlers.NewAlertHandler(alertSvc)
 	userHnr := handlers.NewUserHandler(userSvc)
+	thresholdRuleHnr := handlers.NewThresholdRuleHandler(thresholdRuleSvc)
+	vitalTypeHnr := handlers.NewVitalTypeHandler(vitalCatalog)
+	fhirHnr := handlers.NewFHIRHandler(fhirSvc)
+	hl7Hnr := handlers.NewHL7Handler(hl7Svc)
+	questionnaireHnr := handlers.NewQuestionnaireHandler(questionnaireSvc)
+	instrumentHnr := handlers.NewInstrumentHandler(instrumentSvc)
+	reviewQueueHnr := handlers.NewReviewQueueHandler(reviewQueueSvc)
+	searchHnr := handlers.NewSearchHandler(searchSvc)
+	analysisHnr := handlers.NewAnalysisHandler(analysisSvc)
 
 	// workers
 	checkinScheduler := workers.NewCheckinScheduler(db.DB, lgr, checkinSvc, cfg.Timezone)
 	checkinScheduler.Start(ctx)
+	idempotencyCleaner := workers.NewIdempotencyCleaner(lgr, idempotencySvc)
+	idempotencyCleaner.Start(ctx)
+	patternDetector := workers.NewPatternDetector(lgr, patternSvc)
+	patternDetector.Start(ctx)
+	mllpListener := workers.NewMLLPListener(lgr, hl7Svc, cfg.Internal.HL7.MLLPAddress)
+	mllpListener.Start(ctx)
+	checkinAnalyzer := workers.NewCheckinAnalyzer(lgr, analysisSvc)
+	checkinAnalyzer.Start(ctx)
 
 	// engine and routes
-	router := http.NewRouter(cfg, authSvc)
-	routes.RegisterRoutes(router, orgHnr, userHnr, checkinHnr, checkinScheduleHnr, vitalReadingHnr, alertHnr)
+	router := http.NewRouter(cfg, lgr, authSvc)
+	routes.RegisterRoutes(router, lgr, idempotencySvc, orgHnr, userHnr, checkinHnr, checkinScheduleHnr, vitalReadingHnr, alertHnr, thresholdRuleHnr, vitalTypeHnr, fhirHnr, hl7Hnr, questionnaireHnr, instrumentHnr, reviewQueueHnr, searchHnr, analysisHnr)
 
 	err = router.Run()
 	if err != nil {
@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type Alert struct {
+	ID             uuid.UUID           `json:"id"`
+	PatientID      uuid.UUID           `json:"patient_id"`
+	PatientUserID  *uuid.UUID          `json:"patient_user_id"`
+	CheckinID      *uuid.UUID          `json:"checkin_id"`
+	Severity       enums.AlertSeverity `json:"severity"`
+	AlertType      enums.AlertType     `json:"alert_type"`
+	Title          string              `json:"title"`
+	Message        string              `json:"message"`
+	Details        models.JSONB        `json:"details"`
+	IsAcknowledged bool                `json:"is_acknowledged"`
+	AcknowledgedBy *uuid.UUID          `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time          `json:"acknowledged_at"`
+	ActionTaken    *string             `json:"action_taken"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
+
+// NewAlert expects Patient to be preloaded to fill PatientUserID.
+func NewAlert(a *models.Alert) Alert {
+	out := Alert{
+		ID:             a.ID,
+		PatientID:      a.PatientID,
+		CheckinID:      a.CheckinID,
+		Severity:       a.Severity,
+		AlertType:      a.AlertType,
+		Title:          a.Title,
+		Message:        a.Message,
+		Details:        a.Details,
+		IsAcknowledged: a.IsAcknowledged,
+		AcknowledgedBy: a.AcknowledgedBy,
+		AcknowledgedAt: a.AcknowledgedAt,
+		ActionTaken:    a.ActionTaken,
+		CreatedAt:      a.CreatedAt,
+	}
+	if a.Patient != nil {
+		out.PatientUserID = &a.Patient.UserID
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type Checkin struct {
+	ID         uuid.UUID  `json:"id"`
+	PatientID  uuid.UUID  `json:"patient_id"`
+	ScheduleID *uuid.UUID `json:"schedule_id"`
+
+	QuestionnaireTemplateID *uuid.UUID `json:"questionnaire_template_id"`
+
+	Status         enums.CheckinStatus `json:"status"`
+	InitiatedAt    time.Time           `json:"initiated_at"`
+	CompletedAt    *time.Time          `json:"completed_at"`
+	LastActivityAt *time.Time          `json:"last_activity_at"`
+	Partial        bool                `json:"partial"` // completed with questions left unanswered
+
+	Questions   models.JSONB `json:"questions"`
+	Answers     models.JSONB `json:"answers"`
+	RawMessages []string     `json:"raw_messages"`
+
+	AIAnalysis    models.JSONB         `json:"ai_analysis"`
+	AnalyzedAt    *time.Time           `json:"analyzed_at"`
+	MedicalStatus *enums.MedicalStatus `json:"medical_status"`
+	RiskScore     *int                 `json:"risk_score"`
+
+	AnalysisAttempts int        `json:"analysis_attempts"`
+	AnalysisError    *string    `json:"analysis_error"`
+	AnalysisFailedAt *time.Time `json:"analysis_failed_at"` // set once the analyzer gave up on the checkin
+
+	EarlyWarningScore      *int                    `json:"early_warning_score"`
+	EarlyWarningRisk       *enums.EarlyWarningRisk `json:"early_warning_risk"`
+	EarlyWarningBreakdown  models.JSONB            `json:"early_warning_breakdown"`
+	EarlyWarningComputedAt *time.Time              `json:"early_warning_computed_at"`
+
+	ReviewedBy  *uuid.UUID `json:"reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	DoctorNotes *string    `json:"doctor_notes"`
+
+	ClaimedBy *uuid.UUID `json:"claimed_by"`
+	ClaimedAt *time.Time `json:"claimed_at"`
+
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewCheckin(c *models.Checkin) Checkin {
+	return Checkin{
+		ID:                      c.ID,
+		PatientID:               c.PatientID,
+		ScheduleID:              c.ScheduleID,
+		QuestionnaireTemplateID: c.QuestionnaireTemplateID,
+		Status:                  c.Status,
+		InitiatedAt:             c.InitiatedAt,
+		CompletedAt:             c.CompletedAt,
+		LastActivityAt:          c.LastActivityAt,
+		Partial:                 c.Partial,
+		Questions:               c.Questions,
+		Answers:                 c.Answers,
+		RawMessages:             stringsOrEmpty(c.RawMessages),
+		AIAnalysis:              c.AIAnalysis,
+		AnalyzedAt:              c.AnalyzedAt,
+		MedicalStatus:           c.MedicalStatus,
+		RiskScore:               c.RiskScore,
+		AnalysisAttempts:        c.AnalysisAttempts,
+		AnalysisError:           c.AnalysisError,
+		AnalysisFailedAt:        c.AnalysisFailedAt,
+		EarlyWarningScore:       c.EarlyWarningScore,
+		EarlyWarningRisk:        c.EarlyWarningRisk,
+		EarlyWarningBreakdown:   c.EarlyWarningBreakdown,
+		EarlyWarningComputedAt:  c.EarlyWarningComputedAt,
+		ReviewedBy:              c.ReviewedBy,
+		ReviewedAt:              c.ReviewedAt,
+		DoctorNotes:             c.DoctorNotes,
+		ClaimedBy:               c.ClaimedBy,
+		ClaimedAt:               c.ClaimedAt,
+		Version:                 c.Version,
+		CreatedAt:               c.CreatedAt,
+		UpdatedAt:               c.UpdatedAt,
+	}
+}
+
+type StartCheckinRequest struct {
+	PatientID  uuid.UUID  `json:"patient_id" binding:"required"`
+	ScheduleID *uuid.UUID `json:"schedule_id"`
+}
+
+type AddCheckinQuestionsRequest struct {
+	Items []CheckinQuestionRequest `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// CheckinQuestionRequest is a question the bot asks. Key is set for
+// questions of a questionnaire template; the type-specific fields follow the
+// template question rules.
+type CheckinQuestionRequest struct {
+	Seq       int                     `json:"seq" binding:"required,min=1"`
+	Key       string                  `json:"key" binding:"omitempty,max=50"`
+	Type      enums.QuestionType      `json:"type" binding:"required"`
+	Text      string                  `json:"text" binding:"required,max=1000"`
+	Required  bool                    `json:"required"`
+	VitalType *enums.VitalType        `json:"vital_type"`
+	Unit      *enums.VitalUnit        `json:"unit"`
+	Min       *float64                `json:"min"`
+	Max       *float64                `json:"max"`
+	Choices   []QuestionChoiceRequest `json:"choices" binding:"omitempty,dive"`
+	Multiple  bool                    `json:"multiple"`
+
+	Locale        *string    `json:"locale" binding:"omitempty,max=10"`
+	AskedAt       *time.Time `json:"asked_at"`
+	SourceMessage *string    `json:"source_message" binding:"omitempty,max=4000"`
+}
+
+func (q CheckinQuestionRequest) TemplateQuestion() models.TemplateQuestion {
+	return models.TemplateQuestion{
+		Key:       q.Key,
+		Type:      q.Type,
+		Text:      q.Text,
+		Required:  q.Required,
+		VitalType: q.VitalType,
+		Unit:      q.Unit,
+		Min:       q.Min,
+		Max:       q.Max,
+		Choices:   questionChoices(q.Choices),
+		Multiple:  q.Multiple,
+	}
+}
+
+type AddCheckinAnswersRequest struct {
+	Items []CheckinAnswerRequest `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// CheckinAnswerRequest answers a question of the checkin, named by
+// question_id or seq. Value is checked against the question's type.
+type CheckinAnswerRequest struct {
+	QuestionID *uuid.UUID  `json:"question_id"`
+	Seq        *int        `json:"seq" binding:"omitempty,min=1"`
+	Value      interface{} `json:"value"`
+	Text       *string     `json:"text" binding:"omitempty,max=5000"`
+
+	Locale        *string    `json:"locale" binding:"omitempty,max=10"`
+	AnsweredAt    *time.Time `json:"answered_at"`
+	SourceMessage *string    `json:"source_message" binding:"omitempty,max=4000"`
+}
+
+// CheckinTransitionRequest cancels or fails a checkin. Reason and UserID,
+// the user it is done on behalf of, are recorded in the checkin's history.
+type CheckinTransitionRequest struct {
+	Reason *string    `json:"reason" binding:"omitempty,max=1000"`
+	UserID *uuid.UUID `json:"user_id"`
+}
+
+type CheckinTransition struct {
+	ID         uuid.UUID            `json:"id"`
+	CheckinID  uuid.UUID            `json:"checkin_id"`
+	FromStatus *enums.CheckinStatus `json:"from_status"`
+	ToStatus   enums.CheckinStatus  `json:"to_status"`
+	Trigger    enums.CheckinTrigger `json:"trigger"`
+	UserID     *uuid.UUID           `json:"user_id"`
+	Reason     *string              `json:"reason"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+func NewCheckinTransition(t *models.CheckinTransition) CheckinTransition {
+	return CheckinTransition{
+		ID:         t.ID,
+		CheckinID:  t.CheckinID,
+		FromStatus: t.FromStatus,
+		ToStatus:   t.ToStatus,
+		Trigger:    t.Trigger,
+		UserID:     t.UserID,
+		Reason:     t.Reason,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+type ReviewCheckinRequest struct {
+	DoctorID    uuid.UUID `json:"doctor_id" binding:"required"`
+	DoctorNotes *string   `json:"doctor_notes"`
+}
+
+type CheckinAlertRequest struct {
+	Severity  enums.AlertSeverity `json:"severity"`
+	AlertType enums.AlertType     `json:"alert_type"`
+	Title     string              `json:"title"`
+	Message   string              `json:"message"`
+	Details   *models.JSONB       `json:"details"`
+}
+
+type UpdateCheckinAnalysisRequest struct {
+	AIAnalysis    *models.JSONB        `json:"ai_analysis"`
+	MedicalStatus *enums.MedicalStatus `json:"medical_status"`
+	RiskScore     *int                 `json:"risk_score"`
+	Alert         *CheckinAlertRequest `json:"alert"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+import "github.com/erkinov-wtf/vital-sync/internal/api/services"
+
+type FHIRImportSkip struct {
+	// Entry is the index of the entry in the bundle.
+	Entry        int    `json:"entry"`
+	ResourceType string `json:"resource_type"`
+	ID           string `json:"id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+type FHIRImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	User     User             `json:"user"`
+	Patient  Patient          `json:"patient"`
+	Schedule *CheckinSchedule `json:"schedule"`
+	// Imported counts the bundle entries used, by resource type.
+	Imported map[string]int   `json:"imported"`
+	Skipped  []FHIRImportSkip `json:"skipped"`
+}
+
+func NewFHIRImportResult(r *services.FHIRImportResult) FHIRImportResult {
+	out := FHIRImportResult{
+		DryRun:   r.DryRun,
+		User:     NewUser(r.User),
+		Patient:  NewPatient(r.Patient),
+		Imported: r.Imported,
+		Skipped:  make([]FHIRImportSkip, len(r.Skipped)),
+	}
+	if r.Schedule != nil {
+		schedule := NewCheckinSchedule(r.Schedule)
+		out.Schedule = &schedule
+	}
+	for i, s := range r.Skipped {
+		out.Skipped[i] = FHIRImportSkip{Entry: s.Entry, ResourceType: s.ResourceType, ID: s.ID, Reason: s.Reason}
+	}
+	return out
+}
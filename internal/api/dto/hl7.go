@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type HL7Message struct {
+	ID                 uuid.UUID              `json:"id"`
+	ControlID          string                 `json:"control_id"`
+	SendingApplication string                 `json:"sending_application"`
+	SendingFacility    string                 `json:"sending_facility"`
+	MessageType        string                 `json:"message_type"`
+	Transport          enums.HL7Transport     `json:"transport"`
+	Status             enums.HL7MessageStatus `json:"status"`
+	AckCode            string                 `json:"ack_code"`
+	Problems           []string               `json:"problems"`
+	PatientID          *uuid.UUID             `json:"patient_id"`
+	PatientUserID      *uuid.UUID             `json:"patient_user_id"`
+	Raw                string                 `json:"raw"`
+	Ack                string                 `json:"ack"`
+	ReceivedAt         time.Time              `json:"received_at"`
+	ProcessedAt        time.Time              `json:"processed_at"`
+}
+
+// NewHL7Message expects Patient to be preloaded to fill PatientUserID.
+func NewHL7Message(m *models.HL7Message) HL7Message {
+	out := HL7Message{
+		ID:                 m.ID,
+		ControlID:          m.ControlID,
+		SendingApplication: m.SendingApplication,
+		SendingFacility:    m.SendingFacility,
+		MessageType:        m.MessageType,
+		Transport:          m.Transport,
+		Status:             m.Status,
+		AckCode:            m.AckCode,
+		Problems:           m.Problems,
+		PatientID:          m.PatientID,
+		Raw:                m.Raw,
+		Ack:                m.Ack,
+		ReceivedAt:         m.ReceivedAt,
+		ProcessedAt:        m.ProcessedAt,
+	}
+	if out.Problems == nil {
+		out.Problems = []string{}
+	}
+	if m.Patient != nil {
+		out.PatientUserID = &m.Patient.UserID
+	}
+	return out
+}
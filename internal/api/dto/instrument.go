@@ -0,0 +1,97 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type Instrument struct {
+	Code          enums.Instrument `json:"code"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	TemplateKey   string           `json:"template_key"`
+	Items         []InstrumentItem `json:"items"`
+	Min           float64          `json:"min"`
+	Max           float64          `json:"max"`
+	HigherIsWorse bool             `json:"higher_is_worse"`
+	MinimalChange float64          `json:"minimal_change"`
+	Bands         []InstrumentBand `json:"bands"`
+}
+
+type InstrumentItem struct {
+	Key     string                  `json:"key"`
+	Text    string                  `json:"text"`
+	Choices []models.QuestionChoice `json:"choices"`
+}
+
+// InstrumentBand starts at From and runs to the next band's From. Alert is
+// the severity of the alert a score in the band raises, if any.
+type InstrumentBand struct {
+	From  float64              `json:"from"`
+	Band  enums.InstrumentBand `json:"band"`
+	Alert *enums.AlertSeverity `json:"alert"`
+}
+
+func NewInstrument(i *services.Instrument) Instrument {
+	items := make([]InstrumentItem, len(i.Items))
+	for j, item := range i.Items {
+		items[j] = InstrumentItem{Key: item.Key, Text: item.Text, Choices: item.Choices}
+	}
+	bands := make([]InstrumentBand, len(i.Bands))
+	for j, b := range i.Bands {
+		bands[j] = InstrumentBand{From: b.From, Band: b.Band}
+		if b.Alert != "" {
+			alert := b.Alert
+			bands[j].Alert = &alert
+		}
+	}
+	return Instrument{
+		Code:          i.Code,
+		Name:          i.Name,
+		Description:   i.Description,
+		TemplateKey:   i.TemplateKey(),
+		Items:         items,
+		Min:           i.Min,
+		Max:           i.Max,
+		HigherIsWorse: i.HigherIsWorse,
+		MinimalChange: i.MinimalChange,
+		Bands:         bands,
+	}
+}
+
+type InstrumentScore struct {
+	ID          uuid.UUID            `json:"id"`
+	PatientID   uuid.UUID            `json:"patient_id"`
+	CheckinID   uuid.UUID            `json:"checkin_id"`
+	Instrument  enums.Instrument     `json:"instrument"`
+	Score       float64              `json:"score"`
+	Band        enums.InstrumentBand `json:"band"`
+	Subscores   map[string]float64   `json:"subscores"`
+	Items       map[string]float64   `json:"items"`
+	CompletedAt time.Time            `json:"completed_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+func NewInstrumentScore(s *models.InstrumentScore) InstrumentScore {
+	var subscores, items map[string]float64
+	_ = s.Subscores.Unmarshal(&subscores)
+	_ = s.Items.Unmarshal(&items)
+	return InstrumentScore{
+		ID:          s.ID,
+		PatientID:   s.PatientID,
+		CheckinID:   s.CheckinID,
+		Instrument:  s.Instrument,
+		Score:       s.Score,
+		Band:        s.Band,
+		Subscores:   subscores,
+		Items:       items,
+		CompletedAt: s.CompletedAt,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
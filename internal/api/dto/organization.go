@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type Organization struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Address       *string   `json:"address"`
+	LicenseNumber string    `json:"license_number"`
+	ContactEmail  *string   `json:"contact_email"`
+	ContactPhone  *string   `json:"contact_phone"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func NewOrganization(o *models.Organization) Organization {
+	return Organization{
+		ID:            o.ID,
+		Name:          o.Name,
+		Address:       o.Address,
+		LicenseNumber: o.LicenseNumber,
+		ContactEmail:  o.ContactEmail,
+		ContactPhone:  o.ContactPhone,
+		IsActive:      o.IsActive,
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+}
+
+type CreateOrganizationRequest struct {
+	Name          string  `json:"name" binding:"required"`
+	Address       *string `json:"address" binding:"required"`
+	LicenseNumber string  `json:"license_number" binding:"required"`
+	ContactEmail  *string `json:"contact_email" binding:"required"`
+	ContactPhone  *string `json:"contact_phone" binding:"required"`
+	IsActive      *bool   `json:"is_active" binding:"required"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name          *string `json:"name"`
+	Address       *string `json:"address"`
+	LicenseNumber *string `json:"license_number"`
+	ContactEmail  *string `json:"contact_email"`
+	ContactPhone  *string `json:"contact_phone"`
+	IsActive      *bool   `json:"is_active"`
+}
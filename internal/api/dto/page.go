@@ -0,0 +1,20 @@
+package dto
+
+import "github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+
+// NewPage maps every item of a model page to its response type, keeping the paging metadata.
+func NewPage[M any, D any](page *pagination.Page[M], fn func(*M) D) *pagination.Page[D] {
+	if page == nil {
+		return nil
+	}
+	out := &pagination.Page[D]{Items: make([]D, 0, len(page.Items)), Meta: page.Meta}
+	for i := range page.Items {
+		out.Items = append(out.Items, fn(&page.Items[i]))
+	}
+	return out
+}
+
+// List wraps a non-paginated collection in the same "data" envelope.
+type List[T any] struct {
+	Items []T `json:"data"`
+}
@@ -0,0 +1,130 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+)
+
+type Patient struct {
+	ID       uuid.UUID `json:"id"`
+	UserID   uuid.UUID `json:"user_id"`
+	DoctorID uuid.UUID `json:"doctor_id"`
+
+	ConditionSummary   string       `json:"condition_summary"`
+	Comorbidities      []string     `json:"comorbidities"`
+	CurrentMedications models.JSONB `json:"current_medications"`
+	Allergies          []string     `json:"allergies"`
+	BaselineVitals     models.JSONB `json:"baseline_vitals"`
+
+	RiskLevel           enums.RiskLevel           `json:"risk_level"`
+	MonitoringFrequency enums.MonitoringFrequency `json:"monitoring_frequency"`
+
+	Status         enums.PatientStatus `json:"status"`
+	DischargeDate  *time.Time          `json:"discharge_date"`
+	DischargeNotes *string             `json:"discharge_notes"`
+
+	EmergencyContactName     *string `json:"emergency_contact_name"`
+	EmergencyContactPhone    *string `json:"emergency_contact_phone"`
+	EmergencyContactRelation *string `json:"emergency_contact_relation"`
+
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User   *User `json:"user,omitempty"`
+	Doctor *User `json:"doctor,omitempty"`
+}
+
+func NewPatient(p *models.Patient) Patient {
+	return Patient{
+		ID:                       p.ID,
+		UserID:                   p.UserID,
+		DoctorID:                 p.DoctorID,
+		ConditionSummary:         p.ConditionSummary,
+		Comorbidities:            stringsOrEmpty(p.Comorbidities),
+		CurrentMedications:       p.CurrentMedications,
+		Allergies:                stringsOrEmpty(p.Allergies),
+		BaselineVitals:           p.BaselineVitals,
+		RiskLevel:                p.RiskLevel,
+		MonitoringFrequency:      p.MonitoringFrequency,
+		Status:                   p.Status,
+		DischargeDate:            p.DischargeDate,
+		DischargeNotes:           p.DischargeNotes,
+		EmergencyContactName:     p.EmergencyContactName,
+		EmergencyContactPhone:    p.EmergencyContactPhone,
+		EmergencyContactRelation: p.EmergencyContactRelation,
+		Version:                  p.Version,
+		CreatedAt:                p.CreatedAt,
+		UpdatedAt:                p.UpdatedAt,
+		User:                     newUserRef(p.User),
+		Doctor:                   newUserRef(p.Doctor),
+	}
+}
+
+type PatientComplete struct {
+	User          User                           `json:"user"`
+	Patient       Patient                        `json:"patient"`
+	Schedule      *CheckinSchedule               `json:"schedule"`
+	Checkins      *pagination.Page[Checkin]      `json:"checkins"`
+	VitalReadings *pagination.Page[VitalReading] `json:"vital_readings"`
+}
+
+func NewPatientComplete(user *models.User, patient *models.Patient, schedule *models.CheckinSchedule,
+	checkins *pagination.Page[models.Checkin], vitals *pagination.Page[models.VitalReading]) PatientComplete {
+	out := PatientComplete{
+		User:          NewUser(user),
+		Patient:       NewPatient(patient),
+		Checkins:      NewPage(checkins, NewCheckin),
+		VitalReadings: NewPage(vitals, NewVitalReading),
+	}
+	if schedule != nil {
+		s := NewCheckinSchedule(schedule)
+		out.Schedule = &s
+	}
+	return out
+}
+
+type CreatePatientMedicalRequest struct {
+	DoctorID                 uuid.UUID                  `json:"doctor_id" binding:"required"`
+	ConditionSummary         string                     `json:"condition_summary" binding:"required"`
+	Comorbidities            []string                   `json:"comorbidities"`
+	CurrentMedications       models.JSONB               `json:"current_medications"`
+	Allergies                []string                   `json:"allergies"`
+	BaselineVitals           models.BaselineVitals      `json:"baseline_vitals"`
+	RiskLevel                *enums.RiskLevel           `json:"risk_level"`
+	MonitoringFrequency      *enums.MonitoringFrequency `json:"monitoring_frequency"`
+	Status                   *enums.PatientStatus       `json:"status"`
+	DischargeDate            *time.Time                 `json:"discharge_date"`
+	DischargeNotes           *string                    `json:"discharge_notes"`
+	EmergencyContactName     *string                    `json:"emergency_contact_name"`
+	EmergencyContactPhone    *string                    `json:"emergency_contact_phone"`
+	EmergencyContactRelation *string                    `json:"emergency_contact_relation"`
+}
+
+type UpdatePatientMedicalRequest struct {
+	DoctorID                 *uuid.UUID                 `json:"doctor_id"`
+	ConditionSummary         *string                    `json:"condition_summary"`
+	Comorbidities            *[]string                  `json:"comorbidities"`
+	CurrentMedications       *models.JSONB              `json:"current_medications"`
+	Allergies                *[]string                  `json:"allergies"`
+	BaselineVitals           *models.BaselineVitals     `json:"baseline_vitals"`
+	RiskLevel                *enums.RiskLevel           `json:"risk_level"`
+	MonitoringFrequency      *enums.MonitoringFrequency `json:"monitoring_frequency"`
+	Status                   *enums.PatientStatus       `json:"status"`
+	DischargeDate            *time.Time                 `json:"discharge_date"`
+	DischargeNotes           *string                    `json:"discharge_notes"`
+	EmergencyContactName     *string                    `json:"emergency_contact_name"`
+	EmergencyContactPhone    *string                    `json:"emergency_contact_phone"`
+	EmergencyContactRelation *string                    `json:"emergency_contact_relation"`
+}
+
+func stringsOrEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type QuestionnaireTemplate struct {
+	ID          uuid.UUID                 `json:"id"`
+	Key         string                    `json:"key"`
+	Version     int                       `json:"version"`
+	Name        string                    `json:"name"`
+	Description *string                   `json:"description"`
+	Questions   []models.TemplateQuestion `json:"questions"`
+	IsActive    bool                      `json:"is_active"`
+	CreatedBy   *uuid.UUID                `json:"created_by"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+}
+
+func NewQuestionnaireTemplate(t *models.QuestionnaireTemplate) QuestionnaireTemplate {
+	questions, _ := t.ParsedQuestions()
+	if questions == nil {
+		questions = []models.TemplateQuestion{}
+	}
+	return QuestionnaireTemplate{
+		ID:          t.ID,
+		Key:         t.Key,
+		Version:     t.Version,
+		Name:        t.Name,
+		Description: t.Description,
+		Questions:   questions,
+		IsActive:    t.IsActive,
+		CreatedBy:   t.CreatedBy,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// CreateQuestionnaireTemplateRequest publishes a template; reusing the key of
+// an existing one publishes its next version.
+type CreateQuestionnaireTemplateRequest struct {
+	Key         string                    `json:"key" binding:"required,max=100"`
+	Name        string                    `json:"name" binding:"required,max=255"`
+	Description *string                   `json:"description"`
+	Questions   []TemplateQuestionRequest `json:"questions" binding:"required,min=1,dive"`
+	CreatedBy   *uuid.UUID                `json:"created_by"`
+}
+
+type UpdateQuestionnaireTemplateRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=255"`
+	Description *string `json:"description"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+type TemplateQuestionRequest struct {
+	Key       string                  `json:"key" binding:"required,max=50"`
+	Type      enums.QuestionType      `json:"type" binding:"required"`
+	Text      string                  `json:"text" binding:"required,max=1000"`
+	Required  bool                    `json:"required"`
+	VitalType *enums.VitalType        `json:"vital_type"`
+	Unit      *enums.VitalUnit        `json:"unit"`
+	Min       *float64                `json:"min"`
+	Max       *float64                `json:"max"`
+	Choices   []QuestionChoiceRequest `json:"choices" binding:"omitempty,dive"`
+	Multiple  bool                    `json:"multiple"`
+}
+
+type QuestionChoiceRequest struct {
+	Value string `json:"value" binding:"required,max=100"`
+	Label string `json:"label" binding:"required,max=255"`
+}
+
+func TemplateQuestions(in []TemplateQuestionRequest) []models.TemplateQuestion {
+	out := make([]models.TemplateQuestion, len(in))
+	for i, q := range in {
+		out[i] = models.TemplateQuestion{
+			Key:       q.Key,
+			Type:      q.Type,
+			Text:      q.Text,
+			Required:  q.Required,
+			VitalType: q.VitalType,
+			Unit:      q.Unit,
+			Min:       q.Min,
+			Max:       q.Max,
+			Choices:   questionChoices(q.Choices),
+			Multiple:  q.Multiple,
+		}
+	}
+	return out
+}
+
+func questionChoices(in []QuestionChoiceRequest) []models.QuestionChoice {
+	var out []models.QuestionChoice
+	for _, c := range in {
+		out = append(out, models.QuestionChoice{Value: c.Value, Label: c.Label})
+	}
+	return out
+}
+
+type QuestionnaireAssignment struct {
+	ID          uuid.UUID  `json:"id"`
+	TemplateKey string     `json:"template_key"`
+	PatientID   *uuid.UUID `json:"patient_id"`
+	Condition   *string    `json:"condition"`
+	Priority    int        `json:"priority"`
+	CreatedBy   *uuid.UUID `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func NewQuestionnaireAssignment(a *models.QuestionnaireAssignment) QuestionnaireAssignment {
+	return QuestionnaireAssignment{
+		ID:          a.ID,
+		TemplateKey: a.TemplateKey,
+		PatientID:   a.PatientID,
+		Condition:   a.Condition,
+		Priority:    a.Priority,
+		CreatedBy:   a.CreatedBy,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// CreateQuestionnaireAssignmentRequest assigns a template to a patient (by
+// patient user id) or to a condition; exactly one of the two must be set.
+type CreateQuestionnaireAssignmentRequest struct {
+	TemplateKey string     `json:"template_key" binding:"required,max=100"`
+	PatientID   *uuid.UUID `json:"patient_id"`
+	Condition   *string    `json:"condition" binding:"omitempty,max=255"`
+	Priority    int        `json:"priority"`
+	CreatedBy   *uuid.UUID `json:"created_by"`
+}
@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+)
+
+// ReviewQueue is a page of a doctor's review queue; Total and Overdue count
+// all of it.
+type ReviewQueue struct {
+	Items   []ReviewQueueItem `json:"data"`
+	Meta    pagination.Meta   `json:"pagination"`
+	Total   int64             `json:"total"`
+	Overdue int64             `json:"overdue"`
+}
+
+type ReviewQueueItem struct {
+	CheckinID     uuid.UUID `json:"checkin_id"`
+	PatientID     uuid.UUID `json:"patient_id"`
+	PatientUserID uuid.UUID `json:"patient_user_id"`
+	PatientName   string    `json:"patient_name"`
+
+	MedicalStatus    *enums.MedicalStatus    `json:"medical_status"`
+	RiskScore        *int                    `json:"risk_score"`
+	EarlyWarningRisk *enums.EarlyWarningRisk `json:"early_warning_risk"`
+	CompletedAt      *time.Time              `json:"completed_at"`
+	AnalyzedAt       *time.Time              `json:"analyzed_at"`
+
+	DueAt   time.Time `json:"due_at"`
+	Overdue bool      `json:"overdue"`
+
+	ClaimedBy      *uuid.UUID `json:"claimed_by"`
+	ClaimedByName  *string    `json:"claimed_by_name"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at"`
+
+	Version int `json:"version"`
+}
+
+func NewReviewQueue(q *services.ReviewQueue) ReviewQueue {
+	items := make([]ReviewQueueItem, len(q.Items))
+	for i, item := range q.Items {
+		c := item.Checkin
+		items[i] = ReviewQueueItem{
+			CheckinID:        c.ID,
+			PatientID:        c.PatientID,
+			MedicalStatus:    c.MedicalStatus,
+			RiskScore:        c.RiskScore,
+			EarlyWarningRisk: c.EarlyWarningRisk,
+			CompletedAt:      c.CompletedAt,
+			AnalyzedAt:       c.AnalyzedAt,
+			DueAt:            item.DueAt,
+			Overdue:          item.Overdue,
+			Version:          c.Version,
+		}
+		if c.Patient != nil {
+			items[i].PatientUserID = c.Patient.UserID
+			if c.Patient.User != nil {
+				items[i].PatientName = c.Patient.User.FirstName + " " + c.Patient.User.LastName
+			}
+		}
+		// a lapsed claim is as good as none
+		if item.ClaimExpiresAt != nil {
+			items[i].ClaimedBy = c.ClaimedBy
+			items[i].ClaimExpiresAt = item.ClaimExpiresAt
+			if c.Claimer != nil {
+				name := c.Claimer.FirstName + " " + c.Claimer.LastName
+				items[i].ClaimedByName = &name
+			}
+		}
+	}
+	return ReviewQueue{Items: items, Meta: q.Meta, Total: q.Total, Overdue: q.Overdue}
+}
+
+type ClaimCheckinRequest struct {
+	DoctorID uuid.UUID `json:"doctor_id" binding:"required"`
+}
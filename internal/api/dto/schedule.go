@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/constants"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type CheckinSchedule struct {
+	ID            uuid.UUID               `json:"id"`
+	PatientID     uuid.UUID               `json:"patient_id"`
+	Frequency     enums.ScheduleFrequency `json:"frequency"`
+	TimeSlots     []string                `json:"time_slots"`
+	Timezone      string                  `json:"timezone"`
+	IsActive      bool                    `json:"is_active"`
+	NextCheckinAt *time.Time              `json:"next_checkin_at"`
+	Version       int                     `json:"version"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
+
+func NewCheckinSchedule(s *models.CheckinSchedule) CheckinSchedule {
+	slots := make([]string, 0, len(s.TimeSlots))
+	for _, slot := range s.TimeSlots {
+		slots = append(slots, slot.Format(constants.TimeFormat))
+	}
+	return CheckinSchedule{
+		ID:            s.ID,
+		PatientID:     s.PatientID,
+		Frequency:     s.Frequency,
+		TimeSlots:     slots,
+		Timezone:      s.Timezone,
+		IsActive:      s.IsActive,
+		NextCheckinAt: s.NextCheckinAt,
+		Version:       s.Version,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+}
+
+type CreateScheduleRequest struct {
+	PatientID     uuid.UUID               `json:"patient_id" binding:"required"`
+	Frequency     enums.ScheduleFrequency `json:"frequency" binding:"required"`
+	TimeSlots     []string                `json:"time_slots" binding:"required"`
+	Timezone      *string                 `json:"timezone"`
+	IsActive      *bool                   `json:"is_active"`
+	NextCheckinAt *time.Time              `json:"next_checkin_at"`
+}
+
+type UpdateScheduleRequest struct {
+	Frequency     *enums.ScheduleFrequency `json:"frequency"`
+	TimeSlots     *[]string                `json:"time_slots"`
+	Timezone      *string                  `json:"timezone"`
+	IsActive      *bool                    `json:"is_active"`
+	NextCheckinAt *time.Time               `json:"next_checkin_at"`
+}
@@ -0,0 +1,60 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+)
+
+// SearchResult is a page of hits; Total counts every match.
+type SearchResult struct {
+	Hits  []SearchHit     `json:"data"`
+	Meta  pagination.Meta `json:"pagination"`
+	Total int64           `json:"total"`
+}
+
+type SearchHit struct {
+	Type          services.SearchKind  `json:"type"`
+	ID            uuid.UUID            `json:"id"`
+	CheckinID     *uuid.UUID           `json:"checkin_id"`
+	PatientID     uuid.UUID            `json:"patient_id"`
+	PatientUserID uuid.UUID            `json:"patient_user_id"`
+	PatientName   string               `json:"patient_name"`
+	MedicalStatus *enums.MedicalStatus `json:"medical_status"`
+	OccurredAt    time.Time            `json:"occurred_at"`
+	Rank          float64              `json:"rank"`
+	Snippets      []SearchSnippet      `json:"snippets"`
+}
+
+// SearchSnippet is an HTML-escaped excerpt of a matching field with the
+// matched words in <mark> tags.
+type SearchSnippet struct {
+	Field string `json:"field"`
+	Text  string `json:"text"`
+}
+
+func NewSearchResult(r *services.SearchResult) SearchResult {
+	hits := make([]SearchHit, len(r.Hits))
+	for i, h := range r.Hits {
+		snippets := make([]SearchSnippet, len(h.Snippets))
+		for j, s := range h.Snippets {
+			snippets[j] = SearchSnippet{Field: s.Field, Text: s.Text}
+		}
+		hits[i] = SearchHit{
+			Type:          h.Kind,
+			ID:            h.ID,
+			CheckinID:     h.CheckinID,
+			PatientID:     h.PatientID,
+			PatientUserID: h.PatientUserID,
+			PatientName:   h.PatientName,
+			MedicalStatus: h.MedicalStatus,
+			OccurredAt:    h.OccurredAt,
+			Rank:          h.Rank,
+			Snippets:      snippets,
+		}
+	}
+	return SearchResult{Hits: hits, Meta: r.Meta, Total: r.Total}
+}
@@ -0,0 +1,78 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type ThresholdRule struct {
+	ID             uuid.UUID              `json:"id"`
+	OrganizationID *uuid.UUID             `json:"organization_id"`
+	PatientID      *uuid.UUID             `json:"patient_id"`
+	Key            string                 `json:"key"`
+	Name           string                 `json:"name"`
+	Severity       enums.AlertSeverity    `json:"severity"`
+	Conditions     []models.RuleCondition `json:"conditions"`
+	IsActive       bool                   `json:"is_active"`
+	CreatedBy      *uuid.UUID             `json:"created_by"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+func NewThresholdRule(r *models.ThresholdRule) ThresholdRule {
+	conditions, _ := r.ParsedConditions()
+	if conditions == nil {
+		conditions = []models.RuleCondition{}
+	}
+	return ThresholdRule{
+		ID:             r.ID,
+		OrganizationID: r.OrganizationID,
+		PatientID:      r.PatientID,
+		Key:            r.Key,
+		Name:           r.Name,
+		Severity:       r.Severity,
+		Conditions:     conditions,
+		IsActive:       r.IsActive,
+		CreatedBy:      r.CreatedBy,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+	}
+}
+
+// CreateThresholdRuleRequest scopes a rule to an organization or to a patient
+// (by patient user id); exactly one of the two must be set.
+type CreateThresholdRuleRequest struct {
+	OrganizationID *uuid.UUID                      `json:"organization_id"`
+	PatientID      *uuid.UUID                      `json:"patient_id"`
+	Key            string                          `json:"key" binding:"required,max=100"`
+	Name           string                          `json:"name" binding:"required,max=255"`
+	Severity       enums.AlertSeverity             `json:"severity" binding:"required"`
+	Conditions     []ThresholdRuleConditionRequest `json:"conditions" binding:"required,min=1,dive"`
+	IsActive       *bool                           `json:"is_active"`
+	CreatedBy      *uuid.UUID                      `json:"created_by"`
+}
+
+type UpdateThresholdRuleRequest struct {
+	Key        *string                          `json:"key" binding:"omitempty,max=100"`
+	Name       *string                          `json:"name" binding:"omitempty,max=255"`
+	Severity   *enums.AlertSeverity             `json:"severity"`
+	Conditions *[]ThresholdRuleConditionRequest `json:"conditions" binding:"omitempty,min=1,dive"`
+	IsActive   *bool                            `json:"is_active"`
+}
+
+type ThresholdRuleConditionRequest struct {
+	Metric   enums.RuleMetric   `json:"metric" binding:"required"`
+	Operator enums.RuleOperator `json:"operator" binding:"required"`
+	Value    *float64           `json:"value" binding:"required"`
+}
+
+func RuleConditions(in []ThresholdRuleConditionRequest) []models.RuleCondition {
+	out := make([]models.RuleCondition, len(in))
+	for i, c := range in {
+		out[i] = models.RuleCondition{Metric: c.Metric, Operator: c.Operator, Value: *c.Value}
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID               uuid.UUID      `json:"id"`
+	PhoneNumber      string         `json:"phone_number"`
+	FirstName        string         `json:"first_name"`
+	LastName         string         `json:"last_name"`
+	Role             enums.UserRole `json:"role"`
+	Gender           *enums.Gender  `json:"gender"`
+	IsActive         bool           `json:"is_active"`
+	TelegramUsername string         `json:"telegram_username"`
+	LastLoginAt      *time.Time     `json:"last_login_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+func NewUser(u *models.User) User {
+	return User{
+		ID:               u.ID,
+		PhoneNumber:      u.PhoneNumber,
+		FirstName:        u.FirstName,
+		LastName:         u.LastName,
+		Role:             u.Role,
+		Gender:           u.Gender,
+		IsActive:         u.IsActive,
+		TelegramUsername: u.TelegramUsername,
+		LastLoginAt:      u.LastLoginAt,
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
+	}
+}
+
+func newUserRef(u *models.User) *User {
+	if u == nil {
+		return nil
+	}
+	out := NewUser(u)
+	return &out
+}
+
+type OrganizationDoctor struct {
+	ID             uuid.UUID     `json:"id"`
+	DoctorID       uuid.UUID     `json:"doctor_id"`
+	OrganizationID uuid.UUID     `json:"organization_id"`
+	JoinedAt       time.Time     `json:"joined_at"`
+	LeftAt         *time.Time    `json:"left_at"`
+	IsActive       bool          `json:"is_active"`
+	Organization   *Organization `json:"organization"`
+}
+
+func NewOrganizationDoctor(od *models.OrganizationDoctor) OrganizationDoctor {
+	out := OrganizationDoctor{
+		ID:             od.ID,
+		DoctorID:       od.DoctorID,
+		OrganizationID: od.OrganizationID,
+		JoinedAt:       od.JoinedAt,
+		LeftAt:         od.LeftAt,
+		IsActive:       od.IsActive,
+	}
+	if od.Organization != nil {
+		org := NewOrganization(od.Organization)
+		out.Organization = &org
+	}
+	return out
+}
+
+type CreateDoctorRequest struct {
+	PhoneNumber      string        `json:"phone_number" binding:"required"`
+	Password         string        `json:"password" binding:"required"`
+	FirstName        string        `json:"first_name" binding:"required"`
+	LastName         string        `json:"last_name" binding:"required"`
+	Gender           *enums.Gender `json:"gender"`
+	IsActive         *bool         `json:"is_active"`
+	TelegramUsername string        `json:"telegram_username" binding:"required"`
+	OrganizationID   uuid.UUID     `json:"organization_id" binding:"required"`
+}
+
+type UpdateDoctorRequest struct {
+	PhoneNumber      *string       `json:"phone_number"`
+	FirstName        *string       `json:"first_name"`
+	LastName         *string       `json:"last_name"`
+	Gender           *enums.Gender `json:"gender"`
+	IsActive         *bool         `json:"is_active"`
+	Password         *string       `json:"password"`
+	TelegramUsername *string       `json:"telegram_username"`
+}
+
+type CreatePatientRequest struct {
+	PhoneNumber      string        `json:"phone_number" binding:"required"`
+	Password         string        `json:"password" binding:"required"`
+	FirstName        string        `json:"first_name" binding:"required"`
+	LastName         string        `json:"last_name" binding:"required"`
+	Gender           *enums.Gender `json:"gender"`
+	IsActive         *bool         `json:"is_active"`
+	TelegramUsername string        `json:"telegram_username" binding:"required"`
+}
+
+type UpdatePatientRequest struct {
+	Email            *string       `json:"email"`
+	PhoneNumber      *string       `json:"phone_number"`
+	FirstName        *string       `json:"first_name"`
+	LastName         *string       `json:"last_name"`
+	Gender           *enums.Gender `json:"gender"`
+	IsActive         *bool         `json:"is_active"`
+	Password         *string       `json:"password"`
+	TelegramUsername *string       `json:"telegram_username"`
+}
@@ -0,0 +1,142 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+)
+
+type VitalReading struct {
+	ID        uuid.UUID  `json:"id"`
+	CheckinID *uuid.UUID `json:"checkin_id"`
+	PatientID uuid.UUID  `json:"patient_id"`
+
+	VitalType    enums.VitalType  `json:"vital_type"`
+	ValueNumeric *float64         `json:"value_numeric"`
+	ValueText    *string          `json:"value_text"`
+	Unit         *enums.VitalUnit `json:"unit"`
+
+	OriginalValue *float64         `json:"original_value"`
+	OriginalUnit  *enums.VitalUnit `json:"original_unit"`
+	Context       *string          `json:"context"`
+
+	Systolic             *float64 `json:"systolic"`
+	Diastolic            *float64 `json:"diastolic"`
+	MeanArterialPressure *float64 `json:"mean_arterial_pressure"`
+
+	IsAbnormal            bool     `json:"is_abnormal"`
+	DeviationFromBaseline *float64 `json:"deviation_from_baseline"`
+
+	Source     enums.VitalSource `json:"source"`
+	Device     *string           `json:"device"`
+	MeasuredAt time.Time         `json:"measured_at"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func NewVitalReading(v *models.VitalReading) VitalReading {
+	return VitalReading{
+		ID:                    v.ID,
+		CheckinID:             v.CheckinID,
+		PatientID:             v.PatientID,
+		VitalType:             v.VitalType,
+		ValueNumeric:          v.ValueNumeric,
+		ValueText:             v.ValueText,
+		Unit:                  v.Unit,
+		OriginalValue:         v.OriginalValue,
+		OriginalUnit:          v.OriginalUnit,
+		Context:               v.Context,
+		Systolic:              v.Systolic,
+		Diastolic:             v.Diastolic,
+		MeanArterialPressure:  v.MeanArterialPressure,
+		IsAbnormal:            v.IsAbnormal,
+		DeviationFromBaseline: v.DeviationFromBaseline,
+		Source:                v.Source,
+		Device:                v.Device,
+		MeasuredAt:            v.MeasuredAt,
+		CreatedAt:             v.CreatedAt,
+	}
+}
+
+type CreateVitalReadingRequest struct {
+	CheckinID             *uuid.UUID         `json:"checkin_id"`
+	PatientID             uuid.UUID          `json:"patient_id" binding:"required"`
+	VitalType             enums.VitalType    `json:"vital_type" binding:"required"`
+	Unit                  *enums.VitalUnit   `json:"unit"`
+	ValueNumeric          *float64           `json:"value_numeric"`
+	ValueText             *string            `json:"value_text"`
+	Systolic              *float64           `json:"systolic"`
+	Diastolic             *float64           `json:"diastolic"`
+	Context               *string            `json:"context" binding:"omitempty,max=30"`
+	MeasuredAt            *time.Time         `json:"measured_at"`
+	Source                *enums.VitalSource `json:"source"`
+	Device                *string            `json:"device" binding:"omitempty,max=100"`
+	IsAbnormal            *bool              `json:"is_abnormal"`
+	DeviationFromBaseline *float64           `json:"deviation_from_baseline"`
+}
+
+type UpdateVitalReadingRequest struct {
+	VitalType             *enums.VitalType `json:"vital_type"`
+	Unit                  *enums.VitalUnit `json:"unit"`
+	ValueNumeric          *float64         `json:"value_numeric"`
+	ValueText             *string          `json:"value_text"`
+	Systolic              *float64         `json:"systolic"`
+	Diastolic             *float64         `json:"diastolic"`
+	Context               *string          `json:"context" binding:"omitempty,max=30"`
+	IsAbnormal            *bool            `json:"is_abnormal"`
+	DeviationFromBaseline *float64         `json:"deviation_from_baseline"`
+}
+
+// VitalReadingBatchRow is one reading of a batch upload. Rows are validated
+// one by one, so fields carry no binding rules; CSV uploads use the same names
+// as columns, plus "value" for either a number or a text such as "140/90".
+type VitalReadingBatchRow struct {
+	CheckinID    *uuid.UUID         `json:"checkin_id"`
+	VitalType    enums.VitalType    `json:"vital_type"`
+	Unit         *enums.VitalUnit   `json:"unit"`
+	ValueNumeric *float64           `json:"value_numeric"`
+	ValueText    *string            `json:"value_text"`
+	Systolic     *float64           `json:"systolic"`
+	Diastolic    *float64           `json:"diastolic"`
+	Context      *string            `json:"context"`
+	MeasuredAt   *time.Time         `json:"measured_at"`
+	Source       *enums.VitalSource `json:"source"`
+	Device       *string            `json:"device"`
+}
+
+type VitalReadingBatchRequest struct {
+	Readings []VitalReadingBatchRow `json:"readings" binding:"required"`
+}
+
+type VitalReadingBatchRowResult struct {
+	// Row is the index in readings for JSON, or the line number for CSV and NDJSON.
+	Row            int                     `json:"row"`
+	Status         services.BatchRowStatus `json:"status"`
+	VitalReadingID *uuid.UUID              `json:"vital_reading_id"`
+	IsAbnormal     bool                    `json:"is_abnormal"`
+	Errors         []errs.FieldError       `json:"errors,omitempty"`
+}
+
+type VitalReadingBatchResult struct {
+	Created    int                          `json:"created"`
+	Duplicates int                          `json:"duplicates"`
+	Invalid    int                          `json:"invalid"`
+	Rows       []VitalReadingBatchRowResult `json:"rows"`
+}
+
+func NewVitalReadingBatchResult(r *services.VitalReadingBatchResult) VitalReadingBatchResult {
+	rows := make([]VitalReadingBatchRowResult, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = VitalReadingBatchRowResult{
+			Row:            row.Row,
+			Status:         row.Status,
+			VitalReadingID: row.VitalReadingID,
+			IsAbnormal:     row.IsAbnormal,
+			Errors:         row.Errors,
+		}
+	}
+	return VitalReadingBatchResult{Created: r.Created, Duplicates: r.Duplicates, Invalid: r.Invalid, Rows: rows}
+}
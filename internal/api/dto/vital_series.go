@@ -0,0 +1,78 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/google/uuid"
+)
+
+type VitalSeries struct {
+	PatientID uuid.UUID         `json:"patient_id"`
+	Timezone  string            `json:"timezone"`
+	Bucket    string            `json:"bucket"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Series    []VitalTypeSeries `json:"series"`
+}
+
+type VitalTypeSeries struct {
+	VitalType   enums.VitalType `json:"vital_type"`
+	DisplayName string          `json:"display_name"`
+	Unit        enums.VitalUnit `json:"unit"`
+	Points      []SeriesPoint   `json:"points"`
+	Trend       VitalTrend      `json:"trend"`
+}
+
+type SeriesStats struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Avg  float64 `json:"avg"`
+	Last float64 `json:"last"`
+}
+
+type SeriesPoint struct {
+	BucketStart time.Time `json:"bucket_start"`
+	Count       int       `json:"count"`
+	SeriesStats
+	Diastolic *SeriesStats `json:"diastolic,omitempty"`
+}
+
+// VitalTrend slopes are in the series unit per day.
+type VitalTrend struct {
+	Slope7Days                *float64 `json:"slope_7d"`
+	Slope30Days               *float64 `json:"slope_30d"`
+	Latest                    *float64 `json:"latest"`
+	Baseline                  *float64 `json:"baseline"`
+	ChangeFromBaselinePercent *float64 `json:"change_from_baseline_percent"`
+}
+
+func NewVitalSeries(s *services.VitalSeries) VitalSeries {
+	out := VitalSeries{
+		PatientID: s.PatientID,
+		Timezone:  s.Timezone,
+		Bucket:    string(s.Bucket),
+		From:      s.From,
+		To:        s.To,
+		Series:    make([]VitalTypeSeries, len(s.Series)),
+	}
+	for i, ts := range s.Series {
+		points := make([]SeriesPoint, len(ts.Points))
+		for j, p := range ts.Points {
+			points[j] = SeriesPoint{BucketStart: p.BucketStart, Count: p.Count, SeriesStats: SeriesStats(p.SeriesStats)}
+			if p.Diastolic != nil {
+				d := SeriesStats(*p.Diastolic)
+				points[j].Diastolic = &d
+			}
+		}
+		out.Series[i] = VitalTypeSeries{
+			VitalType:   ts.VitalType,
+			DisplayName: ts.DisplayName,
+			Unit:        ts.Unit,
+			Points:      points,
+			Trend:       VitalTrend(ts.Trend),
+		}
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+)
+
+type VitalType struct {
+	Code          enums.VitalType             `json:"code"`
+	DisplayName   string                      `json:"display_name"`
+	Description   *string                     `json:"description"`
+	CanonicalUnit enums.VitalUnit             `json:"canonical_unit"`
+	Units         models.VitalUnitConversions `json:"units"`
+	Contexts      []string                    `json:"contexts"`
+
+	MinValue *float64 `json:"min_value"`
+	MaxValue *float64 `json:"max_value"`
+
+	Low                 *float64 `json:"low"`
+	High                *float64 `json:"high"`
+	CriticalLow         *float64 `json:"critical_low"`
+	CriticalHigh        *float64 `json:"critical_high"`
+	MaxDeviationPercent *float64 `json:"max_deviation_percent"`
+
+	IsBuiltin bool      `json:"is_builtin"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewVitalType(d *models.VitalTypeDefinition) VitalType {
+	units, _ := d.Conversions()
+	if units == nil {
+		units = models.VitalUnitConversions{}
+	}
+	return VitalType{
+		Code:                d.Code,
+		DisplayName:         d.DisplayName,
+		Description:         d.Description,
+		CanonicalUnit:       d.CanonicalUnit,
+		Units:               units,
+		Contexts:            stringsOrEmpty(d.Contexts),
+		MinValue:            d.MinValue,
+		MaxValue:            d.MaxValue,
+		Low:                 d.Low,
+		High:                d.High,
+		CriticalLow:         d.CriticalLow,
+		CriticalHigh:        d.CriticalHigh,
+		MaxDeviationPercent: d.MaxDeviationPercent,
+		IsBuiltin:           d.IsBuiltin,
+		IsActive:            d.IsActive,
+		CreatedAt:           d.CreatedAt,
+		UpdatedAt:           d.UpdatedAt,
+	}
+}
+
+// VitalTypeRange is a reference range in the canonical unit; omitted bounds
+// are not checked.
+type VitalTypeRange struct {
+	Low                 *float64 `json:"low"`
+	High                *float64 `json:"high"`
+	CriticalLow         *float64 `json:"critical_low"`
+	CriticalHigh        *float64 `json:"critical_high"`
+	MaxDeviationPercent *float64 `json:"max_deviation_percent"`
+}
+
+// CreateVitalTypeRequest adds a clinic-defined vital type. Units lists the
+// accepted units other than the canonical one, as value = canonical*factor + offset.
+type CreateVitalTypeRequest struct {
+	Code          enums.VitalType             `json:"code" binding:"required,max=50"`
+	DisplayName   string                      `json:"display_name" binding:"required,max=100"`
+	Description   *string                     `json:"description"`
+	CanonicalUnit enums.VitalUnit             `json:"canonical_unit" binding:"required,max=20"`
+	Units         models.VitalUnitConversions `json:"units"`
+	Contexts      []string                    `json:"contexts"`
+	MinValue      *float64                    `json:"min_value"`
+	MaxValue      *float64                    `json:"max_value"`
+	Range         VitalTypeRange              `json:"range"`
+	IsActive      *bool                       `json:"is_active"`
+}
+
+// UpdateVitalTypeRequest changes a catalog entry; a given range replaces the
+// whole reference range.
+type UpdateVitalTypeRequest struct {
+	DisplayName *string                      `json:"display_name" binding:"omitempty,max=100"`
+	Description *string                      `json:"description"`
+	Units       *models.VitalUnitConversions `json:"units"`
+	Contexts    *[]string                    `json:"contexts"`
+	MinValue    *float64                     `json:"min_value"`
+	MaxValue    *float64                     `json:"max_value"`
+	Range       *VitalTypeRange              `json:"range"`
+	IsActive    *bool                        `json:"is_active"`
+}
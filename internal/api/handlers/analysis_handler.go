@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type AnalysisHandler struct {
+	analysisService *services.AnalysisService
+}
+
+func NewAnalysisHandler(analysisService *services.AnalysisService) *AnalysisHandler {
+	return &AnalysisHandler{analysisService: analysisService}
+}
+
+// Analyze runs the configured analyzer on a checkin now, instead of waiting
+// for the background worker, e.g. to redo an analysis before review.
+func (h *AnalysisHandler) Analyze(c *gin.Context) {
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	checkin, err := h.analysisService.Analyze(c.Request.Context(), checkinID)
+	if err != nil {
+		handleError(c, err, errs.ErrCheckinNotFound)
+		return
+	}
+
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
+}
@@ -1,16 +1,14 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
 
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
-	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
 	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type CheckinHandler struct {
@@ -22,120 +20,88 @@ func NewCheckinHandler(checkinService *services.CheckinService) *CheckinHandler
 }
 
 func (h *CheckinHandler) StartCheckin(c *gin.Context) {
-	var body struct {
-		PatientID  uuid.UUID  `json:"patient_id" binding:"required"`
-		ScheduleID *uuid.UUID `json:"schedule_id"`
-	}
+	var body dto.StartCheckinRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	checkin, err := h.checkinService.StartCheckin(body.PatientID, body.ScheduleID)
+	checkin, err := h.checkinService.StartCheckin(body.PatientID, body.ScheduleID, services.APICheckinActor(nil, nil))
 	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrActiveCheckinExists):
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start checkin: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusCreated, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusCreated, dto.NewCheckin(checkin))
 }
 
 func (h *CheckinHandler) EndCheckin(c *gin.Context) {
-	patientUserID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	patientUserID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	checkin, err := h.checkinService.EndCheckin(patientUserID)
+	checkin, err := h.checkinService.EndCheckin(patientUserID, services.APICheckinActor(nil, nil))
 	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrCheckinNotActive):
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "checkin not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end checkin: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
 }
 
 func (h *CheckinHandler) GetActiveCheckin(c *gin.Context) {
-	patientID, err := uuid.Parse(c.Param("patientId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	patientID, ok := uuidParam(c, "patientId")
+	if !ok {
 		return
 	}
 
 	checkin, err := h.checkinService.GetActiveCheckin(patientID)
 	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrNoActiveCheckin):
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch active checkin: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
 }
 
 func (h *CheckinHandler) ListCompletedCheckins(c *gin.Context) {
-	patientID, err := uuid.Parse(c.Param("patientId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	patientID, ok := uuidParam(c, "patientId")
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
 		return
 	}
 
-	checkins, err := h.checkinService.ListCompletedByPatient(patientID)
+	checkins, err := h.checkinService.ListCompletedByPatient(patientID, page)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list checkins: " + err.Error()})
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": checkins})
+	c.JSON(http.StatusOK, dto.NewPage(checkins, dto.NewCheckin))
 }
 
 func (h *CheckinHandler) UpdateCheckinAI(c *gin.Context) {
-	checkinID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkin id"})
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		AIAnalysis    *models.JSONB        `json:"ai_analysis"`
-		MedicalStatus *enums.MedicalStatus `json:"medical_status"`
-		RiskScore     *int                 `json:"risk_score"`
-		Alert         *struct {
-			Severity  enums.AlertSeverity `json:"severity"`
-			AlertType enums.AlertType     `json:"alert_type"`
-			Title     string              `json:"title"`
-			Message   string              `json:"message"`
-			Details   *models.JSONB       `json:"details"`
-		} `json:"alert"`
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
 	}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body dto.UpdateCheckinAnalysisRequest
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -150,165 +116,194 @@ func (h *CheckinHandler) UpdateCheckinAI(c *gin.Context) {
 		}
 	}
 
-	updated, err := h.checkinService.UpdateAIFields(checkinID, services.CheckinAIUpdate{
+	updated, err := h.checkinService.UpdateAIFields(checkinID, version, services.CheckinAIUpdate{
 		AIAnalysis:    body.AIAnalysis,
 		MedicalStatus: body.MedicalStatus,
 		RiskScore:     body.RiskScore,
 		Alert:         alertInput,
-	})
+	}, services.APICheckinActor(nil, nil))
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "checkin not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update checkin analysis: " + err.Error()})
+		handleError(c, err, errs.ErrCheckinNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(updated))
 }
 
 func (h *CheckinHandler) ReviewCheckin(c *gin.Context) {
-	checkinID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkin id"})
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		DoctorID    uuid.UUID `json:"doctor_id" binding:"required"`
-		DoctorNotes *string   `json:"doctor_notes"`
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
 	}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body dto.ReviewCheckinRequest
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	updated, err := h.checkinService.ReviewCheckin(checkinID, body.DoctorID, body.DoctorNotes)
+	updated, err := h.checkinService.ReviewCheckin(checkinID, version, body.DoctorID, body.DoctorNotes)
 	if err != nil {
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "doctor or checkin not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review checkin: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrNotFound.WithMessage("doctor or checkin not found"))
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(updated))
 }
 
 func (h *CheckinHandler) AddQuestions(c *gin.Context) {
-	checkinID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkin id"})
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		Items []interface{} `json:"items" binding:"required"`
-	}
+	var body dto.AddCheckinQuestionsRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	if len(body.Items) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "items cannot be empty"})
-		return
+	questions := make([]services.CheckinQuestionInput, len(body.Items))
+	for i, item := range body.Items {
+		questions[i] = services.CheckinQuestionInput{
+			Seq:           item.Seq,
+			Question:      item.TemplateQuestion(),
+			Locale:        item.Locale,
+			AskedAt:       item.AskedAt,
+			SourceMessage: item.SourceMessage,
+		}
 	}
 
-	checkin, err := h.checkinService.AddQuestions(checkinID, body.Items)
+	checkin, err := h.checkinService.AddQuestions(checkinID, questions)
 	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrCheckinNotActive):
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "checkin not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update questions: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrCheckinNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
 }
 
 func (h *CheckinHandler) AddAnswers(c *gin.Context) {
-	checkinID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkin id"})
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		Items []interface{} `json:"items" binding:"required"`
-	}
+	var body dto.AddCheckinAnswersRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	if len(body.Items) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "items cannot be empty"})
-		return
+	answers := make([]services.CheckinAnswerInput, len(body.Items))
+	for i, item := range body.Items {
+		answers[i] = services.CheckinAnswerInput{
+			QuestionID:    item.QuestionID,
+			Seq:           item.Seq,
+			Value:         item.Value,
+			Text:          item.Text,
+			Locale:        item.Locale,
+			AnsweredAt:    item.AnsweredAt,
+			SourceMessage: item.SourceMessage,
+		}
 	}
 
-	checkin, err := h.checkinService.AddAnswers(checkinID, body.Items)
+	checkin, err := h.checkinService.AddAnswers(checkinID, answers, services.APICheckinActor(nil, nil))
 	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrCheckinNotActive):
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "checkin not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update answers: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrCheckinNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
 }
 
 func (h *CheckinHandler) GetCheckin(c *gin.Context) {
-	checkinID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkin id"})
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	checkin, err := h.checkinService.GetByID(checkinID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "checkin not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch checkin: " + err.Error()})
+		handleError(c, err, errs.ErrCheckinNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
 }
 
 func (h *CheckinHandler) ManualCheckin(c *gin.Context) {
-	patientID, err := uuid.Parse(c.Param("patientId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	patientID, ok := uuidParam(c, "patientId")
+	if !ok {
 		return
 	}
 
 	checkingType := c.Query("type")
-	checkin, err := h.checkinService.StartManualCheckin(patientID, checkingType)
-	if errors.Is(err, errs.ErrActiveCheckinExists) {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-	} else if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start manual checkin: " + err.Error()})
+	checkin, err := h.checkinService.StartManualCheckin(patientID, checkingType, services.APICheckinActor(nil, nil))
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusCreated, dto.NewCheckin(checkin))
+}
+
+func (h *CheckinHandler) CancelCheckin(c *gin.Context) {
+	h.transition(c, h.checkinService.Cancel)
+}
+
+func (h *CheckinHandler) FailCheckin(c *gin.Context) {
+	h.transition(c, h.checkinService.Fail)
+}
+
+func (h *CheckinHandler) transition(c *gin.Context, move func(uuid.UUID, services.CheckinActor) (*models.Checkin, error)) {
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var body dto.CheckinTransitionRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	checkin, err := move(checkinID, services.APICheckinActor(body.UserID, body.Reason))
+	if err != nil {
+		handleError(c, err, errs.ErrCheckinNotFound)
+		return
 	}
 
-	c.JSON(http.StatusCreated, checkin)
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
+}
+
+func (h *CheckinHandler) ListTransitions(c *gin.Context) {
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	transitions, err := h.checkinService.Transitions(checkinID)
+	if err != nil {
+		handleError(c, err, errs.ErrCheckinNotFound)
+		return
+	}
+
+	items := make([]dto.CheckinTransition, len(transitions))
+	for i := range transitions {
+		items[i] = dto.NewCheckinTransition(&transitions[i])
+	}
+	c.JSON(http.StatusOK, dto.List[dto.CheckinTransition]{Items: items})
 }
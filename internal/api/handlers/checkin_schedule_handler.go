@@ -1,16 +1,14 @@
 package handlers
 
 import (
-	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
-	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type CheckinScheduleHandler struct {
@@ -24,23 +22,15 @@ func NewCheckinScheduleHandler(service *services.CheckinScheduleService) *Checki
 }
 
 func (h *CheckinScheduleHandler) CreateSchedule(c *gin.Context) {
-	var body struct {
-		PatientID     uuid.UUID               `json:"patient_id" binding:"required"`
-		Frequency     enums.ScheduleFrequency `json:"frequency" binding:"required"`
-		TimeSlots     []string                `json:"time_slots" binding:"required"`
-		Timezone      *string                 `json:"timezone"`
-		IsActive      *bool                   `json:"is_active"`
-		NextCheckinAt *time.Time              `json:"next_checkin_at"`
-	}
+	var body dto.CreateScheduleRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
 	parsedSlots, err := parseTimeSlots(body.TimeSlots)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid time slot: " + err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -53,87 +43,69 @@ func (h *CheckinScheduleHandler) CreateSchedule(c *gin.Context) {
 		NextCheckinAt: body.NextCheckinAt,
 	})
 	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrScheduleExists):
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusCreated, schedule)
+	setETag(c, schedule.Version)
+	c.JSON(http.StatusCreated, dto.NewCheckinSchedule(schedule))
 }
 
 func (h *CheckinScheduleHandler) GetSchedule(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	schedule, err := h.checkinScheduleService.GetByID(id)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch schedule: " + err.Error()})
+		handleError(c, err, errs.ErrScheduleNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, schedule)
+	setETag(c, schedule.Version)
+	c.JSON(http.StatusOK, dto.NewCheckinSchedule(schedule))
 }
 
 func (h *CheckinScheduleHandler) ListSchedules(c *gin.Context) {
-	includeInactive := false
-	if v := c.Query("include_inactive"); v != "" {
-		parsed, err := parseBool(v)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_inactive value"})
-			return
-		}
-		includeInactive = parsed
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
 	}
 
-	var patientID *uuid.UUID
-	if raw := c.Query("patient_id"); raw != "" {
-		id, err := uuid.Parse(raw)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient_id"})
-			return
-		}
-		patientID = &id
+	patientID, ok := uuidQuery(c, "patient_id")
+	if !ok {
+		return
 	}
 
-	schedules, err := h.checkinScheduleService.List(includeInactive, patientID)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	schedules, err := h.checkinScheduleService.List(includeInactive, patientID, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list schedules: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": schedules})
+	c.JSON(http.StatusOK, dto.NewPage(schedules, dto.NewCheckinSchedule))
 }
 
 func (h *CheckinScheduleHandler) UpdateSchedule(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		Frequency     *enums.ScheduleFrequency `json:"frequency"`
-		TimeSlots     *[]string                `json:"time_slots"`
-		Timezone      *string                  `json:"timezone"`
-		IsActive      *bool                    `json:"is_active"`
-		NextCheckinAt *time.Time               `json:"next_checkin_at"`
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
 	}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body dto.UpdateScheduleRequest
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -141,13 +113,13 @@ func (h *CheckinScheduleHandler) UpdateSchedule(c *gin.Context) {
 	if body.TimeSlots != nil {
 		slots, err := parseTimeSlots(*body.TimeSlots)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid time slot: " + err.Error()})
+			_ = c.Error(err)
 			return
 		}
 		parsedSlots = &slots
 	}
 
-	schedule, err := h.checkinScheduleService.Update(id, services.UpdateScheduleInput{
+	schedule, err := h.checkinScheduleService.Update(id, version, services.UpdateScheduleInput{
 		Frequency:     body.Frequency,
 		TimeSlots:     parsedSlots,
 		Timezone:      body.Timezone,
@@ -155,59 +127,40 @@ func (h *CheckinScheduleHandler) UpdateSchedule(c *gin.Context) {
 		NextCheckinAt: body.NextCheckinAt,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update schedule: " + err.Error()})
+		handleError(c, err, errs.ErrScheduleNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, schedule)
+	setETag(c, schedule.Version)
+	c.JSON(http.StatusOK, dto.NewCheckinSchedule(schedule))
 }
 
 func (h *CheckinScheduleHandler) DeleteSchedule(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	if err := h.checkinScheduleService.Delete(id); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete schedule: " + err.Error()})
+		handleError(c, err, errs.ErrScheduleNotFound)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.Status(http.StatusNoContent)
 }
 
 func parseTimeSlots(slots []string) ([]time.Time, error) {
 	out := make([]time.Time, 0, len(slots))
-	for _, s := range slots {
+	for i, s := range slots {
 		t, err := time.Parse("15:04", s)
 		if err != nil {
 			// also allow HH:MM:SS
 			t, err = time.Parse("15:04:05", s)
 			if err != nil {
-				return nil, err
+				return nil, errs.InvalidField(fmt.Sprintf("time_slots[%d]", i), "must be HH:MM or HH:MM:SS")
 			}
 		}
 		out = append(out, t)
 	}
 	return out, nil
 }
-
-func parseBool(val string) (bool, error) {
-	switch val {
-	case "true", "1", "TRUE", "True":
-		return true, nil
-	case "false", "0", "FALSE", "False":
-		return false, nil
-	default:
-		return false, errors.New("invalid boolean")
-	}
-}
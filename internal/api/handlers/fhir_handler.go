@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FHIRHandler struct {
+	fhirService *services.FHIRService
+}
+
+func NewFHIRHandler(service *services.FHIRService) *FHIRHandler {
+	return &FHIRHandler{fhirService: service}
+}
+
+func (h *FHIRHandler) Metadata(c *gin.Context) {
+	renderFHIR(c, h.fhirService.Capabilities())
+}
+
+func (h *FHIRHandler) Patient(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	patient, err := h.fhirService.Patient(id)
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	renderFHIR(c, patient)
+}
+
+// Everything exports a patient and all of their data as a searchset Bundle.
+// start and end are the operation's optional date range.
+func (h *FHIRHandler) Everything(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+	start, ok := timeQuery(c, "start")
+	if !ok {
+		return
+	}
+	end, ok := timeQuery(c, "end")
+	if !ok {
+		return
+	}
+	if start != nil && end != nil && !start.Before(*end) {
+		_ = c.Error(errs.InvalidField("query.end", "must be after start"))
+		return
+	}
+
+	bundle, err := h.fhirService.Everything(id, start, end, fhirBase(c))
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	renderFHIR(c, bundle)
+}
+
+func (h *FHIRHandler) Observation(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	obs, err := h.fhirService.Observation(id)
+	if err != nil {
+		handleError(c, err, errs.ErrVitalReadingNotFound)
+		return
+	}
+
+	renderFHIR(c, obs)
+}
+
+// SearchObservations lists a patient's observations as a searchset Bundle
+// paged with the usual cursor parameters; the next link carries the cursor.
+// patient is a patient user id, bare or as a "Patient/<id>" reference.
+func (h *FHIRHandler) SearchObservations(c *gin.Context) {
+	patientID, err := uuid.Parse(strings.TrimPrefix(c.Query("patient"), "Patient/"))
+	if err != nil {
+		_ = c.Error(errs.InvalidField("query.patient", "must be a patient id or Patient/<id> reference"))
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	base := fhirBase(c)
+	bundle, meta, err := h.fhirService.SearchObservations(patientID, c.Query("code"), page, base)
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	query := c.Request.URL.Query()
+	bundle.Link = []fhir.BundleLink{{Relation: "self", URL: base + "/Observation?" + query.Encode()}}
+	if meta.NextCursor != nil {
+		query.Set("cursor", *meta.NextCursor)
+		bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: "next", URL: base + "/Observation?" + query.Encode()})
+	}
+
+	renderFHIR(c, bundle)
+}
+
+func (h *FHIRHandler) Flag(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	flag, err := h.fhirService.Flag(id)
+	if err != nil {
+		handleError(c, err, errs.ErrAlertNotFound)
+		return
+	}
+
+	renderFHIR(c, flag)
+}
+
+func (h *FHIRHandler) DetectedIssue(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	issue, err := h.fhirService.DetectedIssue(id)
+	if err != nil {
+		handleError(c, err, errs.ErrAlertNotFound)
+		return
+	}
+
+	renderFHIR(c, issue)
+}
+
+func (h *FHIRHandler) QuestionnaireResponse(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	qr, err := h.fhirService.QuestionnaireResponse(id)
+	if err != nil {
+		handleError(c, err, errs.ErrCheckinNotFound)
+		return
+	}
+
+	renderFHIR(c, qr)
+}
+
+// Import onboards a patient from a FHIR Bundle sent as the body. The query
+// names the follow-up doctor, the patient's Telegram username and optional
+// record settings; dry_run reports what would be created and keeps nothing.
+func (h *FHIRHandler) Import(c *gin.Context) {
+	doctorID, ok := uuidQuery(c, "doctor_id")
+	if !ok {
+		return
+	}
+	if doctorID == nil {
+		_ = c.Error(errs.InvalidField("query.doctor_id", "is required"))
+		return
+	}
+	schedule, ok := boolQuery(c, "schedule", false)
+	if !ok {
+		return
+	}
+	dryRun, ok := boolQuery(c, "dry_run", false)
+	if !ok {
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		handleError(c, errs.ErrMalformedBody.Wrap(err), nil)
+		return
+	}
+
+	input := services.FHIRImportInput{
+		Bundle:           body,
+		DoctorID:         *doctorID,
+		TelegramUsername: c.Query("telegram_username"),
+		CreateSchedule:   schedule,
+		DryRun:           dryRun,
+	}
+	if raw, ok := c.GetQuery("condition_summary"); ok {
+		input.ConditionSummary = &raw
+	}
+	if raw := c.Query("risk_level"); raw != "" {
+		level := enums.RiskLevel(raw)
+		input.RiskLevel = &level
+	}
+	if raw := c.Query("monitoring_frequency"); raw != "" {
+		freq := enums.MonitoringFrequency(raw)
+		input.MonitoringFrequency = &freq
+	}
+	if raw := c.Query("timezone"); raw != "" {
+		input.Timezone = &raw
+	}
+
+	result, err := h.fhirService.Import(input)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.DryRun {
+		status = http.StatusOK
+	}
+	c.JSON(status, dto.NewFHIRImportResult(result))
+}
+
+// renderFHIR sends a resource as FHIR JSON. The mappers are validated against
+// the FHIR structure in their tests, not on every response.
+func renderFHIR(c *gin.Context, resource interface{}) {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+	c.Data(http.StatusOK, fhir.ContentType+"; charset=utf-8", body)
+}
+
+// fhirBase is the absolute URL of the FHIR endpoint the request was made to,
+// used for Bundle fullUrl and link values.
+func fhirBase(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	path := c.FullPath()
+	if i := strings.Index(path, "/fhir/"); i >= 0 {
+		path = path[:i+len("/fhir")]
+	}
+	return scheme + "://" + c.Request.Host + path
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/constants"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// handleError hands err over to the error middleware. A gorm not-found error is
+// replaced with notFound so clients get a resource-specific code.
+func handleError(c *gin.Context, err error, notFound *errs.AppError) {
+	if notFound != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		err = notFound
+	}
+	_ = c.Error(err)
+}
+
+// bindJSON decodes the request body into dst and reports binding problems as
+// validation errors. It returns false when the handler should stop.
+func bindJSON(c *gin.Context, dst interface{}) bool {
+	if err := c.ShouldBindJSON(dst); err != nil {
+		_ = c.Error(bindingError(err))
+		return false
+	}
+	return true
+}
+
+func uuidParam(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		_ = c.Error(errs.InvalidField("path."+name, "must be a valid UUID"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// uuidQuery parses an optional UUID query parameter; nil means it was not provided.
+func uuidQuery(c *gin.Context, name string) (*uuid.UUID, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		_ = c.Error(errs.InvalidField("query."+name, "must be a valid UUID"))
+		return nil, false
+	}
+	return &id, true
+}
+
+// boolQuery parses an optional boolean query parameter, falling back to def.
+func boolQuery(c *gin.Context, name string, def bool) (bool, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, true
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		_ = c.Error(errs.InvalidField("query."+name, "must be a boolean"))
+		return false, false
+	}
+	return val, true
+}
+
+// floatQuery parses an optional numeric query parameter.
+func floatQuery(c *gin.Context, name string) (*float64, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	val, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		_ = c.Error(errs.InvalidField("query."+name, "must be a number"))
+		return nil, false
+	}
+	return &val, true
+}
+
+// pageParams reads the shared limit/cursor/sort/order/from/to/include_total query parameters.
+func pageParams(c *gin.Context) (pagination.Params, bool) {
+	params := pagination.Params{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > pagination.MaxLimit {
+			_ = c.Error(errs.InvalidField("query.limit", fmt.Sprintf("must be an integer between 1 and %d", pagination.MaxLimit)))
+			return params, false
+		}
+		params.Limit = limit
+	}
+
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		params.Direction = pagination.Asc
+	case "desc":
+		params.Direction = pagination.Desc
+	default:
+		_ = c.Error(errs.InvalidField("query.order", "must be one of [asc desc]"))
+		return params, false
+	}
+
+	var ok bool
+	if params.From, ok = timeQuery(c, "from"); !ok {
+		return params, false
+	}
+	if params.To, ok = timeQuery(c, "to"); !ok {
+		return params, false
+	}
+	if params.From != nil && params.To != nil && !params.From.Before(*params.To) {
+		_ = c.Error(errs.InvalidField("query.to", "must be after from"))
+		return params, false
+	}
+
+	if params.IncludeTotal, ok = boolQuery(c, "include_total", false); !ok {
+		return params, false
+	}
+
+	return params, true
+}
+
+// timeQuery parses an optional RFC 3339 timestamp or YYYY-MM-DD date query parameter.
+func timeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, true
+	}
+	if t, err := time.ParseInLocation(constants.DateFormat, raw, time.Local); err == nil {
+		return &t, true
+	}
+	_ = c.Error(errs.InvalidField("query."+name, "must be an RFC 3339 timestamp or YYYY-MM-DD date"))
+	return nil, false
+}
+
+// ifMatchVersion reads the resource version the client last saw from If-Match.
+// Writes to versioned resources must send it; see setETag.
+func ifMatchVersion(c *gin.Context) (int, bool) {
+	raw := strings.TrimSpace(c.GetHeader("If-Match"))
+	if raw == "" {
+		_ = c.Error(errs.ErrIfMatchRequired)
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.Trim(raw, `"`))
+	if err != nil || !strings.HasPrefix(raw, `"`) || !strings.HasSuffix(raw, `"`) || version < 1 {
+		_ = c.Error(errs.InvalidField("header.If-Match", "must be an ETag returned by this API"))
+		return 0, false
+	}
+	return version, true
+}
+
+// setETag exposes a resource version as a strong ETag.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+func bindingError(err error) *errs.AppError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]errs.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, errs.FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Message: validationMessage(fe),
+			})
+		}
+		return errs.Validation(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return errs.InvalidField(field, "must be of type "+typeErr.Type.Kind().String())
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errs.ErrMalformedBody
+	}
+
+	return errs.ErrMalformedBody.WithMessage(err.Error())
+}
+
+// fieldPath drops the root struct name from a validator namespace,
+// e.g. "body.items[0].seq" -> "items[0].seq".
+func fieldPath(namespace string) string {
+	if idx := strings.Index(namespace, "."); idx >= 0 {
+		return namespace[idx+1:]
+	}
+	return namespace
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", fe.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed %q validation", fe.Tag())
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+	"github.com/gin-gonic/gin"
+)
+
+type HL7Handler struct {
+	hl7Service *services.HL7Service
+}
+
+func NewHL7Handler(service *services.HL7Service) *HL7Handler {
+	return &HL7Handler{hl7Service: service}
+}
+
+// Receive is the HTTP fallback for senders that cannot use MLLP. The body is
+// one ER7 message; the response is always its ACK, so AE and AR come back
+// with 200 like they would over MLLP.
+func (h *HL7Handler) Receive(c *gin.Context) {
+	limit := h.hl7Service.MaxMessageSize()
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+	if err != nil {
+		handleError(c, errs.ErrMalformedBody.Wrap(err), nil)
+		return
+	}
+
+	var ack []byte
+	if len(body) > limit {
+		ack = h.hl7Service.Oversized(body[:limit], enums.HL7TransportHTTP)
+	} else {
+		ack = h.hl7Service.Handle(body, enums.HL7TransportHTTP)
+	}
+	c.Data(http.StatusOK, hl7.ContentType, ack)
+}
+
+func (h *HL7Handler) ListMessages(c *gin.Context) {
+	patientID, ok := uuidQuery(c, "patient_id")
+	if !ok {
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	filter := services.HL7MessageFilter{
+		MessageType: c.Query("message_type"),
+		ControlID:   c.Query("control_id"),
+		PatientID:   patientID,
+	}
+	if raw := c.Query("status"); raw != "" {
+		status := enums.HL7MessageStatus(raw)
+		filter.Status = &status
+	}
+
+	messages, err := h.hl7Service.ListMessages(filter, page)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPage(messages, dto.NewHL7Message))
+}
+
+func (h *HL7Handler) GetMessage(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	message, err := h.hl7Service.GetMessage(id)
+	if err != nil {
+		handleError(c, err, errs.ErrHL7MessageNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewHL7Message(message))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type InstrumentHandler struct {
+	instrumentService *services.InstrumentService
+}
+
+func NewInstrumentHandler(instrumentService *services.InstrumentService) *InstrumentHandler {
+	return &InstrumentHandler{instrumentService: instrumentService}
+}
+
+func (h *InstrumentHandler) List(c *gin.Context) {
+	instruments := h.instrumentService.List()
+
+	items := make([]dto.Instrument, len(instruments))
+	for i, instrument := range instruments {
+		items[i] = dto.NewInstrument(instrument)
+	}
+	c.JSON(http.StatusOK, dto.List[dto.Instrument]{Items: items})
+}
+
+func (h *InstrumentHandler) Get(c *gin.Context) {
+	instrument, err := h.instrumentService.Get(enums.Instrument(strings.ToUpper(c.Param("code"))))
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewInstrument(instrument))
+}
+
+func (h *InstrumentHandler) History(c *gin.Context) {
+	patientID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	var instrument *enums.Instrument
+	if raw := c.Query("instrument"); raw != "" {
+		code := enums.Instrument(raw)
+		instrument = &code
+	}
+
+	scores, err := h.instrumentService.History(patientID, instrument, page)
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPage(scores, dto.NewInstrumentScore))
+}
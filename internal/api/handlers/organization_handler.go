@@ -1,15 +1,13 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
-	"strconv"
 
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type OrganizationHandler struct {
@@ -23,17 +21,9 @@ func NewOrganizationHandler(organizationService *services.OrganizationService) *
 }
 
 func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
-	var body struct {
-		Name          string  `json:"name" binding:"required"`
-		Address       *string `json:"address" binding:"required"`
-		LicenseNumber string  `json:"license_number" binding:"required"`
-		ContactEmail  *string `json:"contact_email" binding:"required"`
-		ContactPhone  *string `json:"contact_phone" binding:"required"`
-		IsActive      *bool   `json:"is_active" binding:"required"`
-	}
+	var body dto.CreateOrganizationRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -50,71 +40,57 @@ func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
 	}
 
 	if err := h.organizationService.Create(&org); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create organization: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusCreated, org)
+	c.JSON(http.StatusCreated, dto.NewOrganization(&org))
 }
 
 func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
-	includeInactive := false
-	if includeInactiveStr := c.Query("include_inactive"); includeInactiveStr != "" {
-		parsed, err := strconv.ParseBool(includeInactiveStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_inactive value"})
-			return
-		}
-		includeInactive = parsed
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
 	}
 
-	organizations, err := h.organizationService.List(includeInactive)
+	organizations, err := h.organizationService.List(includeInactive, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list organizations: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": organizations})
+	c.JSON(http.StatusOK, dto.NewPage(organizations, dto.NewOrganization))
 }
 
 func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	organization, err := h.organizationService.GetByID(id)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch organization: " + err.Error()})
+		handleError(c, err, errs.ErrOrganizationNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, organization)
+	c.JSON(http.StatusOK, dto.NewOrganization(organization))
 }
 
 func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		Name          *string `json:"name"`
-		Address       *string `json:"address"`
-		LicenseNumber *string `json:"license_number"`
-		ContactEmail  *string `json:"contact_email"`
-		ContactPhone  *string `json:"contact_phone"`
-		IsActive      *bool   `json:"is_active"`
-	}
+	var body dto.UpdateOrganizationRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -127,32 +103,23 @@ func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
 		IsActive:      body.IsActive,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update organization: " + err.Error()})
+		handleError(c, err, errs.ErrOrganizationNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	c.JSON(http.StatusOK, dto.NewOrganization(updated))
 }
 
 func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	if err := h.organizationService.Delete(id); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete organization: " + err.Error()})
+		handleError(c, err, errs.ErrOrganizationNotFound)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.Status(http.StatusNoContent)
 }
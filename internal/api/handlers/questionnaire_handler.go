@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type QuestionnaireHandler struct {
+	questionnaireService *services.QuestionnaireService
+}
+
+func NewQuestionnaireHandler(service *services.QuestionnaireService) *QuestionnaireHandler {
+	return &QuestionnaireHandler{questionnaireService: service}
+}
+
+func (h *QuestionnaireHandler) CreateTemplate(c *gin.Context) {
+	var body dto.CreateQuestionnaireTemplateRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	template, err := h.questionnaireService.CreateTemplate(services.CreateTemplateInput{
+		Key:         body.Key,
+		Name:        body.Name,
+		Description: body.Description,
+		Questions:   dto.TemplateQuestions(body.Questions),
+		CreatedBy:   body.CreatedBy,
+	})
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewQuestionnaireTemplate(template))
+}
+
+func (h *QuestionnaireHandler) GetTemplate(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	template, err := h.questionnaireService.GetTemplate(id)
+	if err != nil {
+		handleError(c, err, errs.ErrQuestionnaireTemplateNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewQuestionnaireTemplate(template))
+}
+
+func (h *QuestionnaireHandler) ListTemplates(c *gin.Context) {
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
+	}
+
+	allVersions, ok := boolQuery(c, "all_versions", false)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	templates, err := h.questionnaireService.ListTemplates(services.ListTemplatesFilter{
+		Key:             c.Query("key"),
+		AllVersions:     allVersions,
+		IncludeInactive: includeInactive,
+	}, page)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPage(templates, dto.NewQuestionnaireTemplate))
+}
+
+func (h *QuestionnaireHandler) GetEffectiveTemplate(c *gin.Context) {
+	patientID, ok := uuidParam(c, "patientId")
+	if !ok {
+		return
+	}
+
+	template, err := h.questionnaireService.EffectiveTemplate(patientID)
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewQuestionnaireTemplate(template))
+}
+
+func (h *QuestionnaireHandler) UpdateTemplate(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var body dto.UpdateQuestionnaireTemplateRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	template, err := h.questionnaireService.UpdateTemplate(id, services.UpdateTemplateInput{
+		Name:        body.Name,
+		Description: body.Description,
+		IsActive:    body.IsActive,
+	})
+	if err != nil {
+		handleError(c, err, errs.ErrQuestionnaireTemplateNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewQuestionnaireTemplate(template))
+}
+
+// GetCheckinTemplate returns the template version a checkin asks, for the bot
+// to run the conversation from.
+func (h *QuestionnaireHandler) GetCheckinTemplate(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	template, err := h.questionnaireService.CheckinTemplate(id)
+	if err != nil {
+		handleError(c, err, errs.ErrCheckinNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewQuestionnaireTemplate(template))
+}
+
+func (h *QuestionnaireHandler) CreateAssignment(c *gin.Context) {
+	var body dto.CreateQuestionnaireAssignmentRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	assignment, err := h.questionnaireService.CreateAssignment(services.CreateAssignmentInput{
+		TemplateKey:   body.TemplateKey,
+		PatientUserID: body.PatientID,
+		Condition:     body.Condition,
+		Priority:      body.Priority,
+		CreatedBy:     body.CreatedBy,
+	})
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewQuestionnaireAssignment(assignment))
+}
+
+func (h *QuestionnaireHandler) ListAssignments(c *gin.Context) {
+	patientID, ok := uuidQuery(c, "patient_id")
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.questionnaireService.ListAssignments(services.ListAssignmentsFilter{
+		TemplateKey:   c.Query("template_key"),
+		PatientUserID: patientID,
+	}, page)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPage(assignments, dto.NewQuestionnaireAssignment))
+}
+
+func (h *QuestionnaireHandler) DeleteAssignment(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.questionnaireService.DeleteAssignment(id); err != nil {
+		handleError(c, err, errs.ErrQuestionnaireAssignmentNotFound)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
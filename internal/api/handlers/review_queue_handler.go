@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewQueueHandler struct {
+	reviewQueueService *services.ReviewQueueService
+}
+
+func NewReviewQueueHandler(reviewQueueService *services.ReviewQueueService) *ReviewQueueHandler {
+	return &ReviewQueueHandler{reviewQueueService: reviewQueueService}
+}
+
+func (h *ReviewQueueHandler) Queue(c *gin.Context) {
+	doctorID, ok := uuidQuery(c, "doctor_id")
+	if !ok {
+		return
+	}
+	if doctorID == nil {
+		_ = c.Error(errs.InvalidField("query.doctor_id", "is required"))
+		return
+	}
+	unclaimed, ok := boolQuery(c, "unclaimed", false)
+	if !ok {
+		return
+	}
+	overdue, ok := boolQuery(c, "overdue", false)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	queue, err := h.reviewQueueService.Queue(services.ReviewQueueFilter{
+		DoctorID:  *doctorID,
+		Scope:     services.ReviewScope(c.Query("scope")),
+		Unclaimed: unclaimed,
+		Overdue:   overdue,
+	}, page)
+	if err != nil {
+		handleError(c, err, errs.ErrNotFound.WithMessage("doctor not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewReviewQueue(queue))
+}
+
+func (h *ReviewQueueHandler) Claim(c *gin.Context) {
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var body dto.ClaimCheckinRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	checkin, err := h.reviewQueueService.Claim(checkinID, body.DoctorID)
+	if err != nil {
+		handleError(c, err, errs.ErrNotFound.WithMessage("doctor or checkin not found"))
+		return
+	}
+
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
+}
+
+func (h *ReviewQueueHandler) Unclaim(c *gin.Context) {
+	checkinID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var body dto.ClaimCheckinRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	checkin, err := h.reviewQueueService.Unclaim(checkinID, body.DoctorID)
+	if err != nil {
+		handleError(c, err, errs.ErrNotFound.WithMessage("doctor or checkin not found"))
+		return
+	}
+
+	setETag(c, checkin.Version)
+	c.JSON(http.StatusOK, dto.NewCheckin(checkin))
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+func (h *SearchHandler) Search(c *gin.Context) {
+	doctorID, ok := uuidQuery(c, "doctor_id")
+	if !ok {
+		return
+	}
+	if doctorID == nil {
+		_ = c.Error(errs.InvalidField("query.doctor_id", "is required"))
+		return
+	}
+	patientID, ok := uuidQuery(c, "patient_id")
+	if !ok {
+		return
+	}
+
+	var medicalStatus *enums.MedicalStatus
+	if raw := c.Query("medical_status"); raw != "" {
+		status := enums.MedicalStatus(raw)
+		medicalStatus = &status
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.searchService.Search(services.SearchQuery{
+		DoctorID:      *doctorID,
+		Scope:         services.ReviewScope(c.Query("scope")),
+		Text:          c.Query("q"),
+		Kind:          services.SearchKind(c.Query("type")),
+		PatientUserID: patientID,
+		MedicalStatus: medicalStatus,
+	}, page)
+	if err != nil {
+		handleError(c, err, errs.ErrNotFound.WithMessage("doctor not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewSearchResult(result))
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type ThresholdRuleHandler struct {
+	thresholdRuleService *services.ThresholdRuleService
+}
+
+func NewThresholdRuleHandler(service *services.ThresholdRuleService) *ThresholdRuleHandler {
+	return &ThresholdRuleHandler{thresholdRuleService: service}
+}
+
+func (h *ThresholdRuleHandler) Create(c *gin.Context) {
+	var body dto.CreateThresholdRuleRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	rule, err := h.thresholdRuleService.Create(services.CreateThresholdRuleInput{
+		OrganizationID: body.OrganizationID,
+		PatientUserID:  body.PatientID,
+		Key:            body.Key,
+		Name:           body.Name,
+		Severity:       body.Severity,
+		Conditions:     dto.RuleConditions(body.Conditions),
+		IsActive:       body.IsActive,
+		CreatedBy:      body.CreatedBy,
+	})
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewThresholdRule(rule))
+}
+
+func (h *ThresholdRuleHandler) Get(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	rule, err := h.thresholdRuleService.GetByID(id)
+	if err != nil {
+		handleError(c, err, errs.ErrRuleNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewThresholdRule(rule))
+}
+
+func (h *ThresholdRuleHandler) List(c *gin.Context) {
+	organizationID, ok := uuidQuery(c, "organization_id")
+	if !ok {
+		return
+	}
+
+	patientID, ok := uuidQuery(c, "patient_id")
+	if !ok {
+		return
+	}
+
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	rules, err := h.thresholdRuleService.List(services.ListThresholdRulesFilter{
+		OrganizationID:  organizationID,
+		PatientID:       patientID,
+		IncludeInactive: includeInactive,
+	}, page)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPage(rules, dto.NewThresholdRule))
+}
+
+func (h *ThresholdRuleHandler) ListEffective(c *gin.Context) {
+	patientID, ok := uuidParam(c, "patientId")
+	if !ok {
+		return
+	}
+
+	rules, err := h.thresholdRuleService.EffectiveRules(patientID)
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	items := make([]dto.ThresholdRule, len(rules))
+	for i := range rules {
+		items[i] = dto.NewThresholdRule(&rules[i])
+	}
+	c.JSON(http.StatusOK, dto.List[dto.ThresholdRule]{Items: items})
+}
+
+func (h *ThresholdRuleHandler) Update(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var body dto.UpdateThresholdRuleRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	var conditions *[]models.RuleCondition
+	if body.Conditions != nil {
+		converted := dto.RuleConditions(*body.Conditions)
+		conditions = &converted
+	}
+
+	rule, err := h.thresholdRuleService.Update(id, services.UpdateThresholdRuleInput{
+		Key:        body.Key,
+		Name:       body.Name,
+		Severity:   body.Severity,
+		Conditions: conditions,
+		IsActive:   body.IsActive,
+	})
+	if err != nil {
+		handleError(c, err, errs.ErrRuleNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewThresholdRule(rule))
+}
+
+func (h *ThresholdRuleHandler) Delete(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.thresholdRuleService.Delete(id); err != nil {
+		handleError(c, err, errs.ErrRuleNotFound)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,17 +1,13 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
-	"strconv"
-	"time"
 
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
-	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type UserHandler struct {
@@ -25,19 +21,9 @@ func NewUserHandler(userService *services.UserService) *UserHandler {
 }
 
 func (h *UserHandler) CreateDoctor(c *gin.Context) {
-	var body struct {
-		PhoneNumber      string        `json:"phone_number" binding:"required"`
-		Password         string        `json:"password" binding:"required"`
-		FirstName        string        `json:"first_name" binding:"required"`
-		LastName         string        `json:"last_name" binding:"required"`
-		Gender           *enums.Gender `json:"gender"`
-		IsActive         *bool         `json:"is_active"`
-		TelegramUsername string        `json:"telegram_username" binding:"required"`
-		OrganizationID   uuid.UUID     `json:"organization_id" binding:"required"`
-	}
+	var body dto.CreateDoctorRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -54,72 +40,57 @@ func (h *UserHandler) CreateDoctor(c *gin.Context) {
 	}
 
 	if _, _, err := h.userService.CreateDoctor(&doctor, body.OrganizationID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create doctor: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusCreated, doctor)
+	c.JSON(http.StatusCreated, dto.NewUser(&doctor))
 }
 
 func (h *UserHandler) ListDoctors(c *gin.Context) {
-	includeInactive := false
-	if includeInactiveStr := c.Query("include_inactive"); includeInactiveStr != "" {
-		val, err := strconv.ParseBool(includeInactiveStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_inactive value"})
-			return
-		}
-		includeInactive = val
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
 	}
 
-	doctors, err := h.userService.ListDoctors(includeInactive)
+	doctors, err := h.userService.ListDoctors(includeInactive, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list doctors: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": doctors})
+	c.JSON(http.StatusOK, dto.NewPage(doctors, dto.NewUser))
 }
 
 func (h *UserHandler) GetDoctor(c *gin.Context) {
-	doctorID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+	doctorID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	doctor, err := h.userService.GetDoctorByID(doctorID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "doctor not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch doctor: " + err.Error()})
+		handleError(c, err, errs.ErrDoctorNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, doctor)
+	c.JSON(http.StatusOK, dto.NewUser(doctor))
 }
 
 func (h *UserHandler) UpdateDoctor(c *gin.Context) {
-	doctorID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+	doctorID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		PhoneNumber      *string       `json:"phone_number"`
-		FirstName        *string       `json:"first_name"`
-		LastName         *string       `json:"last_name"`
-		Gender           *enums.Gender `json:"gender"`
-		IsActive         *bool         `json:"is_active"`
-		Password         *string       `json:"password"`
-		TelegramUsername *string       `json:"telegram_username"`
-	}
+	var body dto.UpdateDoctorRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -133,104 +104,74 @@ func (h *UserHandler) UpdateDoctor(c *gin.Context) {
 		TelegramUsername: body.TelegramUsername,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "doctor not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update doctor: " + err.Error()})
+		handleError(c, err, errs.ErrDoctorNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	c.JSON(http.StatusOK, dto.NewUser(updated))
 }
 
 func (h *UserHandler) DeleteDoctor(c *gin.Context) {
-	doctorID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+	doctorID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	if err := h.userService.DeleteDoctor(doctorID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "doctor not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete doctor: " + err.Error()})
+		handleError(c, err, errs.ErrDoctorNotFound)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.Status(http.StatusNoContent)
 }
 
 func (h *UserHandler) UnassignFromOrganization(c *gin.Context) {
-	doctorID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+	doctorID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
-	orgIDStr := c.Param("organizationId")
-	orgID, err := uuid.Parse(orgIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization id"})
+	orgID, ok := uuidParam(c, "organizationId")
+	if !ok {
 		return
 	}
 
 	if err := h.userService.UnassignFromOrganization(doctorID, orgID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "assignment not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unassign doctor: " + err.Error()})
+		handleError(c, err, errs.ErrAssignmentNotFound)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.Status(http.StatusNoContent)
 }
 
 func (h *UserHandler) ListDoctorOrganizations(c *gin.Context) {
-	doctorID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+	doctorID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
 		return
 	}
 
-	includeInactive := false
-	if includeInactiveStr := c.Query("include_inactive"); includeInactiveStr != "" {
-		val, err := strconv.ParseBool(includeInactiveStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_inactive value"})
-			return
-		}
-		includeInactive = val
+	page, ok := pageParams(c)
+	if !ok {
+		return
 	}
 
-	relations, err := h.userService.ListDoctorOrganizations(doctorID, includeInactive)
+	relations, err := h.userService.ListDoctorOrganizations(doctorID, includeInactive, page)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "doctor not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list doctor organizations: " + err.Error()})
+		handleError(c, err, errs.ErrDoctorNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": relations})
+	c.JSON(http.StatusOK, dto.NewPage(relations, dto.NewOrganizationDoctor))
 }
 
 func (h *UserHandler) CreatePatient(c *gin.Context) {
-	var body struct {
-		PhoneNumber      string        `json:"phone_number" binding:"required"`
-		Password         string        `json:"password" binding:"required"`
-		FirstName        string        `json:"first_name" binding:"required"`
-		LastName         string        `json:"last_name" binding:"required"`
-		Gender           *enums.Gender `json:"gender"`
-		IsActive         *bool         `json:"is_active"`
-		TelegramUsername string        `json:"telegram_username" binding:"required"`
-	}
+	var body dto.CreatePatientRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -244,39 +185,22 @@ func (h *UserHandler) CreatePatient(c *gin.Context) {
 		TelegramUsername: body.TelegramUsername,
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient user: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
+	c.JSON(http.StatusCreated, dto.NewUser(user))
 }
 
 func (h *UserHandler) CreatePatientMedicalInfo(c *gin.Context) {
-	userID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	userID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		DoctorID                 uuid.UUID                  `json:"doctor_id" binding:"required"`
-		ConditionSummary         string                     `json:"condition_summary" binding:"required"`
-		Comorbidities            []string                   `json:"comorbidities"`
-		CurrentMedications       models.JSONB               `json:"current_medications"`
-		Allergies                []string                   `json:"allergies"`
-		BaselineVitals           models.JSONB               `json:"baseline_vitals"`
-		RiskLevel                *enums.RiskLevel           `json:"risk_level"`
-		MonitoringFrequency      *enums.MonitoringFrequency `json:"monitoring_frequency"`
-		Status                   *enums.PatientStatus       `json:"status"`
-		DischargeDate            *time.Time                 `json:"discharge_date"`
-		DischargeNotes           *string                    `json:"discharge_notes"`
-		EmergencyContactName     *string                    `json:"emergency_contact_name"`
-		EmergencyContactPhone    *string                    `json:"emergency_contact_phone"`
-		EmergencyContactRelation *string                    `json:"emergency_contact_relation"`
-	}
+	var body dto.CreatePatientMedicalRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -297,41 +221,23 @@ func (h *UserHandler) CreatePatientMedicalInfo(c *gin.Context) {
 		EmergencyContactRelation: body.EmergencyContactRelation,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient or doctor not found"})
-			return
-		}
-		if err.Error() == "patient medical info already exists" {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient medical info: " + err.Error()})
+		handleError(c, err, errs.ErrNotFound.WithMessage("patient or doctor not found"))
 		return
 	}
 
-	c.JSON(http.StatusCreated, patient)
+	setETag(c, patient.Version)
+	c.JSON(http.StatusCreated, dto.NewPatient(patient))
 }
 
 func (h *UserHandler) UpdatePatient(c *gin.Context) {
-	userID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	userID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		Email            *string       `json:"email"`
-		PhoneNumber      *string       `json:"phone_number"`
-		FirstName        *string       `json:"first_name"`
-		LastName         *string       `json:"last_name"`
-		Gender           *enums.Gender `json:"gender"`
-		IsActive         *bool         `json:"is_active"`
-		Password         *string       `json:"password"`
-		TelegramUsername *string       `json:"telegram_username"`
-	}
+	var body dto.UpdatePatientRequest
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -346,47 +252,31 @@ func (h *UserHandler) UpdatePatient(c *gin.Context) {
 		TelegramUsername: body.TelegramUsername,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient user: " + err.Error()})
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, dto.NewUser(user))
 }
 
 func (h *UserHandler) UpdatePatientMedicalInfo(c *gin.Context) {
-	userID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	userID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		DoctorID                 *uuid.UUID                 `json:"doctor_id"`
-		ConditionSummary         *string                    `json:"condition_summary"`
-		Comorbidities            *[]string                  `json:"comorbidities"`
-		CurrentMedications       *models.JSONB              `json:"current_medications"`
-		Allergies                *[]string                  `json:"allergies"`
-		BaselineVitals           *models.JSONB              `json:"baseline_vitals"`
-		RiskLevel                *enums.RiskLevel           `json:"risk_level"`
-		MonitoringFrequency      *enums.MonitoringFrequency `json:"monitoring_frequency"`
-		Status                   *enums.PatientStatus       `json:"status"`
-		DischargeDate            *time.Time                 `json:"discharge_date"`
-		DischargeNotes           *string                    `json:"discharge_notes"`
-		EmergencyContactName     *string                    `json:"emergency_contact_name"`
-		EmergencyContactPhone    *string                    `json:"emergency_contact_phone"`
-		EmergencyContactRelation *string                    `json:"emergency_contact_relation"`
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
 	}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body dto.UpdatePatientMedicalRequest
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	patient, err := h.userService.UpdatePatientMedicalInfo(userID, services.PatientMedicalUpdate{
+	patient, err := h.userService.UpdatePatientMedicalInfo(userID, version, services.PatientMedicalUpdate{
 		DoctorID:                 body.DoctorID,
 		ConditionSummary:         body.ConditionSummary,
 		Comorbidities:            body.Comorbidities,
@@ -403,88 +293,77 @@ func (h *UserHandler) UpdatePatientMedicalInfo(c *gin.Context) {
 		EmergencyContactRelation: body.EmergencyContactRelation,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient medical info: " + err.Error()})
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, patient)
+	setETag(c, patient.Version)
+	c.JSON(http.StatusOK, dto.NewPatient(patient))
 }
 
 func (h *UserHandler) ListPatients(c *gin.Context) {
-	includeInactive := false
-	if includeInactiveStr := c.Query("include_inactive"); includeInactiveStr != "" {
-		val, err := strconv.ParseBool(includeInactiveStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_inactive value"})
-			return
-		}
-		includeInactive = val
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
 	}
 
-	patients, err := h.userService.ListPatientUsers(includeInactive)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	patients, err := h.userService.ListPatientUsers(includeInactive, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list patients: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": patients})
+	c.JSON(http.StatusOK, dto.NewPage(patients, dto.NewUser))
 }
 
 func (h *UserHandler) GetPatient(c *gin.Context) {
-	patientID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+	patientID, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	patient, err := h.userService.GetPatientDetailsByUserID(patientID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found or no medical data found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch patient: " + err.Error()})
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, patient)
+	setETag(c, patient.Version)
+	c.JSON(http.StatusOK, dto.NewPatient(patient))
 }
 
 func (h *UserHandler) GetPatientComplete(c *gin.Context) {
-	userID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient user id"})
+	userID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
 		return
 	}
 
-	data, err := h.userService.GetPatientCompleteData(userID)
+	data, err := h.userService.GetPatientCompleteData(userID, page)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch patient data: " + err.Error()})
+		handleError(c, err, errs.ErrPatientNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	c.JSON(http.StatusOK, dto.NewPatientComplete(data.User, data.Patient, data.Schedule, data.Checkins, data.VitalReadings))
 }
 
 func (h *UserHandler) GetUserByTgUsername(c *gin.Context) {
 	username := c.Param("username")
 	user, err := h.userService.GetUserByTelegramUsername(username)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user: " + err.Error()})
+		handleError(c, err, errs.ErrUserNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, dto.NewUser(user))
 }
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+
+	maxNDJSONLine = 64 * 1024
+)
+
+var errTooManyRows = errs.InvalidField("readings", "must have at most 1000 rows")
+
+// CreateBatch records a batch of readings for a patient user, typically a
+// device export. The body is JSON ({"readings": [...]}), NDJSON with one
+// reading per line, or CSV with a header row. The source and device query
+// parameters apply to rows that do not set their own; source defaults to DEVICE.
+func (h *VitalReadingHandler) CreateBatch(c *gin.Context) {
+	patientID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	source := enums.VitalSourceDevice
+	if raw := c.Query("source"); raw != "" {
+		source = enums.VitalSource(raw)
+	}
+	device := strings.TrimSpace(c.Query("device"))
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+	var rows []services.VitalReadingBatchRow
+	var err error
+	switch mediaType {
+	case csvContentType:
+		rows, err = decodeCSVBatch(c.Request.Body)
+	case ndjsonContentType:
+		rows, err = decodeNDJSONBatch(c.Request.Body)
+	default:
+		var body dto.VitalReadingBatchRequest
+		if !bindJSON(c, &body) {
+			return
+		}
+		if len(body.Readings) > services.MaxVitalReadingBatch {
+			err = errTooManyRows
+			break
+		}
+		for i, r := range body.Readings {
+			rows = append(rows, services.VitalReadingBatchRow{Row: i, Input: batchRowInput(r)})
+		}
+	}
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	for i := range rows {
+		in := &rows[i].Input
+		if in.Source == nil {
+			in.Source = &source
+		}
+		if in.Device == nil && device != "" {
+			in.Device = &device
+		}
+	}
+
+	result, err := h.vitalReadingService.CreateBatch(patientID, rows)
+	if err != nil {
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewVitalReadingBatchResult(result))
+}
+
+func batchRowInput(r dto.VitalReadingBatchRow) services.CreateVitalReadingInput {
+	return services.CreateVitalReadingInput{
+		CheckinID:    r.CheckinID,
+		VitalType:    r.VitalType,
+		Unit:         r.Unit,
+		ValueNumeric: r.ValueNumeric,
+		ValueText:    r.ValueText,
+		Systolic:     r.Systolic,
+		Diastolic:    r.Diastolic,
+		Context:      r.Context,
+		MeasuredAt:   r.MeasuredAt,
+		Source:       r.Source,
+		Device:       r.Device,
+	}
+}
+
+// decodeNDJSONBatch reads one JSON reading per line; rows are numbered by line.
+func decodeNDJSONBatch(body io.Reader) ([]services.VitalReadingBatchRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxNDJSONLine)
+
+	var rows []services.VitalReadingBatchRow
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if len(rows) == services.MaxVitalReadingBatch {
+			return nil, errTooManyRows
+		}
+
+		row := services.VitalReadingBatchRow{Row: line}
+		var r dto.VitalReadingBatchRow
+		if err := json.Unmarshal(raw, &r); err != nil {
+			row.Errors = []errs.FieldError{ndjsonError(err)}
+		} else {
+			row.Input = batchRowInput(r)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, errs.InvalidField("body", "NDJSON lines must be at most 64 KiB")
+		}
+		return nil, errs.ErrMalformedBody.Wrap(err)
+	}
+	return rows, nil
+}
+
+func ndjsonError(err error) errs.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return errs.FieldError{Field: typeErr.Field, Message: "must be of type " + typeErr.Type.Kind().String()}
+	}
+	var parseErr *time.ParseError
+	if errors.As(err, &parseErr) {
+		return errs.FieldError{Field: "measured_at", Message: "must be an RFC 3339 timestamp"}
+	}
+	return errs.FieldError{Field: "row", Message: "is not a valid JSON object"}
+}
+
+// csvColumns are the accepted CSV header names.
+var csvColumns = map[string]bool{
+	"checkin_id": true, "vital_type": true, "unit": true, "value": true, "value_numeric": true, "value_text": true,
+	"systolic": true, "diastolic": true, "context": true, "measured_at": true, "source": true, "device": true,
+}
+
+// decodeCSVBatch reads a CSV upload with a header row naming the columns of
+// dto.VitalReadingBatchRow; rows are numbered by line, the header being line 1.
+func decodeCSVBatch(body io.Reader) ([]services.VitalReadingBatchRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errs.InvalidField("body", "must be CSV with a header row")
+	}
+	columns := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !csvColumns[name] {
+			return nil, errs.InvalidField("body", "unknown CSV column "+strconv.Quote(name))
+		}
+		if seen[name] {
+			return nil, errs.InvalidField("body", "duplicate CSV column "+strconv.Quote(name))
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	if !seen["vital_type"] {
+		return nil, errs.InvalidField("body", "CSV header must include vital_type")
+	}
+
+	var rows []services.VitalReadingBatchRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, errs.InvalidField("body", parseErr.Error())
+			}
+			return nil, errs.ErrMalformedBody.Wrap(err)
+		}
+		if len(rows) == services.MaxVitalReadingBatch {
+			return nil, errTooManyRows
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, csvRow(line, columns, record))
+	}
+	return rows, nil
+}
+
+func csvRow(line int, columns, record []string) services.VitalReadingBatchRow {
+	row := services.VitalReadingBatchRow{Row: line}
+	if len(record) != len(columns) {
+		row.Errors = []errs.FieldError{{Field: "row", Message: "has " + strconv.Itoa(len(record)) + " fields, header has " + strconv.Itoa(len(columns))}}
+		return row
+	}
+
+	in := &row.Input
+	number := func(field, raw string) *float64 {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			row.Errors = append(row.Errors, errs.FieldError{Field: field, Message: "must be a number"})
+			return nil
+		}
+		return &v
+	}
+
+	for i, name := range columns {
+		raw := strings.TrimSpace(record[i])
+		if raw == "" {
+			continue
+		}
+		switch name {
+		case "checkin_id":
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				row.Errors = append(row.Errors, errs.FieldError{Field: name, Message: "must be a valid UUID"})
+				continue
+			}
+			in.CheckinID = &id
+		case "vital_type":
+			in.VitalType = enums.VitalType(raw)
+		case "unit":
+			unit := enums.VitalUnit(raw)
+			in.Unit = &unit
+		case "value":
+			// devices write blood pressure as "140/90" in the same column as numbers
+			if v, err := strconv.ParseFloat(raw, 64); err == nil {
+				in.ValueNumeric = &v
+			} else {
+				in.ValueText = &raw
+			}
+		case "value_numeric":
+			in.ValueNumeric = number(name, raw)
+		case "value_text":
+			in.ValueText = &raw
+		case "systolic":
+			in.Systolic = number(name, raw)
+		case "diastolic":
+			in.Diastolic = number(name, raw)
+		case "context":
+			in.Context = &raw
+		case "measured_at":
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				row.Errors = append(row.Errors, errs.FieldError{Field: name, Message: "must be an RFC 3339 timestamp"})
+				continue
+			}
+			in.MeasuredAt = &t
+		case "source":
+			source := enums.VitalSource(raw)
+			in.Source = &source
+		case "device":
+			in.Device = &raw
+		}
+	}
+	return row
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+)
+
+func rowFields(row services.VitalReadingBatchRow) []string {
+	var fields []string
+	for _, f := range row.Errors {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+func TestDecodeCSVBatch(t *testing.T) {
+	body := "\ufeffVital_Type, value ,unit,checkin_id,measured_at,device\n" +
+		"HEART_RATE,72,bpm,,2026-03-01T08:00:00+05:00,Apple Watch\n" +
+		"\n" +
+		"BLOOD_PRESSURE, 140/90 ,,6f1c2a4e-3b0d-4f7e-9a51-0c2d8e7b4a10,,\n" +
+		"WEIGHT,\"81.5\",kg,,,\"Scale, bathroom\"\n"
+	rows, err := decodeCSVBatch(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeCSVBatch: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+
+	hr := rows[0]
+	if hr.Row != 2 || hr.Input.VitalType != enums.VitalTypeHeartRate || *hr.Input.ValueNumeric != 72 || *hr.Input.Unit != "bpm" || *hr.Input.Device != "Apple Watch" {
+		t.Errorf("row 2 = %+v", hr)
+	}
+	if want := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC); hr.Input.MeasuredAt == nil || !hr.Input.MeasuredAt.Equal(want) {
+		t.Errorf("measured at = %v, want %v", hr.Input.MeasuredAt, want)
+	}
+	if hr.Input.CheckinID != nil {
+		t.Error("an empty cell set checkin_id")
+	}
+
+	// blood pressure in the value column is kept as text
+	bp := rows[1]
+	if bp.Row != 4 || bp.Input.ValueNumeric != nil || bp.Input.ValueText == nil || *bp.Input.ValueText != "140/90" || bp.Input.CheckinID == nil {
+		t.Errorf("row 4 = %+v", bp)
+	}
+	if bp.Input.Unit != nil || bp.Input.MeasuredAt != nil || bp.Input.Device != nil {
+		t.Errorf("row 4 = %+v, want empty cells left unset", bp)
+	}
+	if weight := rows[2]; weight.Row != 5 || *weight.Input.ValueNumeric != 81.5 || *weight.Input.Device != "Scale, bathroom" {
+		t.Errorf("row 5 = %+v", weight)
+	}
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			t.Errorf("row %d errors = %v", row.Row, row.Errors)
+		}
+	}
+}
+
+func TestDecodeCSVBatchRowErrors(t *testing.T) {
+	body := "vital_type,value_numeric,systolic,diastolic,checkin_id,measured_at\n" +
+		"HEART_RATE,fast,,,,\n" +
+		"BLOOD_PRESSURE,,high,80,not-a-uuid,yesterday\n" +
+		"HEART_RATE,72\n" +
+		"BLOOD_PRESSURE,,140,90,,2026-03-01T08:00:00Z\n"
+	rows, err := decodeCSVBatch(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeCSVBatch: %v", err)
+	}
+	want := [][]string{
+		{"value_numeric"},
+		{"systolic", "checkin_id", "measured_at"},
+		{"row"},
+		nil,
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d", len(rows), len(want))
+	}
+	for i, fields := range want {
+		if got := rowFields(rows[i]); !slices.Equal(got, fields) {
+			t.Errorf("row %d errors = %v, want %v", rows[i].Row, rows[i].Errors, fields)
+		}
+	}
+	if d := rows[1].Input.Diastolic; d == nil || *d != 80 {
+		t.Errorf("diastolic = %v, want the valid cell kept", d)
+	}
+	if msg := rows[2].Errors[0].Message; msg != "has 2 fields, header has 6" {
+		t.Errorf("row 4 error = %q", msg)
+	}
+}
+
+func TestDecodeCSVBatchRejects(t *testing.T) {
+	tests := map[string]string{
+		"empty body":         "",
+		"unknown column":     "vital_type,value,colour\nHEART_RATE,72,red\n",
+		"duplicate column":   "vital_type,value,VALUE\nHEART_RATE,72,73\n",
+		"no vital_type":      "value,unit\n72,bpm\n",
+		"unterminated quote": "vital_type,value\nHEART_RATE,\"72\n",
+		"too many rows":      "vital_type,value\n" + strings.Repeat("HEART_RATE,72\n", services.MaxVitalReadingBatch+1),
+	}
+	for name, body := range tests {
+		_, err := decodeCSVBatch(strings.NewReader(body))
+		if appErr := errs.As(err); appErr == nil || appErr.Status != 400 {
+			t.Errorf("%s: err = %v, want a 400", name, err)
+		}
+	}
+
+	rows, err := decodeCSVBatch(strings.NewReader("vital_type,value\n" + strings.Repeat("HEART_RATE,72\n", services.MaxVitalReadingBatch)))
+	if err != nil || len(rows) != services.MaxVitalReadingBatch {
+		t.Errorf("%d rows, %v; want the largest batch accepted", len(rows), err)
+	}
+}
+
+func TestDecodeNDJSONBatch(t *testing.T) {
+	body := `{"vital_type":"HEART_RATE","value_numeric":72,"device":"Apple Watch"}` + "\n" +
+		"\n" +
+		`  {"vital_type":"BLOOD_PRESSURE","systolic":140,"diastolic":90,"measured_at":"2026-03-01T08:00:00Z"}  ` + "\r\n" +
+		`{"vital_type":"WEIGHT","value_numeric":"heavy"}` + "\n" +
+		`{"vital_type":"WEIGHT","measured_at":"yesterday"}` + "\n" +
+		`{"vital_type":` + "\n" +
+		`[1,2]`
+	rows, err := decodeNDJSONBatch(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeNDJSONBatch: %v", err)
+	}
+
+	want := []struct {
+		line   int
+		fields []string
+	}{
+		{1, nil},
+		{3, nil},
+		{4, []string{"value_numeric"}},
+		{5, []string{"measured_at"}},
+		{6, []string{"row"}},
+		{7, []string{"row"}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d", len(rows), len(want))
+	}
+	for i, w := range want {
+		if rows[i].Row != w.line || !slices.Equal(rowFields(rows[i]), w.fields) {
+			t.Errorf("row %d = line %d with errors %v, want line %d with %v", i, rows[i].Row, rows[i].Errors, w.line, w.fields)
+		}
+	}
+	if hr := rows[0].Input; hr.VitalType != enums.VitalTypeHeartRate || *hr.ValueNumeric != 72 || *hr.Device != "Apple Watch" {
+		t.Errorf("line 1 = %+v", hr)
+	}
+	if bp := rows[1].Input; *bp.Systolic != 140 || *bp.Diastolic != 90 || bp.MeasuredAt == nil {
+		t.Errorf("line 3 = %+v", bp)
+	}
+}
+
+func TestDecodeNDJSONBatchRejects(t *testing.T) {
+	tests := map[string]string{
+		"line too long": `{"vital_type":"HEART_RATE","device":"` + strings.Repeat("x", maxNDJSONLine) + `"}`,
+		"too many rows": strings.Repeat(`{"vital_type":"HEART_RATE","value_numeric":72}`+"\n", services.MaxVitalReadingBatch+1),
+	}
+	for name, body := range tests {
+		_, err := decodeNDJSONBatch(strings.NewReader(body))
+		if appErr := errs.As(err); appErr == nil || appErr.Status != 400 {
+			t.Errorf("%s: err = %v, want a 400", name, err)
+		}
+	}
+
+	rows, err := decodeNDJSONBatch(strings.NewReader(""))
+	if err != nil || len(rows) != 0 {
+		t.Errorf("empty body = %v, %v; want no rows for the service to reject", rows, err)
+	}
+}
@@ -1,15 +1,14 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
-	"strconv"
 
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
 	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type VitalReadingHandler struct {
@@ -21,19 +20,14 @@ func NewVitalReadingHandler(service *services.VitalReadingService) *VitalReading
 }
 
 func (h *VitalReadingHandler) Create(c *gin.Context) {
-	var body struct {
-		CheckinID             uuid.UUID        `json:"checkin_id" binding:"required"`
-		PatientID             uuid.UUID        `json:"patient_id" binding:"required"`
-		VitalType             enums.VitalType  `json:"vital_type" binding:"required"`
-		Unit                  *enums.VitalUnit `json:"unit"`
-		ValueNumeric          *float64         `json:"value_numeric"`
-		ValueText             *string          `json:"value_text"`
-		IsAbnormal            *bool            `json:"is_abnormal"`
-		DeviationFromBaseline *float64         `json:"deviation_from_baseline"`
+	units, ok := h.preferredUnits(c)
+	if !ok {
+		return
 	}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body dto.CreateVitalReadingRequest
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -44,61 +38,52 @@ func (h *VitalReadingHandler) Create(c *gin.Context) {
 		Unit:                  body.Unit,
 		ValueNumeric:          body.ValueNumeric,
 		ValueText:             body.ValueText,
+		Systolic:              body.Systolic,
+		Diastolic:             body.Diastolic,
+		Context:               body.Context,
+		MeasuredAt:            body.MeasuredAt,
+		Source:                body.Source,
+		Device:                body.Device,
 		IsAbnormal:            body.IsAbnormal,
 		DeviationFromBaseline: body.DeviationFromBaseline,
 	})
 	if err != nil {
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient or checkin not found"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create vital reading: " + err.Error()})
-		}
+		handleError(c, err, errs.ErrNotFound.WithMessage("patient or checkin not found"))
 		return
 	}
 
-	c.JSON(http.StatusCreated, reading)
+	c.JSON(http.StatusCreated, dto.NewVitalReading(units.Apply(reading)))
 }
 
 func (h *VitalReadingHandler) Get(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	units, ok := h.preferredUnits(c)
+	if !ok {
 		return
 	}
 
 	reading, err := h.vitalReadingService.GetByID(id)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "vital reading not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch vital reading: " + err.Error()})
+		handleError(c, err, errs.ErrVitalReadingNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, reading)
+	c.JSON(http.StatusOK, dto.NewVitalReading(units.Apply(reading)))
 }
 
 func (h *VitalReadingHandler) List(c *gin.Context) {
-	var patientID *uuid.UUID
-	if raw := c.Query("patient_id"); raw != "" {
-		id, err := uuid.Parse(raw)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient_id"})
-			return
-		}
-		patientID = &id
-	}
-
-	var checkinID *uuid.UUID
-	if raw := c.Query("checkin_id"); raw != "" {
-		id, err := uuid.Parse(raw)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkin_id"})
-			return
-		}
-		checkinID = &id
+	patientID, ok := uuidQuery(c, "patient_id")
+	if !ok {
+		return
+	}
+
+	checkinID, ok := uuidQuery(c, "checkin_id")
+	if !ok {
+		return
 	}
 
 	var vitalType *enums.VitalType
@@ -107,43 +92,112 @@ func (h *VitalReadingHandler) List(c *gin.Context) {
 		vitalType = &vt
 	}
 
-	onlyAbnormal := false
-	if raw := c.Query("only_abnormal"); raw != "" {
-		val, err := strconv.ParseBool(raw)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid only_abnormal value"})
-			return
-		}
-		onlyAbnormal = val
+	onlyAbnormal, ok := boolQuery(c, "only_abnormal", false)
+	if !ok {
+		return
 	}
 
-	readings, err := h.vitalReadingService.List(patientID, checkinID, vitalType, onlyAbnormal)
+	var component *enums.VitalComponent
+	if raw := c.Query("component"); raw != "" {
+		vc := enums.VitalComponent(raw)
+		component = &vc
+	}
+
+	minValue, ok := floatQuery(c, "min_value")
+	if !ok {
+		return
+	}
+
+	maxValue, ok := floatQuery(c, "max_value")
+	if !ok {
+		return
+	}
+
+	units, ok := h.preferredUnits(c)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	readings, err := h.vitalReadingService.List(services.ListVitalReadingsFilter{
+		PatientID:    patientID,
+		CheckinID:    checkinID,
+		VitalType:    vitalType,
+		OnlyAbnormal: onlyAbnormal,
+		Component:    component,
+		MinValue:     minValue,
+		MaxValue:     maxValue,
+	}, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list vital readings: " + err.Error()})
+		handleError(c, err, nil)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": readings})
+	c.JSON(http.StatusOK, dto.NewPage(readings, func(r *models.VitalReading) dto.VitalReading {
+		return dto.NewVitalReading(units.Apply(r))
+	}))
 }
 
-func (h *VitalReadingHandler) Update(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
+func (h *VitalReadingHandler) Series(c *gin.Context) {
+	patientID, ok := uuidParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var vitalType *enums.VitalType
+	if raw := c.Query("type"); raw != "" {
+		vt := enums.VitalType(raw)
+		vitalType = &vt
+	}
+
+	from, ok := timeQuery(c, "from")
+	if !ok {
+		return
+	}
+
+	to, ok := timeQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	units, ok := h.preferredUnits(c)
+	if !ok {
+		return
+	}
+
+	series, err := h.vitalReadingService.Series(patientID, services.VitalSeriesQuery{
+		VitalType: vitalType,
+		From:      from,
+		To:        to,
+		Bucket:    services.SeriesBucket(c.Query("bucket")),
+		Units:     units,
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		handleError(c, err, errs.ErrPatientNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewVitalSeries(series))
+}
+
+func (h *VitalReadingHandler) Update(c *gin.Context) {
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		VitalType             *enums.VitalType `json:"vital_type"`
-		Unit                  *enums.VitalUnit `json:"unit"`
-		ValueNumeric          *float64         `json:"value_numeric"`
-		ValueText             *string          `json:"value_text"`
-		IsAbnormal            *bool            `json:"is_abnormal"`
-		DeviationFromBaseline *float64         `json:"deviation_from_baseline"`
+	units, ok := h.preferredUnits(c)
+	if !ok {
+		return
 	}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body dto.UpdateVitalReadingRequest
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -152,36 +206,41 @@ func (h *VitalReadingHandler) Update(c *gin.Context) {
 		Unit:                  body.Unit,
 		ValueNumeric:          body.ValueNumeric,
 		ValueText:             body.ValueText,
+		Systolic:              body.Systolic,
+		Diastolic:             body.Diastolic,
+		Context:               body.Context,
 		IsAbnormal:            body.IsAbnormal,
 		DeviationFromBaseline: body.DeviationFromBaseline,
 	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "vital reading not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update vital reading: " + err.Error()})
+		handleError(c, err, errs.ErrVitalReadingNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, reading)
+	c.JSON(http.StatusOK, dto.NewVitalReading(units.Apply(reading)))
 }
 
 func (h *VitalReadingHandler) Delete(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+	id, ok := uuidParam(c, "id")
+	if !ok {
 		return
 	}
 
 	if err := h.vitalReadingService.Delete(id); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "vital reading not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete vital reading: " + err.Error()})
+		handleError(c, err, errs.ErrVitalReadingNotFound)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.Status(http.StatusNoContent)
+}
+
+// preferredUnits reads the optional units query parameter, e.g.
+// units=MMOL/L,°F, which converts matching readings in the response.
+func (h *VitalReadingHandler) preferredUnits(c *gin.Context) (services.PreferredUnits, bool) {
+	units, err := h.vitalReadingService.PreferredUnits(c.Query("units"))
+	if err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+	return units, true
 }
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type VitalTypeHandler struct {
+	catalog *services.VitalCatalog
+}
+
+func NewVitalTypeHandler(catalog *services.VitalCatalog) *VitalTypeHandler {
+	return &VitalTypeHandler{catalog: catalog}
+}
+
+func (h *VitalTypeHandler) Create(c *gin.Context) {
+	var body dto.CreateVitalTypeRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	def, err := h.catalog.Create(services.VitalTypeInput{
+		Code:          body.Code,
+		DisplayName:   body.DisplayName,
+		Description:   body.Description,
+		CanonicalUnit: body.CanonicalUnit,
+		Units:         body.Units,
+		Contexts:      body.Contexts,
+		MinValue:      body.MinValue,
+		MaxValue:      body.MaxValue,
+		Range:         vitalRange(body.Range),
+		IsActive:      body.IsActive,
+	})
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewVitalType(def))
+}
+
+func (h *VitalTypeHandler) List(c *gin.Context) {
+	includeInactive, ok := boolQuery(c, "include_inactive", false)
+	if !ok {
+		return
+	}
+
+	defs, err := h.catalog.List(includeInactive)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+
+	items := make([]dto.VitalType, len(defs))
+	for i := range defs {
+		items[i] = dto.NewVitalType(&defs[i])
+	}
+	c.JSON(http.StatusOK, dto.List[dto.VitalType]{Items: items})
+}
+
+func (h *VitalTypeHandler) Get(c *gin.Context) {
+	def, err := h.catalog.GetByCode(codeParam(c))
+	if err != nil {
+		handleError(c, err, errs.ErrVitalTypeNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewVitalType(def))
+}
+
+func (h *VitalTypeHandler) Update(c *gin.Context) {
+	var body dto.UpdateVitalTypeRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	input := services.UpdateVitalTypeInput{
+		DisplayName: body.DisplayName,
+		Description: body.Description,
+		Units:       body.Units,
+		Contexts:    body.Contexts,
+		MinValue:    body.MinValue,
+		MaxValue:    body.MaxValue,
+		IsActive:    body.IsActive,
+	}
+	if body.Range != nil {
+		r := vitalRange(*body.Range)
+		input.Range = &r
+	}
+
+	def, err := h.catalog.Update(codeParam(c), input)
+	if err != nil {
+		handleError(c, err, errs.ErrVitalTypeNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewVitalType(def))
+}
+
+func (h *VitalTypeHandler) Delete(c *gin.Context) {
+	if err := h.catalog.Delete(codeParam(c)); err != nil {
+		handleError(c, err, errs.ErrVitalTypeNotFound)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func codeParam(c *gin.Context) enums.VitalType {
+	return enums.VitalType(strings.ToUpper(c.Param("code")))
+}
+
+func vitalRange(r dto.VitalTypeRange) services.VitalRange {
+	return services.VitalRange{
+		Low:                 r.Low,
+		High:                r.High,
+		CriticalLow:         r.CriticalLow,
+		CriticalHigh:        r.CriticalHigh,
+		MaxDeviationPercent: r.MaxDeviationPercent,
+	}
+}
@@ -1,36 +1,39 @@
 package middlewares
 
 import (
-	"net/http"
-
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
 	"github.com/erkinov-wtf/vital-sync/internal/pkg/jwt"
 	"github.com/gin-gonic/gin"
 )
 
+// CallerIDKey is the context key of the authenticated user's id.
+const CallerIDKey = "caller_id"
+
 func Auth(authSvc *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			_ = c.Error(errs.ErrMissingAuthHeader)
 			c.Abort()
 			return
 		}
 
 		tokenString, err := jwt.ExtractBearerToken(authHeader)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			_ = c.Error(errs.ErrInvalidToken.WithMessage(err.Error()))
 			c.Abort()
 			return
 		}
 
 		// Validate the token
-		_, err = authSvc.ValidateAccessToken(tokenString)
+		claims, err := authSvc.ValidateAccessToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			_ = c.Error(errs.ErrInvalidToken.WithMessage("invalid or expired token"))
 			c.Abort()
 			return
 		}
+		c.Set(CallerIDKey, claims.UserID)
 
 		c.Next()
 	}
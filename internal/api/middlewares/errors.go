@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypeBase    = "https://vital-sync.uz/problems/"
+)
+
+// Problem is an RFC 7807 problem details document extended with a stable
+// error code and field-level validation errors.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Errors   []errs.FieldError `json:"errors,omitempty"`
+}
+
+// ErrorHandler renders the last error recorded with c.Error as problem+json.
+// Handlers and middlewares only need to call c.Error and return.
+func ErrorHandler(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		appErr := toAppError(err)
+		if appErr.Status >= http.StatusInternalServerError {
+			logger.Error("request failed",
+				"method", c.Request.Method,
+				"path", c.FullPath(),
+				"code", appErr.Code,
+				"error", err,
+			)
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+
+		WriteProblem(c, appErr)
+	}
+}
+
+// Recovery turns panics into INTERNAL_ERROR problems rendered by ErrorHandler.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		_ = c.Error(errs.ErrInternal.Wrap(panicError{value: recovered}))
+		c.Abort()
+	})
+}
+
+// NoRoute and NoMethod are used as engine fallbacks so unknown routes share the envelope.
+func NoRoute(c *gin.Context) {
+	_ = c.Error(errs.ErrRouteNotFound)
+}
+
+func NoMethod(c *gin.Context) {
+	_ = c.Error(errs.ErrMethodNotAllowed)
+}
+
+// WriteProblem writes appErr as a problem+json response and aborts the chain.
+func WriteProblem(c *gin.Context, appErr *errs.AppError) {
+	problem := Problem{
+		Type:     problemTypeBase + strings.ToLower(strings.ReplaceAll(appErr.Code, "_", "-")),
+		Title:    http.StatusText(appErr.Status),
+		Status:   appErr.Status,
+		Detail:   appErr.Message,
+		Instance: c.Request.URL.Path,
+		Code:     appErr.Code,
+		Errors:   appErr.Fields,
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(appErr.Status, problem)
+}
+
+func toAppError(err error) *errs.AppError {
+	if appErr := errs.As(err); appErr != nil {
+		return appErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errs.ErrNotFound
+	}
+	return errs.ErrInternal.Wrap(err)
+}
+
+type panicError struct {
+	value any
+}
+
+func (p panicError) Error() string {
+	if err, ok := p.value.(error); ok {
+		return "panic: " + err.Error()
+	}
+	if s, ok := p.value.(string); ok {
+		return "panic: " + s
+	}
+	return "panic"
+}
@@ -0,0 +1,170 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency makes POST, PUT and PATCH requests carrying an Idempotency-Key
+// header safe to retry. The first request runs normally and its successful
+// response is stored; a retry by the same caller on the same route with the
+// same key, body and If-Match precondition gets the stored response back,
+// ETag and Location included. The key stays locked while the first request
+// runs, however long it takes. Failed requests release the key so they can be
+// retried for real.
+func Idempotency(svc *services.IdempotencyService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !idempotentMethods[c.Request.Method] {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			_ = c.Error(errs.InvalidField("header."+IdempotencyKeyHeader, "must be at most 255 characters"))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			_ = c.Error(errs.ErrMalformedBody.Wrap(err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, replay, err := svc.Begin(services.BeginIdempotentInput{
+			Caller:      idempotencyCaller(c),
+			Method:      c.Request.Method,
+			Route:       c.FullPath(),
+			Key:         key,
+			Path:        c.Request.URL.Path,
+			RequestHash: requestHash(c.Request.Method, c.Request.URL.RequestURI(), c.GetHeader("If-Match"), body),
+		})
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if replay {
+			c.Header(IdempotencyReplayedHeader, "true")
+			if record.ETag != "" {
+				c.Header("ETag", record.ETag)
+			}
+			if record.Location != "" {
+				c.Header("Location", record.Location)
+			}
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		stop := holdIdempotencyKey(svc, record.ID, key, logger)
+		c.Next()
+		stop()
+		c.Writer = recorder.ResponseWriter
+
+		status := recorder.Status()
+		if len(c.Errors) > 0 || !recorder.Written() || status >= http.StatusBadRequest {
+			if err := svc.Release(record.ID); err != nil {
+				logger.Error("failed to release idempotency key", "key", key, "error", err)
+			}
+			return
+		}
+
+		if err := svc.Complete(record.ID, services.CompleteIdempotentInput{
+			StatusCode:   status,
+			ContentType:  recorder.Header().Get("Content-Type"),
+			ETag:         recorder.Header().Get("ETag"),
+			Location:     recorder.Header().Get("Location"),
+			ResponseBody: recorder.body.Bytes(),
+		}); err != nil {
+			logger.Error("failed to store idempotent response", "key", key, "error", err)
+		}
+	}
+}
+
+// holdIdempotencyKey renews the key's lock until stop is called, so a retry
+// cannot run the request again while it is still running.
+func holdIdempotencyKey(svc *services.IdempotencyService, id uuid.UUID, key string, logger *slog.Logger) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(services.IdempotencyRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := svc.Renew(id); err != nil {
+					logger.Error("failed to renew idempotency key", "key", key, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodPost:  true,
+	http.MethodPut:   true,
+	http.MethodPatch: true,
+}
+
+// idempotencyCaller scopes keys to who sent the request: the authenticated
+// user, else a fingerprint of the credentials sent, else nobody in particular.
+func idempotencyCaller(c *gin.Context) string {
+	if id := c.GetString(CallerIDKey); id != "" {
+		return "user:" + id
+	}
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return "credentials:" + hex.EncodeToString(sum[:16])
+	}
+	return ""
+}
+
+// requestHash fingerprints what the key was first used for: method, URI,
+// If-Match precondition and body.
+func requestHash(method, uri, ifMatch string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + uri + "\n"))
+	h.Write([]byte("If-Match: " + ifMatch + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
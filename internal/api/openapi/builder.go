@@ -0,0 +1,196 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+const jsonContentType = "application/json"
+
+var ginParam = regexp.MustCompile(`[:*](\w+)`)
+
+// Route documents one gin route. Path uses gin syntax relative to the API base path.
+type Route struct {
+	Method  string
+	Path    string
+	ID      string
+	Summary string
+	Tag     string
+	Query   []Parameter
+	Body    interface{} // zero value of the request type, nil when there is no JSON body
+	// BodyTypes lists media types accepted besides JSON, documented as text.
+	// With a nil Body they are the only ones accepted.
+	BodyTypes []string
+	Response  interface{} // zero value of the response type, nil for 204
+	// ResponseType is the media type of the response; JSON when empty.
+	ResponseType string
+	Status       int
+
+	// PathParams overrides the default uuid schema of path parameters.
+	PathParams map[string]*Schema
+
+	// Versioned marks a resource with optimistic concurrency: responses carry
+	// an ETag and PUT/PATCH requests must send it back in If-Match.
+	Versioned bool
+}
+
+// Spec is a built document plus a lookup of operations by gin route.
+type Spec struct {
+	Document Document
+	basePath string
+	byRoute  map[string]*Operation
+}
+
+func Build(info Info, basePath string, routes []Route, enums map[reflect.Type][]string) *Spec {
+	gen := newGenerator(enums)
+	problem := gen.schemaOf(middlewares.Problem{})
+
+	spec := &Spec{
+		Document: Document{
+			OpenAPI: "3.0.3",
+			Info:    info,
+			Servers: []Server{{URL: basePath}},
+			Paths:   map[string]PathItem{},
+			Components: Components{
+				Responses: map[string]Response{
+					"Problem": {
+						Description: "Error described as RFC 7807 problem details",
+						Content:     map[string]MediaType{"application/problem+json": {Schema: problem}},
+					},
+				},
+			},
+		},
+		basePath: basePath,
+		byRoute:  map[string]*Operation{},
+	}
+
+	tags := map[string]bool{}
+	for _, r := range routes {
+		op := &Operation{
+			OperationID: r.ID,
+			Summary:     r.Summary,
+			Tags:        []string{r.Tag},
+			Responses:   map[string]Response{},
+		}
+		tags[r.Tag] = true
+
+		for _, m := range ginParam.FindAllStringSubmatch(r.Path, -1) {
+			schema := &Schema{Type: "string", Format: "uuid"}
+			if override, ok := r.PathParams[m[1]]; ok {
+				schema = override
+			}
+			op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: schema})
+		}
+		op.Parameters = append(op.Parameters, r.Query...)
+		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        middlewares.IdempotencyKeyHeader,
+				In:          "header",
+				Description: "retries with the same key, body and If-Match replay the first response",
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		if r.Versioned && (r.Method == http.MethodPut || r.Method == http.MethodPatch) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        "If-Match",
+				In:          "header",
+				Description: "ETag of the version being modified; 412 if it is stale",
+				Required:    true,
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		if r.Body != nil || len(r.BodyTypes) > 0 {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+			if r.Body != nil {
+				op.RequestBody.Content[jsonContentType] = MediaType{Schema: gen.schemaOf(r.Body)}
+			}
+			for _, t := range r.BodyTypes {
+				op.RequestBody.Content[t] = MediaType{Schema: &Schema{Type: "string"}}
+			}
+		}
+
+		status := r.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := Response{Description: http.StatusText(status)}
+		if r.Response != nil {
+			mediaType := r.ResponseType
+			if mediaType == "" {
+				mediaType = jsonContentType
+			}
+			resp.Content = map[string]MediaType{mediaType: {Schema: gen.schemaOf(r.Response)}}
+		}
+		if r.Versioned {
+			resp.Headers = map[string]Header{"ETag": {Description: "current resource version", Schema: &Schema{Type: "string"}}}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+		op.Responses["default"] = Response{Ref: "#/components/responses/Problem"}
+
+		path := ginParam.ReplaceAllString(r.Path, "{$1}")
+		item, ok := spec.Document.Paths[path]
+		if !ok {
+			item = PathItem{}
+			spec.Document.Paths[path] = item
+		}
+		item[strings.ToLower(r.Method)] = op
+		spec.byRoute[routeKey(r.Method, basePath+r.Path)] = op
+	}
+
+	for tag := range tags {
+		spec.Document.Tags = append(spec.Document.Tags, Tag{Name: tag})
+	}
+	sort.Slice(spec.Document.Tags, func(i, j int) bool { return spec.Document.Tags[i].Name < spec.Document.Tags[j].Name })
+	spec.Document.Components.Schemas = gen.schemas
+
+	return spec
+}
+
+// Operation returns the documented operation for a gin method and full path.
+func (s *Spec) Operation(method, fullPath string) (*Operation, bool) {
+	op, ok := s.byRoute[routeKey(method, fullPath)]
+	return op, ok
+}
+
+// CheckRoutes reports routes registered under the base path that are missing
+// from the spec, and documented operations that have no route.
+func (s *Spec) CheckRoutes(routes gin.RoutesInfo) error {
+	registered := map[string]bool{}
+	var problems []string
+
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, s.basePath+"/") && r.Path != s.basePath {
+			continue
+		}
+		key := routeKey(r.Method, r.Path)
+		registered[key] = true
+		if _, ok := s.byRoute[key]; !ok {
+			problems = append(problems, "undocumented route "+key)
+		}
+	}
+	for key := range s.byRoute {
+		if !registered[key] {
+			problems = append(problems, "documented but not registered "+key)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("openapi spec drifted from routes:\n  %s", strings.Join(problems, "\n  "))
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
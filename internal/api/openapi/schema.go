@@ -0,0 +1,183 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	uuidType      = reflect.TypeOf(uuid.UUID{})
+	jsonbType     = reflect.TypeOf(models.JSONB{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// generator turns Go types into schemas, collecting named structs as components.
+type generator struct {
+	schemas map[string]*Schema
+	enums   map[reflect.Type][]string
+}
+
+func newGenerator(enums map[reflect.Type][]string) *generator {
+	return &generator{schemas: map[string]*Schema{}, enums: enums}
+}
+
+func (g *generator) schemaOf(v interface{}) *Schema {
+	return g.schemaFor(reflect.TypeOf(v))
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		inner := g.schemaFor(t.Elem())
+		if inner.Ref != "" {
+			return &Schema{AllOf: []*Schema{inner}, Nullable: true}
+		}
+		cp := *inner
+		cp.Nullable = true
+		return &cp
+	}
+
+	if values, ok := g.enums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case jsonbType, rawJSONType, interfaceType:
+		return &Schema{Description: "arbitrary JSON"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return &Schema{Description: "arbitrary JSON"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = &Schema{} // placeholder for recursive types
+			g.schemas[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schemaFor(f.Type)
+		applyBinding(prop, f.Tag.Get("binding"))
+		s.Properties[name] = prop
+
+		if hasRule(f.Tag.Get("binding"), "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// applyBinding mirrors the validator rules we rely on into the schema.
+func applyBinding(s *Schema, binding string) {
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "min", "gte":
+			if f, err := strconv.ParseFloat(value, 64); err == nil && isNumeric(s) {
+				s.Minimum = &f
+			}
+		case "max", "lte":
+			if f, err := strconv.ParseFloat(value, 64); err == nil && isNumeric(s) {
+				s.Maximum = &f
+			}
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		}
+	}
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumeric(s *Schema) bool {
+	return s.Type == "integer" || s.Type == "number"
+}
+
+// componentName gives generic wrappers readable names, e.g. Page[dto.User] -> UserPage.
+// FHIR resources are prefixed so they do not clash with the API's own types.
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	if strings.HasSuffix(t.PkgPath(), "/fhir") {
+		return "FHIR" + name
+	}
+	open := strings.Index(name, "[")
+	if open < 0 {
+		return name
+	}
+	base := name[:open]
+	arg := name[open+1 : len(name)-1]
+	if dot := strings.LastIndex(arg, "."); dot >= 0 {
+		arg = arg[dot+1:]
+	}
+	return arg + base
+}
@@ -0,0 +1,120 @@
+package openapi
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ValidateRequests checks path and query parameters and the body content type
+// against the documented operation before the handler runs. Body fields are
+// validated by the same request types the schemas are generated from.
+func (s *Spec) ValidateRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, ok := s.Operation(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var fields []errs.FieldError
+		for _, p := range op.Parameters {
+			var raw string
+			var present bool
+			switch p.In {
+			case "path":
+				raw = c.Param(p.Name)
+				present = raw != ""
+			case "query":
+				raw, present = c.GetQuery(p.Name)
+			default:
+				continue
+			}
+
+			if !present || raw == "" {
+				if p.Required {
+					fields = append(fields, errs.FieldError{Field: p.In + "." + p.Name, Message: "is required"})
+				}
+				continue
+			}
+			if msg := checkValue(p.Schema, raw); msg != "" {
+				fields = append(fields, errs.FieldError{Field: p.In + "." + p.Name, Message: msg})
+			}
+		}
+		if len(fields) > 0 {
+			_ = c.Error(errs.Validation(fields...))
+			c.Abort()
+			return
+		}
+
+		if op.RequestBody != nil && c.Request.ContentLength != 0 {
+			mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+			if _, ok := op.RequestBody.Content[mediaType]; err != nil || !ok {
+				_ = c.Error(unsupportedMediaType(op.RequestBody))
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func unsupportedMediaType(body *RequestBody) error {
+	if len(body.Content) == 1 {
+		return errs.ErrUnsupportedMediaType
+	}
+	types := make([]string, 0, len(body.Content))
+	for t := range body.Content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return errs.ErrUnsupportedMediaType.WithMessage("request body must be one of " + strings.Join(types, ", "))
+}
+
+func checkValue(schema *Schema, raw string) string {
+	if schema == nil {
+		return ""
+	}
+
+	if len(schema.Enum) > 0 {
+		for _, v := range schema.Enum {
+			if v == raw {
+				return ""
+			}
+		}
+		return "must be one of " + "[" + strings.Join(schema.Enum, " ") + "]"
+	}
+
+	switch {
+	case schema.Format == "uuid":
+		if _, err := uuid.Parse(raw); err != nil {
+			return "must be a valid UUID"
+		}
+	case schema.Type == "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		if schema.Minimum != nil && float64(n) < *schema.Minimum {
+			return "must be at least " + strconv.FormatFloat(*schema.Minimum, 'f', -1, 64)
+		}
+		if schema.Maximum != nil && float64(n) > *schema.Maximum {
+			return "must be at most " + strconv.FormatFloat(*schema.Maximum, 'f', -1, 64)
+		}
+	case schema.Type == "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return "must be a number"
+		}
+	case schema.Type == "boolean":
+		if _, err := strconv.ParseBool(raw); err != nil {
+			return "must be a boolean"
+		}
+	}
+	return ""
+}
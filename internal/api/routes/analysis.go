@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerAnalysisRoutes(r *gin.RouterGroup, handler *handlers.AnalysisHandler) {
+	r.POST("/checkins/:id/analyze", handler.Analyze)
+}
@@ -10,6 +10,9 @@ func registerCheckinRoutes(r *gin.RouterGroup, handler *handlers.CheckinHandler)
 	{
 		checkins.POST("/start", handler.StartCheckin)
 		checkins.POST("/:id/end", handler.EndCheckin)
+		checkins.POST("/:id/cancel", handler.CancelCheckin)
+		checkins.POST("/:id/fail", handler.FailCheckin)
+		checkins.GET("/:id/transitions", handler.ListTransitions)
 		checkins.GET("/active/:patientId", handler.GetActiveCheckin)
 		checkins.GET("/completed/:patientId", handler.ListCompletedCheckins)
 		checkins.POST("/:id/questions", handler.AddQuestions)
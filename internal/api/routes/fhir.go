@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerFHIRRoutes(r *gin.RouterGroup, handler *handlers.FHIRHandler) {
+	resources := r.Group("/fhir")
+	{
+		resources.GET("/metadata", handler.Metadata)
+		resources.GET("/Patient/:id", handler.Patient)
+		resources.GET("/Patient/:id/$everything", handler.Everything)
+		resources.GET("/Observation", handler.SearchObservations)
+		resources.GET("/Observation/:id", handler.Observation)
+		resources.GET("/Flag/:id", handler.Flag)
+		resources.GET("/DetectedIssue/:id", handler.DetectedIssue)
+		resources.GET("/QuestionnaireResponse/:id", handler.QuestionnaireResponse)
+		resources.POST("/$import", handler.Import)
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerHL7Routes(r *gin.RouterGroup, handler *handlers.HL7Handler) {
+	messages := r.Group("/hl7/messages")
+	{
+		messages.POST("", handler.Receive)
+		messages.GET("", handler.ListMessages)
+		messages.GET("/:id", handler.GetMessage)
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerInstrumentRoutes(r *gin.RouterGroup, handler *handlers.InstrumentHandler) {
+	instruments := r.Group("/instruments")
+	{
+		instruments.GET("", handler.List)
+		instruments.GET("/:code", handler.Get)
+	}
+
+	r.GET("/patients/:id/instrument-scores", handler.History)
+}
@@ -0,0 +1,458 @@
+package routes
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/dto"
+	"github.com/erkinov-wtf/vital-sync/internal/api/openapi"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+)
+
+const apiBasePath = "/api/v1"
+
+// apiSpec documents every route registered under apiBasePath; the routes
+// tests fail when the two drift apart.
+func apiSpec() *openapi.Spec {
+	return openapi.Build(openapi.Info{
+		Title:   "vital-sync API",
+		Version: "1.0.0",
+	}, apiBasePath, apiRoutes(), enumValues())
+}
+
+func apiRoutes() []openapi.Route {
+	var routes []openapi.Route
+	routes = append(routes, organizationDocs()...)
+	routes = append(routes, userDocs()...)
+	routes = append(routes, checkinDocs()...)
+	routes = append(routes, checkinScheduleDocs()...)
+	routes = append(routes, vitalReadingDocs()...)
+	routes = append(routes, alertDocs()...)
+	routes = append(routes, thresholdRuleDocs()...)
+	routes = append(routes, vitalTypeDocs()...)
+	routes = append(routes, fhirDocs()...)
+	routes = append(routes, hl7Docs()...)
+	routes = append(routes, questionnaireDocs()...)
+	routes = append(routes, instrumentDocs()...)
+	routes = append(routes, reviewQueueDocs()...)
+	routes = append(routes, searchDocs()...)
+	routes = append(routes, analysisDocs()...)
+	return routes
+}
+
+func organizationDocs() []openapi.Route {
+	const tag = "organizations"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/organizations", ID: "createOrganization", Summary: "Create an organization", Tag: tag,
+			Body: dto.CreateOrganizationRequest{}, Response: dto.Organization{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/organizations", ID: "listOrganizations", Summary: "List organizations", Tag: tag,
+			Query: withPageQuery(boolQuery("include_inactive")), Response: pagination.Page[dto.Organization]{}},
+		{Method: http.MethodGet, Path: "/organizations/:id", ID: "getOrganization", Summary: "Get an organization", Tag: tag,
+			Response: dto.Organization{}},
+		{Method: http.MethodPut, Path: "/organizations/:id", ID: "updateOrganization", Summary: "Update an organization", Tag: tag,
+			Body: dto.UpdateOrganizationRequest{}, Response: dto.Organization{}},
+		{Method: http.MethodDelete, Path: "/organizations/:id", ID: "deleteOrganization", Summary: "Delete an organization", Tag: tag,
+			Status: http.StatusNoContent},
+	}
+}
+
+func userDocs() []openapi.Route {
+	const doctors, patients = "doctors", "patients"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/users/doctors", ID: "createDoctor", Summary: "Create a doctor and assign an organization", Tag: doctors,
+			Body: dto.CreateDoctorRequest{}, Response: dto.User{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/users/doctors", ID: "listDoctors", Summary: "List doctors", Tag: doctors,
+			Query: withPageQuery(boolQuery("include_inactive")), Response: pagination.Page[dto.User]{}},
+		{Method: http.MethodGet, Path: "/users/doctors/:id", ID: "getDoctor", Summary: "Get a doctor", Tag: doctors,
+			Response: dto.User{}},
+		{Method: http.MethodGet, Path: "/users/doctors/:id/organizations", ID: "listDoctorOrganizations", Summary: "List a doctor's organizations", Tag: doctors,
+			Query: withPageQuery(boolQuery("include_inactive")), Response: pagination.Page[dto.OrganizationDoctor]{}},
+		{Method: http.MethodPut, Path: "/users/doctors/:id", ID: "updateDoctor", Summary: "Update a doctor", Tag: doctors,
+			Body: dto.UpdateDoctorRequest{}, Response: dto.User{}},
+		{Method: http.MethodDelete, Path: "/users/doctors/:id", ID: "deleteDoctor", Summary: "Delete a doctor", Tag: doctors,
+			Status: http.StatusNoContent},
+
+		{Method: http.MethodPost, Path: "/users/patients", ID: "createPatient", Summary: "Create a patient user", Tag: patients,
+			Body: dto.CreatePatientRequest{}, Response: dto.User{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/users/patients", ID: "listPatients", Summary: "List patient users", Tag: patients,
+			Query: withPageQuery(boolQuery("include_inactive")), Response: pagination.Page[dto.User]{}},
+		{Method: http.MethodGet, Path: "/users/patients/:id", ID: "getPatient", Summary: "Get a patient's medical record by user id", Tag: patients,
+			Response: dto.Patient{}, Versioned: true},
+		{Method: http.MethodGet, Path: "/users/patients/:id/full", ID: "getPatientComplete", Summary: "Get a patient with schedule, checkins and vitals", Tag: patients,
+			Query: withPageQuery(), Response: dto.PatientComplete{}},
+		{Method: http.MethodPost, Path: "/users/patients/:id/medical", ID: "createPatientMedicalInfo", Summary: "Create a patient's medical record", Tag: patients,
+			Body: dto.CreatePatientMedicalRequest{}, Response: dto.Patient{}, Status: http.StatusCreated, Versioned: true},
+		{Method: http.MethodPut, Path: "/users/patients/:id", ID: "updatePatient", Summary: "Update a patient user", Tag: patients,
+			Body: dto.UpdatePatientRequest{}, Response: dto.User{}},
+		{Method: http.MethodPut, Path: "/users/patients/:id/medical", ID: "updatePatientMedicalInfo", Summary: "Update a patient's medical record", Tag: patients,
+			Body: dto.UpdatePatientMedicalRequest{}, Response: dto.Patient{}, Versioned: true},
+		{Method: http.MethodGet, Path: "/users/patients/telegram/:username", ID: "getUserByTelegramUsername", Summary: "Find a user by Telegram username", Tag: patients,
+			Response: dto.User{}, PathParams: map[string]*openapi.Schema{"username": {Type: "string"}}},
+	}
+}
+
+func checkinDocs() []openapi.Route {
+	const tag = "checkins"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/checkins/start", ID: "startCheckin", Summary: "Start a checkin", Tag: tag,
+			Body: dto.StartCheckinRequest{}, Response: dto.Checkin{}, Status: http.StatusCreated, Versioned: true},
+		{Method: http.MethodPost, Path: "/checkins/:id/end", ID: "endCheckin", Summary: "Complete the active checkin of a patient user", Tag: tag,
+			Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodPost, Path: "/checkins/:id/cancel", ID: "cancelCheckin", Summary: "Cancel an active checkin", Tag: tag,
+			Body: dto.CheckinTransitionRequest{}, Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodPost, Path: "/checkins/:id/fail", ID: "failCheckin", Summary: "Mark an active checkin as failed", Tag: tag,
+			Body: dto.CheckinTransitionRequest{}, Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodGet, Path: "/checkins/:id/transitions", ID: "listCheckinTransitions", Summary: "List the status history of a checkin", Tag: tag,
+			Response: dto.List[dto.CheckinTransition]{}},
+		{Method: http.MethodGet, Path: "/checkins/active/:patientId", ID: "getActiveCheckin", Summary: "Get the active checkin of a patient user", Tag: tag,
+			Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodGet, Path: "/checkins/completed/:patientId", ID: "listCompletedCheckins", Summary: "List completed checkins of a patient user", Tag: tag,
+			Query: withPageQuery(), Response: pagination.Page[dto.Checkin]{}},
+		{Method: http.MethodPost, Path: "/checkins/:id/questions", ID: "addCheckinQuestions", Summary: "Add questions to a checkin", Tag: tag,
+			Body: dto.AddCheckinQuestionsRequest{}, Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodPost, Path: "/checkins/:id/answers", ID: "addCheckinAnswers", Summary: "Add answers to a checkin", Tag: tag,
+			Body: dto.AddCheckinAnswersRequest{}, Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodPatch, Path: "/checkins/:id/review", ID: "reviewCheckin", Summary: "Record a doctor's review", Tag: tag,
+			Body: dto.ReviewCheckinRequest{}, Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodPatch, Path: "/checkins/:id/analysis", ID: "updateCheckinAnalysis", Summary: "Store AI analysis for a completed or analyzed checkin", Tag: tag,
+			Body: dto.UpdateCheckinAnalysisRequest{}, Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodGet, Path: "/checkins/:id", ID: "getCheckin", Summary: "Get a checkin", Tag: tag,
+			Response: dto.Checkin{}, Versioned: true},
+		{Method: http.MethodPost, Path: "/checkins/start/manual/:patientId", ID: "startManualCheckin", Summary: "Start a checkin and notify the bot", Tag: tag,
+			Query:    []openapi.Parameter{{Name: "type", In: "query", Description: "checkin type passed to the bot", Schema: &openapi.Schema{Type: "string"}}},
+			Response: dto.Checkin{}, Status: http.StatusCreated, Versioned: true},
+	}
+}
+
+func checkinScheduleDocs() []openapi.Route {
+	const tag = "checkin-schedules"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/checkin-schedules", ID: "createCheckinSchedule", Summary: "Create a patient's checkin schedule", Tag: tag,
+			Body: dto.CreateScheduleRequest{}, Response: dto.CheckinSchedule{}, Status: http.StatusCreated, Versioned: true},
+		{Method: http.MethodGet, Path: "/checkin-schedules", ID: "listCheckinSchedules", Summary: "List checkin schedules", Tag: tag,
+			Query: withPageQuery(boolQuery("include_inactive"), uuidQuery("patient_id")), Response: pagination.Page[dto.CheckinSchedule]{}},
+		{Method: http.MethodGet, Path: "/checkin-schedules/:id", ID: "getCheckinSchedule", Summary: "Get a checkin schedule", Tag: tag,
+			Response: dto.CheckinSchedule{}, Versioned: true},
+		{Method: http.MethodPut, Path: "/checkin-schedules/:id", ID: "updateCheckinSchedule", Summary: "Update a checkin schedule", Tag: tag,
+			Body: dto.UpdateScheduleRequest{}, Response: dto.CheckinSchedule{}, Versioned: true},
+		{Method: http.MethodDelete, Path: "/checkin-schedules/:id", ID: "deleteCheckinSchedule", Summary: "Delete a checkin schedule", Tag: tag,
+			Status: http.StatusNoContent},
+	}
+}
+
+func vitalReadingDocs() []openapi.Route {
+	const tag = "vital-readings"
+	units := openapi.Parameter{Name: "units", In: "query", Description: "comma separated preferred units, e.g. MMOL/L,°F,LB; matching readings are converted",
+		Schema: &openapi.Schema{Type: "string"}}
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/vital-readings", ID: "createVitalReading", Summary: "Record a vital reading in any supported unit", Tag: tag,
+			Query: []openapi.Parameter{units}, Body: dto.CreateVitalReadingRequest{}, Response: dto.VitalReading{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/vital-readings", ID: "listVitalReadings", Summary: "List vital readings", Tag: tag,
+			Query: withPageQuery(
+				uuidQuery("patient_id"),
+				uuidQuery("checkin_id"),
+				openapi.Parameter{Name: "vital_type", In: "query", Description: "vital type code from /vital-types", Schema: &openapi.Schema{Type: "string"}},
+				boolQuery("only_abnormal"),
+				enumQuery("component", enums.VitalComponent("")),
+				numberQuery("min_value", "inclusive lower bound on the selected component, in the canonical unit"),
+				numberQuery("max_value", "inclusive upper bound on the selected component, in the canonical unit"),
+				units,
+			),
+			Response: pagination.Page[dto.VitalReading]{}},
+		{Method: http.MethodGet, Path: "/vital-readings/:id", ID: "getVitalReading", Summary: "Get a vital reading", Tag: tag,
+			Query: []openapi.Parameter{units}, Response: dto.VitalReading{}},
+		{Method: http.MethodPut, Path: "/vital-readings/:id", ID: "updateVitalReading", Summary: "Update a vital reading", Tag: tag,
+			Query: []openapi.Parameter{units}, Body: dto.UpdateVitalReadingRequest{}, Response: dto.VitalReading{}},
+		{Method: http.MethodDelete, Path: "/vital-readings/:id", ID: "deleteVitalReading", Summary: "Delete a vital reading", Tag: tag,
+			Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/patients/:id/vital-readings/batch", ID: "createVitalReadingBatch", Summary: "Upload readings for a patient user as JSON, NDJSON or CSV; each row is validated on its own", Tag: tag,
+			Query: []openapi.Parameter{
+				enumQuery("source", enums.VitalSource("")),
+				{Name: "device", In: "query", Description: "device identifier for rows that do not set one", Schema: &openapi.Schema{Type: "string"}},
+			},
+			Body: dto.VitalReadingBatchRequest{}, BodyTypes: []string{"text/csv", "application/x-ndjson"}, Response: dto.VitalReadingBatchResult{}},
+		{Method: http.MethodGet, Path: "/patients/:id/vitals/series", ID: "getVitalSeries", Summary: "Bucketed vital aggregates and trends for a patient user", Tag: tag,
+			Query: []openapi.Parameter{
+				{Name: "type", In: "query", Description: "vital type code; all types when omitted", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "from", In: "query", Description: "RFC 3339 timestamp or YYYY-MM-DD, inclusive; defaults to 30 days before to", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "to", In: "query", Description: "RFC 3339 timestamp or YYYY-MM-DD, exclusive; defaults to now", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "bucket", In: "query", Description: "bucket width in the patient's timezone", Schema: &openapi.Schema{Type: "string", Enum: []string{"hour", "day", "week", "month"}}},
+				units,
+			},
+			Response: dto.VitalSeries{}},
+	}
+}
+
+func alertDocs() []openapi.Route {
+	const tag = "alerts"
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/alerts/:doctorId", ID: "listDoctorAlerts", Summary: "List alerts for a doctor's patients", Tag: tag,
+			Query: withPageQuery(boolQuery("show_all")), Response: pagination.Page[dto.Alert]{}},
+	}
+}
+
+func thresholdRuleDocs() []openapi.Route {
+	const tag = "threshold-rules"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/threshold-rules", ID: "createThresholdRule", Summary: "Create an organization default or patient-specific threshold rule", Tag: tag,
+			Body: dto.CreateThresholdRuleRequest{}, Response: dto.ThresholdRule{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/threshold-rules", ID: "listThresholdRules", Summary: "List threshold rules", Tag: tag,
+			Query:    withPageQuery(uuidQuery("organization_id"), uuidQuery("patient_id"), boolQuery("include_inactive")),
+			Response: pagination.Page[dto.ThresholdRule]{}},
+		{Method: http.MethodGet, Path: "/threshold-rules/effective/:patientId", ID: "listEffectiveThresholdRules", Summary: "List the rules applied to a patient user after overrides", Tag: tag,
+			Response: dto.List[dto.ThresholdRule]{}},
+		{Method: http.MethodGet, Path: "/threshold-rules/:id", ID: "getThresholdRule", Summary: "Get a threshold rule", Tag: tag,
+			Response: dto.ThresholdRule{}},
+		{Method: http.MethodPut, Path: "/threshold-rules/:id", ID: "updateThresholdRule", Summary: "Update a threshold rule", Tag: tag,
+			Body: dto.UpdateThresholdRuleRequest{}, Response: dto.ThresholdRule{}},
+		{Method: http.MethodDelete, Path: "/threshold-rules/:id", ID: "deleteThresholdRule", Summary: "Delete a threshold rule", Tag: tag,
+			Status: http.StatusNoContent},
+	}
+}
+
+func vitalTypeDocs() []openapi.Route {
+	const tag = "vital-types"
+	code := map[string]*openapi.Schema{"code": {Type: "string", Description: "vital type code, e.g. RESPIRATORY_RATE"}}
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/vital-types", ID: "createVitalType", Summary: "Add a vital type to the catalog", Tag: tag,
+			Body: dto.CreateVitalTypeRequest{}, Response: dto.VitalType{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/vital-types", ID: "listVitalTypes", Summary: "List the vital type catalog", Tag: tag,
+			Query: []openapi.Parameter{boolQuery("include_inactive")}, Response: dto.List[dto.VitalType]{}},
+		{Method: http.MethodGet, Path: "/vital-types/:code", ID: "getVitalType", Summary: "Get a vital type", Tag: tag,
+			Response: dto.VitalType{}, PathParams: code},
+		{Method: http.MethodPut, Path: "/vital-types/:code", ID: "updateVitalType", Summary: "Update a vital type's units, contexts or ranges", Tag: tag,
+			Body: dto.UpdateVitalTypeRequest{}, Response: dto.VitalType{}, PathParams: code},
+		{Method: http.MethodDelete, Path: "/vital-types/:code", ID: "deleteVitalType", Summary: "Delete an unused custom vital type", Tag: tag,
+			Status: http.StatusNoContent, PathParams: code},
+	}
+}
+
+// fhirDocs documents the FHIR R4 export and the patient import. Resources are
+// served as application/fhir+json; errors stay problem details like the rest
+// of the API.
+func fhirDocs() []openapi.Route {
+	const tag = "fhir"
+	date := func(name, description string) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+	}
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/fhir/metadata", ID: "fhirCapabilities", Summary: "Get the FHIR capability statement", Tag: tag,
+			Response: fhir.CapabilityStatement{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/Patient/:id", ID: "fhirReadPatient", Summary: "Read a patient user as a FHIR Patient", Tag: tag,
+			Response: fhir.Patient{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/Patient/:id/$everything", ID: "fhirPatientEverything", Summary: "Export a patient with all their observations, checkins and alerts", Tag: tag,
+			Query: []openapi.Parameter{
+				date("start", "RFC 3339 timestamp or YYYY-MM-DD, inclusive"),
+				date("end", "RFC 3339 timestamp or YYYY-MM-DD, exclusive"),
+			},
+			Response: fhir.Bundle{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/Observation", ID: "fhirSearchObservations", Summary: "Search a patient's vital readings as FHIR Observations", Tag: tag,
+			Query: withPageQuery(
+				openapi.Parameter{Name: "patient", In: "query", Required: true, Description: "patient user id or Patient/<id>", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "code", In: "query", Description: "LOINC or vital type code, optionally system|code", Schema: &openapi.Schema{Type: "string"}},
+			),
+			Response: fhir.Bundle{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/Observation/:id", ID: "fhirReadObservation", Summary: "Read a vital reading as a FHIR Observation", Tag: tag,
+			Response: fhir.Observation{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/Flag/:id", ID: "fhirReadFlag", Summary: "Read an alert as a FHIR Flag", Tag: tag,
+			Response: fhir.Flag{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/DetectedIssue/:id", ID: "fhirReadDetectedIssue", Summary: "Read an alert as a FHIR DetectedIssue", Tag: tag,
+			Response: fhir.DetectedIssue{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodGet, Path: "/fhir/QuestionnaireResponse/:id", ID: "fhirReadQuestionnaireResponse", Summary: "Read a checkin as a FHIR QuestionnaireResponse", Tag: tag,
+			Response: fhir.QuestionnaireResponse{}, ResponseType: fhir.ContentType},
+		{Method: http.MethodPost, Path: "/fhir/$import", ID: "fhirImportPatient", Summary: "Onboard a patient from a FHIR Bundle", Tag: tag,
+			Query: []openapi.Parameter{
+				openapi.Parameter{Name: "doctor_id", In: "query", Required: true, Description: "doctor who follows the patient up", Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+				{Name: "telegram_username", In: "query", Required: true, Description: "the patient's Telegram username", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "condition_summary", In: "query", Description: "overrides the bundle's encounter diagnosis", Schema: &openapi.Schema{Type: "string"}},
+				enumQuery("risk_level", enums.RiskLevel("")),
+				enumQuery("monitoring_frequency", enums.MonitoringFrequency("")),
+				{Name: "schedule", In: "query", Description: "also create a checkin schedule for the monitoring frequency", Schema: &openapi.Schema{Type: "boolean"}},
+				{Name: "timezone", In: "query", Description: "IANA time zone of the schedule", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "dry_run", In: "query", Description: "report what would be created without keeping it", Schema: &openapi.Schema{Type: "boolean"}},
+			},
+			Body: fhir.Bundle{}, BodyTypes: []string{fhir.ContentType}, Response: dto.FHIRImportResult{}, Status: http.StatusCreated},
+	}
+}
+
+func hl7Docs() []openapi.Route {
+	const tag = "hl7"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/hl7/messages", ID: "receiveHL7Message", Summary: "Receive an HL7 v2 ADT^A03 or ORU^R01 message and return its ACK", Tag: tag,
+			BodyTypes: []string{hl7.ContentType, "text/plain"}, Response: "", ResponseType: hl7.ContentType},
+		{Method: http.MethodGet, Path: "/hl7/messages", ID: "listHL7Messages", Summary: "List received HL7 messages", Tag: tag,
+			Query: withPageQuery(
+				enumQuery("status", enums.HL7MessageStatus("")),
+				openapi.Parameter{Name: "message_type", In: "query", Description: "e.g. ADT^A03", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "control_id", In: "query", Description: "MSH-10 of the message", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "patient_id", In: "query", Description: "patient user id", Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+			),
+			Response: pagination.Page[dto.HL7Message]{}},
+		{Method: http.MethodGet, Path: "/hl7/messages/:id", ID: "getHL7Message", Summary: "Get a received HL7 message with its ACK", Tag: tag, Response: dto.HL7Message{}},
+	}
+}
+
+// withPageQuery appends the shared pagination parameters to params.
+func questionnaireDocs() []openapi.Route {
+	const tag = "questionnaires"
+	str := func(name, description string) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+	}
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/questionnaire-templates", ID: "createQuestionnaireTemplate", Summary: "Publish a questionnaire template, or the next version of an existing key", Tag: tag,
+			Body: dto.CreateQuestionnaireTemplateRequest{}, Response: dto.QuestionnaireTemplate{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/questionnaire-templates", ID: "listQuestionnaireTemplates", Summary: "List questionnaire templates, the latest version of each key by default", Tag: tag,
+			Query:    withPageQuery(str("key", "template key"), boolQuery("all_versions"), boolQuery("include_inactive")),
+			Response: pagination.Page[dto.QuestionnaireTemplate]{}},
+		{Method: http.MethodGet, Path: "/questionnaire-templates/effective/:patientId", ID: "getEffectiveQuestionnaireTemplate", Summary: "Get the template a patient user's next checkin would use", Tag: tag,
+			Response: dto.QuestionnaireTemplate{}},
+		{Method: http.MethodGet, Path: "/questionnaire-templates/:id", ID: "getQuestionnaireTemplate", Summary: "Get a questionnaire template version", Tag: tag,
+			Response: dto.QuestionnaireTemplate{}},
+		{Method: http.MethodPut, Path: "/questionnaire-templates/:id", ID: "updateQuestionnaireTemplate", Summary: "Rename, describe, retire or restore a template version", Tag: tag,
+			Body: dto.UpdateQuestionnaireTemplateRequest{}, Response: dto.QuestionnaireTemplate{}},
+		{Method: http.MethodPost, Path: "/questionnaire-assignments", ID: "createQuestionnaireAssignment", Summary: "Assign a template to a patient or a condition", Tag: tag,
+			Body: dto.CreateQuestionnaireAssignmentRequest{}, Response: dto.QuestionnaireAssignment{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/questionnaire-assignments", ID: "listQuestionnaireAssignments", Summary: "List questionnaire assignments", Tag: tag,
+			Query:    withPageQuery(str("template_key", "template key"), uuidQuery("patient_id")),
+			Response: pagination.Page[dto.QuestionnaireAssignment]{}},
+		{Method: http.MethodDelete, Path: "/questionnaire-assignments/:id", ID: "deleteQuestionnaireAssignment", Summary: "Delete a questionnaire assignment", Tag: tag,
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/checkins/:id/questionnaire", ID: "getCheckinQuestionnaire", Summary: "Get the questionnaire template version a checkin asks", Tag: tag,
+			Response: dto.QuestionnaireTemplate{}},
+	}
+}
+
+func instrumentDocs() []openapi.Route {
+	const tag = "instruments"
+	code := map[string]*openapi.Schema{"code": {Type: "string", Description: "instrument code, e.g. PHQ9"}}
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/instruments", ID: "listInstruments", Summary: "List the built-in patient-reported outcome instruments", Tag: tag,
+			Response: dto.List[dto.Instrument]{}},
+		{Method: http.MethodGet, Path: "/instruments/:code", ID: "getInstrument", Summary: "Get an instrument with its items, bands and alert cutoffs", Tag: tag,
+			Response: dto.Instrument{}, PathParams: code},
+		{Method: http.MethodGet, Path: "/patients/:id/instrument-scores", ID: "listInstrumentScores", Summary: "List a patient user's instrument scores", Tag: tag,
+			Query:    withPageQuery(enumQuery("instrument", enums.Instrument(""))),
+			Response: pagination.Page[dto.InstrumentScore]{}},
+	}
+}
+
+func reviewQueueDocs() []openapi.Route {
+	const tag = "reviews"
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/review-queue", ID: "getReviewQueue", Summary: "List a doctor's analyzed checkins awaiting review, most urgent first", Tag: tag,
+			Query: withPageQuery(
+				openapi.Parameter{Name: "doctor_id", In: "query", Required: true, Description: "doctor whose queue to list", Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+				openapi.Parameter{Name: "scope", In: "query", Description: "own patients only, or the whole practice (default)", Schema: &openapi.Schema{Type: "string", Enum: []string{string(services.ReviewScopeOwn), string(services.ReviewScopePractice)}}},
+				openapi.Parameter{Name: "unclaimed", In: "query", Description: "leave out checkins other doctors have claimed", Schema: &openapi.Schema{Type: "boolean"}},
+				openapi.Parameter{Name: "overdue", In: "query", Description: "only checkins past their review SLA", Schema: &openapi.Schema{Type: "boolean"}},
+			),
+			Response: dto.ReviewQueue{}},
+		{Method: http.MethodPost, Path: "/checkins/:id/claim", ID: "claimCheckin", Summary: "Claim an analyzed checkin for review, or renew the claim", Tag: tag,
+			Body: dto.ClaimCheckinRequest{}, Response: dto.Checkin{}},
+		{Method: http.MethodPost, Path: "/checkins/:id/unclaim", ID: "unclaimCheckin", Summary: "Release a review claim", Tag: tag,
+			Body: dto.ClaimCheckinRequest{}, Response: dto.Checkin{}},
+	}
+}
+
+func searchDocs() []openapi.Route {
+	const tag = "search"
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/search", ID: "search", Summary: "Full-text search over a doctor's patients' checkin conversations, doctor notes and alerts, best match first", Tag: tag,
+			Query: withPageQuery(
+				openapi.Parameter{Name: "doctor_id", In: "query", Required: true, Description: "doctor whose patients to search", Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+				openapi.Parameter{Name: "q", In: "query", Required: true, Description: "words in Uzbek, Russian or English; quotes for phrases, or, and a leading - to exclude", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "scope", In: "query", Description: "own patients (default), or the whole practice", Schema: &openapi.Schema{Type: "string", Enum: []string{string(services.ReviewScopeOwn), string(services.ReviewScopePractice)}}},
+				openapi.Parameter{Name: "type", In: "query", Description: "search only checkins or only alerts", Schema: &openapi.Schema{Type: "string", Enum: []string{string(services.SearchKindCheckin), string(services.SearchKindAlert)}}},
+				openapi.Parameter{Name: "patient_id", In: "query", Description: "patient user id", Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+				enumQuery("medical_status", enums.MedicalStatus("")),
+			),
+			Response: dto.SearchResult{}},
+	}
+}
+
+func analysisDocs() []openapi.Route {
+	const tag = "checkins"
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/checkins/:id/analyze", ID: "analyzeCheckin", Summary: "Analyze a completed checkin with the configured analyzer, or analyze an analyzed one again", Tag: tag,
+			Response: dto.Checkin{}, Versioned: true},
+	}
+}
+
+func withPageQuery(params ...openapi.Parameter) []openapi.Parameter {
+	minLimit, maxLimit := float64(1), float64(pagination.MaxLimit)
+	return append(params,
+		openapi.Parameter{Name: "limit", In: "query", Description: "page size", Schema: &openapi.Schema{Type: "integer", Minimum: &minLimit, Maximum: &maxLimit}},
+		openapi.Parameter{Name: "cursor", In: "query", Description: "next_cursor from the previous page", Schema: &openapi.Schema{Type: "string"}},
+		openapi.Parameter{Name: "sort", In: "query", Description: "sort key; allowed keys depend on the endpoint", Schema: &openapi.Schema{Type: "string"}},
+		openapi.Parameter{Name: "order", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"asc", "desc"}}},
+		openapi.Parameter{Name: "from", In: "query", Description: "RFC 3339 timestamp or YYYY-MM-DD, inclusive", Schema: &openapi.Schema{Type: "string"}},
+		openapi.Parameter{Name: "to", In: "query", Description: "RFC 3339 timestamp or YYYY-MM-DD, exclusive", Schema: &openapi.Schema{Type: "string"}},
+		boolQuery("include_total"),
+	)
+}
+
+func boolQuery(name string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Schema: &openapi.Schema{Type: "boolean"}}
+}
+
+func uuidQuery(name string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Schema: &openapi.Schema{Type: "string", Format: "uuid"}}
+}
+
+func numberQuery(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "number"}}
+}
+
+func enumQuery(name string, enum interface{}) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Schema: &openapi.Schema{Type: "string", Enum: enumValues()[reflect.TypeOf(enum)]}}
+}
+
+// enumValues lists the fixed enums. Vital types, their units and rule metrics
+// come from the vital type catalog and are documented as plain strings.
+func enumValues() map[reflect.Type][]string {
+	return map[reflect.Type][]string{
+		reflect.TypeOf(enums.AlertSeverity("")): values(enums.AlertSeverityLow, enums.AlertSeverityMedium, enums.AlertSeverityHigh, enums.AlertSeverityCritical),
+		reflect.TypeOf(enums.AlertType("")): values(enums.AlertTypeVitalAbnormal, enums.AlertTypeNoResponse, enums.AlertTypeSentimentNegative, enums.AlertTypePatternDetected,
+			enums.AlertTypeEarlyWarning, enums.AlertTypeInstrumentScore, enums.AlertTypeAnalysisFinding, enums.AlertTypeThresholdRule),
+		reflect.TypeOf(enums.CheckinStatus("")): values(enums.CheckinStatusPending, enums.CheckinStatusInProgress, enums.CheckinStatusCompleted,
+			enums.CheckinStatusAnalyzed, enums.CheckinStatusReviewed, enums.CheckinStatusFailed, enums.CheckinStatusMissed, enums.CheckinStatusCancelled),
+		reflect.TypeOf(enums.CheckinTrigger("")): values(enums.CheckinTriggerAPI, enums.CheckinTriggerUser, enums.CheckinTriggerScheduler, enums.CheckinTriggerSystem),
+		reflect.TypeOf(enums.EarlyWarningRisk("")): values(enums.EarlyWarningRiskLow, enums.EarlyWarningRiskLowMedium,
+			enums.EarlyWarningRiskMedium, enums.EarlyWarningRiskHigh),
+		reflect.TypeOf(enums.HL7MessageStatus("")): values(enums.HL7MessageProcessed, enums.HL7MessageDuplicate, enums.HL7MessageFailed, enums.HL7MessageRejected),
+		reflect.TypeOf(enums.HL7Transport("")):     values(enums.HL7TransportMLLP, enums.HL7TransportHTTP),
+		reflect.TypeOf(enums.MedicalStatus("")):    values(enums.MedicalStatusNormal, enums.MedicalStatusConcern, enums.MedicalStatusUrgent, enums.MedicalStatusCritical),
+		reflect.TypeOf(enums.RiskLevel("")):        values(enums.RiskLevelLow, enums.RiskLevelMedium, enums.RiskLevelHigh, enums.RiskLevelCritical),
+		reflect.TypeOf(enums.MonitoringFrequency("")): values(enums.MonitoringFrequencyTwiceDaily, enums.MonitoringFrequencyDaily,
+			enums.MonitoringFrequencyEveryOtherDay, enums.MonitoringFrequencyWeekly),
+		reflect.TypeOf(enums.PatientStatus("")): values(enums.PatientStatusActive, enums.PatientStatusPaused, enums.PatientStatusDischarged, enums.PatientStatusCritical),
+		reflect.TypeOf(enums.ScheduleFrequency("")): values(enums.ScheduleFrequencyTwiceDaily, enums.ScheduleFrequencyDaily,
+			enums.ScheduleFrequencyEveryOtherDay, enums.ScheduleFrequencyWeekly),
+		reflect.TypeOf(enums.UserRole("")): values(enums.UserRoleAdmin, enums.UserRoleDoctor, enums.UserRolePatient),
+		reflect.TypeOf(enums.Gender("")):   values(enums.GenderMale, enums.GenderFemale, enums.GenderOther),
+		reflect.TypeOf(enums.RuleOperator("")): values(enums.RuleOperatorLT, enums.RuleOperatorLTE, enums.RuleOperatorGT,
+			enums.RuleOperatorGTE, enums.RuleOperatorEQ, enums.RuleOperatorNEQ),
+		reflect.TypeOf(enums.VitalSource("")): values(enums.VitalSourceManual, enums.VitalSourceBot, enums.VitalSourceDevice, enums.VitalSourceHL7),
+		reflect.TypeOf(enums.VitalComponent("")): values(enums.VitalComponentValue, enums.VitalComponentSystolic,
+			enums.VitalComponentDiastolic, enums.VitalComponentMeanArterialPressure),
+		reflect.TypeOf(enums.QuestionType("")): values(enums.QuestionTypeNumericVital, enums.QuestionTypeScale, enums.QuestionTypeYesNo,
+			enums.QuestionTypeFreeText, enums.QuestionTypeChoice),
+		reflect.TypeOf(enums.Instrument("")): values(enums.InstrumentPHQ9, enums.InstrumentGAD7, enums.InstrumentKCCQ12, enums.InstrumentCAT),
+		reflect.TypeOf(enums.InstrumentBand("")): values(enums.InstrumentBandMinimal, enums.InstrumentBandMild, enums.InstrumentBandModerate,
+			enums.InstrumentBandModeratelySevere, enums.InstrumentBandSevere, enums.InstrumentBandLow, enums.InstrumentBandMedium,
+			enums.InstrumentBandHigh, enums.InstrumentBandVeryHigh, enums.InstrumentBandVeryPoor, enums.InstrumentBandPoor,
+			enums.InstrumentBandFair, enums.InstrumentBandGood),
+	}
+}
+
+func values[T ~string](vals ...T) []string {
+	out := make([]string, len(vals))
+	for i, v := range vals {
+		out[i] = string(v)
+	}
+	return out
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerQuestionnaireRoutes(r *gin.RouterGroup, handler *handlers.QuestionnaireHandler) {
+	templates := r.Group("/questionnaire-templates")
+	{
+		templates.POST("", handler.CreateTemplate)
+		templates.GET("", handler.ListTemplates)
+		templates.GET("/effective/:patientId", handler.GetEffectiveTemplate)
+		templates.GET("/:id", handler.GetTemplate)
+		templates.PUT("/:id", handler.UpdateTemplate)
+	}
+
+	assignments := r.Group("/questionnaire-assignments")
+	{
+		assignments.POST("", handler.CreateAssignment)
+		assignments.GET("", handler.ListAssignments)
+		assignments.DELETE("/:id", handler.DeleteAssignment)
+	}
+
+	r.GET("/checkins/:id/questionnaire", handler.GetCheckinTemplate)
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerReviewQueueRoutes(r *gin.RouterGroup, handler *handlers.ReviewQueueHandler) {
+	r.GET("/review-queue", handler.Queue)
+	r.POST("/checkins/:id/claim", handler.Claim)
+	r.POST("/checkins/:id/unclaim", handler.Unclaim)
+}
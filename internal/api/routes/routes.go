@@ -1,20 +1,41 @@
 package routes
 
 import (
+	"log/slog"
+
 	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/erkinov-wtf/vital-sync/internal/api/middlewares"
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
 	"github.com/erkinov-wtf/vital-sync/internal/http"
 )
 
 func RegisterRoutes(
 	router *http.Router,
+	lgr *slog.Logger,
+	idempotencySvc *services.IdempotencyService,
 	orgHnr *handlers.OrganizationHandler,
 	userHnr *handlers.UserHandler,
 	checkinHnr *handlers.CheckinHandler,
 	checkinScheduleHnr *handlers.CheckinScheduleHandler,
 	vitalReadingHnr *handlers.VitalReadingHandler,
 	alertHnr *handlers.AlertHandler,
+	thresholdRuleHnr *handlers.ThresholdRuleHandler,
+	vitalTypeHnr *handlers.VitalTypeHandler,
+	fhirHnr *handlers.FHIRHandler,
+	hl7Hnr *handlers.HL7Handler,
+	questionnaireHnr *handlers.QuestionnaireHandler,
+	instrumentHnr *handlers.InstrumentHandler,
+	reviewQueueHnr *handlers.ReviewQueueHandler,
+	searchHnr *handlers.SearchHandler,
+	analysisHnr *handlers.AnalysisHandler,
 ) {
-	api := router.Engine().Group("/api/v1")
+	spec := apiSpec()
+
+	router.Engine().GET("/openapi.json", spec.ServeJSON)
+	router.Engine().GET("/docs", spec.ServeDocs)
+
+	api := router.Engine().Group(apiBasePath)
+	api.Use(spec.ValidateRequests(), middlewares.Idempotency(idempotencySvc, lgr))
 	{
 		registerOrgRoutes(api, orgHnr)
 		registerUserRoutes(api, userHnr)
@@ -22,5 +43,14 @@ func RegisterRoutes(
 		registerCheckinScheduleRoutes(api, checkinScheduleHnr)
 		registerVitalReadingRoutes(api, vitalReadingHnr)
 		registerAlertRoutes(api, alertHnr)
+		registerThresholdRuleRoutes(api, thresholdRuleHnr)
+		registerVitalTypeRoutes(api, vitalTypeHnr)
+		registerFHIRRoutes(api, fhirHnr)
+		registerHL7Routes(api, hl7Hnr)
+		registerQuestionnaireRoutes(api, questionnaireHnr)
+		registerInstrumentRoutes(api, instrumentHnr)
+		registerReviewQueueRoutes(api, reviewQueueHnr)
+		registerSearchRoutes(api, searchHnr)
+		registerAnalysisRoutes(api, analysisHnr)
 	}
 }
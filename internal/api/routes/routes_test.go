@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/http"
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesMatchSpec fails when a route is registered without being
+// documented in apiSpec, or documented without being registered. Handlers
+// are never called, so they go without services.
+func TestRoutesMatchSpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := http.NewRouter(&config.Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	RegisterRoutes(
+		router,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		nil,
+		&handlers.OrganizationHandler{},
+		&handlers.UserHandler{},
+		&handlers.CheckinHandler{},
+		&handlers.CheckinScheduleHandler{},
+		&handlers.VitalReadingHandler{},
+		&handlers.AlertHandler{},
+		&handlers.ThresholdRuleHandler{},
+		&handlers.VitalTypeHandler{},
+		&handlers.FHIRHandler{},
+		&handlers.HL7Handler{},
+		&handlers.QuestionnaireHandler{},
+		&handlers.InstrumentHandler{},
+		&handlers.ReviewQueueHandler{},
+		&handlers.SearchHandler{},
+		&handlers.AnalysisHandler{},
+	)
+
+	if err := apiSpec().CheckRoutes(router.Engine().Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerSearchRoutes(r *gin.RouterGroup, handler *handlers.SearchHandler) {
+	r.GET("/search", handler.Search)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerThresholdRuleRoutes(r *gin.RouterGroup, handler *handlers.ThresholdRuleHandler) {
+	rules := r.Group("/threshold-rules")
+	{
+		rules.POST("", handler.Create)
+		rules.GET("", handler.List)
+		rules.GET("/effective/:patientId", handler.ListEffective)
+		rules.GET("/:id", handler.Get)
+		rules.PUT("/:id", handler.Update)
+		rules.DELETE("/:id", handler.Delete)
+	}
+}
@@ -14,4 +14,7 @@ func registerVitalReadingRoutes(r *gin.RouterGroup, handler *handlers.VitalReadi
 		vitals.PUT("/:id", handler.Update)
 		vitals.DELETE("/:id", handler.Delete)
 	}
+
+	r.POST("/patients/:id/vital-readings/batch", handler.CreateBatch)
+	r.GET("/patients/:id/vitals/series", handler.Series)
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func registerVitalTypeRoutes(r *gin.RouterGroup, handler *handlers.VitalTypeHandler) {
+	types := r.Group("/vital-types")
+	{
+		types.POST("", handler.Create)
+		types.GET("", handler.List)
+		types.GET("/:code", handler.Get)
+		types.PUT("/:code", handler.Update)
+		types.DELETE("/:code", handler.Delete)
+	}
+}
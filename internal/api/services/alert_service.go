@@ -3,6 +3,7 @@ package services
 import (
 	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -50,7 +51,16 @@ func (s *AlertService) Create(input CreateAlertInput) (*models.Alert, error) {
 	return &alert, nil
 }
 
-func (s *AlertService) ListByDoctor(doctorID uuid.UUID, includeAcknowledged bool) ([]models.Alert, error) {
+var alertPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "alerts.created_at", Field: "created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "alerts.created_at",
+	IDColumn:    "alerts.id",
+}
+
+func (s *AlertService) ListByDoctor(doctorID uuid.UUID, includeAcknowledged bool, page pagination.Params) (*pagination.Page[models.Alert], error) {
 	// ensure doctor exists
 	if err := s.db.First(&models.User{}, "id = ? AND role = ?", doctorID, enums.UserRoleDoctor).Error; err != nil {
 		return nil, err
@@ -58,16 +68,12 @@ func (s *AlertService) ListByDoctor(doctorID uuid.UUID, includeAcknowledged bool
 
 	query := s.db.Model(&models.Alert{}).
 		Joins("JOIN patients p ON p.id = alerts.patient_id").
-		Where("p.doctor_id = ?", doctorID).
-		Preload("Patient")
+		Where("p.doctor_id = ?", doctorID)
 	if !includeAcknowledged {
 		query = query.Where("alerts.is_acknowledged = ?", false)
 	}
 
-	var alerts []models.Alert
-	if err := query.Order("alerts.created_at DESC").Find(&alerts).Error; err != nil {
-		return nil, err
-	}
-
-	return alerts, nil
+	return pagination.Paginate[models.Alert](query, page, alertPageSpec, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Patient")
+	})
 }
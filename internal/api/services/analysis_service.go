@@ -0,0 +1,485 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/analysis"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAnalysisTimeoutSeconds = 30
+	defaultAnalysisSweepMinutes   = 5
+	defaultAnalysisMaxAttempts    = 5
+
+	// maxAnalysisBackoff caps the wait before a failed analysis is retried;
+	// the wait doubles from the sweep interval with every failure.
+	maxAnalysisBackoff = 6 * time.Hour
+
+	// analysisQueueSize bounds the checkins waiting to be analyzed; those
+	// completed while it is full wait for the next sweep.
+	analysisQueueSize = 100
+	// analysisSweepBatch is how many waiting checkins a sweep takes at once.
+	analysisSweepBatch = 20
+)
+
+// AnalysisService analyzes completed checkins with the configured analyzer
+// and stores the results the way the outside analysis service does through
+// the API: as the checkin's AI analysis, medical status and risk score,
+// which makes it ANALYZED. Without an analyzer it does nothing and checkins
+// wait for the outside service.
+type AnalysisService struct {
+	db       *gorm.DB
+	logger   *slog.Logger
+	checkins *CheckinService
+	analyzer analysis.Analyzer // nil when analysis is left to the outside service
+	interval time.Duration
+	attempts int // failed analyses before the sweep gives up on a checkin
+	queue    chan uuid.UUID
+}
+
+func NewAnalysisService(db *gorm.DB, cfg *config.Config, logger *slog.Logger, checkins *CheckinService) (*AnalysisService, error) {
+	c := cfg.Internal.Analysis
+	analyzer, err := newAnalyzer(c)
+	if err != nil {
+		return nil, err
+	}
+	s := &AnalysisService{
+		db:       db,
+		logger:   logger,
+		checkins: checkins,
+		analyzer: analyzer,
+		interval: time.Duration(c.SweepMinutes) * time.Minute,
+		attempts: c.MaxAttempts,
+		queue:    make(chan uuid.UUID, analysisQueueSize),
+	}
+	if s.interval <= 0 {
+		s.interval = defaultAnalysisSweepMinutes * time.Minute
+	}
+	if s.attempts <= 0 {
+		s.attempts = defaultAnalysisMaxAttempts
+	}
+	return s, nil
+}
+
+// newAnalyzer builds the configured analyzer; nil for none.
+func newAnalyzer(c config.Analysis) (analysis.Analyzer, error) {
+	switch c.Provider {
+	case "":
+		return nil, nil
+	case analysis.ProviderRules:
+		return analysis.Rules{}, nil
+	case analysis.ProviderOpenAI:
+		timeout := c.TimeoutSeconds
+		if timeout <= 0 {
+			timeout = defaultAnalysisTimeoutSeconds
+		}
+		model, err := analysis.NewOpenAICompatible(analysis.OpenAIConfig{
+			BaseURL:       c.BaseURL,
+			APIKey:        c.APIKey,
+			Model:         c.Model,
+			PromptVersion: c.PromptVersion,
+			Timeout:       time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if c.Fallback {
+			return analysis.WithFallback(model, analysis.Rules{}), nil
+		}
+		return model, nil
+	default:
+		return nil, fmt.Errorf("analysis: unknown provider %q", c.Provider)
+	}
+}
+
+// Enabled reports whether vital-sync analyzes checkins itself.
+func (s *AnalysisService) Enabled() bool {
+	return s.analyzer != nil
+}
+
+// Interval is how often checkins left unanalyzed are swept up.
+func (s *AnalysisService) Interval() time.Duration {
+	return s.interval
+}
+
+// Queue delivers the checkins to analyze as they complete.
+func (s *AnalysisService) Queue() <-chan uuid.UUID {
+	return s.queue
+}
+
+// Enqueue queues a completed checkin for analysis without waiting; see
+// CheckinService.OnCompleted.
+func (s *AnalysisService) Enqueue(checkinID uuid.UUID) {
+	if s.analyzer == nil {
+		return
+	}
+	select {
+	case s.queue <- checkinID:
+	default:
+		s.logger.Warn("analysis queue is full; the checkin waits for the next sweep", "checkin_id", checkinID)
+	}
+}
+
+// Analyze analyzes a completed checkin, or analyzes an analyzed one again,
+// and stores the result. An alert the analysis asks for is raised as an
+// ANALYSIS_FINDING alert. A failure to analyze a completed checkin counts
+// towards the attempts the sweep makes at it.
+func (s *AnalysisService) Analyze(ctx context.Context, checkinID uuid.UUID) (*models.Checkin, error) {
+	if s.analyzer == nil {
+		return nil, errs.ErrAnalysisDisabled
+	}
+	var checkin models.Checkin
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	switch checkin.Status {
+	case enums.CheckinStatusCompleted, enums.CheckinStatusAnalyzed:
+	case enums.CheckinStatusReviewed:
+		return nil, errs.ErrCheckinReviewed
+	default:
+		return nil, errs.ErrCheckinNotCompleted
+	}
+
+	input, err := s.input(&checkin)
+	if err != nil {
+		return nil, s.attemptFailed(&checkin, err)
+	}
+	result, err := s.analyzer.Analyze(ctx, *input)
+	if err == nil {
+		err = result.Validate()
+	}
+	if err != nil {
+		return nil, s.attemptFailed(&checkin, errs.ErrAnalysisFailed.Wrap(err))
+	}
+	if result.FallbackReason != "" {
+		s.logger.Warn("checkin analyzed by the fallback analyzer", "checkin_id", checkin.ID, "reason", result.FallbackReason)
+	}
+
+	stored, err := models.NewJSONB(models.CheckinAnalysis{
+		Summary:         result.Summary,
+		Concerns:        nonNilStrings(result.Concerns),
+		Recommendations: nonNilStrings(result.Recommendations),
+		Provider:        result.Provider,
+		Model:           result.Model,
+		PromptVersion:   result.PromptVersion,
+		LatencyMS:       result.Latency.Milliseconds(),
+		FallbackReason:  result.FallbackReason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	status := enums.MedicalStatus(result.MedicalStatus)
+	update := CheckinAIUpdate{AIAnalysis: &stored, MedicalStatus: &status, RiskScore: &result.RiskScore}
+	if result.Alert != nil {
+		details, err := models.NewJSONB(map[string]interface{}{
+			"provider":       result.Provider,
+			"model":          result.Model,
+			"prompt_version": result.PromptVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		update.Alert = &CheckinAIAlertInput{
+			Severity:  enums.AlertSeverity(result.Alert.Severity),
+			AlertType: enums.AlertTypeAnalysisFinding,
+			Title:     result.Alert.Title,
+			Message:   result.Alert.Message,
+			Details:   &details,
+		}
+	}
+	return s.checkins.UpdateAIFields(checkin.ID, checkin.Version, update, CheckinActor{Trigger: enums.CheckinTriggerSystem})
+}
+
+// attemptFailed records a failed analysis of a completed checkin and
+// returns err. The checkin is retried after a backoff, and left for good
+// once it has failed the maximum number of attempts.
+func (s *AnalysisService) attemptFailed(checkin *models.Checkin, err error) error {
+	if checkin.Status != enums.CheckinStatusCompleted {
+		return err
+	}
+	now := time.Now()
+	attempts := checkin.AnalysisAttempts + 1
+	updates := map[string]interface{}{
+		"analysis_attempts": attempts,
+		"analysis_retry_at": now.Add(s.backoff(attempts)),
+		"analysis_error":    err.Error(),
+	}
+	if attempts >= s.attempts {
+		updates["analysis_failed_at"] = now
+		s.logger.Warn("giving up on analyzing checkin", "checkin_id", checkin.ID, "attempts", attempts, "error", err)
+	}
+	// only while it still waits, so an analysis stored meanwhile is kept clean
+	if uerr := s.db.Model(&models.Checkin{}).
+		Where("id = ? AND status = ?", checkin.ID, enums.CheckinStatusCompleted).
+		UpdateColumns(updates).Error; uerr != nil {
+		return errors.Join(err, uerr)
+	}
+	return err
+}
+
+// backoff is how long a checkin whose analysis failed attempts times waits
+// before the sweep retries it.
+func (s *AnalysisService) backoff(attempts int) time.Duration {
+	backoff := s.interval
+	for i := 1; i < attempts && backoff < maxAnalysisBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxAnalysisBackoff)
+}
+
+// Sweep analyzes completed checkins still waiting for an analysis: those
+// completed while the queue was full, the service was down or the analyzer
+// failed. Checkins that failed fewer times come first, oldest first, and
+// failed ones wait out their backoff, so a few checkins that always fail do
+// not hold up the rest. It returns how many it analyzed.
+func (s *AnalysisService) Sweep(ctx context.Context) (int, error) {
+	if s.analyzer == nil {
+		return 0, nil
+	}
+	var ids []uuid.UUID
+	if err := s.db.Model(&models.Checkin{}).
+		Where("status = ? AND analysis_failed_at IS NULL", enums.CheckinStatusCompleted).
+		Where("analysis_retry_at IS NULL OR analysis_retry_at <= ?", time.Now()).
+		Order("analysis_attempts").
+		Order("completed_at").
+		Limit(analysisSweepBatch).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	analyzed := 0
+	var failed []error
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := s.Analyze(ctx, id); err != nil {
+			// analyzed through the API meanwhile
+			if errors.Is(err, errs.ErrVersionMismatch) || errors.Is(err, errs.ErrCheckinReviewed) {
+				continue
+			}
+			failed = append(failed, fmt.Errorf("checkin %s: %w", id, err))
+			continue
+		}
+		analyzed++
+	}
+	return analyzed, errors.Join(failed...)
+}
+
+// input gathers what the analyzer reads: the patient's medical record and
+// the checkin's answers, vitals, scores and alerts.
+func (s *AnalysisService) input(checkin *models.Checkin) (*analysis.Input, error) {
+	var patient models.Patient
+	if err := s.db.Preload("User").First(&patient, "id = ?", checkin.PatientID).Error; err != nil {
+		return nil, err
+	}
+	in := analysis.Input{
+		Patient: analysis.Patient{
+			ConditionSummary: patient.ConditionSummary,
+			Comorbidities:    patient.Comorbidities,
+			Medications:      medicationLines(patient.CurrentMedications),
+			Allergies:        patient.Allergies,
+			RiskLevel:        string(patient.RiskLevel),
+		},
+		Checkin: analysis.Checkin{
+			StartedAt:   checkin.InitiatedAt,
+			CompletedAt: checkin.CompletedAt,
+			Partial:     checkin.Partial,
+			Messages:    checkin.RawMessages,
+		},
+	}
+	if patient.User != nil && patient.User.Gender != nil {
+		in.Patient.Gender = string(*patient.User.Gender)
+	}
+
+	baselines, err := patient.Baselines()
+	if err != nil {
+		return nil, err
+	}
+	for vitalType, b := range baselines {
+		baseline := analysis.Baseline{VitalType: string(vitalType), Value: formatNumber(b.Value)}
+		if b.Diastolic != nil {
+			baseline.Value += "/" + formatNumber(*b.Diastolic)
+		}
+		if b.Unit != nil {
+			baseline.Unit = string(*b.Unit)
+		}
+		in.Patient.Baselines = append(in.Patient.Baselines, baseline)
+	}
+	sort.Slice(in.Patient.Baselines, func(i, j int) bool {
+		return in.Patient.Baselines[i].VitalType < in.Patient.Baselines[j].VitalType
+	})
+
+	questions, err := checkin.ParsedQuestions()
+	if err != nil {
+		return nil, err
+	}
+	answers, err := checkin.ParsedAnswers()
+	if err != nil {
+		return nil, err
+	}
+	asked := make(map[uuid.UUID]string, len(questions))
+	for _, q := range questions {
+		asked[q.ID] = q.Text
+	}
+	for _, a := range answers {
+		in.Checkin.Answers = append(in.Checkin.Answers, analysis.Answer{Question: asked[a.QuestionID], Answer: answerText(a)})
+	}
+	unanswered, err := unansweredQuestions(checkin)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range unanswered {
+		in.Checkin.Unanswered = append(in.Checkin.Unanswered, q.Text)
+	}
+
+	var readings []models.VitalReading
+	if err := s.db.Where("checkin_id = ?", checkin.ID).Order("measured_at").Find(&readings).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range readings {
+		vital := analysis.Vital{
+			VitalType: string(r.VitalType),
+			Value:     readingValue(r),
+			Abnormal:  r.IsAbnormal,
+			Deviation: r.DeviationFromBaseline,
+		}
+		if r.Unit != nil {
+			vital.Unit = string(*r.Unit)
+		}
+		if r.Context != nil {
+			vital.Context = *r.Context
+		}
+		in.Checkin.Vitals = append(in.Checkin.Vitals, vital)
+	}
+
+	if checkin.EarlyWarningScore != nil && checkin.EarlyWarningRisk != nil {
+		in.Checkin.EarlyWarning = &analysis.EarlyWarning{Score: *checkin.EarlyWarningScore, Risk: string(*checkin.EarlyWarningRisk)}
+	}
+
+	var scores []models.InstrumentScore
+	if err := s.db.Where("checkin_id = ?", checkin.ID).Order("instrument").Find(&scores).Error; err != nil {
+		return nil, err
+	}
+	for _, sc := range scores {
+		in.Checkin.Instruments = append(in.Checkin.Instruments, analysis.InstrumentScore{
+			Instrument: string(sc.Instrument),
+			Score:      sc.Score,
+			Band:       string(sc.Band),
+		})
+	}
+
+	// alerts of an earlier analysis are left out, so analyzing again does
+	// not feed on itself
+	var alerts []models.Alert
+	if err := s.db.Where("checkin_id = ? AND alert_type <> ?", checkin.ID, enums.AlertTypeAnalysisFinding).
+		Order("created_at").Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range alerts {
+		in.Checkin.Alerts = append(in.Checkin.Alerts, analysis.Alert{Severity: string(a.Severity), Title: a.Title, Message: a.Message})
+	}
+	return &in, nil
+}
+
+// medicationLines renders Patient.CurrentMedications, whose items are
+// strings, objects with a name such as the FHIR import writes, or anything
+// else a client stored, one line per item.
+func medicationLines(medications models.JSONB) []string {
+	var items []json.RawMessage
+	if medications.Unmarshal(&items) != nil {
+		if len(medications) == 0 || string(medications) == "null" {
+			return nil
+		}
+		return []string{string(medications)}
+	}
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		var text string
+		if json.Unmarshal(item, &text) == nil {
+			lines = append(lines, text)
+			continue
+		}
+		var med map[string]interface{}
+		if json.Unmarshal(item, &med) == nil {
+			if name, ok := med["name"].(string); ok && name != "" {
+				line := name
+				for _, key := range []string{"dosage", "dose", "frequency", "status"} {
+					if v, ok := med[key].(string); ok && v != "" {
+						line += ", " + v
+					}
+				}
+				lines = append(lines, line)
+				continue
+			}
+		}
+		lines = append(lines, string(item))
+	}
+	return lines
+}
+
+// answerText is an answer as the patient wrote it, or its value.
+func answerText(a models.CheckinAnswer) string {
+	if a.Text != nil && strings.TrimSpace(*a.Text) != "" {
+		return *a.Text
+	}
+	switch v := a.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		return formatNumber(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func readingValue(r models.VitalReading) string {
+	switch {
+	case r.Systolic != nil && r.Diastolic != nil:
+		return formatNumber(*r.Systolic) + "/" + formatNumber(*r.Diastolic)
+	case r.ValueNumeric != nil:
+		return formatNumber(*r.ValueNumeric)
+	case r.ValueText != nil:
+		return *r.ValueText
+	default:
+		return ""
+	}
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func nonNilStrings(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+)
+
+// Plausible measurement bounds; values outside them are input errors, not
+// abnormal readings.
+const (
+	minSystolic  = 50
+	maxSystolic  = 300
+	minDiastolic = 20
+	maxDiastolic = 200
+)
+
+// bloodPressureText matches what patients type to the bot, e.g. "140/90",
+// "140 / 90 mmHg", "140 over 90", "140 на 90" or "140 ga 90".
+var bloodPressureText = regexp.MustCompile(`(?i)^\s*(\d{2,3}(?:[.,]\d+)?)\s*(?:/|\\|over|на|ga)\s*(\d{2,3}(?:[.,]\d+)?)\s*(?:mm\s*hg|мм\s*рт\.?\s*ст\.?)?\s*$`)
+
+// parseBloodPressure extracts systolic and diastolic values from text.
+func parseBloodPressure(text string) (systolic, diastolic float64, ok bool) {
+	m := bloodPressureText.FindStringSubmatch(text)
+	if m == nil {
+		return 0, 0, false
+	}
+	systolic, err1 := strconv.ParseFloat(commaToDot(m[1]), 64)
+	diastolic, err2 := strconv.ParseFloat(commaToDot(m[2]), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return systolic, diastolic, true
+}
+
+func commaToDot(s string) string {
+	b := []byte(s)
+	for i := range b {
+		if b[i] == ',' {
+			b[i] = '.'
+		}
+	}
+	return string(b)
+}
+
+// meanArterialPressure estimates MAP as diastolic + a third of the pulse pressure.
+func meanArterialPressure(systolic, diastolic float64) float64 {
+	return round2(diastolic + (systolic-diastolic)/3)
+}
+
+// resolveBloodPressure fills the blood pressure components of reading from
+// explicit systolic/diastolic values or, failing that, from ValueText, and
+// normalises ValueNumeric, ValueText and Unit to match. Field names in
+// validation errors are the request's.
+func resolveBloodPressure(reading *models.VitalReading, systolic, diastolic *float64) error {
+	switch {
+	case systolic != nil || diastolic != nil:
+		var fields []errs.FieldError
+		if systolic == nil {
+			fields = append(fields, errs.FieldError{Field: "systolic", Message: "is required with diastolic"})
+		}
+		if diastolic == nil {
+			fields = append(fields, errs.FieldError{Field: "diastolic", Message: "is required with systolic"})
+		}
+		if len(fields) > 0 {
+			return errs.Validation(fields...)
+		}
+	case reading.ValueText != nil:
+		s, d, ok := parseBloodPressure(*reading.ValueText)
+		if !ok {
+			return errs.InvalidField("value_text", "must be a blood pressure such as 120/80")
+		}
+		systolic, diastolic = &s, &d
+	case reading.Systolic != nil && reading.Diastolic != nil:
+		systolic, diastolic = reading.Systolic, reading.Diastolic
+	default:
+		return errs.Validation(
+			errs.FieldError{Field: "systolic", Message: "is required for blood pressure"},
+			errs.FieldError{Field: "diastolic", Message: "is required for blood pressure"},
+		)
+	}
+
+	if err := validateBloodPressure(*systolic, *diastolic); err != nil {
+		return err
+	}
+
+	s, d := *systolic, *diastolic
+	mean := meanArterialPressure(s, d)
+	text := formatValue(s) + "/" + formatValue(d)
+	unit := enums.VitalUnitMmHg
+
+	reading.Systolic = &s
+	reading.Diastolic = &d
+	reading.MeanArterialPressure = &mean
+	reading.ValueNumeric = &s
+	reading.ValueText = &text
+	reading.Unit = &unit
+	return nil
+}
+
+// clearBloodPressure drops components when a reading stops being blood pressure.
+func clearBloodPressure(reading *models.VitalReading) {
+	reading.Systolic = nil
+	reading.Diastolic = nil
+	reading.MeanArterialPressure = nil
+}
+
+func validateBloodPressure(systolic, diastolic float64) error {
+	var fields []errs.FieldError
+	if systolic < minSystolic || systolic > maxSystolic {
+		fields = append(fields, errs.FieldError{Field: "systolic", Message: "must be between 50 and 300 mmHg"})
+	}
+	if diastolic < minDiastolic || diastolic > maxDiastolic {
+		fields = append(fields, errs.FieldError{Field: "diastolic", Message: "must be between 20 and 200 mmHg"})
+	}
+	if len(fields) == 0 && diastolic >= systolic {
+		fields = append(fields, errs.FieldError{Field: "diastolic", Message: "must be lower than systolic"})
+	}
+	if len(fields) > 0 {
+		return errs.Validation(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+)
+
+func TestParseBloodPressure(t *testing.T) {
+	tests := []struct {
+		text      string
+		systolic  float64
+		diastolic float64
+	}{
+		{"140/90", 140, 90},
+		{"  120 / 80  ", 120, 80},
+		{`118\76`, 118, 76},
+		{"140/90 mmHg", 140, 90},
+		{"140/90 MM HG", 140, 90},
+		{"140 over 90", 140, 90},
+		{"140 OVER 90", 140, 90},
+		{"140 на 90", 140, 90},
+		{"140 ga 90", 140, 90},
+		{"140/90 мм рт. ст.", 140, 90},
+		{"140/90 мм рт ст", 140, 90},
+		{"120,5/80.5", 120.5, 80.5},
+		{"95/60", 95, 60},
+	}
+	for _, tt := range tests {
+		s, d, ok := parseBloodPressure(tt.text)
+		if !ok || s != tt.systolic || d != tt.diastolic {
+			t.Errorf("parseBloodPressure(%q) = %v, %v, %v; want %v, %v", tt.text, s, d, ok, tt.systolic, tt.diastolic)
+		}
+	}
+
+	for _, text := range []string{
+		"",
+		"high",
+		"140",
+		"140/",
+		"/90",
+		"140-90",
+		"140 90",
+		"9/6",
+		"1400/90",
+		"140/90/80",
+		"bp 140/90",
+		"140/90 kPa",
+		"140.5.5/90",
+	} {
+		if s, d, ok := parseBloodPressure(text); ok {
+			t.Errorf("parseBloodPressure(%q) = %v, %v; want no match", text, s, d)
+		}
+	}
+}
+
+func TestMeanArterialPressure(t *testing.T) {
+	tests := []struct{ systolic, diastolic, want float64 }{
+		{120, 80, 93.33},
+		{90, 60, 70},
+		{141, 90, 107},
+	}
+	for _, tt := range tests {
+		if got := meanArterialPressure(tt.systolic, tt.diastolic); got != tt.want {
+			t.Errorf("meanArterialPressure(%v, %v) = %v, want %v", tt.systolic, tt.diastolic, got, tt.want)
+		}
+	}
+}
+
+// errorFields lists the fields of a validation error, in order.
+func errorFields(err error) []string {
+	appErr := errs.As(err)
+	if appErr == nil {
+		return nil
+	}
+	fields := make([]string, len(appErr.Fields))
+	for i, f := range appErr.Fields {
+		fields[i] = f.Field
+	}
+	return fields
+}
+
+func TestResolveBloodPressure(t *testing.T) {
+	tests := []struct {
+		name      string
+		reading   models.VitalReading
+		systolic  *float64
+		diastolic *float64
+		want      string
+		fields    []string
+	}{
+		{name: "explicit values", systolic: ptr(120.0), diastolic: ptr(80.0), want: "120/80"},
+		{name: "explicit values win over text", reading: models.VitalReading{ValueText: ptr("150/95")}, systolic: ptr(130.0), diastolic: ptr(85.0), want: "130/85"},
+		{name: "text", reading: models.VitalReading{ValueText: ptr("135 over 85.5")}, want: "135/85.5"},
+		{name: "stored components", reading: models.VitalReading{Systolic: ptr(110.0), Diastolic: ptr(70.0)}, want: "110/70"},
+		{name: "lowest plausible", systolic: ptr(50.0), diastolic: ptr(20.0), want: "50/20"},
+		{name: "highest plausible", systolic: ptr(300.0), diastolic: ptr(200.0), want: "300/200"},
+		{name: "systolic alone", systolic: ptr(120.0), fields: []string{"diastolic"}},
+		{name: "diastolic alone", diastolic: ptr(80.0), fields: []string{"systolic"}},
+		{name: "unreadable text", reading: models.VitalReading{ValueText: ptr("fine")}, fields: []string{"value_text"}},
+		{name: "nothing", fields: []string{"systolic", "diastolic"}},
+		{name: "below plausible", systolic: ptr(49.0), diastolic: ptr(19.0), fields: []string{"systolic", "diastolic"}},
+		{name: "above plausible", systolic: ptr(301.0), diastolic: ptr(80.0), fields: []string{"systolic"}},
+		{name: "diastolic equal to systolic", systolic: ptr(90.0), diastolic: ptr(90.0), fields: []string{"diastolic"}},
+		{name: "diastolic above systolic", reading: models.VitalReading{ValueText: ptr("80/120")}, fields: []string{"diastolic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := tt.reading
+			err := resolveBloodPressure(&reading, tt.systolic, tt.diastolic)
+			if tt.fields != nil {
+				if got := errorFields(err); !slices.Equal(got, tt.fields) {
+					t.Fatalf("err = %v with fields %v, want fields %v", err, got, tt.fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveBloodPressure: %v", err)
+			}
+			if reading.ValueText == nil || *reading.ValueText != tt.want {
+				t.Fatalf("value text = %v, want %s", reading.ValueText, tt.want)
+			}
+			s, d := *reading.Systolic, *reading.Diastolic
+			if *reading.ValueNumeric != s || *reading.MeanArterialPressure != meanArterialPressure(s, d) || *reading.Unit != enums.VitalUnitMmHg {
+				t.Errorf("value %v, MAP %v, unit %s; want the systolic, its MAP and mmHg", *reading.ValueNumeric, *reading.MeanArterialPressure, *reading.Unit)
+			}
+		})
+	}
+}
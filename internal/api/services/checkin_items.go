@@ -0,0 +1,545 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxFreeTextAnswer = 5000
+
+var questionKeyPattern = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// questionFieldErrors checks a question's key, text and the fields its type
+// needs, reporting problems under path. A NUMERIC_VITAL question must measure
+// an active catalog vital type, in one of its units.
+func questionFieldErrors(catalog *VitalCatalog, path string, q models.TemplateQuestion) []errs.FieldError {
+	var fields []errs.FieldError
+	add := func(field, message string) {
+		fields = append(fields, errs.FieldError{Field: field, Message: message})
+	}
+	if q.Key != "" && !questionKeyPattern.MatchString(q.Key) {
+		add(path+".key", "must be 1-50 lowercase letters, digits or underscores")
+	}
+	if strings.TrimSpace(q.Text) == "" {
+		add(path+".text", "is required")
+	}
+
+	switch q.Type {
+	case enums.QuestionTypeNumericVital:
+		if q.VitalType == nil {
+			add(path+".vital_type", "is required for NUMERIC_VITAL questions")
+			break
+		}
+		entry, ok := catalog.lookup(*q.VitalType)
+		if !ok || !entry.IsActive {
+			add(path+".vital_type", "must be an active vital type")
+			break
+		}
+		if q.Unit != nil {
+			if _, ok := entry.units[*q.Unit]; !ok {
+				add(path+".unit", fmt.Sprintf("must be one of %v", sortedUnits(entry.units)))
+			}
+		}
+	case enums.QuestionTypeScale:
+		if q.Min == nil || q.Max == nil {
+			add(path, "SCALE questions need min and max")
+		} else if *q.Min >= *q.Max {
+			add(path+".max", "must be greater than min")
+		}
+	case enums.QuestionTypeChoice:
+		if len(q.Choices) < 2 {
+			add(path+".choices", "must have at least two choices")
+		}
+		values := map[string]bool{}
+		for j, c := range q.Choices {
+			cpath := fmt.Sprintf("%s.choices[%d]", path, j)
+			if strings.TrimSpace(c.Value) == "" {
+				add(cpath+".value", "is required")
+			} else if values[c.Value] {
+				add(cpath+".value", "is used by another choice")
+			}
+			values[c.Value] = true
+			if strings.TrimSpace(c.Label) == "" {
+				add(cpath+".label", "is required")
+			}
+		}
+	case enums.QuestionTypeYesNo, enums.QuestionTypeFreeText:
+	default:
+		add(path+".type", "must be one of [NUMERIC_VITAL SCALE YES_NO FREE_TEXT CHOICE]")
+		return fields
+	}
+
+	if q.Type != enums.QuestionTypeNumericVital && (q.VitalType != nil || q.Unit != nil) {
+		add(path, "only NUMERIC_VITAL questions have vital_type and unit")
+	}
+	if q.Type != enums.QuestionTypeScale && (q.Min != nil || q.Max != nil) {
+		add(path, "only SCALE questions have min and max")
+	}
+	if q.Type != enums.QuestionTypeChoice && (len(q.Choices) > 0 || q.Multiple) {
+		add(path, "only CHOICE questions have choices")
+	}
+	return fields
+}
+
+// answerValue checks value against the question's type and returns it in
+// the form it is stored in, or a message saying what is wrong with it.
+func answerValue(q models.CheckinQuestion, value interface{}) (interface{}, string) {
+	if value == nil {
+		return nil, "is required"
+	}
+	switch q.Type {
+	case enums.QuestionTypeNumericVital:
+		switch v := value.(type) {
+		case float64:
+			return v, ""
+		case string:
+			if v = strings.TrimSpace(v); v != "" && len(v) <= 50 {
+				return v, ""
+			}
+		}
+		return nil, "must be a number, or text such as 140/90 of at most 50 characters"
+	case enums.QuestionTypeScale:
+		v, ok := value.(float64)
+		if !ok || v < *q.Min || v > *q.Max {
+			return nil, fmt.Sprintf("must be a number from %g to %g", *q.Min, *q.Max)
+		}
+		return v, ""
+	case enums.QuestionTypeYesNo:
+		if v, ok := value.(bool); ok {
+			return v, ""
+		}
+		return nil, "must be true or false"
+	case enums.QuestionTypeFreeText:
+		v, ok := value.(string)
+		if v = strings.TrimSpace(v); !ok || v == "" || len(v) > maxFreeTextAnswer {
+			return nil, fmt.Sprintf("must be text of 1-%d characters", maxFreeTextAnswer)
+		}
+		return v, ""
+	case enums.QuestionTypeChoice:
+		allowed := make([]string, len(q.Choices))
+		for i, c := range q.Choices {
+			allowed[i] = c.Value
+		}
+		if !q.Multiple {
+			if v, ok := value.(string); ok && slices.Contains(allowed, v) {
+				return v, ""
+			}
+			return nil, fmt.Sprintf("must be one of %v", allowed)
+		}
+		list, ok := value.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, fmt.Sprintf("must be a list of one or more of %v", allowed)
+		}
+		picked := make([]string, 0, len(list))
+		for _, item := range list {
+			v, ok := item.(string)
+			if !ok || !slices.Contains(allowed, v) || slices.Contains(picked, v) {
+				return nil, fmt.Sprintf("must be a list of distinct values from %v", allowed)
+			}
+			picked = append(picked, v)
+		}
+		return picked, ""
+	}
+	return nil, "cannot answer a question of type " + string(q.Type)
+}
+
+type CheckinQuestionInput struct {
+	Seq      int
+	Question models.TemplateQuestion
+
+	Locale        *string
+	AskedAt       *time.Time // defaults to now
+	SourceMessage *string
+}
+
+// AddQuestions adds the questions the bot asks. A question with the seq of an
+// earlier one replaces it and keeps its id, unless it has been answered.
+func (s *CheckinService) AddQuestions(checkinID uuid.UUID, inputs []CheckinQuestionInput) (*models.Checkin, error) {
+	var checkin models.Checkin
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockActiveCheckin(tx, &checkin, checkinID); err != nil {
+			return err
+		}
+		items, err := models.CheckinItems(checkin.Questions)
+		if err != nil {
+			return err
+		}
+		existing, err := checkin.ParsedQuestions()
+		if err != nil {
+			return err
+		}
+		answers, err := checkin.ParsedAnswers()
+		if err != nil {
+			return err
+		}
+		answered := map[uuid.UUID]bool{}
+		for _, a := range answers {
+			answered[a.QuestionID] = true
+		}
+
+		var fields []errs.FieldError
+		seen, keys := map[int]bool{}, map[string]bool{}
+		now := time.Now()
+		for i, in := range inputs {
+			path := fmt.Sprintf("items[%d]", i)
+			fields = append(fields, questionFieldErrors(s.readings.catalog, path, in.Question)...)
+			if seen[in.Seq] {
+				fields = append(fields, errs.FieldError{Field: path + ".seq", Message: "is used by another item"})
+				continue
+			}
+			seen[in.Seq] = true
+			if in.Question.Key != "" && keys[in.Question.Key] {
+				fields = append(fields, errs.FieldError{Field: path + ".key", Message: "is used by another item"})
+			}
+			keys[in.Question.Key] = true
+
+			question := models.CheckinQuestion{
+				ID:               uuid.New(),
+				Seq:              in.Seq,
+				TemplateQuestion: in.Question,
+				Locale:           in.Locale,
+				AskedAt:          in.AskedAt,
+				SourceMessage:    in.SourceMessage,
+			}
+			if question.AskedAt == nil {
+				question.AskedAt = &now
+			}
+			for _, q := range existing {
+				switch {
+				case q.Seq == in.Seq && answered[q.ID]:
+					fields = append(fields, errs.FieldError{Field: path + ".seq", Message: "is a question that has been answered"})
+				case q.Seq == in.Seq:
+					question.ID = q.ID
+				case in.Question.Key != "" && q.Key == in.Question.Key:
+					fields = append(fields, errs.FieldError{Field: path + ".key", Message: "is used by another question"})
+				}
+			}
+
+			raw, err := json.Marshal(question)
+			if err != nil {
+				return err
+			}
+			items = putCheckinItem(items, in.Seq, raw)
+		}
+		if len(fields) > 0 {
+			return errs.Validation(fields...)
+		}
+
+		updated, err := models.NewJSONB(items)
+		if err != nil {
+			return err
+		}
+		return updateVersioned(tx, &models.Checkin{}, checkin.ID, nil, map[string]interface{}{"questions": updated, "last_activity_at": now})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	return &checkin, nil
+}
+
+type CheckinAnswerInput struct {
+	// The question answered, by id or by seq; both must agree when both are set.
+	QuestionID *uuid.UUID
+	Seq        *int
+
+	Value interface{}
+	Text  *string
+
+	Locale        *string
+	AnsweredAt    *time.Time // defaults to now
+	SourceMessage *string
+}
+
+// AddAnswers stores answers, each checked against the question it answers.
+// An answer to an already answered question replaces the earlier one.
+// NUMERIC_VITAL answers are recorded as BOT vital readings of the checkin,
+// replacing the reading of the answer they replace. The first answers move
+// a PENDING checkin to IN_PROGRESS, and the answers that leave no question
+// of a questionnaire checkin unanswered complete it.
+func (s *CheckinService) AddAnswers(checkinID uuid.UUID, inputs []CheckinAnswerInput, actor CheckinActor) (*models.Checkin, error) {
+	var checkin models.Checkin
+	completed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockActiveCheckin(tx, &checkin, checkinID); err != nil {
+			return err
+		}
+		var patient models.Patient
+		if err := tx.First(&patient, "id = ?", checkin.PatientID).Error; err != nil {
+			return err
+		}
+
+		items, err := models.CheckinItems(checkin.Answers)
+		if err != nil {
+			return err
+		}
+		questions, err := checkin.ParsedQuestions()
+		if err != nil {
+			return err
+		}
+		earlier, err := checkin.ParsedAnswers()
+		if err != nil {
+			return err
+		}
+		readingOf := map[uuid.UUID]*uuid.UUID{}
+		for _, a := range earlier {
+			readingOf[a.QuestionID] = a.VitalReadingID
+		}
+
+		var (
+			fields   []errs.FieldError
+			readings []preparedReading
+			replaced []uuid.UUID
+		)
+		seen := map[uuid.UUID]bool{}
+		now := time.Now()
+		for i, in := range inputs {
+			path := fmt.Sprintf("items[%d]", i)
+			question, message := answeredQuestion(questions, in)
+			if message != "" {
+				fields = append(fields, errs.FieldError{Field: path, Message: message})
+				continue
+			}
+			if seen[question.ID] {
+				fields = append(fields, errs.FieldError{Field: path, Message: "answers the same question as another item"})
+				continue
+			}
+			seen[question.ID] = true
+
+			value, message := answerValue(question, in.Value)
+			if message != "" {
+				fields = append(fields, errs.FieldError{Field: path + ".value", Message: message})
+				continue
+			}
+			answer := models.CheckinAnswer{
+				ID:            uuid.New(),
+				QuestionID:    question.ID,
+				Seq:           question.Seq,
+				Type:          question.Type,
+				Value:         value,
+				Text:          trimmedOrNil(in.Text),
+				Locale:        in.Locale,
+				AnsweredAt:    now,
+				SourceMessage: in.SourceMessage,
+			}
+			if in.AnsweredAt != nil {
+				if in.AnsweredAt.After(now.Add(maxClockSkew)) {
+					fields = append(fields, errs.FieldError{Field: path + ".answered_at", Message: "must not be in the future"})
+					continue
+				}
+				answer.AnsweredAt = in.AnsweredAt.Truncate(time.Microsecond)
+			}
+
+			if question.Type == enums.QuestionTypeNumericVital {
+				prepared, err := s.vitalAnswerReading(&patient, checkin.ID, question, answer)
+				if err != nil {
+					appErr := errs.As(err)
+					if appErr == nil || len(appErr.Fields) == 0 {
+						return err
+					}
+					for _, f := range appErr.Fields {
+						fields = append(fields, errs.FieldError{Field: path + ".value", Message: f.Message})
+					}
+					continue
+				}
+				readings = append(readings, prepared)
+				answer.VitalReadingID = &prepared.reading.ID
+			}
+			if id := readingOf[question.ID]; id != nil {
+				replaced = append(replaced, *id)
+			}
+
+			raw, err := json.Marshal(answer)
+			if err != nil {
+				return err
+			}
+			items = putCheckinAnswer(items, question.ID, raw)
+		}
+		if len(fields) > 0 {
+			return errs.Validation(fields...)
+		}
+
+		updated, err := models.NewJSONB(items)
+		if err != nil {
+			return err
+		}
+		if err := updateVersioned(tx, &models.Checkin{}, checkin.ID, nil, map[string]interface{}{"answers": updated, "last_activity_at": now}); err != nil {
+			return err
+		}
+		if checkin.Status == enums.CheckinStatusPending {
+			if err := transitionCheckin(tx, &checkin, enums.CheckinStatusInProgress, actor, nil, nil); err != nil {
+				return err
+			}
+		}
+
+		if len(replaced) > 0 {
+			if err := tx.Delete(&models.VitalReading{}, "id IN ? AND checkin_id = ?", replaced, checkin.ID).Error; err != nil {
+				return err
+			}
+		}
+		for _, r := range readings {
+			if err := s.readings.persist(tx, &patient, r.reading, r.assessment); err != nil {
+				return err
+			}
+		}
+		checkin.Answers = updated
+		if err := scoreCheckinInstruments(tx, &checkin); err != nil {
+			return err
+		}
+
+		// the bot may still end the checkin itself; one with a questionnaire
+		// needs no more once everything asked is answered
+		if checkin.QuestionnaireTemplateID == nil {
+			return nil
+		}
+		unanswered, err := unansweredQuestions(&checkin)
+		if err != nil || len(unanswered) > 0 {
+			return err
+		}
+		reason := "every question answered"
+		completed = true
+		return completeCheckin(tx, &checkin, CheckinActor{Trigger: enums.CheckinTriggerSystem, Reason: &reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if completed {
+		s.notifyCompleted(checkin.ID)
+	}
+
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	return &checkin, nil
+}
+
+// vitalAnswerReading prepares the reading a NUMERIC_VITAL answer records,
+// taken when the patient answered, in the question's unit.
+func (s *CheckinService) vitalAnswerReading(patient *models.Patient, checkinID uuid.UUID, question models.CheckinQuestion, answer models.CheckinAnswer) (preparedReading, error) {
+	source := enums.VitalSourceBot
+	input := CreateVitalReadingInput{
+		CheckinID:  &checkinID,
+		PatientID:  patient.UserID,
+		VitalType:  *question.VitalType,
+		Unit:       question.Unit,
+		MeasuredAt: &answer.AnsweredAt,
+		Source:     &source,
+	}
+	switch v := answer.Value.(type) {
+	case float64:
+		input.ValueNumeric = &v
+	case string:
+		input.ValueText = &v
+	}
+
+	reading, assessment, err := s.readings.prepare(patient, input)
+	if err != nil {
+		return preparedReading{}, err
+	}
+	// the answer refers to the reading before it is stored
+	reading.ID = uuid.New()
+	return preparedReading{reading: reading, assessment: assessment}, nil
+}
+
+type preparedReading struct {
+	reading    *models.VitalReading
+	assessment VitalAssessment
+}
+
+// answeredQuestion finds the question an answer is for, or says why it cannot.
+func answeredQuestion(questions []models.CheckinQuestion, in CheckinAnswerInput) (models.CheckinQuestion, string) {
+	if in.QuestionID == nil && in.Seq == nil {
+		return models.CheckinQuestion{}, "needs question_id or seq"
+	}
+	for _, q := range questions {
+		if in.QuestionID != nil && q.ID != *in.QuestionID {
+			continue
+		}
+		if in.Seq != nil && q.Seq != *in.Seq {
+			if in.QuestionID != nil {
+				return models.CheckinQuestion{}, "seq does not match the question"
+			}
+			continue
+		}
+		return q, ""
+	}
+	return models.CheckinQuestion{}, "does not answer a question of this checkin"
+}
+
+// lockActiveCheckin loads the checkin for update, so concurrent additions to
+// its questions and answers apply one after another.
+func lockActiveCheckin(tx *gorm.DB, checkin *models.Checkin, id uuid.UUID) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(checkin, "id = ?", id).Error; err != nil {
+		return err
+	}
+	if !isActiveCheckinStatus(checkin.Status) {
+		return errs.ErrCheckinNotActive
+	}
+	return nil
+}
+
+// unansweredQuestions returns the checkin's questions without an answer, in
+// stored order.
+func unansweredQuestions(checkin *models.Checkin) ([]models.CheckinQuestion, error) {
+	questions, err := checkin.ParsedQuestions()
+	if err != nil {
+		return nil, err
+	}
+	answers, err := checkin.ParsedAnswers()
+	if err != nil {
+		return nil, err
+	}
+	answered := make(map[uuid.UUID]bool, len(answers))
+	for _, a := range answers {
+		answered[a.QuestionID] = true
+	}
+	var unanswered []models.CheckinQuestion
+	for _, q := range questions {
+		if !answered[q.ID] {
+			unanswered = append(unanswered, q)
+		}
+	}
+	return unanswered, nil
+}
+
+// putCheckinItem replaces the item with the given seq, or appends item.
+func putCheckinItem(items []json.RawMessage, seq int, item json.RawMessage) []json.RawMessage {
+	for i, raw := range items {
+		var existing struct {
+			Seq *float64 `json:"seq"`
+		}
+		if json.Unmarshal(raw, &existing) == nil && existing.Seq != nil && *existing.Seq == float64(seq) {
+			items[i] = item
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// putCheckinAnswer replaces the answer to the given question, or appends item.
+func putCheckinAnswer(items []json.RawMessage, questionID uuid.UUID, item json.RawMessage) []json.RawMessage {
+	for i, raw := range items {
+		var existing struct {
+			QuestionID uuid.UUID `json:"question_id"`
+		}
+		if json.Unmarshal(raw, &existing) == nil && existing.QuestionID == questionID {
+			items[i] = item
+			return items
+		}
+	}
+	return append(items, item)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
 	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -77,7 +78,16 @@ func (s *CheckinScheduleService) GetByID(id uuid.UUID) (*models.CheckinSchedule,
 	return &schedule, nil
 }
 
-func (s *CheckinScheduleService) List(includeInactive bool, patientID *uuid.UUID) ([]models.CheckinSchedule, error) {
+var schedulePageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "created_at",
+	IDColumn:    "id",
+}
+
+func (s *CheckinScheduleService) List(includeInactive bool, patientID *uuid.UUID, page pagination.Params) (*pagination.Page[models.CheckinSchedule], error) {
 	query := s.db.Model(&models.CheckinSchedule{})
 	if patientID != nil {
 		query = query.Where("patient_id = ?", *patientID)
@@ -85,11 +95,7 @@ func (s *CheckinScheduleService) List(includeInactive bool, patientID *uuid.UUID
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
 	}
-	var schedules []models.CheckinSchedule
-	if err := query.Order("created_at DESC").Find(&schedules).Error; err != nil {
-		return nil, err
-	}
-	return schedules, nil
+	return pagination.Paginate[models.CheckinSchedule](query, page, schedulePageSpec)
 }
 
 type UpdateScheduleInput struct {
@@ -100,11 +106,15 @@ type UpdateScheduleInput struct {
 	NextCheckinAt *time.Time
 }
 
-func (s *CheckinScheduleService) Update(id uuid.UUID, input UpdateScheduleInput) (*models.CheckinSchedule, error) {
+// Update applies input only if the schedule is still at version.
+func (s *CheckinScheduleService) Update(id uuid.UUID, version int, input UpdateScheduleInput) (*models.CheckinSchedule, error) {
 	var schedule models.CheckinSchedule
 	if err := s.db.First(&schedule, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
+	if err := checkVersion(schedule.Version, version); err != nil {
+		return nil, err
+	}
 
 	updates := map[string]interface{}{}
 	if input.Frequency != nil {
@@ -127,7 +137,7 @@ func (s *CheckinScheduleService) Update(id uuid.UUID, input UpdateScheduleInput)
 		return &schedule, nil
 	}
 
-	if err := s.db.Model(&schedule).Updates(updates).Error; err != nil {
+	if err := updateVersioned(s.db, &models.CheckinSchedule{}, schedule.ID, &version, updates); err != nil {
 		return nil, err
 	}
 
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,20 +11,40 @@ import (
 	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
 	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+const defaultInactivityMinutes = 60
+
 type CheckinService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db       *gorm.DB
+	cfg      *config.Config
+	rules    *ThresholdRuleService
+	readings *VitalReadingService
+
+	completed func(checkinID uuid.UUID) // see OnCompleted
+}
+
+func NewCheckinService(db *gorm.DB, cfg *config.Config, rules *ThresholdRuleService, readings *VitalReadingService) *CheckinService {
+	return &CheckinService{db: db, cfg: cfg, rules: rules, readings: readings}
 }
 
-func NewCheckinService(db *gorm.DB, cfg *config.Config) *CheckinService {
-	return &CheckinService{db: db, cfg: cfg}
+// OnCompleted sets fn to be called with each checkin that completes, once
+// the completion is stored. fn must not block.
+func (s *CheckinService) OnCompleted(fn func(checkinID uuid.UUID)) {
+	s.completed = fn
 }
 
-func (s *CheckinService) StartCheckin(patientID uuid.UUID, scheduleID *uuid.UUID) (*models.Checkin, error) {
+func (s *CheckinService) notifyCompleted(checkinID uuid.UUID) {
+	if s.completed != nil {
+		s.completed(checkinID)
+	}
+}
+
+// StartCheckin opens a PENDING checkin; it moves on once the patient answers.
+func (s *CheckinService) StartCheckin(patientID uuid.UUID, scheduleID *uuid.UUID, actor CheckinActor) (*models.Checkin, error) {
 	var patient models.User
 	if err := s.db.First(&patient, "id = ? AND role = ?", patientID, enums.UserRolePatient).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -51,18 +70,38 @@ func (s *CheckinService) StartCheckin(patientID uuid.UUID, scheduleID *uuid.UUID
 	checkin := models.Checkin{
 		PatientID:   pat.ID,
 		ScheduleID:  scheduleID,
-		Status:      enums.CheckinStatusInProgress,
+		Status:      enums.CheckinStatusPending,
 		InitiatedAt: time.Now(),
 	}
 
-	if err := s.db.Create(&checkin).Error; err != nil {
+	// the checkin asks the questionnaire assigned to the patient at start;
+	// later versions of the template do not change it
+	template, err := resolveQuestionnaire(s.db, &pat)
+	if err != nil {
+		return nil, err
+	}
+	if template != nil {
+		questions, err := templateCheckinQuestions(template)
+		if err != nil {
+			return nil, err
+		}
+		checkin.QuestionnaireTemplateID = &template.ID
+		checkin.Questions = questions
+	}
+
+	if err := createCheckin(s.db, &checkin, actor); err != nil {
 		return nil, err
 	}
 
 	return &checkin, nil
 }
 
-func (s *CheckinService) EndCheckin(patientUserID uuid.UUID) (*models.Checkin, error) {
+// EndCheckin completes the patient's active checkin. It is partial when
+// questions are left unanswered, and always when it is still PENDING, the
+// patient having answered nothing. Checkins the bot does not end
+// complete by themselves once every questionnaire question is answered, or
+// after a spell of inactivity; see AddAnswers and CompleteInactive.
+func (s *CheckinService) EndCheckin(patientUserID uuid.UUID, actor CheckinActor) (*models.Checkin, error) {
 	var patient models.Patient
 	if err := s.db.First(&patient, "user_id = ?", patientUserID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -77,20 +116,96 @@ func (s *CheckinService) EndCheckin(patientUserID uuid.UUID) (*models.Checkin, e
 		return nil, err
 	}
 
-	completedAt := time.Now()
-	if err := s.db.Model(checkin).Updates(map[string]interface{}{
-		"status":       enums.CheckinStatusCompleted,
-		"completed_at": &completedAt,
-	}).Error; err != nil {
+	if err := completeCheckin(s.db, checkin, actor); err != nil {
 		return nil, err
 	}
-
-	checkin.Status = enums.CheckinStatusCompleted
-	checkin.CompletedAt = &completedAt
+	s.notifyCompleted(checkin.ID)
 
 	return checkin, nil
 }
 
+// InactivityTimeout is how long an IN_PROGRESS checkin may go without new
+// questions or answers before CompleteInactive completes it.
+func (s *CheckinService) InactivityTimeout() time.Duration {
+	minutes := 0
+	if s.cfg != nil {
+		minutes = s.cfg.Internal.Checkins.InactivityMinutes
+	}
+	if minutes <= 0 {
+		minutes = defaultInactivityMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// CompleteInactive completes the IN_PROGRESS checkins that have had no new
+// questions or answers for the inactivity timeout, so that those the bot
+// never ended are still analyzed. It returns how many it completed.
+func (s *CheckinService) CompleteInactive(now time.Time, actor CheckinActor) (int, error) {
+	timeout := s.InactivityTimeout()
+	cutoff := now.Add(-timeout)
+	var ids []uuid.UUID
+	if err := s.db.Model(&models.Checkin{}).
+		Where("status = ? AND COALESCE(last_activity_at, initiated_at) <= ?", enums.CheckinStatusInProgress, cutoff).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	if actor.Reason == nil {
+		reason := fmt.Sprintf("no activity for %s", timeout)
+		actor.Reason = &reason
+	}
+	completed := 0
+	var failed []error
+	for _, id := range ids {
+		var checkin models.Checkin
+		idle := false
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := lockActiveCheckin(tx, &checkin, id); err != nil {
+				return err
+			}
+			// answered or ended since it was listed
+			if checkin.Status != enums.CheckinStatusInProgress || lastCheckinActivity(&checkin).After(cutoff) {
+				return nil
+			}
+			idle = true
+			return completeCheckin(tx, &checkin, actor)
+		})
+		if errors.Is(err, errs.ErrCheckinNotActive) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, fmt.Errorf("checkin %s: %w", id, err))
+			continue
+		}
+		if idle {
+			s.notifyCompleted(id)
+			completed++
+		}
+	}
+	return completed, errors.Join(failed...)
+}
+
+// completeCheckin moves an active checkin to COMPLETED, as partial when any
+// of its questions is unanswered or, being PENDING, it has no answers at all.
+// Callers tell OnCompleted once the completion is committed.
+func completeCheckin(db *gorm.DB, checkin *models.Checkin, actor CheckinActor) error {
+	unanswered, err := unansweredQuestions(checkin)
+	if err != nil {
+		return err
+	}
+	return transitionCheckin(db, checkin, enums.CheckinStatusCompleted, actor, nil, map[string]interface{}{
+		"completed_at": time.Now(),
+		"partial":      len(unanswered) > 0 || checkin.Status == enums.CheckinStatusPending,
+	})
+}
+
+func lastCheckinActivity(checkin *models.Checkin) time.Time {
+	if checkin.LastActivityAt != nil {
+		return *checkin.LastActivityAt
+	}
+	return checkin.InitiatedAt
+}
+
 func (s *CheckinService) GetActiveCheckin(patientID uuid.UUID) (*models.Checkin, error) {
 	var patient models.Patient
 	if err := s.db.First(&patient, "user_id = ?", patientID).Error; err != nil {
@@ -110,15 +225,17 @@ func (s *CheckinService) GetByID(checkinID uuid.UUID) (*models.Checkin, error) {
 	return &checkin, nil
 }
 
-func (s *CheckinService) AddQuestions(checkinID uuid.UUID, questions []interface{}) (*models.Checkin, error) {
-	return s.appendToArrayField(checkinID, "questions", questions)
-}
-
-func (s *CheckinService) AddAnswers(checkinID uuid.UUID, answers []interface{}) (*models.Checkin, error) {
-	return s.appendToArrayField(checkinID, "answers", answers)
+var checkinPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"initiated_at": {Expr: "initiated_at", Field: "initiated_at", Kind: pagination.KindTime},
+		"created_at":   {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "initiated_at",
+	DateColumn:  "initiated_at",
+	IDColumn:    "id",
 }
 
-func (s *CheckinService) ListCompletedByPatient(patientID uuid.UUID) ([]models.Checkin, error) {
+func (s *CheckinService) ListCompletedByPatient(patientID uuid.UUID, page pagination.Params) (*pagination.Page[models.Checkin], error) {
 	var patient models.Patient
 	if err := s.db.First(&patient, "user_id = ?", patientID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -127,16 +244,13 @@ func (s *CheckinService) ListCompletedByPatient(patientID uuid.UUID) ([]models.C
 		return nil, err
 	}
 
-	var checkins []models.Checkin
-	if err := s.db.Where("patient_id = ? AND status = ?", patient.ID, enums.CheckinStatusCompleted).
-		Order("initiated_at DESC").
-		Find(&checkins).Error; err != nil {
-		return nil, err
-	}
-	return checkins, nil
+	query := s.db.Model(&models.Checkin{}).Where("patient_id = ? AND status IN ?", patient.ID, completedCheckinStatuses())
+	return pagination.Paginate[models.Checkin](query, page, checkinPageSpec)
 }
 
-func (s *CheckinService) ReviewCheckin(checkinID, doctorID uuid.UUID, doctorNotes *string) (*models.Checkin, error) {
+// ReviewCheckin records the review of an analyzed checkin, only if it is
+// still at version and no other doctor holds a claim on it.
+func (s *CheckinService) ReviewCheckin(checkinID uuid.UUID, version int, doctorID uuid.UUID, doctorNotes *string) (*models.Checkin, error) {
 	if err := s.db.First(&models.User{}, "id = ? AND role = ?", doctorID, enums.UserRoleDoctor).Error; err != nil {
 		return nil, err
 	}
@@ -146,7 +260,10 @@ func (s *CheckinService) ReviewCheckin(checkinID, doctorID uuid.UUID, doctorNote
 		return nil, err
 	}
 
-	if checkin.AIAnalysis == nil {
+	if err := checkVersion(checkin.Version, version); err != nil {
+		return nil, err
+	}
+	if checkin.Status == enums.CheckinStatusCompleted {
 		return nil, errs.ErrCheckinNotAnalyzed
 	}
 
@@ -154,24 +271,37 @@ func (s *CheckinService) ReviewCheckin(checkinID, doctorID uuid.UUID, doctorNote
 	updates := map[string]interface{}{
 		"reviewed_by": doctorID,
 		"reviewed_at": reviewedAt,
+		"claimed_by":  nil,
+		"claimed_at":  nil,
 	}
 	if doctorNotes != nil {
 		updates["doctor_notes"] = doctorNotes
 	}
-
-	if err := s.db.Model(&checkin).Updates(updates).Error; err != nil {
-		return nil, err
+	// a claim is not a new version of the checkin, so the claim check goes
+	// in the update itself to see one taken since the checkin was read
+	claimCutoff := reviewedAt.Add(-time.Duration(reviewSettings(s.cfg).ClaimMinutes) * time.Minute)
+	unclaimed := &checkinGuard{
+		where: "(claimed_by IS NULL OR claimed_by = ? OR claimed_at <= ?)",
+		args:  []interface{}{doctorID, claimCutoff},
+		err:   errs.ErrCheckinClaimed,
 	}
 
-	// acknowledge related alerts for this checkin
-	ackUpdates := map[string]interface{}{
-		"is_acknowledged": true,
-		"acknowledged_by": doctorID,
-		"acknowledged_at": reviewedAt,
-	}
-	if err := s.db.Model(&models.Alert{}).
-		Where("checkin_id = ? AND is_acknowledged = ?", checkinID, false).
-		Updates(ackUpdates).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := guardedTransitionCheckin(tx, &checkin, enums.CheckinStatusReviewed, APICheckinActor(&doctorID, nil), &version, updates, unclaimed); err != nil {
+			return err
+		}
+
+		// acknowledge related alerts for this checkin
+		ackUpdates := map[string]interface{}{
+			"is_acknowledged": true,
+			"acknowledged_by": doctorID,
+			"acknowledged_at": reviewedAt,
+		}
+		return tx.Model(&models.Alert{}).
+			Where("checkin_id = ? AND is_acknowledged = ?", checkinID, false).
+			Updates(ackUpdates).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -197,13 +327,25 @@ type CheckinAIAlertInput struct {
 	Details   *models.JSONB
 }
 
-func (s *CheckinService) UpdateAIFields(checkinID uuid.UUID, input CheckinAIUpdate) (*models.Checkin, error) {
+// UpdateAIFields stores the analysis only if the checkin is still at version.
+// The first analysis of a completed checkin makes it ANALYZED; it may be
+// redone until the checkin is reviewed. The analysis, its alert and the rule
+// alerts it raises are kept or dropped together.
+func (s *CheckinService) UpdateAIFields(checkinID uuid.UUID, version int, input CheckinAIUpdate, actor CheckinActor) (*models.Checkin, error) {
 	var checkin models.Checkin
-	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+	err := s.db.First(&checkin, "id = ?", checkinID).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(checkin.Version, version); err != nil {
 		return nil, err
 	}
 
-	if checkin.Status != enums.CheckinStatusCompleted {
+	switch checkin.Status {
+	case enums.CheckinStatusCompleted, enums.CheckinStatusAnalyzed:
+	case enums.CheckinStatusReviewed:
+		return nil, errs.ErrCheckinReviewed
+	default:
 		return nil, errs.ErrCheckinNotCompleted
 	}
 
@@ -222,15 +364,31 @@ func (s *CheckinService) UpdateAIFields(checkinID uuid.UUID, input CheckinAIUpda
 		return &checkin, nil
 	}
 
-	if err := s.db.Model(&checkin).Updates(updates).Error; err != nil {
-		return nil, err
-	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if checkin.Status == enums.CheckinStatusCompleted && input.AIAnalysis != nil {
+			updates["analyzed_at"] = time.Now()
+			if err := transitionCheckin(tx, &checkin, enums.CheckinStatusAnalyzed, actor, &version, updates); err != nil {
+				return err
+			}
+		} else {
+			// re-analysis before review, or fields other than the analysis itself,
+			// keep the status; the status guard stops it from racing the review
+			if err := updateCheckinInStatus(tx, &checkin, version, updates); err != nil {
+				return err
+			}
+		}
 
-	// create alert if requested
-	if input.Alert != nil {
-		if err := s.createAlertFromAI(checkin, *input.Alert); err != nil {
-			return nil, err
+		if input.Alert != nil {
+			if err := createAlertFromAI(tx, checkin, *input.Alert); err != nil {
+				return err
+			}
 		}
+
+		_, err := s.rules.EvaluateCheckin(tx, checkin.ID, enums.RuleTriggerCheckinAnalysis)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if err := s.db.First(&checkin, "id = ?", checkin.ID).Error; err != nil {
@@ -240,14 +398,14 @@ func (s *CheckinService) UpdateAIFields(checkinID uuid.UUID, input CheckinAIUpda
 	return &checkin, nil
 }
 
-func (s *CheckinService) createAlertFromAI(checkin models.Checkin, alertInput CheckinAIAlertInput) error {
+func createAlertFromAI(db *gorm.DB, checkin models.Checkin, alertInput CheckinAIAlertInput) error {
 	// minimal validation
 	if alertInput.Severity == "" || alertInput.AlertType == "" || alertInput.Title == "" || alertInput.Message == "" {
 		return errs.ErrMissingAlertFields
 	}
 
 	var patient models.Patient
-	if err := s.db.First(&patient, "id = ?", checkin.PatientID).Error; err != nil {
+	if err := db.First(&patient, "id = ?", checkin.PatientID).Error; err != nil {
 		return err
 	}
 
@@ -263,7 +421,7 @@ func (s *CheckinService) createAlertFromAI(checkin models.Checkin, alertInput Ch
 		alert.Details = *alertInput.Details
 	}
 
-	return s.db.Create(&alert).Error
+	return db.Create(&alert).Error
 }
 
 func (s *CheckinService) findActiveCheckin(patientID uuid.UUID) (*models.Checkin, error) {
@@ -279,43 +437,9 @@ func (s *CheckinService) findActiveCheckin(patientID uuid.UUID) (*models.Checkin
 	return &checkin, nil
 }
 
-func (s *CheckinService) appendToArrayField(checkinID uuid.UUID, field string, items []interface{}) (*models.Checkin, error) {
-	var checkin models.Checkin
-	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
-		return nil, err
-	}
-
-	if !isActiveCheckinStatus(checkin.Status) {
-		return nil, errs.ErrCheckinNotActive
-	}
-
-	var current models.JSONB
-	switch field {
-	case "questions":
-		current = checkin.Questions
-	case "answers":
-		current = checkin.Answers
-	default:
-		return nil, errors.New("unsupported field")
-	}
-
-	updated, err := appendJSONBArray(current, items)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := s.db.Model(&checkin).Update(field, updated).Error; err != nil {
-		return nil, err
-	}
-
-	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
-		return nil, err
-	}
-
-	return &checkin, nil
-}
-
-func (s *CheckinService) StartManualCheckin(patientID uuid.UUID, checkingType string) (*models.Checkin, error) {
+// StartManualCheckin starts a checkin and asks the bot to run it. A checkin
+// the bot could not be reached for is marked FAILED.
+func (s *CheckinService) StartManualCheckin(patientID uuid.UUID, checkingType string, actor CheckinActor) (*models.Checkin, error) {
 	var patient models.User
 	if err := s.db.First(&patient, "id = ? AND role = ?", patientID, enums.UserRolePatient).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -340,18 +464,31 @@ func (s *CheckinService) StartManualCheckin(patientID uuid.UUID, checkingType st
 		return nil, err
 	}
 
-	checkin, err := s.StartCheckin(patientID, &schedule.ID)
+	checkin, err := s.StartCheckin(patientID, &schedule.ID, actor)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.notifyBot(patient.ID, checkingType); err != nil {
+		reason := err.Error()
+		failed := CheckinActor{Trigger: enums.CheckinTriggerSystem, Reason: &reason}
+		if ferr := transitionCheckin(s.db, checkin, enums.CheckinStatusFailed, failed, nil, nil); ferr != nil {
+			return nil, errors.Join(err, ferr)
+		}
+		return nil, err
+	}
+
+	return checkin, nil
+}
+
+func (s *CheckinService) notifyBot(patientUserID uuid.UUID, checkingType string) error {
 	// making request to external AI service
-	url := fmt.Sprintf("%s/%s?type=%s", s.cfg.Internal.TgBotURL, patient.ID, checkingType)
+	url := fmt.Sprintf("%s/%s?type=%s", s.cfg.Internal.TgBotURL, patientUserID, checkingType)
 
 	// create HTTP request
 	req, err := http.NewRequest(http.MethodPost, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -363,73 +500,15 @@ func (s *CheckinService) StartManualCheckin(patientID uuid.UUID, checkingType st
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request to bot service: %w", err)
+		return errs.ErrBotUnavailable.Wrap(fmt.Errorf("failed to send request to bot service: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 
-		return nil, fmt.Errorf("bot service returned error status: %d; body: %v", resp.StatusCode, string(body))
-	}
-
-	return checkin, nil
-}
-
-func appendJSONBArray(existing models.JSONB, additions []interface{}) (models.JSONB, error) {
-	var arr []map[string]interface{}
-	if len(existing) > 0 {
-		if err := json.Unmarshal(existing, &arr); err != nil {
-			return nil, err
-		}
-	}
-
-	// Build a map of existing items by seq
-	seqMap := make(map[float64]int) // seq -> index in arr
-	for i, item := range arr {
-		if seq, ok := item["seq"].(float64); ok {
-			seqMap[seq] = i
-		}
+		return errs.ErrBotUnavailable.Wrap(fmt.Errorf("bot service returned error status: %d; body: %v", resp.StatusCode, string(body)))
 	}
 
-	// Merge or append new items
-	for _, add := range additions {
-		addMap, ok := add.(map[string]interface{})
-		if !ok {
-			arr = append(arr, map[string]interface{}{"data": add})
-			continue
-		}
-
-		if seq, exists := addMap["seq"].(float64); exists {
-			if idx, found := seqMap[seq]; found {
-				// Update existing item with same seq
-				arr[idx] = addMap
-			} else {
-				// New seq, append
-				arr = append(arr, addMap)
-				seqMap[seq] = len(arr) - 1
-			}
-		} else {
-			// No seq field, just append
-			arr = append(arr, addMap)
-		}
-	}
-
-	return models.NewJSONB(arr)
-}
-
-func activeCheckinStatuses() []enums.CheckinStatus {
-	return []enums.CheckinStatus{
-		enums.CheckinStatusPending,
-		enums.CheckinStatusInProgress,
-	}
-}
-
-func isActiveCheckinStatus(status enums.CheckinStatus) bool {
-	for _, active := range activeCheckinStatuses() {
-		if status == active {
-			return true
-		}
-	}
-	return false
+	return nil
 }
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+)
+
+func newAnalyzedCheckinDB(t *testing.T) (*CheckinService, *fakeDB, uuid.UUID) {
+	t.Helper()
+	checkinID, patientID := uuid.New(), uuid.New()
+	db, fake := newFakeDB(t, func(query string, _ []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "checkins"`):
+			return &fakeRows{
+				columns: []string{"id", "patient_id", "status", "version"},
+				values:  [][]driver.Value{{checkinID.String(), patientID.String(), "COMPLETED", int64(3)}},
+			}
+		case strings.Contains(query, `FROM "patients"`):
+			return &fakeRows{
+				columns: []string{"id", "user_id", "doctor_id"},
+				values:  [][]driver.Value{{patientID.String(), uuid.NewString(), uuid.NewString()}},
+			}
+		}
+		return nil
+	})
+	catalog := testVitalCatalog()
+	return NewCheckinService(db, nil, NewThresholdRuleService(db, catalog), nil), fake, checkinID
+}
+
+func TestUpdateAIFieldsAnalyzesInOneTransaction(t *testing.T) {
+	s, fake, checkinID := newAnalyzedCheckinDB(t)
+	analysis, _ := models.NewJSONB(map[string]string{"summary": "worse breathing"})
+	status, risk := enums.MedicalStatusUrgent, 62
+
+	_, err := s.UpdateAIFields(checkinID, 3, CheckinAIUpdate{
+		AIAnalysis:    &analysis,
+		MedicalStatus: &status,
+		RiskScore:     &risk,
+		Alert:         &CheckinAIAlertInput{Severity: enums.AlertSeverityHigh, AlertType: enums.AlertTypeAnalysisFinding, Title: "Breathing worse", Message: "Reports worse breathing"},
+	}, CheckinActor{Trigger: enums.CheckinTriggerSystem})
+	if err != nil {
+		t.Fatalf("UpdateAIFields: %v", err)
+	}
+	if fake.commits != 1 || fake.rollbacks != 0 {
+		t.Errorf("%d commits and %d rollbacks, want the analysis committed once", fake.commits, fake.rollbacks)
+	}
+	if transitions := fake.insertedInto("checkin_transitions"); len(transitions) != 1 || transitions[0]["to_status"] != "ANALYZED" {
+		t.Errorf("transitions = %v, want one to ANALYZED", transitions)
+	}
+	if alerts := fake.insertedInto("alerts"); len(alerts) != 1 || alerts[0]["checkin_id"] != checkinID.String() {
+		t.Errorf("alerts = %v, want the analysis alert of the checkin", alerts)
+	}
+}
+
+func TestUpdateAIFieldsRollsBackWithAnInvalidAlert(t *testing.T) {
+	s, fake, checkinID := newAnalyzedCheckinDB(t)
+	analysis, _ := models.NewJSONB(map[string]string{"summary": "worse breathing"})
+
+	_, err := s.UpdateAIFields(checkinID, 3, CheckinAIUpdate{
+		AIAnalysis: &analysis,
+		Alert:      &CheckinAIAlertInput{Severity: enums.AlertSeverityHigh, AlertType: enums.AlertTypeAnalysisFinding},
+	}, CheckinActor{Trigger: enums.CheckinTriggerSystem})
+	if !errors.Is(err, errs.ErrMissingAlertFields) {
+		t.Fatalf("err = %v, want ErrMissingAlertFields", err)
+	}
+	// the ANALYZED transition is undone with the alert
+	if fake.commits != 0 || fake.rollbacks != 1 {
+		t.Errorf("%d commits and %d rollbacks, want the analysis rolled back", fake.commits, fake.rollbacks)
+	}
+}
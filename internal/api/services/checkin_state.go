@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// checkinTransitions is the checkin state machine: the statuses each status
+// may move to. A checkin runs PENDING → IN_PROGRESS → COMPLETED → ANALYZED →
+// REVIEWED and can end early as FAILED, MISSED or CANCELLED while it is still
+// active. The bot may end a checkin before the patient answers anything,
+// which completes it from PENDING as partial. Statuses without an entry are
+// final.
+var checkinTransitions = map[enums.CheckinStatus][]enums.CheckinStatus{
+	enums.CheckinStatusPending: {
+		enums.CheckinStatusInProgress,
+		enums.CheckinStatusCompleted,
+		enums.CheckinStatusFailed,
+		enums.CheckinStatusMissed,
+		enums.CheckinStatusCancelled,
+	},
+	enums.CheckinStatusInProgress: {
+		enums.CheckinStatusCompleted,
+		enums.CheckinStatusFailed,
+		enums.CheckinStatusMissed,
+		enums.CheckinStatusCancelled,
+	},
+	enums.CheckinStatusCompleted: {enums.CheckinStatusAnalyzed},
+	enums.CheckinStatusAnalyzed:  {enums.CheckinStatusReviewed},
+}
+
+func canTransitionCheckin(from, to enums.CheckinStatus) bool {
+	return slices.Contains(checkinTransitions[from], to)
+}
+
+func activeCheckinStatuses() []enums.CheckinStatus {
+	return []enums.CheckinStatus{
+		enums.CheckinStatusPending,
+		enums.CheckinStatusInProgress,
+	}
+}
+
+func isActiveCheckinStatus(status enums.CheckinStatus) bool {
+	return slices.Contains(activeCheckinStatuses(), status)
+}
+
+// completedCheckinStatuses are the statuses of checkins the patient finished.
+func completedCheckinStatuses() []enums.CheckinStatus {
+	return []enums.CheckinStatus{
+		enums.CheckinStatusCompleted,
+		enums.CheckinStatusAnalyzed,
+		enums.CheckinStatusReviewed,
+	}
+}
+
+// CheckinActor is who or what changes a checkin's status, recorded in its
+// transition history.
+type CheckinActor struct {
+	Trigger enums.CheckinTrigger
+	UserID  *uuid.UUID
+	Reason  *string
+}
+
+// APICheckinActor is the actor of an API call, on behalf of userID when it is
+// known. A blank reason is recorded as none.
+func APICheckinActor(userID *uuid.UUID, reason *string) CheckinActor {
+	reason = trimmedOrNil(reason)
+	if userID != nil {
+		return CheckinActor{Trigger: enums.CheckinTriggerUser, UserID: userID, Reason: reason}
+	}
+	return CheckinActor{Trigger: enums.CheckinTriggerAPI, Reason: reason}
+}
+
+// createCheckin stores a new checkin in its initial status and records it as
+// the first transition.
+func createCheckin(db *gorm.DB, checkin *models.Checkin, actor CheckinActor) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(checkin).Error; err != nil {
+			return err
+		}
+		return recordCheckinTransition(tx, checkin.ID, nil, checkin.Status, actor)
+	})
+}
+
+// transitionCheckin moves checkin to status to along with updates, and
+// records the change. It fails with ErrCheckinTransition when the state
+// machine does not allow the move, including when another request changed
+// the status first, and with ErrVersionMismatch when expectedVersion is stale.
+// checkin is updated in place on success.
+func transitionCheckin(db *gorm.DB, checkin *models.Checkin, to enums.CheckinStatus, actor CheckinActor, expectedVersion *int, updates map[string]interface{}) error {
+	return guardedTransitionCheckin(db, checkin, to, actor, expectedVersion, updates, nil)
+}
+
+// checkinGuard is a further condition on the checkin row a transition
+// applies to, checked in the same UPDATE. The transition fails with err when
+// the row is still in its status and version but the condition rules it out.
+type checkinGuard struct {
+	where string
+	args  []interface{}
+	err   error
+}
+
+// guardedTransitionCheckin is transitionCheckin with a guard, when it is not nil.
+func guardedTransitionCheckin(db *gorm.DB, checkin *models.Checkin, to enums.CheckinStatus, actor CheckinActor, expectedVersion *int, updates map[string]interface{}, guard *checkinGuard) error {
+	from := checkin.Status
+	if !canTransitionCheckin(from, to) {
+		return checkinTransitionError(from, to)
+	}
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+	updates["version"] = gorm.Expr("version + 1")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// the status guard makes concurrent transitions from the same status
+		// exclusive: only the first one applies
+		query := tx.Model(&models.Checkin{}).Where("id = ? AND status = ?", checkin.ID, from)
+		if expectedVersion != nil {
+			query = query.Where("version = ?", *expectedVersion)
+		}
+		if guard != nil {
+			query = query.Where(guard.where, guard.args...)
+		}
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var current models.Checkin
+			if err := tx.Select("status", "version").First(&current, "id = ?", checkin.ID).Error; err != nil {
+				return err
+			}
+			if expectedVersion != nil && current.Version != *expectedVersion {
+				return errs.ErrVersionMismatch
+			}
+			if guard != nil && current.Status == from {
+				return guard.err
+			}
+			return checkinTransitionError(current.Status, to)
+		}
+		return recordCheckinTransition(tx, checkin.ID, &from, to, actor)
+	})
+	if err != nil {
+		return err
+	}
+	return db.First(checkin, "id = ?", checkin.ID).Error
+}
+
+// updateCheckinInStatus applies updates that keep the status, only while the
+// checkin is still in it and at expectedVersion.
+func updateCheckinInStatus(db *gorm.DB, checkin *models.Checkin, expectedVersion int, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+	result := db.Model(&models.Checkin{}).
+		Where("id = ? AND status = ? AND version = ?", checkin.ID, checkin.Status, expectedVersion).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// a status change bumps the version too
+		return errs.ErrVersionMismatch
+	}
+	return db.First(checkin, "id = ?", checkin.ID).Error
+}
+
+func recordCheckinTransition(db *gorm.DB, checkinID uuid.UUID, from *enums.CheckinStatus, to enums.CheckinStatus, actor CheckinActor) error {
+	return db.Create(&models.CheckinTransition{
+		CheckinID:  checkinID,
+		FromStatus: from,
+		ToStatus:   to,
+		Trigger:    actor.Trigger,
+		UserID:     actor.UserID,
+		Reason:     actor.Reason,
+	}).Error
+}
+
+func checkinTransitionError(from, to enums.CheckinStatus) error {
+	return errs.ErrCheckinTransition.WithMessage(fmt.Sprintf("a %s checkin cannot become %s", from, to))
+}
+
+// Cancel calls off an active checkin.
+func (s *CheckinService) Cancel(checkinID uuid.UUID, actor CheckinActor) (*models.Checkin, error) {
+	return s.moveTo(checkinID, enums.CheckinStatusCancelled, actor)
+}
+
+// Fail marks an active checkin as one that could not be carried out.
+func (s *CheckinService) Fail(checkinID uuid.UUID, actor CheckinActor) (*models.Checkin, error) {
+	return s.moveTo(checkinID, enums.CheckinStatusFailed, actor)
+}
+
+// Miss marks an active checkin the patient never finished.
+func (s *CheckinService) Miss(checkinID uuid.UUID, actor CheckinActor) (*models.Checkin, error) {
+	return s.moveTo(checkinID, enums.CheckinStatusMissed, actor)
+}
+
+func (s *CheckinService) moveTo(checkinID uuid.UUID, to enums.CheckinStatus, actor CheckinActor) (*models.Checkin, error) {
+	if actor.UserID != nil {
+		if err := s.db.Select("id").First(&models.User{}, "id = ?", *actor.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrUserNotFound
+			}
+			return nil, err
+		}
+	}
+
+	var checkin models.Checkin
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	if err := transitionCheckin(s.db, &checkin, to, actor, nil, nil); err != nil {
+		return nil, err
+	}
+	return &checkin, nil
+}
+
+// Transitions returns a checkin's status history, oldest first.
+func (s *CheckinService) Transitions(checkinID uuid.UUID) ([]models.CheckinTransition, error) {
+	if err := s.db.Select("id").First(&models.Checkin{}, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	var transitions []models.CheckinTransition
+	if err := s.db.Where("checkin_id = ?", checkinID).Order("created_at, id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+)
+
+func TestCanTransitionCheckin(t *testing.T) {
+	tests := []struct {
+		from, to enums.CheckinStatus
+		want     bool
+	}{
+		{enums.CheckinStatusPending, enums.CheckinStatusInProgress, true},
+		// the bot ends checkins the patient never answered
+		{enums.CheckinStatusPending, enums.CheckinStatusCompleted, true},
+		{enums.CheckinStatusInProgress, enums.CheckinStatusCompleted, true},
+		{enums.CheckinStatusInProgress, enums.CheckinStatusCancelled, true},
+		{enums.CheckinStatusCompleted, enums.CheckinStatusAnalyzed, true},
+		{enums.CheckinStatusAnalyzed, enums.CheckinStatusReviewed, true},
+		{enums.CheckinStatusPending, enums.CheckinStatusAnalyzed, false},
+		{enums.CheckinStatusCompleted, enums.CheckinStatusReviewed, false},
+		{enums.CheckinStatusCompleted, enums.CheckinStatusCancelled, false},
+		{enums.CheckinStatusReviewed, enums.CheckinStatusAnalyzed, false},
+		{enums.CheckinStatusCancelled, enums.CheckinStatusInProgress, false},
+	}
+	for _, tt := range tests {
+		if got := canTransitionCheckin(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransitionCheckin(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestAPICheckinActorReason(t *testing.T) {
+	blank, given := "  ", " patient moved to hospital "
+	if actor := APICheckinActor(nil, &blank); actor.Reason != nil {
+		t.Errorf("reason = %q, want none for a blank reason", *actor.Reason)
+	}
+	if actor := APICheckinActor(nil, nil); actor.Reason != nil || actor.Trigger != enums.CheckinTriggerAPI {
+		t.Errorf("actor = %+v, want an API actor without a reason", actor)
+	}
+	if actor := APICheckinActor(nil, &given); actor.Reason == nil || *actor.Reason != "patient moved to hospital" {
+		t.Errorf("reason = %v, want it trimmed", actor.Reason)
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EarlyWarningSystem names the scoring system stored in the breakdown.
+const EarlyWarningSystem = "NEWS2"
+
+// NEWS2 parameters without a vital type. They are never measured by the bot,
+// so they are scored as the normal finding and marked as assumed.
+const (
+	news2AirOrOxygen   = "AIR_OR_OXYGEN"
+	news2Consciousness = "CONSCIOUSNESS"
+)
+
+// news2Band scores values up to and including upTo.
+type news2Band struct {
+	upTo  float64
+	score int
+}
+
+// news2Measured lists the measured NEWS2 parameters in chart order, with the
+// SpO2 scale 1 bands; blood pressure is scored on systolic.
+var news2Measured = []struct {
+	vitalType enums.VitalType
+	bands     []news2Band
+}{
+	{enums.VitalTypeRespiratoryRate, []news2Band{{8, 3}, {11, 1}, {20, 0}, {24, 2}, {math.Inf(1), 3}}},
+	{enums.VitalTypeOxygenSaturation, []news2Band{{91, 3}, {93, 2}, {95, 1}, {math.Inf(1), 0}}},
+	{enums.VitalTypeBloodPressure, []news2Band{{90, 3}, {100, 2}, {110, 1}, {219, 0}, {math.Inf(1), 3}}},
+	{enums.VitalTypeHeartRate, []news2Band{{40, 3}, {50, 1}, {90, 0}, {110, 1}, {130, 2}, {math.Inf(1), 3}}},
+	{enums.VitalTypeTemperature, []news2Band{{35, 3}, {36, 1}, {38, 0}, {39, 1}, {math.Inf(1), 2}}},
+}
+
+var earlyWarningRiskRank = map[enums.EarlyWarningRisk]int{
+	enums.EarlyWarningRiskLow:       0,
+	enums.EarlyWarningRiskLowMedium: 1,
+	enums.EarlyWarningRiskMedium:    2,
+	enums.EarlyWarningRiskHigh:      3,
+}
+
+var earlyWarningAlertSeverity = map[enums.EarlyWarningRisk]enums.AlertSeverity{
+	enums.EarlyWarningRiskLowMedium: enums.AlertSeverityMedium,
+	enums.EarlyWarningRiskMedium:    enums.AlertSeverityHigh,
+	enums.EarlyWarningRiskHigh:      enums.AlertSeverityCritical,
+}
+
+type EarlyWarningParameter struct {
+	Parameter      string           `json:"parameter"`
+	VitalReadingID *uuid.UUID       `json:"vital_reading_id,omitempty"`
+	Value          *float64         `json:"value,omitempty"`
+	Unit           *enums.VitalUnit `json:"unit,omitempty"`
+	Score          int              `json:"score"`
+	// Assumed is set when the parameter was not measured and scored as normal.
+	Assumed bool `json:"assumed,omitempty"`
+}
+
+// EarlyWarning is a checkin's NEWS2 score with its per-parameter breakdown.
+// Complete is false when a measured parameter had no reading; the score then
+// only covers what was measured.
+type EarlyWarning struct {
+	System     string                  `json:"system"`
+	Score      int                     `json:"score"`
+	Risk       enums.EarlyWarningRisk  `json:"risk"`
+	Parameters []EarlyWarningParameter `json:"parameters"`
+	Missing    []enums.VitalType       `json:"missing"`
+	Complete   bool                    `json:"complete"`
+}
+
+// scoreEarlyWarning scores the latest reading of each NEWS2 parameter.
+// It returns nil when none of them was measured.
+func scoreEarlyWarning(readings []models.VitalReading) *EarlyWarning {
+	latest := map[enums.VitalType]models.VitalReading{}
+	for _, r := range readings {
+		if r.ValueNumeric == nil {
+			continue
+		}
+		if prev, ok := latest[r.VitalType]; !ok || !r.MeasuredAt.Before(prev.MeasuredAt) {
+			latest[r.VitalType] = r
+		}
+	}
+
+	ew := &EarlyWarning{System: EarlyWarningSystem, Missing: []enums.VitalType{}}
+	measured, red := 0, false
+	for i, p := range news2Measured {
+		// supplemental oxygen is charted after SpO2
+		if i == 2 {
+			ew.Parameters = append(ew.Parameters, EarlyWarningParameter{Parameter: news2AirOrOxygen, Assumed: true})
+		}
+		r, ok := latest[p.vitalType]
+		if !ok {
+			ew.Missing = append(ew.Missing, p.vitalType)
+			continue
+		}
+		value := *r.ValueNumeric
+		if r.Systolic != nil {
+			value = *r.Systolic
+		}
+		score := news2Score(p.bands, value)
+		measured++
+		red = red || score == 3
+		ew.Score += score
+		ew.Parameters = append(ew.Parameters, EarlyWarningParameter{
+			Parameter:      string(p.vitalType),
+			VitalReadingID: ptr(r.ID),
+			Value:          &value,
+			Unit:           r.Unit,
+			Score:          score,
+		})
+	}
+	ew.Parameters = append(ew.Parameters, EarlyWarningParameter{Parameter: news2Consciousness, Assumed: true})
+	if measured == 0 {
+		return nil
+	}
+	ew.Complete = len(ew.Missing) == 0
+
+	switch {
+	case ew.Score >= 7:
+		ew.Risk = enums.EarlyWarningRiskHigh
+	case ew.Score >= 5:
+		ew.Risk = enums.EarlyWarningRiskMedium
+	case red:
+		ew.Risk = enums.EarlyWarningRiskLowMedium
+	default:
+		ew.Risk = enums.EarlyWarningRiskLow
+	}
+	return ew
+}
+
+func news2Score(bands []news2Band, value float64) int {
+	for _, b := range bands {
+		if value <= b.upTo {
+			return b.score
+		}
+	}
+	return bands[len(bands)-1].score
+}
+
+func isEarlyWarningParameter(vt enums.VitalType) bool {
+	for _, p := range news2Measured {
+		if p.vitalType == vt {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshEarlyWarning recomputes a checkin's early warning score from its
+// readings and raises an EARLY_WARNING alert when the risk band rises to
+// LOW_MEDIUM or above. db may be a transaction.
+func refreshEarlyWarning(db *gorm.DB, checkinID uuid.UUID) error {
+	var readings []models.VitalReading
+	if err := db.Where("checkin_id = ? AND value_numeric IS NOT NULL", checkinID).
+		Order("measured_at ASC").
+		Find(&readings).Error; err != nil {
+		return err
+	}
+
+	ew := scoreEarlyWarning(readings)
+	updates := map[string]interface{}{
+		"early_warning_score":       nil,
+		"early_warning_risk":        nil,
+		"early_warning_breakdown":   nil,
+		"early_warning_computed_at": nil,
+	}
+	if ew != nil {
+		breakdown, err := models.NewJSONB(ew)
+		if err != nil {
+			return err
+		}
+		updates["early_warning_score"] = ew.Score
+		updates["early_warning_risk"] = ew.Risk
+		updates["early_warning_breakdown"] = breakdown
+		updates["early_warning_computed_at"] = time.Now()
+	}
+	if err := updateVersioned(db, &models.Checkin{}, checkinID, nil, updates); err != nil {
+		return err
+	}
+
+	if ew == nil || ew.Risk == enums.EarlyWarningRiskLow {
+		return nil
+	}
+
+	var raised []string
+	if err := db.Model(&models.Alert{}).
+		Where("checkin_id = ? AND alert_type = ?", checkinID, enums.AlertTypeEarlyWarning).
+		Pluck("details->>'risk'", &raised).Error; err != nil {
+		return err
+	}
+	if riskRaised(raised, ew.Risk) {
+		return nil
+	}
+
+	var checkin models.Checkin
+	if err := db.Select("id", "patient_id").First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return err
+	}
+	return db.Create(earlyWarningAlert(checkin.PatientID, &checkin.ID, ew)).Error
+}
+
+// patientEarlyWarning scores the patient's readings of the last day, in or
+// outside checkins, and raises an EARLY_WARNING alert without a checkin when
+// the risk band is LOW_MEDIUM or above and no alert of the band or a higher
+// one was raised that way in the last day. It covers readings uploaded on
+// their own, which have no checkin to keep the score on. db may be a
+// transaction.
+func patientEarlyWarning(db *gorm.DB, patientID uuid.UUID) error {
+	readings, err := recentPatientReadings(db, patientID)
+	if err != nil {
+		return err
+	}
+	ew := scoreEarlyWarning(readings)
+	if ew == nil || ew.Risk == enums.EarlyWarningRiskLow {
+		return nil
+	}
+
+	var raised []string
+	if err := db.Model(&models.Alert{}).
+		Where("patient_id = ? AND checkin_id IS NULL AND alert_type = ? AND created_at >= ?", patientID, enums.AlertTypeEarlyWarning, time.Now().Add(-alertableReadingAge)).
+		Pluck("details->>'risk'", &raised).Error; err != nil {
+		return err
+	}
+	if riskRaised(raised, ew.Risk) {
+		return nil
+	}
+	return db.Create(earlyWarningAlert(patientID, nil, ew)).Error
+}
+
+// riskRaised reports whether one of the raised risk bands is at least risk.
+func riskRaised(raised []string, risk enums.EarlyWarningRisk) bool {
+	for _, r := range raised {
+		if earlyWarningRiskRank[enums.EarlyWarningRisk(r)] >= earlyWarningRiskRank[risk] {
+			return true
+		}
+	}
+	return false
+}
+
+func earlyWarningAlert(patientID uuid.UUID, checkinID *uuid.UUID, ew *EarlyWarning) *models.Alert {
+	var parts []string
+	for _, p := range ew.Parameters {
+		if p.Score > 0 && p.Value != nil {
+			parts = append(parts, fmt.Sprintf("%s %s (+%d)", strings.ToLower(vitalLabel(enums.VitalType(p.Parameter))), formatValue(*p.Value), p.Score))
+		}
+	}
+	message := fmt.Sprintf("NEWS2 score %d: %s", ew.Score, strings.Join(parts, ", "))
+	if !ew.Complete {
+		message += fmt.Sprintf("; not measured: %d of %d parameters", len(ew.Missing), len(news2Measured))
+	}
+
+	details, _ := models.NewJSONB(map[string]interface{}{
+		"system":     ew.System,
+		"score":      ew.Score,
+		"risk":       ew.Risk,
+		"complete":   ew.Complete,
+		"parameters": ew.Parameters,
+		"missing":    ew.Missing,
+	})
+
+	return &models.Alert{
+		PatientID: patientID,
+		CheckinID: checkinID,
+		Severity:  earlyWarningAlertSeverity[ew.Risk],
+		AlertType: enums.AlertTypeEarlyWarning,
+		Title:     fmt.Sprintf("Early warning score %d (%s risk)", ew.Score, strings.ToLower(strings.ReplaceAll(string(ew.Risk), "_", "-"))),
+		Message:   message,
+		Details:   details,
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+func news2Bands(t *testing.T, vt enums.VitalType) []news2Band {
+	t.Helper()
+	for _, p := range news2Measured {
+		if p.vitalType == vt {
+			return p.bands
+		}
+	}
+	t.Fatalf("%s is not a NEWS2 parameter", vt)
+	return nil
+}
+
+func TestNEWS2ScoreBandEdges(t *testing.T) {
+	tests := []struct {
+		vitalType enums.VitalType
+		value     float64
+		want      int
+	}{
+		{enums.VitalTypeRespiratoryRate, 8, 3},
+		{enums.VitalTypeRespiratoryRate, 9, 1},
+		{enums.VitalTypeRespiratoryRate, 11, 1},
+		{enums.VitalTypeRespiratoryRate, 12, 0},
+		{enums.VitalTypeRespiratoryRate, 20, 0},
+		{enums.VitalTypeRespiratoryRate, 21, 2},
+		{enums.VitalTypeRespiratoryRate, 24, 2},
+		{enums.VitalTypeRespiratoryRate, 25, 3},
+
+		{enums.VitalTypeOxygenSaturation, 91, 3},
+		{enums.VitalTypeOxygenSaturation, 92, 2},
+		{enums.VitalTypeOxygenSaturation, 93, 2},
+		{enums.VitalTypeOxygenSaturation, 94, 1},
+		{enums.VitalTypeOxygenSaturation, 95, 1},
+		{enums.VitalTypeOxygenSaturation, 96, 0},
+		{enums.VitalTypeOxygenSaturation, 100, 0},
+
+		{enums.VitalTypeBloodPressure, 90, 3},
+		{enums.VitalTypeBloodPressure, 91, 2},
+		{enums.VitalTypeBloodPressure, 100, 2},
+		{enums.VitalTypeBloodPressure, 101, 1},
+		{enums.VitalTypeBloodPressure, 110, 1},
+		{enums.VitalTypeBloodPressure, 111, 0},
+		{enums.VitalTypeBloodPressure, 219, 0},
+		{enums.VitalTypeBloodPressure, 220, 3},
+
+		{enums.VitalTypeHeartRate, 40, 3},
+		{enums.VitalTypeHeartRate, 41, 1},
+		{enums.VitalTypeHeartRate, 50, 1},
+		{enums.VitalTypeHeartRate, 51, 0},
+		{enums.VitalTypeHeartRate, 90, 0},
+		{enums.VitalTypeHeartRate, 91, 1},
+		{enums.VitalTypeHeartRate, 110, 1},
+		{enums.VitalTypeHeartRate, 111, 2},
+		{enums.VitalTypeHeartRate, 130, 2},
+		{enums.VitalTypeHeartRate, 131, 3},
+
+		{enums.VitalTypeTemperature, 35.0, 3},
+		{enums.VitalTypeTemperature, 35.1, 1},
+		{enums.VitalTypeTemperature, 36.0, 1},
+		{enums.VitalTypeTemperature, 36.1, 0},
+		{enums.VitalTypeTemperature, 38.0, 0},
+		{enums.VitalTypeTemperature, 38.1, 1},
+		{enums.VitalTypeTemperature, 39.0, 1},
+		{enums.VitalTypeTemperature, 39.1, 2},
+	}
+	for _, tt := range tests {
+		if got := news2Score(news2Bands(t, tt.vitalType), tt.value); got != tt.want {
+			t.Errorf("%s %v scores %d, want %d", tt.vitalType, tt.value, got, tt.want)
+		}
+	}
+}
+
+// news2Readings makes one reading per vital type, measured an hour ago.
+func news2Readings(values map[enums.VitalType]float64) []models.VitalReading {
+	measured := time.Now().Add(-time.Hour)
+	var readings []models.VitalReading
+	for vt, v := range values {
+		value := v
+		readings = append(readings, models.VitalReading{ID: uuid.New(), VitalType: vt, ValueNumeric: &value, MeasuredAt: measured})
+	}
+	return readings
+}
+
+func TestScoreEarlyWarningRisk(t *testing.T) {
+	normal := map[enums.VitalType]float64{
+		enums.VitalTypeRespiratoryRate:  16,
+		enums.VitalTypeOxygenSaturation: 97,
+		enums.VitalTypeBloodPressure:    120,
+		enums.VitalTypeHeartRate:        72,
+		enums.VitalTypeTemperature:      36.8,
+	}
+	with := func(changes map[enums.VitalType]float64) map[enums.VitalType]float64 {
+		values := map[enums.VitalType]float64{}
+		for vt, v := range normal {
+			values[vt] = v
+		}
+		for vt, v := range changes {
+			values[vt] = v
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values map[enums.VitalType]float64
+		score  int
+		risk   enums.EarlyWarningRisk
+	}{
+		{"all normal", normal, 0, enums.EarlyWarningRiskLow},
+		{"low aggregate", with(map[enums.VitalType]float64{enums.VitalTypeHeartRate: 95, enums.VitalTypeTemperature: 38.5}), 2, enums.EarlyWarningRiskLow},
+		{"single parameter scoring 3 is a red flag", with(map[enums.VitalType]float64{enums.VitalTypeRespiratoryRate: 26}), 3, enums.EarlyWarningRiskLowMedium},
+		{"4 without a red flag", with(map[enums.VitalType]float64{enums.VitalTypeRespiratoryRate: 22, enums.VitalTypeHeartRate: 115}), 4, enums.EarlyWarningRiskLow},
+		{"medium from 5", with(map[enums.VitalType]float64{enums.VitalTypeOxygenSaturation: 93, enums.VitalTypeHeartRate: 115, enums.VitalTypeTemperature: 38.5}), 5, enums.EarlyWarningRiskMedium},
+		{"high from 7", with(map[enums.VitalType]float64{enums.VitalTypeOxygenSaturation: 90, enums.VitalTypeHeartRate: 135, enums.VitalTypeTemperature: 39.5}), 8, enums.EarlyWarningRiskHigh},
+		{"partial measurement", map[enums.VitalType]float64{enums.VitalTypeOxygenSaturation: 91}, 3, enums.EarlyWarningRiskLowMedium},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ew := scoreEarlyWarning(news2Readings(tt.values))
+			if ew == nil {
+				t.Fatal("scoreEarlyWarning = nil")
+			}
+			if ew.Score != tt.score || ew.Risk != tt.risk {
+				t.Errorf("score, risk = %d, %s; want %d, %s", ew.Score, ew.Risk, tt.score, tt.risk)
+			}
+			if ew.Complete != (len(tt.values) == len(news2Measured)) || len(ew.Missing) != len(news2Measured)-len(tt.values) {
+				t.Errorf("complete = %t with missing %v for %d measured parameters", ew.Complete, ew.Missing, len(tt.values))
+			}
+		})
+	}
+}
+
+func TestScoreEarlyWarningReadings(t *testing.T) {
+	if ew := scoreEarlyWarning(news2Readings(map[enums.VitalType]float64{enums.VitalTypeWeight: 80})); ew != nil {
+		t.Errorf("scoreEarlyWarning = %+v, want nil without NEWS2 parameters", ew)
+	}
+
+	// the latest reading counts, and blood pressure is scored on systolic
+	older, latest := 30.0, 16.0
+	systolic, diastolic, mean := 85.0, 60.0, 68.3
+	now := time.Now()
+	ew := scoreEarlyWarning([]models.VitalReading{
+		{VitalType: enums.VitalTypeRespiratoryRate, ValueNumeric: &latest, MeasuredAt: now},
+		{VitalType: enums.VitalTypeRespiratoryRate, ValueNumeric: &older, MeasuredAt: now.Add(-time.Hour)},
+		{VitalType: enums.VitalTypeBloodPressure, ValueNumeric: &mean, Systolic: &systolic, Diastolic: &diastolic, MeasuredAt: now},
+	})
+	if ew == nil || ew.Score != 3 {
+		t.Fatalf("early warning = %+v, want 3 for the systolic only", ew)
+	}
+	for _, p := range ew.Parameters {
+		if p.Parameter == string(enums.VitalTypeBloodPressure) && (p.Value == nil || *p.Value != systolic) {
+			t.Errorf("blood pressure value = %v, want the systolic", p.Value)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDB stands in for Postgres in service tests. Inserted rows are kept per
+// table; queries are answered by the test's query function, which tells them
+// apart by their SQL and may reply with the inserted rows. WHERE clauses are
+// not evaluated.
+type fakeDB struct {
+	t     *testing.T
+	query func(sql string, args []driver.Value) *fakeRows
+	// conflict, when set, reports the rows an INSERT ... ON CONFLICT finds
+	// already there; they are not inserted.
+	conflict func(table string, row map[string]driver.Value) bool
+
+	mu        sync.Mutex
+	inserted  map[string][]map[string]driver.Value
+	commits   int
+	rollbacks int
+}
+
+// fakeRows is a query result; nil answers with no rows.
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// newFakeDB opens gorm on a fakeDB answering queries with query.
+func newFakeDB(t *testing.T, query func(sql string, args []driver.Value) *fakeRows) (*gorm.DB, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{t: t, query: query, inserted: map[string][]map[string]driver.Value{}}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(fake)}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return db, fake
+}
+
+// rows returns the rows inserted into table, as a query result.
+func (f *fakeDB) rows(table string) *fakeRows {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := &fakeRows{}
+	for i, row := range f.inserted[table] {
+		if i == 0 {
+			for column := range row {
+				result.columns = append(result.columns, column)
+			}
+		}
+		values := make([]driver.Value, len(result.columns))
+		for j, column := range result.columns {
+			values[j] = row[column]
+		}
+		result.values = append(result.values, values)
+	}
+	return result
+}
+
+// insertedInto returns the rows inserted into table by column.
+func (f *fakeDB) insertedInto(table string) []map[string]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[string]driver.Value(nil), f.inserted[table]...)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+var insertPattern = regexp.MustCompile(`^INSERT INTO "(\w+)" \(([^)]*)\) VALUES (.*?)(?: RETURNING (.*))?$`)
+
+// insert records the rows of an INSERT statement. It returns how many were
+// inserted and their RETURNING columns, taken from their values.
+func (f *fakeDB) insert(query string, args []driver.Value) (*fakeRows, int, bool) {
+	m := insertPattern.FindStringSubmatch(query)
+	if m == nil {
+		return nil, 0, false
+	}
+	columns := strings.Split(strings.ReplaceAll(m[2], `"`, ""), ",")
+	returned := &fakeRows{}
+	if m[4] != "" {
+		returned.columns = strings.Split(strings.ReplaceAll(m[4], `"`, ""), ",")
+	}
+	onConflict := strings.Contains(m[3], "ON CONFLICT")
+	n := 0
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for start := 0; start+len(columns) <= len(args); start += len(columns) {
+		row := map[string]driver.Value{}
+		for i, column := range columns {
+			row[column] = args[start+i]
+		}
+		if onConflict && f.conflict != nil && f.conflict(m[1], row) {
+			continue
+		}
+		f.inserted[m[1]] = append(f.inserted[m[1]], row)
+		n++
+		if len(returned.columns) > 0 {
+			values := make([]driver.Value, len(returned.columns))
+			for i, column := range returned.columns {
+				values[i] = row[column]
+			}
+			returned.values = append(returned.values, values)
+		}
+	}
+	return returned, n, true
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{c.db}, nil }
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{c.db}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, n, ok := c.db.insert(query, values(args)); ok {
+		return driver.RowsAffected(n), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if returned, _, ok := c.db.insert(query, values(args)); ok {
+		return &rowsCursor{rows: returned}, nil
+	}
+	rows := c.db.query(query, values(args))
+	if rows == nil {
+		rows = &fakeRows{}
+	}
+	return &rowsCursor{rows: rows}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i, a := range args {
+		out[i] = a.Value
+	}
+	return out
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+type rowsCursor struct {
+	rows *fakeRows
+	next int
+}
+
+func (r *rowsCursor) Columns() []string {
+	if r.rows == nil {
+		return nil
+	}
+	return r.rows.columns
+}
+
+func (r *rowsCursor) Close() error { return nil }
+
+func (r *rowsCursor) Next(dest []driver.Value) error {
+	if r.rows == nil || r.next == len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
+
+// testVitalCatalog is a catalog of the built-in vital types that never
+// reloads from the database.
+func testVitalCatalog() *VitalCatalog {
+	catalog := &VitalCatalog{entries: map[enums.VitalType]*vitalTypeEntry{}, loadedAt: time.Now().Add(time.Hour)}
+	for _, def := range builtinVitalTypes() {
+		catalog.entries[def.Code] = newVitalTypeEntry(def)
+	}
+	return catalog
+}
@@ -0,0 +1,739 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FHIRImportInput onboards a patient from a discharge Bundle. The bundle
+// carries the clinical data; the rest is what it cannot say: who follows the
+// patient up, how the bot reaches them and how closely they are monitored.
+type FHIRImportInput struct {
+	Bundle              []byte
+	DoctorID            uuid.UUID
+	TelegramUsername    string
+	ConditionSummary    *string // defaults to the bundle's encounter diagnosis
+	RiskLevel           *enums.RiskLevel
+	MonitoringFrequency *enums.MonitoringFrequency
+	// CreateSchedule adds a checkin schedule matching the monitoring frequency.
+	CreateSchedule bool
+	Timezone       *string
+	// DryRun runs the import and rolls it back, reporting what would be created.
+	DryRun bool
+}
+
+// FHIRImportSkip is a bundle entry that was read but not imported.
+type FHIRImportSkip struct {
+	Entry        int
+	ResourceType string
+	ID           string
+	Reason       string
+}
+
+type FHIRImportResult struct {
+	DryRun   bool
+	User     *models.User
+	Patient  *models.Patient
+	Schedule *models.CheckinSchedule
+	// Imported counts the entries used, by resource type.
+	Imported map[string]int
+	Skipped  []FHIRImportSkip
+}
+
+// importedMedication is the Patient.CurrentMedications item written by the import.
+type importedMedication struct {
+	Name   string `json:"name"`
+	Dosage string `json:"dosage,omitempty"`
+	Status string `json:"status,omitempty"`
+	System string `json:"system,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Since  string `json:"since,omitempty"`
+}
+
+// defaultTimeSlots are the checkin times of an imported patient's schedule.
+var defaultTimeSlots = map[enums.ScheduleFrequency][]string{
+	enums.ScheduleFrequencyTwiceDaily:    {"09:00", "21:00"},
+	enums.ScheduleFrequencyDaily:         {"09:00"},
+	enums.ScheduleFrequencyEveryOtherDay: {"09:00"},
+	enums.ScheduleFrequencyWeekly:        {"09:00"},
+}
+
+// errDryRun rolls back a dry-run import once everything has been created.
+var errDryRun = errors.New("dry run")
+
+// bundleImport collects what the entries of a bundle map to.
+type bundleImport struct {
+	user       models.User
+	patient    models.Patient
+	hasPatient bool
+	patientRef map[string]bool // references that point at the bundle's patient
+
+	conditions   []string
+	diagnosis    string
+	medications  []importedMedication
+	allergies    []string
+	baselines    models.BaselineVitals
+	baselineFrom map[enums.VitalType]baselineSource
+
+	imported map[string]int
+	skipped  []FHIRImportSkip
+	fields   []errs.FieldError
+}
+
+// Import creates the patient user, their medical record and optionally a
+// checkin schedule from a FHIR Bundle of Patient, Condition,
+// MedicationStatement, AllergyIntolerance and Observation resources, all in
+// one transaction. Conditions become the condition summary and comorbidities,
+// vital sign observations the baselines. Entries that are inactive, about
+// another patient or of other types are skipped and reported. The user gets a
+// random password; staff set one through the patient update if needed.
+func (s *FHIRService) Import(input FHIRImportInput) (*FHIRImportResult, error) {
+	var bundle fhir.RawBundle
+	if err := json.Unmarshal(input.Bundle, &bundle); err != nil {
+		return nil, errs.ErrMalformedBody.Wrap(err)
+	}
+	if bundle.ResourceType != "Bundle" {
+		return nil, errs.InvalidField("body.resourceType", "must be Bundle")
+	}
+	if len(bundle.Entry) == 0 {
+		return nil, errs.InvalidField("body.entry", "must not be empty")
+	}
+	if strings.TrimSpace(input.TelegramUsername) == "" {
+		return nil, errs.InvalidField("query.telegram_username", "is required")
+	}
+
+	imp := s.readBundle(bundle)
+	if !imp.hasPatient {
+		imp.fields = append(imp.fields, errs.FieldError{Field: "body.entry", Message: "must contain a Patient"})
+	}
+
+	summary := imp.diagnosis
+	if input.ConditionSummary != nil {
+		summary = strings.TrimSpace(*input.ConditionSummary)
+	}
+	if summary == "" && len(imp.conditions) > 0 {
+		summary = imp.conditions[0]
+	}
+	if summary == "" {
+		imp.fields = append(imp.fields, errs.FieldError{Field: "query.condition_summary", Message: "is required when the bundle has no active Condition"})
+	}
+
+	schedule := models.CheckinSchedule{}
+	if input.CreateSchedule && input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil {
+			imp.fields = append(imp.fields, errs.FieldError{Field: "query.timezone", Message: "must be an IANA time zone"})
+		}
+	}
+	if len(imp.fields) > 0 {
+		return nil, errs.Validation(imp.fields...)
+	}
+
+	user := imp.user
+	user.Role = enums.UserRolePatient
+	user.TelegramUsername = strings.TrimPrefix(strings.TrimSpace(input.TelegramUsername), "@")
+	password, err := randomPassword()
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = password
+
+	patient := imp.patient
+	patient.DoctorID = input.DoctorID
+	patient.ConditionSummary = summary
+	patient.Comorbidities = models.StringArray(without(imp.conditions, summary))
+	patient.Allergies = models.StringArray(imp.allergies)
+	if patient.CurrentMedications, err = models.NewJSONB(imp.medications); err != nil {
+		return nil, err
+	}
+	if len(imp.baselines) > 0 {
+		if patient.BaselineVitals, err = models.NewJSONB(imp.baselines); err != nil {
+			return nil, err
+		}
+	}
+	patient.RiskLevel = enums.RiskLevelMedium
+	if input.RiskLevel != nil {
+		patient.RiskLevel = *input.RiskLevel
+	}
+	patient.MonitoringFrequency = enums.MonitoringFrequencyDaily
+	if input.MonitoringFrequency != nil {
+		patient.MonitoringFrequency = *input.MonitoringFrequency
+	}
+	patient.Status = enums.PatientStatusActive
+
+	result := &FHIRImportResult{DryRun: input.DryRun, Imported: imp.imported, Skipped: imp.skipped}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.User{}, "id = ? AND role = ?", input.DoctorID, enums.UserRoleDoctor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrDoctorNotFound
+			}
+			return err
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).
+			Where("phone_number = ? OR telegram_username = ?", user.PhoneNumber, user.TelegramUsername).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return errs.ErrUserExists
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		patient.UserID = user.ID
+		if err := tx.Create(&patient).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("User").Preload("Doctor").First(&patient, "id = ?", patient.ID).Error; err != nil {
+			return err
+		}
+		result.User, result.Patient = &user, &patient
+
+		if input.CreateSchedule {
+			schedule = models.CheckinSchedule{
+				PatientID: patient.ID,
+				Frequency: enums.ScheduleFrequency(patient.MonitoringFrequency),
+				IsActive:  true,
+			}
+			for _, slot := range defaultTimeSlots[schedule.Frequency] {
+				t, _ := time.Parse("15:04", slot)
+				schedule.TimeSlots = append(schedule.TimeSlots, t)
+			}
+			if input.Timezone != nil {
+				schedule.Timezone = *input.Timezone
+			}
+			if err := tx.Create(&schedule).Error; err != nil {
+				return err
+			}
+			result.Schedule = &schedule
+		}
+
+		if input.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readBundle maps every entry it understands and records the rest as skipped.
+// The Patient is read first so that the other entries can be matched to it.
+func (s *FHIRService) readBundle(bundle fhir.RawBundle) *bundleImport {
+	imp := &bundleImport{
+		patientRef:   map[string]bool{},
+		baselines:    models.BaselineVitals{},
+		baselineFrom: map[enums.VitalType]baselineSource{},
+		imported:     map[string]int{},
+	}
+
+	headers := make([]fhir.ResourceHeader, len(bundle.Entry))
+	for i, entry := range bundle.Entry {
+		if err := json.Unmarshal(entry.Resource, &headers[i]); err != nil || headers[i].ResourceType == "" {
+			imp.fields = append(imp.fields, errs.FieldError{Field: fmt.Sprintf("body.entry[%d].resource", i), Message: "must be a FHIR resource"})
+			continue
+		}
+		if headers[i].ResourceType != "Patient" {
+			continue
+		}
+		if imp.hasPatient {
+			imp.fields = append(imp.fields, errs.FieldError{Field: fmt.Sprintf("body.entry[%d]", i), Message: "bundle must contain exactly one Patient"})
+			continue
+		}
+		imp.readPatient(i, entry)
+	}
+	if len(imp.fields) > 0 {
+		return imp
+	}
+
+	for i, entry := range bundle.Entry {
+		switch headers[i].ResourceType {
+		case "Patient":
+		case "Condition":
+			imp.readCondition(i, entry.Resource)
+		case "MedicationStatement":
+			imp.readMedication(i, entry.Resource)
+		case "AllergyIntolerance":
+			imp.readAllergy(i, entry.Resource)
+		case "Observation":
+			s.readObservation(imp, i, entry.Resource)
+		default:
+			imp.skip(i, headers[i], "resource type is not imported")
+		}
+	}
+	sort.SliceStable(imp.skipped, func(a, b int) bool { return imp.skipped[a].Entry < imp.skipped[b].Entry })
+	return imp
+}
+
+// baselineSource is the observation a baseline was taken from.
+type baselineSource struct {
+	entry  int
+	header fhir.ResourceHeader
+	at     time.Time
+}
+
+func (imp *bundleImport) skip(entry int, h fhir.ResourceHeader, reason string) {
+	imp.skipped = append(imp.skipped, FHIRImportSkip{Entry: entry, ResourceType: h.ResourceType, ID: h.ID, Reason: reason})
+}
+
+func (imp *bundleImport) invalid(entry int, field, message string) {
+	imp.fields = append(imp.fields, errs.FieldError{Field: fmt.Sprintf("body.entry[%d].resource.%s", entry, field), Message: message})
+}
+
+// aboutPatient reports whether a subject reference points at the bundle's
+// patient; entries without one are assumed to.
+func (imp *bundleImport) aboutPatient(ref *fhir.Reference) bool {
+	return ref == nil || ref.Reference == "" || imp.patientRef[ref.Reference]
+}
+
+func (imp *bundleImport) readPatient(i int, entry fhir.RawBundleEntry) {
+	var p fhir.Patient
+	if err := json.Unmarshal(entry.Resource, &p); err != nil {
+		imp.invalid(i, "", "is not a valid Patient")
+		return
+	}
+	imp.hasPatient = true
+	imp.imported["Patient"]++
+	if p.ID != "" {
+		imp.patientRef["Patient/"+p.ID] = true
+	}
+	if entry.FullURL != "" {
+		imp.patientRef[entry.FullURL] = true
+	}
+
+	name := officialName(p.Name)
+	if name == nil || strings.TrimSpace(name.Family) == "" || len(name.Given) == 0 {
+		imp.invalid(i, "name", "must have a family and a given name")
+	} else {
+		imp.user.FirstName = strings.TrimSpace(strings.Join(name.Given, " "))
+		imp.user.LastName = strings.TrimSpace(name.Family)
+		if len(imp.user.FirstName) > 100 || len(imp.user.LastName) > 100 {
+			imp.invalid(i, "name", "must be at most 100 characters per part")
+		}
+	}
+
+	imp.user.PhoneNumber = phoneOf(p.Telecom)
+	switch {
+	case imp.user.PhoneNumber == "":
+		imp.invalid(i, "telecom", "must include a phone number")
+	case len(imp.user.PhoneNumber) > 20:
+		imp.invalid(i, "telecom", "phone number must be at most 20 characters")
+	}
+
+	switch p.Gender {
+	case "male", "female", "other":
+		g := enums.Gender(strings.ToUpper(p.Gender))
+		imp.user.Gender = &g
+	}
+	imp.user.IsActive = p.Active == nil || *p.Active
+
+	if contact := emergencyContactOf(p.Contact); contact != nil {
+		if contact.Name != nil {
+			if name := contactName(contact.Name); name != "" {
+				imp.patient.EmergencyContactName = ptr(truncate(name, 255))
+			}
+		}
+		if phone := phoneOf(contact.Telecom); phone != "" {
+			if len(phone) > 20 {
+				imp.invalid(i, "contact.telecom", "phone number must be at most 20 characters")
+			} else {
+				imp.patient.EmergencyContactPhone = &phone
+			}
+		}
+		for _, rel := range contact.Relationship {
+			if _, emergency := rel.Code(fhir.SystemContactRelationship); emergency && rel.Text == "" {
+				continue
+			}
+			if label := rel.Label(); label != "" {
+				imp.patient.EmergencyContactRelation = ptr(truncate(label, 50))
+				break
+			}
+		}
+	}
+}
+
+func (imp *bundleImport) readCondition(i int, raw json.RawMessage) {
+	var c fhir.Condition
+	if err := json.Unmarshal(raw, &c); err != nil {
+		imp.invalid(i, "", "is not a valid Condition")
+		return
+	}
+	h := fhir.ResourceHeader{ResourceType: c.ResourceType, ID: c.ID}
+	switch {
+	case !imp.aboutPatient(c.Subject):
+		imp.skip(i, h, "subject is another patient")
+		return
+	case !activeStatus(c.ClinicalStatus, "active", "recurrence", "relapse"):
+		imp.skip(i, h, "clinical status is "+statusCode(c.ClinicalStatus))
+		return
+	case refuted(c.VerificationStatus):
+		imp.skip(i, h, "verification status is "+statusCode(c.VerificationStatus))
+		return
+	}
+	name := c.Code.Label()
+	if name == "" {
+		imp.skip(i, h, "condition has no code")
+		return
+	}
+
+	imp.imported["Condition"]++
+	if !slices.Contains(imp.conditions, name) {
+		imp.conditions = append(imp.conditions, name)
+	}
+	if imp.diagnosis == "" {
+		for _, cat := range c.Category {
+			if code, _ := cat.Code("http://terminology.hl7.org/CodeSystem/condition-category"); code == "encounter-diagnosis" {
+				imp.diagnosis = name
+			}
+		}
+	}
+}
+
+func (imp *bundleImport) readMedication(i int, raw json.RawMessage) {
+	var m fhir.MedicationStatement
+	if err := json.Unmarshal(raw, &m); err != nil {
+		imp.invalid(i, "", "is not a valid MedicationStatement")
+		return
+	}
+	h := fhir.ResourceHeader{ResourceType: m.ResourceType, ID: m.ID}
+	switch m.Status {
+	case "", "active", "intended", "on-hold", "unknown":
+	default:
+		imp.skip(i, h, "status is "+m.Status)
+		return
+	}
+	if !imp.aboutPatient(m.Subject) {
+		imp.skip(i, h, "subject is another patient")
+		return
+	}
+
+	med := importedMedication{Name: m.MedicationCodeableConcept.Label(), Status: m.Status}
+	if med.Name == "" && m.MedicationReference != nil {
+		med.Name = strings.TrimSpace(m.MedicationReference.Display)
+	}
+	if med.Name == "" {
+		imp.skip(i, h, "medication has no code or display")
+		return
+	}
+	if m.MedicationCodeableConcept != nil {
+		for _, coding := range m.MedicationCodeableConcept.Coding {
+			if coding.System != "" && coding.Code != "" {
+				med.System, med.Code = coding.System, coding.Code
+				break
+			}
+		}
+	}
+	if len(m.Dosage) > 0 {
+		med.Dosage = strings.TrimSpace(m.Dosage[0].Text)
+	}
+	med.Since = m.EffectiveDateTime
+	if m.EffectivePeriod != nil && m.EffectivePeriod.Start != "" {
+		med.Since = m.EffectivePeriod.Start
+	}
+
+	imp.imported["MedicationStatement"]++
+	imp.medications = append(imp.medications, med)
+}
+
+func (imp *bundleImport) readAllergy(i int, raw json.RawMessage) {
+	var a fhir.AllergyIntolerance
+	if err := json.Unmarshal(raw, &a); err != nil {
+		imp.invalid(i, "", "is not a valid AllergyIntolerance")
+		return
+	}
+	h := fhir.ResourceHeader{ResourceType: a.ResourceType, ID: a.ID}
+	switch {
+	case !imp.aboutPatient(a.Patient):
+		imp.skip(i, h, "patient is another patient")
+		return
+	case !activeStatus(a.ClinicalStatus, "active"):
+		imp.skip(i, h, "clinical status is "+statusCode(a.ClinicalStatus))
+		return
+	case refuted(a.VerificationStatus):
+		imp.skip(i, h, "verification status is "+statusCode(a.VerificationStatus))
+		return
+	}
+	name := a.Code.Label()
+	if name == "" {
+		imp.skip(i, h, "allergy has no code")
+		return
+	}
+
+	var reactions []string
+	for _, r := range a.Reaction {
+		for _, m := range r.Manifestation {
+			if label := m.Label(); label != "" && !slices.Contains(reactions, label) {
+				reactions = append(reactions, label)
+			}
+		}
+	}
+	if len(reactions) > 0 {
+		name += " (" + strings.Join(reactions, ", ") + ")"
+	}
+
+	imp.imported["AllergyIntolerance"]++
+	if !slices.Contains(imp.allergies, name) {
+		imp.allergies = append(imp.allergies, name)
+	}
+}
+
+// readObservation keeps the latest observation of each vital type as its
+// baseline. Observations that do not map to a catalog type, or whose value
+// the catalog rejects, are skipped rather than failing the import.
+func (s *FHIRService) readObservation(imp *bundleImport, i int, raw json.RawMessage) {
+	var o fhir.Observation
+	if err := json.Unmarshal(raw, &o); err != nil {
+		imp.invalid(i, "", "is not a valid Observation")
+		return
+	}
+	h := fhir.ResourceHeader{ResourceType: o.ResourceType, ID: o.ID}
+	switch o.Status {
+	case "cancelled", "entered-in-error":
+		imp.skip(i, h, "status is "+o.Status)
+		return
+	}
+	if !imp.aboutPatient(o.Subject) {
+		imp.skip(i, h, "subject is another patient")
+		return
+	}
+
+	vt, ok := s.observationVitalType(&o.Code)
+	if !ok {
+		imp.skip(i, h, "code has no matching vital type")
+		return
+	}
+	entry, _ := s.catalog.lookup(vt)
+
+	var baseline models.VitalBaseline
+	var unit *fhir.Quantity
+	if vt == enums.VitalTypeBloodPressure {
+		var systolic, diastolic *fhir.Quantity
+		for j := range o.Component {
+			switch code, _ := o.Component[j].Code.Code(fhir.SystemLOINC); code {
+			case loincSystolic.code:
+				systolic = o.Component[j].ValueQuantity
+			case loincDiastolic.code:
+				diastolic = o.Component[j].ValueQuantity
+			}
+		}
+		if systolic == nil || systolic.Value == nil || diastolic == nil || diastolic.Value == nil {
+			imp.skip(i, h, "blood pressure needs systolic and diastolic components")
+			return
+		}
+		baseline.Value, baseline.Diastolic, unit = *systolic.Value, diastolic.Value, systolic
+	} else {
+		if o.ValueQuantity == nil || o.ValueQuantity.Value == nil {
+			imp.skip(i, h, "observation has no valueQuantity")
+			return
+		}
+		baseline.Value, unit = *o.ValueQuantity.Value, o.ValueQuantity
+	}
+
+	resolved, ok := quantityUnit(entry, unit)
+	if !ok {
+		imp.skip(i, h, fmt.Sprintf("unit %q is not accepted for %s", unit.Unit+unit.Code, vt))
+		return
+	}
+	baseline.Unit = resolved
+
+	// validateBaselines converts the value to the canonical unit in place
+	single := models.BaselineVitals{vt: baseline}
+	if err := s.catalog.validateBaselines(single); err != nil {
+		reason := "value is not a valid baseline"
+		if appErr := errs.As(err); appErr != nil && len(appErr.Fields) > 0 {
+			reason = appErr.Fields[0].Message
+		}
+		imp.skip(i, h, reason)
+		return
+	}
+
+	at, _ := time.Parse(time.RFC3339, o.EffectiveDateTime)
+	if prev, seen := imp.baselineFrom[vt]; seen {
+		if at.Before(prev.at) {
+			imp.skip(i, h, "a later observation of the same vital type is used")
+			return
+		}
+		imp.skip(prev.entry, prev.header, "a later observation of the same vital type is used")
+		imp.imported["Observation"]--
+	}
+
+	imp.imported["Observation"]++
+	imp.baselines[vt] = single[vt]
+	imp.baselineFrom[vt] = baselineSource{entry: i, header: h, at: at}
+}
+
+// observationVitalType finds the catalog type of an observation code, by its
+// LOINC code or a vital type coding.
+func (s *FHIRService) observationVitalType(code *fhir.CodeableConcept) (enums.VitalType, bool) {
+	if loinc, ok := code.Code(fhir.SystemLOINC); ok {
+		if vt, ok := vitalTypeForCode(fhir.SystemLOINC + "|" + loinc); ok {
+			return vt, true
+		}
+	}
+	if local, ok := code.Code(fhir.SystemVitalType); ok {
+		if _, known := s.catalog.lookup(enums.VitalType(local)); known {
+			return enums.VitalType(local), true
+		}
+	}
+	return "", false
+}
+
+// quantityUnit resolves a quantity's unit among those the type accepts, by
+// UCUM code first and by unit text second; the text may itself be a UCUM code.
+// A quantity without a unit is taken to be in the canonical unit.
+func quantityUnit(entry *vitalTypeEntry, q *fhir.Quantity) (*enums.VitalUnit, bool) {
+	byUCUM := func(code string) (*enums.VitalUnit, bool) {
+		for _, u := range sortedUnitKeys(entry.units) {
+			if ucumUnits[u] == code {
+				return &u, true
+			}
+		}
+		return nil, false
+	}
+	if q.Code != "" && (q.System == "" || q.System == fhir.SystemUCUM) {
+		if u, ok := byUCUM(q.Code); ok {
+			return u, true
+		}
+	}
+	if text := strings.TrimSpace(q.Unit); text != "" {
+		u := normalizeUnit(enums.VitalUnit(text))
+		if _, ok := entry.units[u]; ok {
+			return &u, true
+		}
+		return byUCUM(text)
+	}
+	if q.Code != "" {
+		return nil, false
+	}
+	return nil, true
+}
+
+// sortedUnitKeys orders a type's units so that a UCUM code shared by several
+// spellings resolves to the same one every time.
+func sortedUnitKeys(units map[enums.VitalUnit]models.UnitConversion) []enums.VitalUnit {
+	keys := make([]enums.VitalUnit, 0, len(units))
+	for u := range units {
+		keys = append(keys, u)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func officialName(names []fhir.HumanName) *fhir.HumanName {
+	for i := range names {
+		if names[i].Use == "official" {
+			return &names[i]
+		}
+	}
+	if len(names) > 0 {
+		return &names[0]
+	}
+	return nil
+}
+
+func contactName(name *fhir.HumanName) string {
+	if text := strings.TrimSpace(name.Text); text != "" {
+		return text
+	}
+	return strings.TrimSpace(strings.Join(append(append([]string{}, name.Given...), name.Family), " "))
+}
+
+// phoneOf picks a mobile phone number, else any phone number.
+func phoneOf(telecom []fhir.ContactPoint) string {
+	phone := ""
+	for _, t := range telecom {
+		if t.System != "phone" || strings.TrimSpace(t.Value) == "" {
+			continue
+		}
+		if t.Use == "mobile" {
+			return strings.TrimSpace(t.Value)
+		}
+		if phone == "" {
+			phone = strings.TrimSpace(t.Value)
+		}
+	}
+	return phone
+}
+
+// emergencyContactOf prefers the contact coded as the emergency contact.
+func emergencyContactOf(contacts []fhir.PatientContact) *fhir.PatientContact {
+	for i := range contacts {
+		for _, rel := range contacts[i].Relationship {
+			if code, ok := rel.Code(fhir.SystemContactRelationship); ok && code == "C" {
+				return &contacts[i]
+			}
+		}
+	}
+	if len(contacts) > 0 {
+		return &contacts[0]
+	}
+	return nil
+}
+
+// statusCode is the first code of a status concept, whatever its system.
+func statusCode(c *fhir.CodeableConcept) string {
+	if c == nil {
+		return ""
+	}
+	for _, coding := range c.Coding {
+		if coding.Code != "" {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+// activeStatus accepts a missing status or one of active.
+func activeStatus(c *fhir.CodeableConcept, active ...string) bool {
+	code := statusCode(c)
+	return code == "" || slices.Contains(active, code)
+}
+
+func refuted(c *fhir.CodeableConcept) bool {
+	code := statusCode(c)
+	return code == "refuted" || code == "entered-in-error"
+}
+
+func without(values []string, drop string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != drop {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(r[:n]))
+}
+
+func randomPassword() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/google/uuid"
+)
+
+// loincCode is the standard coding of a built-in vital type. Category is an
+// observation-category code.
+type loincCode struct {
+	code     string
+	display  string
+	category string
+}
+
+var vitalLOINC = map[enums.VitalType]loincCode{
+	enums.VitalTypeBloodPressure:    {"85354-9", "Blood pressure panel with all children optional", "vital-signs"},
+	enums.VitalTypeHeartRate:        {"8867-4", "Heart rate", "vital-signs"},
+	enums.VitalTypeTemperature:      {"8310-5", "Body temperature", "vital-signs"},
+	enums.VitalTypeWeight:           {"29463-7", "Body weight", "vital-signs"},
+	enums.VitalTypeOxygenSaturation: {"59408-5", "Oxygen saturation in Arterial blood by Pulse oximetry", "vital-signs"},
+	enums.VitalTypeRespiratoryRate:  {"9279-1", "Respiratory rate", "vital-signs"},
+	enums.VitalTypeGlucose:          {"2339-0", "Glucose [Mass/volume] in Blood", "laboratory"},
+	enums.VitalTypeHbA1c:            {"4548-4", "Hemoglobin A1c/Hemoglobin.total in Blood", "laboratory"},
+	enums.VitalTypePainScore:        {"72514-3", "Pain severity - 0-10 verbal numeric rating [Score] - Reported", "survey"},
+	enums.VitalTypePeakFlow:         {"19935-6", "Maximum expiratory gas flow Respiratory system airway by Peak flow meter", "exam"},
+	enums.VitalTypeUrineOutput:      {"9187-6", "Urine output", "exam"},
+	enums.VitalTypeSleepHours:       {"93832-4", "Sleep duration", "activity"},
+	enums.VitalTypeSteps:            {"41950-7", "Number of steps in 24 hour Measured", "activity"},
+}
+
+// LOINC codes of the blood pressure panel components.
+var (
+	loincSystolic  = loincCode{"8480-6", "Systolic blood pressure", ""}
+	loincDiastolic = loincCode{"8462-4", "Diastolic blood pressure", ""}
+	loincMAP       = loincCode{"8478-0", "Mean blood pressure", ""}
+)
+
+// ucumUnits maps stored units to UCUM codes. Units without an entry are
+// exported as display text only.
+var ucumUnits = map[enums.VitalUnit]string{
+	enums.VitalUnitMmHg:       "mm[Hg]",
+	enums.VitalUnitMgDL:       "mg/dL",
+	enums.VitalUnitMmolL:      "mmol/L",
+	enums.VitalUnitBPM:        "/min",
+	enums.VitalUnitCelsius:    "Cel",
+	enums.VitalUnitFahrenheit: "[degF]",
+	enums.VitalUnitKg:         "kg",
+	enums.VitalUnitLb:         "[lb_av]",
+	enums.VitalUnitPercent:    "%",
+	enums.VitalUnitBreaths:    "/min",
+	enums.VitalUnitScore:      "{score}",
+	enums.VitalUnitLPerMin:    "L/min",
+	enums.VitalUnitMmolMol:    "mmol/mol",
+	enums.VitalUnitMl:         "mL",
+	enums.VitalUnitLiter:      "L",
+	enums.VitalUnitHours:      "h",
+	enums.VitalUnitMinutes:    "min",
+	enums.VitalUnitSteps:      "{steps}",
+}
+
+var detectedIssueSeverity = map[enums.AlertSeverity]string{
+	enums.AlertSeverityLow:      "low",
+	enums.AlertSeverityMedium:   "moderate",
+	enums.AlertSeverityHigh:     "high",
+	enums.AlertSeverityCritical: "high",
+}
+
+var questionnaireResponseStatus = map[enums.CheckinStatus]string{
+	enums.CheckinStatusPending:    "in-progress",
+	enums.CheckinStatusInProgress: "in-progress",
+	enums.CheckinStatusCompleted:  "completed",
+	enums.CheckinStatusAnalyzed:   "completed",
+	enums.CheckinStatusReviewed:   "completed",
+	enums.CheckinStatusFailed:     "stopped",
+	enums.CheckinStatusMissed:     "stopped",
+	enums.CheckinStatusCancelled:  "stopped",
+}
+
+// vitalTypeForCode resolves an Observation code search, either a LOINC code or
+// a vital type code, optionally prefixed with its system as in "system|code".
+func vitalTypeForCode(raw string) (enums.VitalType, bool) {
+	system, code, hasSystem := strings.Cut(raw, "|")
+	if !hasSystem {
+		code, system = raw, ""
+	}
+	if system == "" || system == fhir.SystemLOINC {
+		for vt, l := range vitalLOINC {
+			if l.code == code {
+				return vt, true
+			}
+		}
+	}
+	if system == "" || system == fhir.SystemVitalType {
+		return enums.VitalType(code), code != ""
+	}
+	return "", false
+}
+
+func fhirPatient(patient *models.Patient) *fhir.Patient {
+	user := patient.User
+	res := &fhir.Patient{
+		ResourceType: "Patient",
+		ID:           patient.UserID.String(),
+		Meta:         &fhir.Meta{LastUpdated: fhirInstant(latest(patient.UpdatedAt, user.UpdatedAt))},
+		Identifier:   []fhir.Identifier{{System: fhir.SystemUserID, Value: patient.UserID.String()}},
+		Active:       ptr(user.IsActive),
+		Name:         []fhir.HumanName{humanName(user)},
+	}
+	if user.PhoneNumber != "" {
+		res.Telecom = []fhir.ContactPoint{{System: "phone", Value: user.PhoneNumber, Use: "mobile"}}
+	}
+	if user.Gender != nil {
+		res.Gender = strings.ToLower(string(*user.Gender))
+	}
+	if contact := emergencyContact(patient); contact != nil {
+		res.Contact = []fhir.PatientContact{*contact}
+	}
+	if patient.Doctor != nil {
+		res.GeneralPractitioner = []fhir.Reference{{Display: "Dr. " + fullName(patient.Doctor)}}
+	}
+	return res
+}
+
+func humanName(user *models.User) fhir.HumanName {
+	name := fhir.HumanName{Use: "official", Text: fullName(user), Family: strings.TrimSpace(user.LastName)}
+	if given := strings.TrimSpace(user.FirstName); given != "" {
+		name.Given = []string{given}
+	}
+	return name
+}
+
+func fullName(user *models.User) string {
+	return strings.TrimSpace(strings.TrimSpace(user.FirstName) + " " + strings.TrimSpace(user.LastName))
+}
+
+func emergencyContact(patient *models.Patient) *fhir.PatientContact {
+	name := trimmed(patient.EmergencyContactName)
+	phone := trimmed(patient.EmergencyContactPhone)
+	if name == "" && phone == "" {
+		return nil
+	}
+	contact := &fhir.PatientContact{
+		Relationship: []fhir.CodeableConcept{{
+			Coding: []fhir.Coding{{System: fhir.SystemContactRelationship, Code: "C", Display: "Emergency Contact"}},
+		}},
+	}
+	if relation := trimmed(patient.EmergencyContactRelation); relation != "" {
+		contact.Relationship = append(contact.Relationship, fhir.CodeableConcept{Text: relation})
+	}
+	if name != "" {
+		contact.Name = &fhir.HumanName{Text: name}
+	}
+	if phone != "" {
+		contact.Telecom = []fhir.ContactPoint{{System: "phone", Value: phone}}
+	}
+	return contact
+}
+
+// fhirObservation maps a reading; patientUserID is the FHIR id of its subject.
+func (s *FHIRService) fhirObservation(reading *models.VitalReading, patientUserID uuid.UUID) *fhir.Observation {
+	label := s.catalog.label(reading.VitalType)
+	obs := &fhir.Observation{
+		ResourceType:      "Observation",
+		ID:                reading.ID.String(),
+		Meta:              &fhir.Meta{LastUpdated: fhirInstant(reading.CreatedAt)},
+		Status:            "final",
+		Code:              fhir.CodeableConcept{Text: label},
+		Subject:           &fhir.Reference{Reference: "Patient/" + patientUserID.String()},
+		EffectiveDateTime: fhirInstant(reading.MeasuredAt),
+		Issued:            fhirInstant(reading.CreatedAt),
+	}
+
+	loinc, standard := vitalLOINC[reading.VitalType]
+	if standard {
+		obs.Code.Coding = append(obs.Code.Coding, fhir.Coding{System: fhir.SystemLOINC, Code: loinc.code, Display: loinc.display})
+		obs.Category = []fhir.CodeableConcept{{
+			Coding: []fhir.Coding{{System: fhir.SystemObservationCategory, Code: loinc.category, Display: observationCategoryDisplay(loinc.category)}},
+		}}
+	}
+	obs.Code.Coding = append(obs.Code.Coding, fhir.Coding{System: fhir.SystemVitalType, Code: string(reading.VitalType), Display: label})
+
+	switch {
+	case reading.VitalType == enums.VitalTypeBloodPressure && reading.Systolic != nil:
+		obs.Component = append(obs.Component, bpComponent(loincSystolic, *reading.Systolic, reading.Unit))
+		if reading.Diastolic != nil {
+			obs.Component = append(obs.Component, bpComponent(loincDiastolic, *reading.Diastolic, reading.Unit))
+		}
+		if reading.MeanArterialPressure != nil {
+			obs.Component = append(obs.Component, bpComponent(loincMAP, *reading.MeanArterialPressure, reading.Unit))
+		}
+	case reading.ValueNumeric != nil:
+		obs.ValueQuantity = fhirQuantity(*reading.ValueNumeric, reading.Unit)
+	case reading.ValueText != nil && strings.TrimSpace(*reading.ValueText) != "":
+		obs.ValueString = strings.TrimSpace(*reading.ValueText)
+	}
+
+	interpretation := fhir.Coding{System: fhir.SystemObservationInterp, Code: "N", Display: "Normal"}
+	if reading.IsAbnormal {
+		interpretation = fhir.Coding{System: fhir.SystemObservationInterp, Code: "A", Display: "Abnormal"}
+	}
+	obs.Interpretation = []fhir.CodeableConcept{{Coding: []fhir.Coding{interpretation}}}
+
+	if context := trimmed(reading.Context); context != "" {
+		obs.Note = append(obs.Note, fhir.Annotation{Text: "Context: " + context})
+	}
+	if reading.OriginalValue != nil && reading.OriginalUnit != nil {
+		obs.Note = append(obs.Note, fhir.Annotation{Text: fmt.Sprintf("Reported as %s %s", formatValue(*reading.OriginalValue), *reading.OriginalUnit)})
+	}
+	if device := trimmed(reading.Device); device != "" {
+		obs.Device = &fhir.Reference{Display: device}
+	}
+	return obs
+}
+
+func bpComponent(code loincCode, value float64, unit *enums.VitalUnit) fhir.ObservationComponent {
+	return fhir.ObservationComponent{
+		Code:          fhir.CodeableConcept{Coding: []fhir.Coding{{System: fhir.SystemLOINC, Code: code.code, Display: code.display}}},
+		ValueQuantity: fhirQuantity(value, unit),
+	}
+}
+
+func fhirQuantity(value float64, unit *enums.VitalUnit) *fhir.Quantity {
+	q := &fhir.Quantity{Value: &value}
+	if unit == nil {
+		return q
+	}
+	q.Unit = string(*unit)
+	if code, ok := ucumUnits[*unit]; ok {
+		q.System, q.Code = fhir.SystemUCUM, code
+	}
+	return q
+}
+
+func observationCategoryDisplay(code string) string {
+	switch code {
+	case "vital-signs":
+		return "Vital Signs"
+	case "laboratory":
+		return "Laboratory"
+	case "survey":
+		return "Survey"
+	case "exam":
+		return "Exam"
+	case "activity":
+		return "Activity"
+	}
+	return code
+}
+
+func alertCode(alert *models.Alert) fhir.CodeableConcept {
+	return fhir.CodeableConcept{
+		Coding: []fhir.Coding{{System: fhir.SystemAlertType, Code: string(alert.AlertType), Display: vitalLabel(enums.VitalType(alert.AlertType))}},
+		Text:   strings.TrimSpace(alert.Title),
+	}
+}
+
+// fhirFlag maps an alert to a Flag that stays active until it is acknowledged.
+func fhirFlag(alert *models.Alert, patientUserID uuid.UUID) *fhir.Flag {
+	status := "active"
+	period := &fhir.Period{Start: fhirInstant(alert.CreatedAt)}
+	if alert.IsAcknowledged {
+		status = "inactive"
+		if alert.AcknowledgedAt != nil {
+			period.End = fhirInstant(*alert.AcknowledgedAt)
+		}
+	}
+	return &fhir.Flag{
+		ResourceType: "Flag",
+		ID:           alert.ID.String(),
+		Meta:         &fhir.Meta{LastUpdated: fhirInstant(alertUpdatedAt(alert))},
+		Status:       status,
+		Category: []fhir.CodeableConcept{{
+			Coding: []fhir.Coding{{System: fhir.SystemFlagCategory, Code: "clinical", Display: "Clinical"}},
+		}},
+		Code:    alertCode(alert),
+		Subject: fhir.Reference{Reference: "Patient/" + patientUserID.String()},
+		Period:  period,
+	}
+}
+
+// fhirDetectedIssue maps an alert to a DetectedIssue. evidence lists the
+// readings the alert was raised on that still exist; acknowledgement is
+// exported as a mitigation.
+func fhirDetectedIssue(alert *models.Alert, patientUserID uuid.UUID, evidence []uuid.UUID) *fhir.DetectedIssue {
+	code := alertCode(alert)
+	issue := &fhir.DetectedIssue{
+		ResourceType:       "DetectedIssue",
+		ID:                 alert.ID.String(),
+		Meta:               &fhir.Meta{LastUpdated: fhirInstant(alertUpdatedAt(alert))},
+		Status:             "preliminary",
+		Code:               &code,
+		Severity:           detectedIssueSeverity[alert.Severity],
+		Patient:            &fhir.Reference{Reference: "Patient/" + patientUserID.String()},
+		IdentifiedDateTime: fhirInstant(alert.CreatedAt),
+		Detail:             strings.TrimSpace(alert.Message),
+	}
+
+	var refs []fhir.Reference
+	for _, id := range evidence {
+		refs = append(refs, fhir.Reference{Reference: "Observation/" + id.String()})
+	}
+	if alert.CheckinID != nil {
+		refs = append(refs, fhir.Reference{Reference: "QuestionnaireResponse/" + alert.CheckinID.String()})
+	}
+	if len(refs) > 0 {
+		issue.Evidence = []fhir.DetectedIssueEvidence{{Detail: refs}}
+	}
+
+	if alert.IsAcknowledged {
+		issue.Status = "final"
+		action := "Acknowledged"
+		if taken := trimmed(alert.ActionTaken); taken != "" {
+			action = taken
+		}
+		mitigation := fhir.DetectedIssueMitigation{Action: fhir.CodeableConcept{Text: action}}
+		if alert.AcknowledgedAt != nil {
+			mitigation.Date = fhirInstant(*alert.AcknowledgedAt)
+		}
+		if alert.Acknowledger != nil {
+			mitigation.Author = &fhir.Reference{Display: fullName(alert.Acknowledger)}
+		}
+		issue.Mitigation = []fhir.DetectedIssueMitigation{mitigation}
+	}
+	return issue
+}
+
+func alertUpdatedAt(alert *models.Alert) time.Time {
+	if alert.AcknowledgedAt != nil {
+		return latest(alert.CreatedAt, *alert.AcknowledgedAt)
+	}
+	return alert.CreatedAt
+}
+
+// alertEvidence collects the vital_reading_id values anywhere in alert details:
+// reading alerts store one at the top level, pattern and early warning alerts
+// one per evidence entry or parameter.
+func alertEvidence(details models.JSONB) []uuid.UUID {
+	var doc interface{}
+	if err := details.Unmarshal(&doc); err != nil {
+		return nil
+	}
+	seen := map[uuid.UUID]bool{}
+	var out []uuid.UUID
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if raw, ok := v["vital_reading_id"].(string); ok {
+				if id, err := uuid.Parse(raw); err == nil && !seen[id] {
+					seen[id] = true
+					out = append(out, id)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+// fhirQuestionnaireResponse maps a checkin's questions and answers, paired by
+// their seq. Items without a seq keep their position in the array.
+func fhirQuestionnaireResponse(checkin *models.Checkin, patientUserID uuid.UUID) *fhir.QuestionnaireResponse {
+	authored := checkin.InitiatedAt
+	if checkin.CompletedAt != nil {
+		authored = *checkin.CompletedAt
+	}
+	qr := &fhir.QuestionnaireResponse{
+		ResourceType: "QuestionnaireResponse",
+		ID:           checkin.ID.String(),
+		Meta:         &fhir.Meta{LastUpdated: fhirInstant(checkin.UpdatedAt)},
+		Status:       questionnaireResponseStatus[checkin.Status],
+		Subject:      &fhir.Reference{Reference: "Patient/" + patientUserID.String()},
+		Authored:     fhirInstant(authored),
+	}
+	if qr.Status == "" {
+		qr.Status = "in-progress"
+	}
+
+	var questions, answers []map[string]interface{}
+	_ = checkin.Questions.Unmarshal(&questions)
+	_ = checkin.Answers.Unmarshal(&answers)
+
+	byLink := map[string]int{}
+	item := func(linkID string) *fhir.QuestionnaireResponseItem {
+		if i, ok := byLink[linkID]; ok {
+			return &qr.Item[i]
+		}
+		byLink[linkID] = len(qr.Item)
+		qr.Item = append(qr.Item, fhir.QuestionnaireResponseItem{LinkID: linkID})
+		return &qr.Item[len(qr.Item)-1]
+	}
+
+	for i, q := range questions {
+		it := item(itemLinkID(q, i))
+		it.Text = firstString(q, "question", "text", "content", "prompt")
+	}
+	for i, a := range answers {
+		// a multiple-choice answer is one answer per picked value
+		if picked, ok := a["value"].([]interface{}); ok {
+			it := item(itemLinkID(a, i))
+			for _, p := range picked {
+				if v, ok := p.(string); ok {
+					it.Answer = append(it.Answer, fhir.QuestionnaireResponseAnswer{ValueString: v})
+				}
+			}
+			continue
+		}
+		answer, ok := itemAnswer(a)
+		if !ok {
+			continue
+		}
+		it := item(itemLinkID(a, i))
+		it.Answer = append(it.Answer, answer)
+	}
+	return qr
+}
+
+// itemLinkID is the item's seq, or its position when it has none.
+func itemLinkID(item map[string]interface{}, index int) string {
+	if seq, ok := item["seq"].(float64); ok {
+		return strconv.FormatFloat(seq, 'f', -1, 64)
+	}
+	return "item-" + strconv.Itoa(index+1)
+}
+
+func itemAnswer(item map[string]interface{}) (fhir.QuestionnaireResponseAnswer, bool) {
+	for _, key := range []string{"answer", "value", "response", "text", "content"} {
+		raw, ok := item[key]
+		if !ok {
+			continue
+		}
+		switch v := raw.(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return fhir.QuestionnaireResponseAnswer{ValueString: v}, true
+			}
+		case float64:
+			return fhir.QuestionnaireResponseAnswer{ValueDecimal: &v}, true
+		case bool:
+			return fhir.QuestionnaireResponseAnswer{ValueBoolean: &v}, true
+		case nil:
+		default:
+			encoded, err := json.Marshal(v)
+			if err == nil {
+				return fhir.QuestionnaireResponseAnswer{ValueString: string(encoded)}, true
+			}
+		}
+	}
+	return fhir.QuestionnaireResponseAnswer{}, false
+}
+
+func firstString(item map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := item[key].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+func trimmed(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// fhirInstant formats a timestamp as a FHIR instant, which also satisfies dateTime.
+func fhirInstant(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/google/uuid"
+)
+
+// testFHIRService maps with the built-in vital types and no database.
+func testFHIRService() *FHIRService {
+	return &FHIRService{catalog: testVitalCatalog(), published: time.Now()}
+}
+
+func mustJSONB(t *testing.T, v interface{}) models.JSONB {
+	t.Helper()
+	j, err := models.NewJSONB(v)
+	if err != nil {
+		t.Fatalf("NewJSONB: %v", err)
+	}
+	return j
+}
+
+func mustValidate(t *testing.T, resource interface{}) {
+	t.Helper()
+	if err := fhir.Validate(resource); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+var fhirTestTime = time.Date(2026, 3, 1, 8, 30, 0, 0, time.FixedZone("UZT", 5*60*60))
+
+func testFHIRPatient() *models.Patient {
+	gender := enums.GenderFemale
+	contact, phone, relation := "Bekzod Karimov", "+998907654321", "  son "
+	return &models.Patient{
+		ID:     uuid.New(),
+		UserID: uuid.New(),
+		User: &models.User{
+			FirstName:   " Aziza ",
+			LastName:    "Karimova",
+			PhoneNumber: "+998901234567",
+			Gender:      &gender,
+			IsActive:    true,
+			UpdatedAt:   fhirTestTime,
+		},
+		Doctor:                   &models.User{FirstName: "Rustam", LastName: "Aliev"},
+		EmergencyContactName:     &contact,
+		EmergencyContactPhone:    &phone,
+		EmergencyContactRelation: &relation,
+		UpdatedAt:                fhirTestTime.Add(-time.Hour),
+	}
+}
+
+func TestFHIRPatient(t *testing.T) {
+	patient := testFHIRPatient()
+	res := fhirPatient(patient)
+	mustValidate(t, res)
+
+	if res.ID != patient.UserID.String() || res.Gender != "female" {
+		t.Errorf("id, gender = %s, %s; want the user id and female", res.ID, res.Gender)
+	}
+	if len(res.Contact) != 1 || len(res.Contact[0].Relationship) != 2 {
+		t.Errorf("contact = %+v, want the emergency contact with its relation", res.Contact)
+	}
+
+	// a bare patient has no optional elements to leave empty
+	bare := &models.Patient{UserID: uuid.New(), User: &models.User{FirstName: "A"}}
+	mustValidate(t, fhirPatient(bare))
+}
+
+func TestFHIRObservation(t *testing.T) {
+	s := testFHIRService()
+	patientUserID := uuid.New()
+	mmHg, bpm, celsius := enums.VitalUnitMmHg, enums.VitalUnitBPM, enums.VitalUnitCelsius
+	systolic, diastolic, mean := 150.0, 95.0, 113.3
+	heartRate, temperature, original := 118.0, 37.0, 98.6
+	fahrenheit := enums.VitalUnitFahrenheit
+	readingContext, device, text := "after stairs", "Omron M3", " dizzy "
+
+	readings := map[string]*models.VitalReading{
+		"blood pressure": {
+			VitalType: enums.VitalTypeBloodPressure, Systolic: &systolic, Diastolic: &diastolic,
+			MeanArterialPressure: &mean, Unit: &mmHg, IsAbnormal: true, Device: &device,
+		},
+		"numeric": {VitalType: enums.VitalTypeHeartRate, ValueNumeric: &heartRate, Unit: &bpm, Context: &readingContext},
+		"converted": {
+			VitalType: enums.VitalTypeTemperature, ValueNumeric: &temperature, Unit: &celsius,
+			OriginalValue: &original, OriginalUnit: &fahrenheit,
+		},
+		"custom type with text": {VitalType: enums.VitalType("SYMPTOM_NOTE"), ValueText: &text},
+	}
+	for name, reading := range readings {
+		t.Run(name, func(t *testing.T) {
+			reading.ID = uuid.New()
+			reading.MeasuredAt = fhirTestTime
+			reading.CreatedAt = fhirTestTime.Add(time.Minute)
+			obs := s.fhirObservation(reading, patientUserID)
+			mustValidate(t, obs)
+			if obs.Subject == nil || obs.Subject.Reference != "Patient/"+patientUserID.String() {
+				t.Errorf("subject = %+v, want the patient", obs.Subject)
+			}
+		})
+	}
+}
+
+func TestFHIRQuestionnaireResponse(t *testing.T) {
+	completed := fhirTestTime.Add(20 * time.Minute)
+	checkin := &models.Checkin{
+		ID:          uuid.New(),
+		Status:      enums.CheckinStatusAnalyzed,
+		InitiatedAt: fhirTestTime,
+		CompletedAt: &completed,
+		UpdatedAt:   completed,
+		Questions: mustJSONB(t, []map[string]interface{}{
+			{"seq": 1, "text": "How many pillows did you sleep on?"},
+			{"seq": 2, "text": "Any swelling?"},
+			{"seq": 3, "text": "Which symptoms?"},
+			{"text": "Anything else?"},
+		}),
+		Answers: mustJSONB(t, []map[string]interface{}{
+			{"seq": 1, "value": 3},
+			{"seq": 2, "value": false, "text": "no"},
+			{"seq": 3, "value": []string{"cough", "fatigue"}},
+			{"seq": 5, "answer": "  "},
+			{"seq": 6, "value": map[string]interface{}{"left": 2}},
+		}),
+	}
+	qr := fhirQuestionnaireResponse(checkin, uuid.New())
+	mustValidate(t, qr)
+
+	if qr.Status != "completed" {
+		t.Errorf("status = %s, want completed", qr.Status)
+	}
+	if len(qr.Item) != 5 {
+		t.Fatalf("items = %+v, want the four questions and the answer to an unasked one", qr.Item)
+	}
+	if got := len(qr.Item[2].Answer); got != 2 {
+		t.Errorf("multiple choice answers = %d, want one per picked value", got)
+	}
+
+	// an unanswered checkin still maps to a valid response
+	mustValidate(t, fhirQuestionnaireResponse(&models.Checkin{ID: uuid.New(), Status: enums.CheckinStatusPending, InitiatedAt: fhirTestTime}, uuid.New()))
+}
+
+func TestFHIREverythingBundle(t *testing.T) {
+	s := testFHIRService()
+	patient := testFHIRPatient()
+	bpm := enums.VitalUnitBPM
+	heartRate := 131.0
+	reading := models.VitalReading{
+		ID: uuid.New(), PatientID: patient.ID, VitalType: enums.VitalTypeHeartRate,
+		ValueNumeric: &heartRate, Unit: &bpm, IsAbnormal: true, MeasuredAt: fhirTestTime, CreatedAt: fhirTestTime,
+	}
+	checkin := models.Checkin{
+		ID: uuid.New(), PatientID: patient.ID, Status: enums.CheckinStatusReviewed, InitiatedAt: fhirTestTime, UpdatedAt: fhirTestTime,
+		Questions: mustJSONB(t, []map[string]interface{}{{"seq": 1, "text": "How do you feel?"}}),
+		Answers:   mustJSONB(t, []map[string]interface{}{{"seq": 1, "value": "tired"}}),
+	}
+	acknowledged := fhirTestTime.Add(time.Hour)
+	action := "Called the patient"
+	alerts := []models.Alert{
+		{
+			ID: uuid.New(), PatientID: patient.ID, CheckinID: &checkin.ID,
+			Severity: enums.AlertSeverityCritical, AlertType: enums.AlertTypeVitalAbnormal,
+			Title: "Heart rate critically high", Message: "131 bpm",
+			Details:        mustJSONB(t, map[string]interface{}{"vital_reading_id": reading.ID.String()}),
+			IsAcknowledged: true, AcknowledgedAt: &acknowledged, ActionTaken: &action,
+			Acknowledger: &models.User{FirstName: "Rustam", LastName: "Aliev"},
+			CreatedAt:    fhirTestTime,
+		},
+		{
+			ID: uuid.New(), PatientID: patient.ID, Severity: enums.AlertSeverityLow, AlertType: enums.AlertTypeNoResponse,
+			Title: "Missed checkin", Message: "No checkin today",
+			// evidence that is not in the bundle is left out
+			Details:   mustJSONB(t, map[string]interface{}{"evidence": []map[string]interface{}{{"vital_reading_id": uuid.NewString()}}}),
+			CreatedAt: fhirTestTime,
+		},
+	}
+
+	bundle := s.everythingBundle(patient, []models.VitalReading{reading}, []models.Checkin{checkin}, alerts, "https://example.org/api/v1/fhir")
+	mustValidate(t, bundle)
+
+	var types []string
+	for _, e := range bundle.Entry {
+		types = append(types, strings.SplitN(strings.TrimPrefix(e.FullURL, "https://example.org/api/v1/fhir/"), "/", 2)[0])
+	}
+	want := "Patient Observation QuestionnaireResponse Flag DetectedIssue Flag DetectedIssue"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("entries = %s, want %s", got, want)
+	}
+	issue, ok := bundle.Entry[6].Resource.(*fhir.DetectedIssue)
+	if !ok {
+		t.Fatalf("entry 6 is %T, want a DetectedIssue", bundle.Entry[6].Resource)
+	}
+	if issue.Evidence != nil {
+		t.Errorf("evidence = %+v, want none for readings outside the bundle", issue.Evidence)
+	}
+
+	// a patient with nothing recorded yet
+	mustValidate(t, s.everythingBundle(patient, nil, nil, nil, "https://example.org/api/v1/fhir"))
+}
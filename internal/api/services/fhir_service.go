@@ -0,0 +1,283 @@
+package services
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FHIRService exports patients and their data as FHIR R4 resources. Patients
+// are identified by their user id, as in the rest of the API; observations
+// are vital readings, flags and detected issues are alerts, and questionnaire
+// responses are checkins, all keyed by the id of the underlying row.
+type FHIRService struct {
+	db      *gorm.DB
+	catalog *VitalCatalog
+	// published dates the capability statement; it only changes on deploys.
+	published time.Time
+}
+
+func NewFHIRService(db *gorm.DB, catalog *VitalCatalog) *FHIRService {
+	return &FHIRService{db: db, catalog: catalog, published: time.Now()}
+}
+
+// Capabilities describes the read-only interactions the export supports.
+func (s *FHIRService) Capabilities() *fhir.CapabilityStatement {
+	read := []fhir.CapabilityCode{{Code: "read"}}
+	return &fhir.CapabilityStatement{
+		ResourceType: "CapabilityStatement",
+		Status:       "active",
+		Date:         fhirInstant(s.published),
+		Kind:         "instance",
+		FHIRVersion:  fhir.Version,
+		Format:       []string{"json"},
+		Rest: []fhir.CapabilityRest{{
+			Mode: "server",
+			Resource: []fhir.CapabilityResource{
+				{
+					Type:        "Patient",
+					Interaction: read,
+					Operation:   []fhir.CapabilityOperation{{Name: "everything", Definition: "http://hl7.org/fhir/OperationDefinition/Patient-everything"}},
+				},
+				{
+					Type:        "Observation",
+					Interaction: []fhir.CapabilityCode{{Code: "read"}, {Code: "search-type"}},
+					SearchParam: []fhir.CapabilitySearch{{Name: "patient", Type: "reference"}, {Name: "code", Type: "token"}},
+				},
+				{Type: "Flag", Interaction: read},
+				{Type: "DetectedIssue", Interaction: read},
+				{Type: "QuestionnaireResponse", Interaction: read},
+			},
+		}},
+	}
+}
+
+func (s *FHIRService) loadPatient(query string, arg interface{}) (*models.Patient, error) {
+	var patient models.Patient
+	if err := s.db.Preload("User").Preload("Doctor").First(&patient, query, arg).Error; err != nil {
+		return nil, err
+	}
+	return &patient, nil
+}
+
+// patientUserID returns the FHIR id of the patient with the given patients.id.
+func (s *FHIRService) patientUserID(patientID uuid.UUID) (uuid.UUID, error) {
+	var patient models.Patient
+	if err := s.db.Select("user_id").First(&patient, "id = ?", patientID).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return patient.UserID, nil
+}
+
+func (s *FHIRService) Patient(patientUserID uuid.UUID) (*fhir.Patient, error) {
+	patient, err := s.loadPatient("user_id = ?", patientUserID)
+	if err != nil {
+		return nil, err
+	}
+	return fhirPatient(patient), nil
+}
+
+func (s *FHIRService) Observation(id uuid.UUID) (*fhir.Observation, error) {
+	var reading models.VitalReading
+	if err := s.db.First(&reading, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	userID, err := s.patientUserID(reading.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	return s.fhirObservation(&reading, userID), nil
+}
+
+// SearchObservations pages through a patient's observations, newest first by
+// default. code is a LOINC or vital type code, optionally "system|code"; a
+// code outside both systems matches nothing. base is the absolute URL of the
+// FHIR endpoint, used for the entries' fullUrl.
+func (s *FHIRService) SearchObservations(patientUserID uuid.UUID, code string, page pagination.Params, base string) (*fhir.Bundle, pagination.Meta, error) {
+	patient, err := s.loadPatient("user_id = ?", patientUserID)
+	if err != nil {
+		return nil, pagination.Meta{}, err
+	}
+
+	query := s.db.Model(&models.VitalReading{}).Where("patient_id = ?", patient.ID)
+	if code != "" {
+		vt, ok := vitalTypeForCode(code)
+		if !ok {
+			query = query.Where("false")
+		} else {
+			query = query.Where("vital_type = ?", vt)
+		}
+	}
+	result, err := pagination.Paginate[models.VitalReading](query, page, vitalReadingPageSpec)
+	if err != nil {
+		return nil, pagination.Meta{}, err
+	}
+
+	bundle := newBundle("searchset")
+	if result.Meta.Total != nil {
+		bundle.Total = ptr(int(*result.Meta.Total))
+	}
+	for i := range result.Items {
+		bundle.Entry = append(bundle.Entry, bundleEntry(base, s.fhirObservation(&result.Items[i], patient.UserID), "Observation", result.Items[i].ID, "match"))
+	}
+	return bundle, result.Meta, nil
+}
+
+// alertFor loads an alert with who acknowledged it, plus its patient's FHIR id.
+func (s *FHIRService) alertFor(id uuid.UUID) (*models.Alert, uuid.UUID, error) {
+	var alert models.Alert
+	if err := s.db.Preload("Acknowledger").First(&alert, "id = ?", id).Error; err != nil {
+		return nil, uuid.Nil, err
+	}
+	userID, err := s.patientUserID(alert.PatientID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return &alert, userID, nil
+}
+
+func (s *FHIRService) Flag(alertID uuid.UUID) (*fhir.Flag, error) {
+	alert, userID, err := s.alertFor(alertID)
+	if err != nil {
+		return nil, err
+	}
+	return fhirFlag(alert, userID), nil
+}
+
+func (s *FHIRService) DetectedIssue(alertID uuid.UUID) (*fhir.DetectedIssue, error) {
+	alert, userID, err := s.alertFor(alertID)
+	if err != nil {
+		return nil, err
+	}
+	evidence, err := s.existingReadings(alert.PatientID, alertEvidence(alert.Details))
+	if err != nil {
+		return nil, err
+	}
+	return fhirDetectedIssue(alert, userID, evidence), nil
+}
+
+// existingReadings keeps the ids that still belong to the patient's readings,
+// so evidence never points at a deleted observation.
+func (s *FHIRService) existingReadings(patientID uuid.UUID, ids []uuid.UUID) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var found []uuid.UUID
+	if err := s.db.Model(&models.VitalReading{}).
+		Where("patient_id = ? AND id IN ?", patientID, ids).
+		Order("id").
+		Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (s *FHIRService) QuestionnaireResponse(checkinID uuid.UUID) (*fhir.QuestionnaireResponse, error) {
+	var checkin models.Checkin
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	userID, err := s.patientUserID(checkin.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	return fhirQuestionnaireResponse(&checkin, userID), nil
+}
+
+// Everything exports the patient with all of their observations, checkins and
+// alerts, as the Patient/$everything operation. start and end limit the
+// clinical data to readings measured, checkins started and alerts raised in
+// that range; the patient is always included.
+func (s *FHIRService) Everything(patientUserID uuid.UUID, start, end *time.Time, base string) (*fhir.Bundle, error) {
+	patient, err := s.loadPatient("user_id = ?", patientUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(query *gorm.DB, column string) *gorm.DB {
+		if start != nil {
+			query = query.Where(column+" >= ?", *start)
+		}
+		if end != nil {
+			query = query.Where(column+" < ?", *end)
+		}
+		return query
+	}
+
+	var readings []models.VitalReading
+	if err := inRange(s.db.Where("patient_id = ?", patient.ID), "measured_at").
+		Order("measured_at ASC, id ASC").
+		Find(&readings).Error; err != nil {
+		return nil, err
+	}
+	var checkins []models.Checkin
+	if err := inRange(s.db.Where("patient_id = ?", patient.ID), "initiated_at").
+		Order("initiated_at ASC, id ASC").
+		Find(&checkins).Error; err != nil {
+		return nil, err
+	}
+	var alerts []models.Alert
+	if err := inRange(s.db.Preload("Acknowledger").Where("patient_id = ?", patient.ID), "created_at").
+		Order("created_at ASC, id ASC").
+		Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+
+	return s.everythingBundle(patient, readings, checkins, alerts, base), nil
+}
+
+// everythingBundle assembles the $everything searchset of a patient and the
+// clinical data loaded for it.
+func (s *FHIRService) everythingBundle(patient *models.Patient, readings []models.VitalReading, checkins []models.Checkin, alerts []models.Alert, base string) *fhir.Bundle {
+	bundle := newBundle("searchset")
+	bundle.Entry = append(bundle.Entry, bundleEntry(base, fhirPatient(patient), "Patient", patient.UserID, "match"))
+	for i := range readings {
+		bundle.Entry = append(bundle.Entry, bundleEntry(base, s.fhirObservation(&readings[i], patient.UserID), "Observation", readings[i].ID, "include"))
+	}
+	for i := range checkins {
+		bundle.Entry = append(bundle.Entry, bundleEntry(base, fhirQuestionnaireResponse(&checkins[i], patient.UserID), "QuestionnaireResponse", checkins[i].ID, "include"))
+	}
+
+	exported := make(map[uuid.UUID]bool, len(readings))
+	for _, r := range readings {
+		exported[r.ID] = true
+	}
+	for i := range alerts {
+		alert := &alerts[i]
+		var evidence []uuid.UUID
+		for _, id := range alertEvidence(alert.Details) {
+			if exported[id] {
+				evidence = append(evidence, id)
+			}
+		}
+		bundle.Entry = append(bundle.Entry,
+			bundleEntry(base, fhirFlag(alert, patient.UserID), "Flag", alert.ID, "include"),
+			bundleEntry(base, fhirDetectedIssue(alert, patient.UserID, evidence), "DetectedIssue", alert.ID, "include"),
+		)
+	}
+	bundle.Total = ptr(1)
+	return bundle
+}
+
+func newBundle(bundleType string) *fhir.Bundle {
+	now := time.Now()
+	return &fhir.Bundle{
+		ResourceType: "Bundle",
+		ID:           uuid.New().String(),
+		Meta:         &fhir.Meta{LastUpdated: fhirInstant(now)},
+		Type:         bundleType,
+		Timestamp:    fhirInstant(now),
+	}
+}
+
+func bundleEntry(base string, resource interface{}, resourceType string, id uuid.UUID, mode string) fhir.BundleEntry {
+	return fhir.BundleEntry{
+		FullURL:  base + "/" + resourceType + "/" + id.String(),
+		Resource: resource,
+		Search:   &fhir.BundleSearch{Mode: mode},
+	}
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hl7Person is what a PID segment says about the patient.
+type hl7Person struct {
+	firstName   string
+	lastName    string
+	phone       string
+	gender      *enums.Gender
+	identifiers []models.PatientIdentifier
+}
+
+// readPID reads the patient identification. Identifiers and the phone number
+// are how messages are matched to monitored patients; names are only needed
+// to enroll a new one.
+func readPID(msg *hl7.Message, pid *hl7.Segment, out *hl7Outcome) hl7Person {
+	var person hl7Person
+
+	authority := msg.Header().Field(4).Value()
+	if authority == "" {
+		authority = msg.Header().Field(3).Value()
+	}
+	for _, cx := range pid.Field(3).Repetitions() {
+		value := strings.TrimSpace(cx.Component(1))
+		if value == "" {
+			continue
+		}
+		system := strings.TrimSpace(cx.Subcomponent(4, 1))
+		if system == "" {
+			system = strings.TrimSpace(cx.Subcomponent(4, 2))
+		}
+		if system == "" {
+			system = authority
+		}
+		if system == "" {
+			out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "PID", Field: 3, Text: "identifier " + value + " has no assigning authority"})
+			continue
+		}
+		person.identifiers = append(person.identifiers, models.PatientIdentifier{System: truncate(system, 227), Value: truncate(value, 199)})
+	}
+
+	name := pid.Field(5)
+	person.lastName = strings.TrimSpace(name.Subcomponent(1, 1))
+	person.firstName = strings.TrimSpace(strings.Join(nonEmpty(name.Component(2), name.Component(3)), " "))
+
+	person.phone = hl7Phone(pid.Field(13))
+	if len(person.phone) > 20 {
+		out.add(hl7.Problem{Code: hl7.ErrDataType, Segment: "PID", Field: 13, Text: "phone number must be at most 20 characters"})
+		person.phone = ""
+	}
+
+	switch pid.Field(8).Value() {
+	case "M":
+		person.gender = ptr(enums.GenderMale)
+	case "F":
+		person.gender = ptr(enums.GenderFemale)
+	case "O", "A":
+		person.gender = ptr(enums.GenderOther)
+	}
+
+	if len(person.identifiers) == 0 && person.phone == "" {
+		out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "PID", Field: 3, Text: "an identifier or a phone number is required to match the patient"})
+	}
+	return person
+}
+
+// hl7Phone picks a mobile number from an XTN field, else the first number.
+func hl7Phone(field hl7.Field) string {
+	phone := ""
+	for _, xtn := range field.Repetitions() {
+		number := strings.TrimSpace(xtn.Component(12))
+		if number == "" {
+			number = strings.TrimSpace(xtn.Component(1))
+		}
+		if number == "" && xtn.Component(7) != "" {
+			number = strings.TrimSpace(strings.Join(nonEmpty("+"+xtn.Component(5), xtn.Component(6), xtn.Component(7)), ""))
+		}
+		if number == "" || xtn.Component(3) == "Internet" || xtn.Component(3) == "FX" {
+			continue
+		}
+		if xtn.Component(3) == "CP" {
+			return number
+		}
+		if phone == "" {
+			phone = number
+		}
+	}
+	return phone
+}
+
+// findPatient matches a person to a monitored patient by identifier first and
+// phone number second. It returns the user alone when the phone number
+// belongs to a patient user without a medical record yet, and nothing when
+// there is no match.
+func (s *HL7Service) findPatient(tx *gorm.DB, person hl7Person, out *hl7Outcome) (*models.User, *models.Patient, error) {
+	for _, id := range person.identifiers {
+		var known models.PatientIdentifier
+		err := tx.First(&known, "system = ? AND value = ?", id.System, id.Value).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		var patient models.Patient
+		if err := tx.Preload("User").First(&patient, "id = ?", known.PatientID).Error; err != nil {
+			return nil, nil, err
+		}
+		return patient.User, &patient, nil
+	}
+
+	if person.phone == "" {
+		return nil, nil, nil
+	}
+	var user models.User
+	err := tx.First(&user, "phone_number = ?", person.phone).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Role != enums.UserRolePatient {
+		out.add(hl7.Problem{Code: hl7.ErrDuplicateKey, Segment: "PID", Field: 13, Text: "phone number belongs to a staff user"})
+		return nil, nil, nil
+	}
+	var patient models.Patient
+	err = tx.First(&patient, "user_id = ?", user.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &user, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, &patient, nil
+}
+
+// linkIdentifiers records the person's identifiers for the patient, so later
+// messages match by identifier. An identifier held by another patient fails
+// the message.
+func linkIdentifiers(tx *gorm.DB, patientID uuid.UUID, identifiers []models.PatientIdentifier, out *hl7Outcome) error {
+	for _, id := range identifiers {
+		var known models.PatientIdentifier
+		err := tx.First(&known, "system = ? AND value = ?", id.System, id.Value).Error
+		if err == nil {
+			if known.PatientID != patientID {
+				out.add(hl7.Problem{Code: hl7.ErrDuplicateKey, Segment: "PID", Field: 3,
+					Text: fmt.Sprintf("identifier %s of %s belongs to another patient", id.Value, id.System)})
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		id.PatientID = patientID
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&id).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discharge applies ADT^A03. A known patient is reactivated with the new
+// discharge date, diagnoses and doctor; an unknown one is enrolled with a
+// patient user and medical record. Enrolled users get a random password and
+// a placeholder Telegram username, which no real username can collide with
+// since Telegram does not allow hyphens; staff replace it so the bot can find
+// the patient.
+func (s *HL7Service) discharge(tx *gorm.DB, msg *hl7.Message) (hl7Outcome, error) {
+	var out hl7Outcome
+	pid, ok := msg.Segment("PID")
+	if !ok {
+		out.add(hl7.Problem{Code: hl7.ErrSegmentSequence, Text: "PID segment is required"})
+		return out, nil
+	}
+	person := readPID(msg, pid, &out)
+
+	dischargedAt := time.Now()
+	var pv1 *hl7.Segment
+	if segment, ok := msg.Segment("PV1"); ok {
+		pv1 = segment
+		if raw := pv1.Field(45).Value(); raw != "" {
+			if t, err := hl7.ParseTime(raw, time.Local); err != nil {
+				out.add(hl7.Problem{Code: hl7.ErrDataType, Segment: "PV1", Field: 45, Text: err.Error()})
+			} else {
+				dischargedAt = t
+			}
+		} else if t, ok := eventTime(msg); ok {
+			dischargedAt = t
+		}
+	} else if t, ok := eventTime(msg); ok {
+		dischargedAt = t
+	}
+
+	doctorID, named, err := s.attendingDoctor(tx, pv1, &out)
+	if err != nil {
+		return out, err
+	}
+	diagnoses := hl7Diagnoses(msg)
+	allergies := hl7Allergies(msg)
+	notes := hl7Notes(msg)
+	contact := hl7EmergencyContact(msg, &out)
+	if out.failed() {
+		return out, nil
+	}
+
+	user, patient, err := s.findPatient(tx, person, &out)
+	if err != nil || out.failed() {
+		return out, err
+	}
+
+	if patient != nil {
+		updates := map[string]interface{}{
+			"status":         enums.PatientStatusActive,
+			"discharge_date": dischargedAt,
+		}
+		if named {
+			updates["doctor_id"] = *doctorID
+		}
+		if len(diagnoses) > 0 {
+			updates["condition_summary"] = diagnoses[0]
+			updates["comorbidities"] = models.StringArray(diagnoses[1:])
+		}
+		if len(allergies) > 0 {
+			merged := append([]string{}, patient.Allergies...)
+			for _, a := range allergies {
+				if !slices.Contains(merged, a) {
+					merged = append(merged, a)
+				}
+			}
+			updates["allergies"] = models.StringArray(merged)
+		}
+		if notes != "" {
+			updates["discharge_notes"] = notes
+		}
+		if contact != nil {
+			updates["emergency_contact_name"] = contact.name
+			updates["emergency_contact_phone"] = contact.phone
+			updates["emergency_contact_relation"] = contact.relation
+		}
+		if err := updateVersioned(tx, &models.Patient{}, patient.ID, nil, updates); err != nil {
+			return out, err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", patient.UserID).Update("is_active", true).Error; err != nil {
+			return out, err
+		}
+	} else {
+		if doctorID == nil {
+			out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "PV1", Field: 7, Text: "attending doctor is not a vital-sync doctor and no default doctor is configured"})
+		}
+		if len(diagnoses) == 0 {
+			out.add(hl7.Problem{Code: hl7.ErrSegmentSequence, Segment: "DG1", Text: "a diagnosis is required to enroll a new patient"})
+		}
+		if user == nil {
+			if person.lastName == "" || person.firstName == "" {
+				out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "PID", Field: 5, Text: "family and given name are required to enroll a new patient"})
+			}
+			if person.phone == "" {
+				out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "PID", Field: 13, Text: "a phone number is required to enroll a new patient"})
+			}
+		}
+		if out.failed() {
+			return out, nil
+		}
+
+		if user == nil {
+			password, err := randomPassword()
+			if err != nil {
+				return out, err
+			}
+			user = &models.User{
+				ID:           uuid.New(),
+				PhoneNumber:  person.phone,
+				PasswordHash: password,
+				FirstName:    truncate(person.firstName, 100),
+				LastName:     truncate(person.lastName, 100),
+				Role:         enums.UserRolePatient,
+				Gender:       person.gender,
+				IsActive:     true,
+			}
+			user.TelegramUsername = "hl7-" + user.ID.String()
+			if err := tx.Create(user).Error; err != nil {
+				return out, err
+			}
+		}
+
+		patient = &models.Patient{
+			UserID:              user.ID,
+			DoctorID:            *doctorID,
+			ConditionSummary:    diagnoses[0],
+			Comorbidities:       models.StringArray(diagnoses[1:]),
+			Allergies:           models.StringArray(allergies),
+			RiskLevel:           enums.RiskLevelMedium,
+			MonitoringFrequency: enums.MonitoringFrequencyDaily,
+			Status:              enums.PatientStatusActive,
+			DischargeDate:       &dischargedAt,
+		}
+		if notes != "" {
+			patient.DischargeNotes = &notes
+		}
+		if contact != nil {
+			patient.EmergencyContactName, patient.EmergencyContactPhone, patient.EmergencyContactRelation = contact.name, contact.phone, contact.relation
+		}
+		if err := tx.Create(patient).Error; err != nil {
+			return out, err
+		}
+	}
+
+	if err := linkIdentifiers(tx, patient.ID, person.identifiers, &out); err != nil {
+		return out, err
+	}
+	out.patientID = &patient.ID
+	return out, nil
+}
+
+// eventTime is when the event happened according to EVN, else when the
+// message was created.
+func eventTime(msg *hl7.Message) (time.Time, bool) {
+	var candidates []string
+	if evn, ok := msg.Segment("EVN"); ok {
+		candidates = append(candidates, evn.Field(6).Value(), evn.Field(2).Value())
+	}
+	candidates = append(candidates, msg.Header().Field(7).Value())
+	for _, raw := range candidates {
+		if raw == "" {
+			continue
+		}
+		if t, err := hl7.ParseTime(raw, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// attendingDoctor resolves PV1-7 to a doctor user by id. named reports that
+// the message itself named the doctor; otherwise the configured default
+// doctor, if any, is returned.
+func (s *HL7Service) attendingDoctor(tx *gorm.DB, pv1 *hl7.Segment, out *hl7Outcome) (id *uuid.UUID, named bool, err error) {
+	if pv1 != nil {
+		for _, xcn := range pv1.Field(7).Repetitions() {
+			raw := strings.TrimSpace(xcn.Component(1))
+			if raw == "" {
+				continue
+			}
+			doctorID, parseErr := uuid.Parse(raw)
+			if parseErr == nil {
+				err := tx.First(&models.User{}, "id = ? AND role = ?", doctorID, enums.UserRoleDoctor).Error
+				if err == nil {
+					return &doctorID, true, nil
+				}
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, false, err
+				}
+			}
+			out.add(hl7.Problem{Code: hl7.ErrUnknownKey, Severity: hl7.SeverityWarning, Segment: "PV1", Field: 7,
+				Text: "attending doctor " + raw + " is not a vital-sync doctor"})
+		}
+	}
+
+	if s.defaultDoctor == nil {
+		return nil, false, nil
+	}
+	err = tx.First(&models.User{}, "id = ? AND role = ?", *s.defaultDoctor, enums.UserRoleDoctor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return s.defaultDoctor, false, nil
+}
+
+// hl7Diagnoses lists the DG1 diagnoses, final ones first, without repeats.
+func hl7Diagnoses(msg *hl7.Message) []string {
+	var final, other []string
+	for _, dg1 := range msg.All("DG1") {
+		label := strings.TrimSpace(dg1.Field(3).Component(2))
+		if label == "" {
+			label = dg1.Field(4).Value()
+		}
+		if label == "" {
+			label = dg1.Field(3).Value()
+		}
+		if label == "" || slices.Contains(final, label) || slices.Contains(other, label) {
+			continue
+		}
+		if dg1.Field(6).Value() == "F" {
+			final = append(final, label)
+		} else {
+			other = append(other, label)
+		}
+	}
+	return append(final, other...)
+}
+
+// hl7Allergies lists the AL1 allergens with their reactions, as the API
+// stores them: "Penicillin (rash, hives)".
+func hl7Allergies(msg *hl7.Message) []string {
+	var out []string
+	for _, al1 := range msg.All("AL1") {
+		name := strings.TrimSpace(al1.Field(3).Component(2))
+		if name == "" {
+			name = al1.Field(3).Value()
+		}
+		if name == "" {
+			continue
+		}
+		var reactions []string
+		for _, r := range al1.Field(5).Repetitions() {
+			if text := r.Value(); text != "" {
+				reactions = append(reactions, text)
+			}
+		}
+		if len(reactions) > 0 {
+			name += " (" + strings.Join(reactions, ", ") + ")"
+		}
+		if !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// hl7Notes joins the NTE comments into the discharge notes.
+func hl7Notes(msg *hl7.Message) string {
+	var lines []string
+	for _, nte := range msg.All("NTE") {
+		for _, c := range nte.Field(3).Repetitions() {
+			if text := strings.TrimSpace(c.Component(1)); text != "" {
+				lines = append(lines, text)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+type hl7Contact struct {
+	name, phone, relation *string
+}
+
+// hl7EmergencyContact reads the NK1 marked as emergency contact (NK1-7 "C"),
+// else the first next of kin.
+func hl7EmergencyContact(msg *hl7.Message, out *hl7Outcome) *hl7Contact {
+	segments := msg.All("NK1")
+	if len(segments) == 0 {
+		return nil
+	}
+	nk1 := segments[0]
+	for _, s := range segments {
+		if s.Field(7).Value() == "C" {
+			nk1 = s
+			break
+		}
+	}
+
+	var contact hl7Contact
+	name := nk1.Field(2)
+	if full := strings.TrimSpace(strings.Join(nonEmpty(name.Component(2), name.Subcomponent(1, 1)), " ")); full != "" {
+		contact.name = ptr(truncate(full, 255))
+	}
+	if phone := hl7Phone(nk1.Field(5)); phone != "" {
+		if len(phone) > 20 {
+			out.add(hl7.Problem{Code: hl7.ErrDataType, Severity: hl7.SeverityWarning, Segment: "NK1", Field: 5, Text: "emergency contact phone number is longer than 20 characters; ignored"})
+		} else {
+			contact.phone = &phone
+		}
+	}
+	relation := strings.TrimSpace(nk1.Field(3).Component(2))
+	if relation == "" {
+		relation = nk1.Field(3).Value()
+	}
+	if relation != "" {
+		contact.relation = ptr(truncate(relation, 50))
+	}
+	if contact.name == nil && contact.phone == nil {
+		return nil
+	}
+	return &contact
+}
+
+func nonEmpty(values ...string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" && v != "+" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
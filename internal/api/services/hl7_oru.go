@@ -0,0 +1,298 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/fhir"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+	"gorm.io/gorm"
+)
+
+// hl7LOINCSystems are the OBX-3 coding system names used for LOINC.
+var hl7LOINCSystems = []string{"LN", "LOINC", fhir.SystemLOINC}
+
+// hl7Observation is a vital reading assembled from one OBX, or from a systolic
+// and a diastolic OBX for blood pressure.
+type hl7Observation struct {
+	sequence int // of the first OBX, to locate problems
+	input    CreateVitalReadingInput
+}
+
+// bpHalf is a systolic or diastolic OBX waiting for its counterpart.
+type bpHalf struct {
+	sequence int
+	value    float64
+	unit     *enums.VitalUnit
+	at       time.Time
+	device   *string
+}
+
+// results applies ORU^R01: each OBX coded as a vital sign becomes a reading
+// of the patient, assessed and alerted on like any other. Blood pressure may
+// come as one "140/90" value or as systolic and diastolic results with the
+// same OBX-4 sub-id. Other results, and ones withdrawn by their status, are
+// skipped with a warning; a vital sign that cannot be stored fails the whole
+// message so the sender can correct and resend it.
+func (s *HL7Service) results(tx *gorm.DB, msg *hl7.Message) (hl7Outcome, error) {
+	var out hl7Outcome
+	pid, ok := msg.Segment("PID")
+	if !ok {
+		out.add(hl7.Problem{Code: hl7.ErrSegmentSequence, Text: "PID segment is required"})
+		return out, nil
+	}
+	person := readPID(msg, pid, &out)
+	if out.failed() {
+		return out, nil
+	}
+	_, patient, err := s.findPatient(tx, person, &out)
+	if err != nil || out.failed() {
+		return out, err
+	}
+	if patient == nil {
+		out.add(hl7.Problem{Code: hl7.ErrUnknownKey, Segment: "PID", Field: 3, Text: "patient is not monitored by vital-sync"})
+		return out, nil
+	}
+	out.patientID = &patient.ID
+
+	observations := s.readOBX(msg, &out)
+	if out.failed() {
+		return out, nil
+	}
+	if len(observations) == 0 {
+		out.add(hl7.Problem{Code: hl7.ErrSegmentSequence, Severity: hl7.SeverityWarning, Segment: "OBX", Text: "message has no vital sign results"})
+		return out, nil
+	}
+
+	for _, obs := range observations {
+		obs.input.PatientID = patient.UserID
+		reading, assessment, err := s.vitals.prepare(patient, obs.input)
+		if err != nil {
+			fields, ok := rowErrors(err)
+			if !ok {
+				return out, err
+			}
+			for _, f := range fields {
+				out.add(hl7.Problem{Code: hl7.ErrDataType, Segment: "OBX", Sequence: obs.sequence, Field: 5, Text: f.Field + " " + f.Message})
+			}
+			continue
+		}
+		existing, err := s.vitals.findDuplicate(reading)
+		if err != nil {
+			return out, err
+		}
+		if existing != nil {
+			continue
+		}
+		if err := s.vitals.persist(tx, patient, reading, assessment); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// readOBX turns the OBX segments into readings. Result times default to the
+// OBR they belong to, then to the message time.
+func (s *HL7Service) readOBX(msg *hl7.Message, out *hl7Outcome) []hl7Observation {
+	defaultTime := time.Now()
+	if t, ok := eventTime(msg); ok {
+		defaultTime = t
+	}
+
+	var observations []hl7Observation
+	systolic := map[string]bpHalf{}
+	diastolic := map[string]bpHalf{}
+	obrTime := defaultTime
+	sequence := 0
+	for i := range msg.Segments {
+		seg := &msg.Segments[i]
+		switch seg.Name {
+		case "OBR":
+			obrTime = defaultTime
+			if raw := seg.Field(7).Value(); raw != "" {
+				t, err := hl7.ParseTime(raw, time.Local)
+				if err != nil {
+					out.add(hl7.Problem{Code: hl7.ErrDataType, Segment: "OBR", Field: 7, Text: err.Error()})
+					continue
+				}
+				obrTime = t
+			}
+			continue
+		case "OBX":
+			sequence++
+		default:
+			continue
+		}
+		problem := func(field int, severity hl7.Severity, code hl7.ErrorCode, format string, args ...interface{}) {
+			out.add(hl7.Problem{Code: code, Severity: severity, Segment: "OBX", Sequence: sequence, Field: field, Text: fmt.Sprintf(format, args...)})
+		}
+
+		switch status := seg.Field(11).Value(); status {
+		case "", "F", "C", "P", "R", "S":
+		default:
+			problem(11, hl7.SeverityWarning, hl7.ErrTableValue, "result status %s is not a usable result; skipped", status)
+			continue
+		}
+
+		code := seg.Field(3)
+		vt, component, ok := s.obxVitalType(code)
+		if !ok {
+			problem(3, hl7.SeverityWarning, hl7.ErrTableValue, "%s is not a vital sign; skipped", strings.Join(nonEmpty(code.Component(1), code.Component(2)), " "))
+			continue
+		}
+		entry, _ := s.vitals.catalog.lookup(vt)
+
+		at := obrTime
+		if raw := seg.Field(14).Value(); raw != "" {
+			t, err := hl7.ParseTime(raw, time.Local)
+			if err != nil {
+				problem(14, hl7.SeverityError, hl7.ErrDataType, "%s", err.Error())
+				continue
+			}
+			at = t
+		}
+
+		var unit *enums.VitalUnit
+		units := seg.Field(6)
+		if !units.Empty() {
+			q := fhir.Quantity{Unit: strings.TrimSpace(units.Component(2))}
+			switch units.Component(3) {
+			case "", "UCUM", fhir.SystemUCUM:
+				q.Code = strings.TrimSpace(units.Component(1))
+			}
+			if q.Unit == "" {
+				q.Unit = strings.TrimSpace(units.Component(1))
+			}
+			resolved, ok := quantityUnit(entry, &q)
+			if !ok {
+				problem(6, hl7.SeverityError, hl7.ErrTableValue, "unit %s is not accepted for %s", units.Component(1), vt)
+				continue
+			}
+			unit = resolved
+		}
+
+		var device *string
+		if d := seg.Field(18).Value(); d != "" {
+			device = &d
+		}
+
+		raw := seg.Field(5).Value()
+		valueType := seg.Field(2).Value()
+		if valueType == "SN" {
+			// structured numeric: comparator^number; only exact values are readings
+			if cmp := seg.Field(5).Component(1); cmp != "" && cmp != "=" {
+				problem(5, hl7.SeverityWarning, hl7.ErrDataType, "value %s%s is not exact; skipped", cmp, seg.Field(5).Component(2))
+				continue
+			}
+			raw = strings.TrimSpace(seg.Field(5).Component(2))
+		}
+		if raw == "" {
+			problem(5, hl7.SeverityWarning, hl7.ErrRequiredField, "result has no value; skipped")
+			continue
+		}
+
+		if component != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				problem(5, hl7.SeverityError, hl7.ErrDataType, "value %q is not a number", raw)
+				continue
+			}
+			half := bpHalf{sequence: sequence, value: value, unit: unit, at: at, device: device}
+			key := seg.Field(4).Value() + "|" + at.UTC().Format(time.RFC3339Nano)
+			if component == enums.VitalComponentSystolic {
+				systolic[key] = half
+			} else {
+				diastolic[key] = half
+			}
+			continue
+		}
+
+		input := CreateVitalReadingInput{
+			VitalType:  vt,
+			Unit:       unit,
+			MeasuredAt: ptr(at),
+			Source:     ptr(enums.VitalSourceHL7),
+			Device:     device,
+		}
+		if value, err := strconv.ParseFloat(raw, 64); err == nil {
+			input.ValueNumeric = &value
+		} else {
+			input.ValueText = &raw
+		}
+		observations = append(observations, hl7Observation{sequence: sequence, input: input})
+	}
+
+	for key, sys := range systolic {
+		dia, ok := diastolic[key]
+		if !ok {
+			out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "OBX", Sequence: sys.sequence, Field: 3, Text: "systolic pressure has no diastolic result with the same sub-id and time"})
+			continue
+		}
+		delete(diastolic, key)
+		unit := sys.unit
+		if unit == nil {
+			unit = dia.unit
+		}
+		device := sys.device
+		if device == nil {
+			device = dia.device
+		}
+		observations = append(observations, hl7Observation{sequence: sys.sequence, input: CreateVitalReadingInput{
+			VitalType:  enums.VitalTypeBloodPressure,
+			Unit:       unit,
+			Systolic:   ptr(sys.value),
+			Diastolic:  ptr(dia.value),
+			MeasuredAt: ptr(sys.at),
+			Source:     ptr(enums.VitalSourceHL7),
+			Device:     device,
+		}})
+	}
+	for _, dia := range diastolic {
+		out.add(hl7.Problem{Code: hl7.ErrRequiredField, Segment: "OBX", Sequence: dia.sequence, Field: 3, Text: "diastolic pressure has no systolic result with the same sub-id and time"})
+	}
+	// store readings in message order
+	sort.Slice(observations, func(i, j int) bool { return observations[i].sequence < observations[j].sequence })
+	return observations
+}
+
+// obxVitalType maps an OBX-3 code, or its alternate coding, to a catalog
+// type. Systolic and diastolic LOINC codes map to blood pressure with the
+// component they carry.
+func (s *HL7Service) obxVitalType(code hl7.Field) (enums.VitalType, enums.VitalComponent, bool) {
+	for _, offset := range []int{0, 3} {
+		id := strings.TrimSpace(code.Component(offset + 1))
+		system := strings.TrimSpace(code.Component(offset + 3))
+		if id == "" {
+			continue
+		}
+		if slicesContainsFold(hl7LOINCSystems, system) {
+			switch id {
+			case loincSystolic.code:
+				return enums.VitalTypeBloodPressure, enums.VitalComponentSystolic, true
+			case loincDiastolic.code:
+				return enums.VitalTypeBloodPressure, enums.VitalComponentDiastolic, true
+			}
+			if vt, ok := vitalTypeForCode(fhir.SystemLOINC + "|" + id); ok {
+				return vt, "", true
+			}
+			continue
+		}
+		if _, ok := s.vitals.catalog.lookup(enums.VitalType(strings.ToUpper(id))); ok {
+			return enums.VitalType(strings.ToUpper(id)), "", true
+		}
+	}
+	return "", "", false
+}
+
+func slicesContainsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
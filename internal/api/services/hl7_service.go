@@ -0,0 +1,340 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultHL7MaxMessageKB       = 1024
+	defaultHL7IdleTimeoutSeconds = 300
+)
+
+// HL7Service takes HL7 v2 messages from hospital systems: ADT^A03 discharges
+// enroll or reactivate monitored patients and ORU^R01 results become vital
+// readings. Every message is answered with an original mode ACK and logged.
+type HL7Service struct {
+	db     *gorm.DB
+	logger *slog.Logger
+	vitals *VitalReadingService
+
+	app           hl7.Application
+	defaultDoctor *uuid.UUID
+	maxSize       int
+	idleTimeout   time.Duration
+	handlers      map[string]hl7Handler
+}
+
+// hl7Handler applies one message type inside the message's transaction.
+type hl7Handler func(tx *gorm.DB, msg *hl7.Message) (hl7Outcome, error)
+
+// hl7Outcome is what applying a message produced. Any problem of error
+// severity fails the message: nothing is kept and the ACK is AE.
+type hl7Outcome struct {
+	patientID *uuid.UUID
+	problems  []hl7.Problem
+}
+
+func (o *hl7Outcome) add(p hl7.Problem) {
+	o.problems = append(o.problems, p)
+}
+
+func (o *hl7Outcome) failed() bool {
+	for _, p := range o.problems {
+		if p.Severity != hl7.SeverityWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// errHL7Failed rolls back a message that is acknowledged with AE.
+var errHL7Failed = errors.New("hl7 message failed")
+
+func NewHL7Service(db *gorm.DB, cfg *config.Config, logger *slog.Logger, vitals *VitalReadingService) *HL7Service {
+	c := cfg.Internal.HL7
+	s := &HL7Service{
+		db:          db,
+		logger:      logger,
+		vitals:      vitals,
+		app:         hl7.Application{Name: c.Application, Facility: c.Facility},
+		maxSize:     c.MaxMessageKB * 1024,
+		idleTimeout: time.Duration(c.IdleTimeoutSeconds) * time.Second,
+	}
+	if s.app.Name == "" {
+		s.app.Name = cfg.AppName
+	}
+	if s.maxSize <= 0 {
+		s.maxSize = defaultHL7MaxMessageKB * 1024
+	}
+	if s.idleTimeout <= 0 {
+		s.idleTimeout = defaultHL7IdleTimeoutSeconds * time.Second
+	}
+	if c.DefaultDoctorID != "" {
+		id, err := uuid.Parse(c.DefaultDoctorID)
+		if err != nil {
+			logger.Error("ignoring invalid hl7 default_doctor_id", "value", c.DefaultDoctorID)
+		} else {
+			s.defaultDoctor = &id
+		}
+	}
+	s.handlers = map[string]hl7Handler{
+		"ADT^A03": s.discharge,
+		"ORU^R01": s.results,
+	}
+	return s
+}
+
+// MaxMessageSize is the largest message accepted, in bytes.
+func (s *HL7Service) MaxMessageSize() int {
+	return s.maxSize
+}
+
+// IdleTimeout is how long an MLLP connection may stay silent.
+func (s *HL7Service) IdleTimeout() time.Duration {
+	return s.idleTimeout
+}
+
+// Handle processes one message and returns its ACK. It never fails: problems
+// with the message are reported in the ACK and infrastructure failures are
+// acknowledged with AE so the sender retries. A message whose control id was
+// already applied is not applied again; the original ACK is sent back, or an
+// AR with a duplicate key error when the control id came with another message.
+func (s *HL7Service) Handle(raw []byte, transport enums.HL7Transport) []byte {
+	entry := newHL7LogEntry(raw, transport)
+
+	var ack []byte
+	msg, err := hl7.Parse(raw)
+	if err != nil {
+		var parseErr *hl7.ParseError
+		text := err.Error()
+		if errors.As(err, &parseErr) {
+			text = parseErr.Message
+		}
+		ack = s.finish(entry, nil, hl7.AckReject, hl7.Problem{Code: hl7.ErrSegmentSequence, Text: text})
+	} else {
+		ack = s.process(entry, msg)
+	}
+	s.record(entry)
+	return ack
+}
+
+// Oversized rejects a message larger than MaxMessageSize with AR. head is the
+// start of the message; when it holds the MSH segment the ACK is addressed
+// to the sender and references the message's control id.
+func (s *HL7Service) Oversized(head []byte, transport enums.HL7Transport) []byte {
+	entry := newHL7LogEntry(head, transport)
+
+	line, _, _ := strings.Cut(strings.ReplaceAll(string(head), "\n", "\r"), "\r")
+	msg, err := hl7.Parse([]byte(line))
+	if err == nil {
+		s.describe(entry, msg)
+	} else {
+		msg = nil
+	}
+	text := fmt.Sprintf("message exceeds the %d KB limit", s.maxSize/1024)
+	ack := s.finish(entry, msg, hl7.AckReject, hl7.Problem{Code: hl7.ErrInternal, Text: text})
+	s.record(entry)
+	return ack
+}
+
+func newHL7LogEntry(raw []byte, transport enums.HL7Transport) *models.HL7Message {
+	return &models.HL7Message{
+		ID:         uuid.New(),
+		Raw:        strings.ToValidUTF8(strings.ReplaceAll(string(raw), "\x00", ""), "\uFFFD"),
+		Transport:  transport,
+		ReceivedAt: time.Now(),
+	}
+}
+
+// describe copies the header fields the log is searched by.
+func (s *HL7Service) describe(entry *models.HL7Message, msg *hl7.Message) {
+	msh := msg.Header()
+	entry.ControlID = truncate(msg.ControlID(), 199)
+	entry.SendingApplication = truncate(msh.Field(3).Value(), 227)
+	entry.SendingFacility = truncate(msh.Field(4).Value(), 227)
+	entry.MessageType = truncate(msg.Type(), 20)
+}
+
+func (s *HL7Service) record(entry *models.HL7Message) {
+	if err := s.db.Create(entry).Error; err != nil {
+		s.logger.Error("failed to log hl7 message", "control_id", entry.ControlID, "status", entry.Status, "error", err)
+	}
+}
+
+func (s *HL7Service) process(entry *models.HL7Message, msg *hl7.Message) []byte {
+	s.describe(entry, msg)
+	if entry.ControlID == "" {
+		return s.finish(entry, msg, hl7.AckReject, hl7.Problem{Code: hl7.ErrRequiredField, Segment: "MSH", Field: 10, Text: "message control id is required"})
+	}
+	apply, ok := s.handlers[entry.MessageType]
+	if !ok {
+		code := msg.Header().Field(9).Component(1)
+		problem := hl7.Problem{Code: hl7.ErrUnsupportedType, Segment: "MSH", Field: 9, Text: "only ADT^A03 and ORU^R01 messages are accepted"}
+		if code == "ADT" || code == "ORU" {
+			problem.Code = hl7.ErrUnsupportedEvent
+		}
+		return s.finish(entry, msg, hl7.AckReject, problem)
+	}
+
+	var ack []byte
+	var replay *models.HL7ControlID
+	conflict := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// claiming the control id first makes a concurrent retransmission wait
+		// for this one and then replay its ACK
+		control := models.HL7ControlID{
+			SendingApplication: entry.SendingApplication,
+			SendingFacility:    entry.SendingFacility,
+			ControlID:          entry.ControlID,
+			MessageID:          entry.ID,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&control)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			replay = &models.HL7ControlID{}
+			if err := tx.First(replay, "sending_application = ? AND sending_facility = ? AND control_id = ?",
+				control.SendingApplication, control.SendingFacility, control.ControlID).Error; err != nil {
+				return err
+			}
+			var original models.HL7Message
+			if err := tx.Select("patient_id", "raw").First(&original, "id = ?", replay.MessageID).Error; err == nil {
+				if !sameHL7Message(original.Raw, entry.Raw) {
+					conflict = true
+					return nil
+				}
+				entry.PatientID = original.PatientID
+			}
+			return nil
+		}
+
+		outcome, err := apply(tx, msg)
+		if err != nil {
+			return err
+		}
+		entry.PatientID = outcome.patientID
+		if outcome.failed() {
+			ack = s.finish(entry, msg, hl7.AckError, outcome.problems...)
+			return errHL7Failed
+		}
+		ack = s.finish(entry, msg, hl7.AckAccept, outcome.problems...)
+		return tx.Model(&control).Update("ack", string(ack)).Error
+	})
+
+	switch {
+	case errors.Is(err, errHL7Failed):
+		entry.PatientID = nil
+		return ack
+	case err != nil:
+		s.logger.Error("failed to apply hl7 message", "control_id", entry.ControlID, "type", entry.MessageType, "error", err)
+		entry.PatientID = nil
+		return s.finish(entry, msg, hl7.AckError, hl7.Problem{Code: hl7.ErrInternal, Text: "the message could not be processed; send it again later"})
+	case conflict:
+		return s.finish(entry, msg, hl7.AckReject, hl7.Problem{Code: hl7.ErrDuplicateKey, Segment: "MSH", Field: 10,
+			Text: "message control id was already used for a different message"})
+	case replay != nil:
+		entry.Status, entry.AckCode, entry.Ack, entry.ProcessedAt = enums.HL7MessageDuplicate, string(hl7.AckAccept), replay.Ack, time.Now()
+		return []byte(replay.Ack)
+	}
+	return ack
+}
+
+// sameHL7Message reports whether two logged messages are the same message,
+// whatever segment terminators each was sent with.
+func sameHL7Message(a, b string) bool {
+	normalize := func(s string) string {
+		s = strings.ReplaceAll(s, "\r\n", "\r")
+		return strings.Trim(strings.ReplaceAll(s, "\n", "\r"), "\r")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// finish builds the ACK and records the outcome on the log entry.
+func (s *HL7Service) finish(entry *models.HL7Message, msg *hl7.Message, code hl7.AckCode, problems ...hl7.Problem) []byte {
+	now := time.Now()
+	ack := hl7.NewACK(msg, code, s.app, newHL7ControlID(), now, problems...)
+
+	switch code {
+	case hl7.AckAccept:
+		entry.Status = enums.HL7MessageProcessed
+	case hl7.AckError:
+		entry.Status = enums.HL7MessageFailed
+	default:
+		entry.Status = enums.HL7MessageRejected
+	}
+	entry.AckCode, entry.Ack, entry.ProcessedAt = string(code), string(ack), now
+	entry.Problems = nil
+	for _, p := range problems {
+		entry.Problems = append(entry.Problems, string(p.Severity)+" "+p.Error())
+	}
+	return ack
+}
+
+// newHL7ControlID returns a 20 character id, the ST length of MSH-10.
+func newHL7ControlID() string {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return strings.ReplaceAll(uuid.NewString(), "-", "")[:20]
+	}
+	return hex.EncodeToString(buf)
+}
+
+type HL7MessageFilter struct {
+	Status      *enums.HL7MessageStatus
+	MessageType string
+	ControlID   string
+	PatientID   *uuid.UUID // patient user id
+}
+
+var hl7MessagePageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"received_at": {Expr: "hl7_messages.received_at", Field: "received_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "received_at",
+	DateColumn:  "hl7_messages.received_at",
+	IDColumn:    "hl7_messages.id",
+}
+
+// ListMessages pages through the message log, newest first by default.
+func (s *HL7Service) ListMessages(filter HL7MessageFilter, page pagination.Params) (*pagination.Page[models.HL7Message], error) {
+	query := s.db.Model(&models.HL7Message{})
+	if filter.Status != nil {
+		query = query.Where("hl7_messages.status = ?", *filter.Status)
+	}
+	if filter.MessageType != "" {
+		query = query.Where("hl7_messages.message_type = ?", filter.MessageType)
+	}
+	if filter.ControlID != "" {
+		query = query.Where("hl7_messages.control_id = ?", filter.ControlID)
+	}
+	if filter.PatientID != nil {
+		query = query.Joins("JOIN patients p ON p.id = hl7_messages.patient_id").Where("p.user_id = ?", *filter.PatientID)
+	}
+	return pagination.Paginate[models.HL7Message](query, page, hl7MessagePageSpec, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Patient")
+	})
+}
+
+func (s *HL7Service) GetMessage(id uuid.UUID) (*models.HL7Message, error) {
+	var message models.HL7Message
+	if err := s.db.Preload("Patient").First(&message, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
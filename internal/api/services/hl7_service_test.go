@@ -0,0 +1,251 @@
+package services
+
+import (
+	"database/sql/driver"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+	"github.com/google/uuid"
+)
+
+const hl7Discharge = "MSH|^~\\&|EPIC|CITYHOSP|VITALSYNC|CLINIC|20260301083000+0500||ADT^A03^ADT_A03|MSG00042|P|2.5\r" +
+	"EVN|A03|20260301083000+0500\r" +
+	"PID|1||MRN123^^^CITYHOSP^MR~998877^^^NATIONAL||Karimova^Aziza||19600412|F|||||^PRN^CP^^998^90^1234567\r" +
+	"PV1|1|I|CARD^101^A||||%s^House^Greg\r" +
+	"DG1|1||I50.9^Heart failure, unspecified^I10||||F\r" +
+	"DG1|2||E11.9^Type 2 diabetes mellitus^I10||||W\r" +
+	"AL1|1|DA|^Penicillin|SV|Rash~Hives\r" +
+	"NTE|1||Low salt diet~Weigh daily\r"
+
+const hl7Results = "MSH|^~\\&|MONITOR|ICU|VITALSYNC|CLINIC|20260301090000+0500||ORU^R01^ORU_R01|OBS77|P|2.5\r" +
+	"PID|1||MRN123^^^CITYHOSP\r" +
+	"OBR|1|||vitals|||20260301085500+0500\r" +
+	"OBX|1|NM|8867-4^Heart rate^LN||72|/min^beats per minute^UCUM|||||F\r" +
+	"OBX|2|NM|8480-6^Systolic blood pressure^LN|1|128|mm[Hg]|||||F\r" +
+	"OBX|3|NM|8462-4^Diastolic blood pressure^LN|1|82|mm[Hg]|||||F\r" +
+	"OBX|4|SN|59408-5^SpO2^LN||=^97|%|||||F\r" +
+	"OBX|5|NM|718-7^Hemoglobin^LN||13.5|g/dL|||||F\r" +
+	"OBX|6|NM|8867-4^Heart rate^LN||80|/min|||||X\r"
+
+// newTestHL7Service builds the service on a fake database; control ids it
+// already holds conflict like the primary key does.
+func newTestHL7Service(t *testing.T, query func(fake *fakeDB, sql string) *fakeRows) (*HL7Service, *fakeDB) {
+	t.Helper()
+	var fake *fakeDB
+	db, fake := newFakeDB(t, func(sql string, _ []driver.Value) *fakeRows {
+		return query(fake, sql)
+	})
+	claimed := map[driver.Value]bool{}
+	fake.conflict = func(table string, row map[string]driver.Value) bool {
+		if table != "hl7_control_ids" {
+			return false
+		}
+		if claimed[row["control_id"]] {
+			return true
+		}
+		claimed[row["control_id"]] = true
+		return false
+	}
+
+	catalog := testVitalCatalog()
+	vitals := &VitalReadingService{
+		db:         db,
+		catalog:    catalog,
+		thresholds: NewVitalThresholds(config.Vitals{}, catalog),
+		rules:      NewThresholdRuleService(db, catalog),
+	}
+	cfg := &config.Config{AppName: "vital-sync"}
+	cfg.Internal.HL7.Application = "VITALSYNC"
+	return NewHL7Service(db, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), vitals), fake
+}
+
+// hl7ReplayRows answers the lookups of a retransmission from the first
+// message logged, which holds the ACK the control id was stored with.
+func hl7ReplayRows(fake *fakeDB, sql string) *fakeRows {
+	switch {
+	case strings.Contains(sql, `FROM "hl7_control_ids"`):
+		messages := fake.insertedInto("hl7_messages")
+		if len(messages) == 0 {
+			return nil
+		}
+		return &fakeRows{
+			columns: []string{"sending_application", "sending_facility", "control_id", "message_id", "ack"},
+			values:  [][]driver.Value{{messages[0]["sending_application"], messages[0]["sending_facility"], messages[0]["control_id"], messages[0]["id"], messages[0]["ack"]}},
+		}
+	case strings.Contains(sql, `FROM "hl7_messages"`):
+		return fake.rows("hl7_messages")
+	}
+	return nil
+}
+
+func parseACK(t *testing.T, ack []byte) (*hl7.Message, *hl7.Segment) {
+	t.Helper()
+	msg, err := hl7.Parse(ack)
+	if err != nil {
+		t.Fatalf("ACK does not parse: %v\n%s", err, ack)
+	}
+	msa, ok := msg.Segment("MSA")
+	if !ok {
+		t.Fatalf("ACK has no MSA: %q", ack)
+	}
+	return msg, msa
+}
+
+func TestHL7DischargeEnrollsPatient(t *testing.T) {
+	doctorID := uuid.New()
+	s, fake := newTestHL7Service(t, func(fake *fakeDB, sql string) *fakeRows {
+		if strings.Contains(sql, `FROM "users"`) && strings.Contains(sql, "role =") {
+			return &fakeRows{columns: []string{"id", "role"}, values: [][]driver.Value{{doctorID.String(), "DOCTOR"}}}
+		}
+		return hl7ReplayRows(fake, sql)
+	})
+	discharge := []byte(strings.ReplaceAll(strings.Replace(hl7Discharge, "%s", doctorID.String(), 1), "\r", "\n"))
+
+	ack := s.Handle(discharge, enums.HL7TransportMLLP)
+	_, msa := parseACK(t, ack)
+	if msa.Field(1).Value() != "AA" || msa.Field(2).Value() != "MSG00042" {
+		t.Fatalf("ACK = %q, want AA for MSG00042", ack)
+	}
+
+	users := fake.insertedInto("users")
+	if len(users) != 1 || users[0]["phone_number"] != "+998901234567" || users[0]["first_name"] != "Aziza" || users[0]["last_name"] != "Karimova" || users[0]["role"] != "PATIENT" {
+		t.Errorf("users = %v, want the patient user from PID", users)
+	}
+	patients := fake.insertedInto("patients")
+	if len(patients) != 1 {
+		t.Fatalf("patients = %v, want one enrolled", patients)
+	}
+	patient := patients[0]
+	if patient["doctor_id"] != doctorID.String() || patient["condition_summary"] != "Heart failure, unspecified" || patient["status"] != "ACTIVE" {
+		t.Errorf("patient = %v, want the attending doctor, final diagnosis and ACTIVE", patient)
+	}
+	if notes, _ := patient["discharge_notes"].(string); notes != "Low salt diet\nWeigh daily" {
+		t.Errorf("discharge notes = %q", patient["discharge_notes"])
+	}
+	var identifiers []string
+	for _, id := range fake.insertedInto("patient_identifiers") {
+		identifiers = append(identifiers, id["system"].(string)+"|"+id["value"].(string))
+	}
+	if got := strings.Join(identifiers, ","); got != "CITYHOSP|MRN123,NATIONAL|998877" {
+		t.Errorf("identifiers = %s", got)
+	}
+
+	messages := fake.insertedInto("hl7_messages")
+	if len(messages) != 1 || messages[0]["status"] != "PROCESSED" || messages[0]["message_type"] != "ADT^A03" || messages[0]["patient_id"] != patient["id"] {
+		t.Errorf("logged messages = %v, want the processed discharge of the patient", messages)
+	}
+}
+
+func TestHL7DuplicateControlID(t *testing.T) {
+	doctorID := uuid.New()
+	s, fake := newTestHL7Service(t, func(fake *fakeDB, sql string) *fakeRows {
+		if strings.Contains(sql, `FROM "users"`) && strings.Contains(sql, "role =") {
+			return &fakeRows{columns: []string{"id", "role"}, values: [][]driver.Value{{doctorID.String(), "DOCTOR"}}}
+		}
+		return hl7ReplayRows(fake, sql)
+	})
+	discharge := strings.Replace(hl7Discharge, "%s", doctorID.String(), 1)
+
+	first := s.Handle([]byte(discharge), enums.HL7TransportMLLP)
+	if _, msa := parseACK(t, first); msa.Field(1).Value() != "AA" {
+		t.Fatalf("first ACK = %q, want AA", first)
+	}
+
+	// a retransmission, with other segment terminators, gets the same ACK
+	again := s.Handle([]byte(strings.ReplaceAll(discharge, "\r", "\r\n")), enums.HL7TransportHTTP)
+	if string(again) != string(first) {
+		t.Errorf("retransmission ACK = %q, want the original %q", again, first)
+	}
+
+	// another message under the same control id is rejected
+	changed := strings.Replace(discharge, "Heart failure, unspecified", "Pneumonia", 1)
+	ack, msa := parseACK(t, s.Handle([]byte(changed), enums.HL7TransportMLLP))
+	if msa.Field(1).Value() != "AR" || msa.Field(2).Value() != "MSG00042" {
+		t.Errorf("MSA = %s|%s, want AR for MSG00042", msa.Field(1).Value(), msa.Field(2).Value())
+	}
+	errSegment, ok := ack.Segment("ERR")
+	if !ok {
+		t.Fatal("rejection has no ERR segment")
+	}
+	if loc := errSegment.Field(2); loc.Component(1) != "MSH" || loc.Component(3) != "10" || errSegment.Field(3).Component(1) != "205" {
+		t.Errorf("ERR = %s %s, want 205 at MSH-10", loc.Component(1)+"-"+loc.Component(3), errSegment.Field(3).Component(1))
+	}
+
+	if patients := fake.insertedInto("patients"); len(patients) != 1 {
+		t.Errorf("%d patients enrolled, want the first message applied once", len(patients))
+	}
+	var statuses []string
+	for _, m := range fake.insertedInto("hl7_messages") {
+		statuses = append(statuses, m["status"].(string))
+	}
+	if got := strings.Join(statuses, ","); got != "PROCESSED,DUPLICATE,REJECTED" {
+		t.Errorf("logged statuses = %s", got)
+	}
+}
+
+func TestHL7ResultsRecordReadings(t *testing.T) {
+	patientID, userID := uuid.New(), uuid.New()
+	s, fake := newTestHL7Service(t, func(fake *fakeDB, sql string) *fakeRows {
+		switch {
+		case strings.Contains(sql, `FROM "patient_identifiers"`):
+			return &fakeRows{
+				columns: []string{"id", "patient_id", "system", "value"},
+				values:  [][]driver.Value{{uuid.NewString(), patientID.String(), "CITYHOSP", "MRN123"}},
+			}
+		case strings.Contains(sql, `FROM "patients"`):
+			return &fakeRows{
+				columns: []string{"id", "user_id", "doctor_id", "status"},
+				values:  [][]driver.Value{{patientID.String(), userID.String(), uuid.NewString(), "ACTIVE"}},
+			}
+		case strings.Contains(sql, `FROM "users"`):
+			return &fakeRows{columns: []string{"id", "role"}, values: [][]driver.Value{{userID.String(), "PATIENT"}}}
+		}
+		return hl7ReplayRows(fake, sql)
+	})
+
+	ack, msa := parseACK(t, s.Handle([]byte(hl7Results), enums.HL7TransportHTTP))
+	if msa.Field(1).Value() != "AA" || msa.Field(2).Value() != "OBS77" {
+		t.Fatalf("MSA = %s|%s, want AA for OBS77", msa.Field(1).Value(), msa.Field(2).Value())
+	}
+	// the hemoglobin and the withdrawn heart rate are skipped with warnings
+	var warnings []string
+	for _, e := range ack.All("ERR") {
+		if e.Field(4).Value() != "W" {
+			t.Errorf("ERR %s is not a warning", e.Field(8).Value())
+		}
+		warnings = append(warnings, e.Field(2).Component(1)+"("+e.Field(2).Component(2)+")-"+e.Field(2).Component(3))
+	}
+	if got := strings.Join(warnings, ","); got != "OBX(5)-3,OBX(6)-11" {
+		t.Errorf("warnings at %s, want OBX(5)-3 and OBX(6)-11", got)
+	}
+
+	readings := fake.insertedInto("vital_readings")
+	want := []struct {
+		vitalType string
+		value     float64
+	}{{"HEART_RATE", 72}, {"BLOOD_PRESSURE", 128}, {"OXYGEN_SATURATION", 97}}
+	if len(readings) != len(want) {
+		t.Fatalf("readings = %v, want %d", readings, len(want))
+	}
+	for i, w := range want {
+		r := readings[i]
+		value := r["value_numeric"]
+		if w.vitalType == "BLOOD_PRESSURE" {
+			value = r["systolic"]
+			if r["diastolic"] != 82.0 {
+				t.Errorf("diastolic = %v, want 82", r["diastolic"])
+			}
+		}
+		if r["vital_type"] != w.vitalType || value != w.value || r["source"] != "HL7" || r["patient_id"] != patientID.String() {
+			t.Errorf("reading %d = %v, want %s %v from HL7", i+1, r, w.vitalType, w.value)
+		}
+	}
+	if fake.rollbacks > 0 {
+		t.Errorf("%d transactions rolled back", fake.rollbacks)
+	}
+}
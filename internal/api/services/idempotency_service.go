@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultIdempotencyRetention = 24 * time.Hour
+
+	// idempotencyLockTimeout bounds how long an unfinished request holds its key
+	// without renewing it, so a crashed request does not block retries for the
+	// whole retention window.
+	idempotencyLockTimeout = time.Minute
+
+	// IdempotencyRenewInterval is how often a running request renews its key,
+	// so the lock holds however long the handler takes.
+	IdempotencyRenewInterval = idempotencyLockTimeout / 4
+)
+
+type IdempotencyService struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB, retention time.Duration) *IdempotencyService {
+	if retention <= 0 {
+		retention = DefaultIdempotencyRetention
+	}
+	return &IdempotencyService{db: db, retention: retention}
+}
+
+type BeginIdempotentInput struct {
+	Caller      string // who sent the request; keys of different callers never meet
+	Method      string
+	Route       string // route template the request matched
+	Key         string
+	Path        string
+	RequestHash string
+}
+
+// Begin reserves input.Key within its caller and route for a new request.
+// When the key was already used there with the same request and has a
+// stored response, that record is returned with replay=true. A key used for
+// a different request or still in flight is rejected.
+func (s *IdempotencyService) Begin(input BeginIdempotentInput) (record *models.IdempotencyKey, replay bool, err error) {
+	now := time.Now()
+	scope := s.db.Where("caller = ? AND method = ? AND route = ? AND key = ?", input.Caller, input.Method, input.Route, input.Key)
+
+	// an expired key is free to be reused
+	if err := scope.Session(&gorm.Session{}).Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record = &models.IdempotencyKey{
+		Caller:      input.Caller,
+		Method:      input.Method,
+		Route:       input.Route,
+		Key:         input.Key,
+		Path:        input.Path,
+		RequestHash: input.RequestHash,
+		ExpiresAt:   now.Add(idempotencyLockTimeout),
+	}
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "caller"}, {Name: "method"}, {Name: "route"}, {Name: "key"}},
+		DoNothing: true,
+	}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, false, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := scope.Session(&gorm.Session{}).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// released between our insert and read; let the client retry
+			return nil, false, errs.ErrIdempotencyInProgress
+		}
+		return nil, false, err
+	}
+
+	if existing.RequestHash != input.RequestHash {
+		return nil, false, errs.ErrIdempotencyKeyReused
+	}
+	if !existing.Completed() {
+		return nil, false, errs.ErrIdempotencyInProgress
+	}
+
+	return &existing, true, nil
+}
+
+// CompleteIdempotentInput is the response replayed to retries: its status,
+// body and the headers a client carries on with.
+type CompleteIdempotentInput struct {
+	StatusCode   int
+	ContentType  string
+	ETag         string
+	Location     string
+	ResponseBody []byte
+}
+
+// Complete stores the response of a reserved key for the retention window.
+func (s *IdempotencyService) Complete(id uuid.UUID, input CompleteIdempotentInput) error {
+	now := time.Now()
+	return s.db.Model(&models.IdempotencyKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status_code":   input.StatusCode,
+			"content_type":  input.ContentType,
+			"etag":          input.ETag,
+			"location":      input.Location,
+			"response_body": input.ResponseBody,
+			"completed_at":  now,
+			"expires_at":    now.Add(s.retention),
+		}).Error
+}
+
+// Renew extends the lock of a reserved key whose request is still running.
+func (s *IdempotencyService) Renew(id uuid.UUID) error {
+	return s.db.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND completed_at IS NULL", id).
+		Update("expires_at", time.Now().Add(idempotencyLockTimeout)).Error
+}
+
+// Release drops a reservation whose request failed, so a retry runs it again.
+func (s *IdempotencyService) Release(id uuid.UUID) error {
+	return s.db.Where("id = ?", id).Delete(&models.IdempotencyKey{}).Error
+}
+
+// PurgeExpired deletes keys whose retention window has passed.
+func (s *IdempotencyService) PurgeExpired(now time.Time) (int64, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
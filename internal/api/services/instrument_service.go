@@ -0,0 +1,90 @@
+package services
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InstrumentService struct {
+	db *gorm.DB
+}
+
+func NewInstrumentService(db *gorm.DB) *InstrumentService {
+	return &InstrumentService{db: db}
+}
+
+// SeedTemplates publishes version 1 of each instrument's questionnaire
+// template when it does not exist yet, so later versions and deactivations
+// survive restarts.
+func (s *InstrumentService) SeedTemplates() error {
+	instruments := builtinInstruments()
+	templates := make([]models.QuestionnaireTemplate, len(instruments))
+	for i, instrument := range instruments {
+		questions := make([]models.TemplateQuestion, len(instrument.Items))
+		for j, item := range instrument.Items {
+			questions[j] = models.TemplateQuestion{
+				Key:      item.Key,
+				Type:     enums.QuestionTypeChoice,
+				Text:     item.Text,
+				Required: true,
+				Choices:  item.Choices,
+			}
+		}
+		data, err := models.NewJSONB(questions)
+		if err != nil {
+			return err
+		}
+		description := instrument.Description
+		templates[i] = models.QuestionnaireTemplate{
+			Key:         instrument.TemplateKey(),
+			Version:     1,
+			Name:        instrument.Name,
+			Description: &description,
+			Questions:   data,
+			IsActive:    true,
+		}
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&templates).Error
+}
+
+// List returns the built-in instruments.
+func (s *InstrumentService) List() []*Instrument {
+	return builtinInstruments()
+}
+
+func (s *InstrumentService) Get(code enums.Instrument) (*Instrument, error) {
+	instrument, ok := instrumentsByCode[code]
+	if !ok {
+		return nil, errs.ErrInstrumentNotFound
+	}
+	return instrument, nil
+}
+
+var instrumentScorePageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"completed_at": {Expr: "completed_at", Field: "completed_at", Kind: pagination.KindTime},
+		"score":        {Expr: "score", Field: "score", Kind: pagination.KindNumber},
+	},
+	DefaultSort: "completed_at",
+	DateColumn:  "completed_at",
+	IDColumn:    "id",
+}
+
+// History pages a patient's instrument scores, by patient user id.
+func (s *InstrumentService) History(patientUserID uuid.UUID, instrument *enums.Instrument, page pagination.Params) (*pagination.Page[models.InstrumentScore], error) {
+	var patient models.Patient
+	if err := s.db.First(&patient, "user_id = ?", patientUserID).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&models.InstrumentScore{}).Where("patient_id = ?", patient.ID)
+	if instrument != nil {
+		query = query.Where("instrument = ?", *instrument)
+	}
+	return pagination.Paginate[models.InstrumentScore](query, page, instrumentScorePageSpec)
+}
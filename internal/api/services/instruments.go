@@ -0,0 +1,516 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Instrument is a built-in patient-reported outcome instrument. It is asked
+// through its questionnaire template, whose question keys are the item
+// keys; any checkin question with those keys counts, so the items can also
+// be part of a clinic's own template. Choice values are the item scores.
+type Instrument struct {
+	Code        enums.Instrument
+	Name        string
+	Description string
+	Items       []InstrumentItem
+
+	Min, Max      float64
+	HigherIsWorse bool
+	// MinimalChange is the smallest change that matters clinically; a
+	// worsening by at least this much since the previous score raises an alert.
+	MinimalChange float64
+	Bands         []InstrumentBand
+
+	// score returns the score and any domain scores, or false when the
+	// answered items are not enough to score.
+	score func(values map[string]float64) (float64, map[string]float64, bool)
+	// findings flags items that need attention whatever the total.
+	findings func(values map[string]float64) []instrumentFinding
+}
+
+type InstrumentItem struct {
+	Key     string
+	Text    string
+	Choices []models.QuestionChoice
+}
+
+// InstrumentBand is a score band starting at From; bands are listed from
+// the lowest score up. Alert, when set, is the severity of the alert a
+// score in the band raises.
+type InstrumentBand struct {
+	From  float64
+	Band  enums.InstrumentBand
+	Alert enums.AlertSeverity
+}
+
+type instrumentFinding struct {
+	rule     string
+	severity enums.AlertSeverity
+	message  string
+}
+
+// TemplateKey is the key of the instrument's built-in questionnaire template.
+func (i *Instrument) TemplateKey() string {
+	return strings.ToLower(string(i.Code))
+}
+
+func (i *Instrument) band(score float64) InstrumentBand {
+	band := i.Bands[0]
+	for _, b := range i.Bands {
+		if score >= b.From {
+			band = b
+		}
+	}
+	return band
+}
+
+// worsening is how much worse score is than previous, negative when better.
+func (i *Instrument) worsening(previous, score float64) float64 {
+	if i.HigherIsWorse {
+		return score - previous
+	}
+	return previous - score
+}
+
+func (i *Instrument) itemKeys() []string {
+	keys := make([]string, len(i.Items))
+	for j, item := range i.Items {
+		keys[j] = item.Key
+	}
+	return keys
+}
+
+var (
+	frequencyChoices = []models.QuestionChoice{
+		{Value: "0", Label: "Not at all"},
+		{Value: "1", Label: "Several days"},
+		{Value: "2", Label: "More than half the days"},
+		{Value: "3", Label: "Nearly every day"},
+	}
+	catChoices = []models.QuestionChoice{
+		{Value: "0", Label: "0 (best)"}, {Value: "1", Label: "1"}, {Value: "2", Label: "2"},
+		{Value: "3", Label: "3"}, {Value: "4", Label: "4"}, {Value: "5", Label: "5 (worst)"},
+	}
+	kccqLimitChoices = []models.QuestionChoice{
+		{Value: "1", Label: "Extremely limited"},
+		{Value: "2", Label: "Quite a bit limited"},
+		{Value: "3", Label: "Moderately limited"},
+		{Value: "4", Label: "Slightly limited"},
+		{Value: "5", Label: "Not at all limited"},
+		{Value: "6", Label: "Limited for other reasons or did not do the activity"},
+	}
+	kccqWeeklyChoices = []models.QuestionChoice{
+		{Value: "1", Label: "Every morning or night"},
+		{Value: "2", Label: "3 or more times a week, but not every day"},
+		{Value: "3", Label: "1-2 times a week"},
+		{Value: "4", Label: "Less than once a week"},
+		{Value: "5", Label: "Never over the past 2 weeks"},
+	}
+	kccqDailyChoices = []models.QuestionChoice{
+		{Value: "1", Label: "All of the time"},
+		{Value: "2", Label: "Several times a day"},
+		{Value: "3", Label: "At least once a day"},
+		{Value: "4", Label: "3 or more times a week, but not every day"},
+		{Value: "5", Label: "1-2 times a week"},
+		{Value: "6", Label: "Less than once a week"},
+		{Value: "7", Label: "Never over the past 2 weeks"},
+	}
+	kccqEnjoymentChoices = []models.QuestionChoice{
+		{Value: "1", Label: "It has extremely limited my enjoyment of life"},
+		{Value: "2", Label: "It has limited my enjoyment of life quite a bit"},
+		{Value: "3", Label: "It has moderately limited my enjoyment of life"},
+		{Value: "4", Label: "It has slightly limited my enjoyment of life"},
+		{Value: "5", Label: "It has not limited my enjoyment of life at all"},
+	}
+	kccqSatisfactionChoices = []models.QuestionChoice{
+		{Value: "1", Label: "Not at all satisfied"},
+		{Value: "2", Label: "Mostly dissatisfied"},
+		{Value: "3", Label: "Somewhat satisfied"},
+		{Value: "4", Label: "Mostly satisfied"},
+		{Value: "5", Label: "Completely satisfied"},
+	}
+	kccqSocialChoices = []models.QuestionChoice{
+		{Value: "1", Label: "Severely limited"},
+		{Value: "2", Label: "Limited quite a bit"},
+		{Value: "3", Label: "Moderately limited"},
+		{Value: "4", Label: "Slightly limited"},
+		{Value: "5", Label: "Did not limit at all"},
+		{Value: "6", Label: "Does not apply or did not do for other reasons"},
+	}
+)
+
+// builtinInstruments lists the supported instruments. PHQ-9 and GAD-7 carry
+// their published wording. KCCQ-12 and CAT are licensed: their items here
+// name what each asks, and a clinic holding the licence publishes a new
+// version of the template with the licensed wording and the same keys.
+func builtinInstruments() []*Instrument {
+	return []*Instrument{
+		{
+			Code:        enums.InstrumentPHQ9,
+			Name:        "Patient Health Questionnaire-9",
+			Description: "Over the last 2 weeks, how often have you been bothered by any of the following problems?",
+			Items: instrumentItems("phq9", frequencyChoices,
+				"Little interest or pleasure in doing things",
+				"Feeling down, depressed, or hopeless",
+				"Trouble falling or staying asleep, or sleeping too much",
+				"Feeling tired or having little energy",
+				"Poor appetite or overeating",
+				"Feeling bad about yourself, or that you are a failure or have let yourself or your family down",
+				"Trouble concentrating on things, such as reading the newspaper or watching television",
+				"Moving or speaking so slowly that other people could have noticed, or the opposite, being so fidgety or restless that you have been moving around a lot more than usual",
+				"Thoughts that you would be better off dead, or of hurting yourself in some way",
+			),
+			Min: 0, Max: 27, HigherIsWorse: true, MinimalChange: 5,
+			Bands: []InstrumentBand{
+				{0, enums.InstrumentBandMinimal, ""},
+				{5, enums.InstrumentBandMild, ""},
+				{10, enums.InstrumentBandModerate, enums.AlertSeverityMedium},
+				{15, enums.InstrumentBandModeratelySevere, enums.AlertSeverityHigh},
+				{20, enums.InstrumentBandSevere, enums.AlertSeverityHigh},
+			},
+			score: sumScore("phq9", 9),
+			findings: func(values map[string]float64) []instrumentFinding {
+				if values["phq9_9"] > 0 {
+					return []instrumentFinding{{
+						rule:     "phq9_item9",
+						severity: enums.AlertSeverityCritical,
+						message:  "PHQ-9 item 9 positive: thoughts of being better off dead or of self-harm",
+					}}
+				}
+				return nil
+			},
+		},
+		{
+			Code:        enums.InstrumentGAD7,
+			Name:        "Generalized Anxiety Disorder-7",
+			Description: "Over the last 2 weeks, how often have you been bothered by the following problems?",
+			Items: instrumentItems("gad7", frequencyChoices,
+				"Feeling nervous, anxious, or on edge",
+				"Not being able to stop or control worrying",
+				"Worrying too much about different things",
+				"Trouble relaxing",
+				"Being so restless that it is hard to sit still",
+				"Becoming easily annoyed or irritable",
+				"Feeling afraid, as if something awful might happen",
+			),
+			Min: 0, Max: 21, HigherIsWorse: true, MinimalChange: 4,
+			Bands: []InstrumentBand{
+				{0, enums.InstrumentBandMinimal, ""},
+				{5, enums.InstrumentBandMild, ""},
+				{10, enums.InstrumentBandModerate, enums.AlertSeverityMedium},
+				{15, enums.InstrumentBandSevere, enums.AlertSeverityHigh},
+			},
+			score: sumScore("gad7", 7),
+		},
+		{
+			Code:        enums.InstrumentKCCQ12,
+			Name:        "Kansas City Cardiomyopathy Questionnaire-12",
+			Description: "Heart failure symptoms, physical and social limitation and quality of life over the past 2 weeks; 0 is worst and 100 best.",
+			Items: []InstrumentItem{
+				{Key: "kccq12_1a", Text: "Limitation showering or bathing because of heart failure", Choices: kccqLimitChoices},
+				{Key: "kccq12_1b", Text: "Limitation walking 1 block on level ground because of heart failure", Choices: kccqLimitChoices},
+				{Key: "kccq12_1c", Text: "Limitation hurrying or jogging because of heart failure", Choices: kccqLimitChoices},
+				{Key: "kccq12_2", Text: "How often you had swelling in your feet, ankles or legs when you woke up", Choices: kccqWeeklyChoices},
+				{Key: "kccq12_3", Text: "How often fatigue limited your ability to do what you wanted", Choices: kccqDailyChoices},
+				{Key: "kccq12_4", Text: "How often shortness of breath limited your ability to do what you wanted", Choices: kccqDailyChoices},
+				{Key: "kccq12_5", Text: "How often you were forced to sleep sitting up in a chair or with pillows because of shortness of breath", Choices: kccqWeeklyChoices},
+				{Key: "kccq12_6", Text: "How much heart failure has limited your enjoyment of life", Choices: kccqEnjoymentChoices},
+				{Key: "kccq12_7", Text: "How you would feel about spending the rest of your life with your heart failure the way it is right now", Choices: kccqSatisfactionChoices},
+				{Key: "kccq12_8a", Text: "How much heart failure limited hobbies and recreational activities", Choices: kccqSocialChoices},
+				{Key: "kccq12_8b", Text: "How much heart failure limited working or doing household chores", Choices: kccqSocialChoices},
+				{Key: "kccq12_8c", Text: "How much heart failure limited visiting family or friends out of your home", Choices: kccqSocialChoices},
+			},
+			Min: 0, Max: 100, HigherIsWorse: false, MinimalChange: 5,
+			Bands: []InstrumentBand{
+				{0, enums.InstrumentBandVeryPoor, enums.AlertSeverityHigh},
+				{25, enums.InstrumentBandPoor, enums.AlertSeverityMedium},
+				{50, enums.InstrumentBandFair, ""},
+				{75, enums.InstrumentBandGood, ""},
+			},
+			score: scoreKCCQ12,
+		},
+		{
+			Code:        enums.InstrumentCAT,
+			Name:        "COPD Assessment Test",
+			Description: "Impact of COPD on wellbeing and daily life, each item from 0 (best) to 5 (worst).",
+			Items: instrumentItems("cat", catChoices,
+				"Cough",
+				"Phlegm (mucus) in the chest",
+				"Chest tightness",
+				"Breathlessness walking up a hill or one flight of stairs",
+				"Limitation doing activities at home",
+				"Confidence leaving home despite the lung condition",
+				"Sleep",
+				"Energy",
+			),
+			Min: 0, Max: 40, HigherIsWorse: true, MinimalChange: 2,
+			Bands: []InstrumentBand{
+				{0, enums.InstrumentBandLow, ""},
+				{10, enums.InstrumentBandMedium, ""},
+				{21, enums.InstrumentBandHigh, enums.AlertSeverityMedium},
+				{31, enums.InstrumentBandVeryHigh, enums.AlertSeverityHigh},
+			},
+			score: sumScore("cat", 8),
+		},
+	}
+}
+
+func instrumentItems(prefix string, choices []models.QuestionChoice, texts ...string) []InstrumentItem {
+	items := make([]InstrumentItem, len(texts))
+	for i, text := range texts {
+		items[i] = InstrumentItem{Key: fmt.Sprintf("%s_%d", prefix, i+1), Text: text, Choices: choices}
+	}
+	return items
+}
+
+// sumScore adds up items prefix_1 to prefix_n, all of which must be answered.
+func sumScore(prefix string, n int) func(map[string]float64) (float64, map[string]float64, bool) {
+	return func(values map[string]float64) (float64, map[string]float64, bool) {
+		total := 0.0
+		for i := 1; i <= n; i++ {
+			v, ok := values[fmt.Sprintf("%s_%d", prefix, i)]
+			if !ok {
+				return 0, nil, false
+			}
+			total += v
+		}
+		return total, nil, true
+	}
+}
+
+// kccqDomains lists the KCCQ-12 domains with the highest answer of each of
+// their items; answers above it mean the item does not apply. A domain is
+// scored when at least minItems of its items apply.
+var kccqDomains = []struct {
+	name     string
+	items    map[string]float64
+	minItems int
+}{
+	{"physical_limitation", map[string]float64{"kccq12_1a": 5, "kccq12_1b": 5, "kccq12_1c": 5}, 2},
+	{"symptom_frequency", map[string]float64{"kccq12_2": 5, "kccq12_3": 7, "kccq12_4": 7, "kccq12_5": 5}, 2},
+	{"quality_of_life", map[string]float64{"kccq12_6": 5, "kccq12_7": 5}, 1},
+	{"social_limitation", map[string]float64{"kccq12_8a": 5, "kccq12_8b": 5, "kccq12_8c": 5}, 2},
+}
+
+// scoreKCCQ12 scores each domain as the mean of its items rescaled to 0-100,
+// and the summary as the mean of the domains that could be scored. It waits
+// for every item to be answered, including with "does not apply".
+func scoreKCCQ12(values map[string]float64) (float64, map[string]float64, bool) {
+	domains := map[string]float64{}
+	total := 0.0
+	for _, d := range kccqDomains {
+		sum, n := 0.0, 0
+		for key, max := range d.items {
+			v, ok := values[key]
+			if !ok {
+				return 0, nil, false
+			}
+			if v < 1 || v > max {
+				continue
+			}
+			sum += (v - 1) / (max - 1) * 100
+			n++
+		}
+		if n < d.minItems {
+			continue
+		}
+		domains[d.name] = round2(sum / float64(n))
+		total += domains[d.name]
+	}
+	if len(domains) == 0 {
+		return 0, nil, false
+	}
+	return round2(total / float64(len(domains))), domains, true
+}
+
+var instrumentsByCode = func() map[enums.Instrument]*Instrument {
+	byCode := map[enums.Instrument]*Instrument{}
+	for _, i := range builtinInstruments() {
+		byCode[i.Code] = i
+	}
+	return byCode
+}()
+
+// scoreCheckinInstruments scores the instruments whose items the checkin's
+// answers complete, keeps the scores with the checkin and raises
+// INSTRUMENT_SCORE alerts for scores past a cutoff, flagged items and
+// clinically relevant worsening. A replaced answer rescores the instrument;
+// alerts already raised for the checkin are not repeated. db may be a
+// transaction.
+func scoreCheckinInstruments(db *gorm.DB, checkin *models.Checkin) error {
+	questions, err := checkin.ParsedQuestions()
+	if err != nil {
+		return err
+	}
+	answers, err := checkin.ParsedAnswers()
+	if err != nil {
+		return err
+	}
+	keyOf := map[uuid.UUID]string{}
+	for _, q := range questions {
+		if q.Key != "" {
+			keyOf[q.ID] = q.Key
+		}
+	}
+	values := map[string]float64{}
+	answeredAt := map[string]time.Time{}
+	for _, a := range answers {
+		key, ok := keyOf[a.QuestionID]
+		if !ok {
+			continue
+		}
+		if v, ok := itemValue(a.Value); ok {
+			values[key] = v
+			answeredAt[key] = a.AnsweredAt
+		}
+	}
+
+	for _, instrument := range builtinInstruments() {
+		score, subscores, ok := instrument.score(values)
+		if !ok {
+			continue
+		}
+		items := map[string]float64{}
+		var completedAt time.Time
+		for _, key := range instrument.itemKeys() {
+			if v, ok := values[key]; ok {
+				items[key] = v
+				if answeredAt[key].After(completedAt) {
+					completedAt = answeredAt[key]
+				}
+			}
+		}
+		if err := saveInstrumentScore(db, checkin, instrument, score, subscores, items, completedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveInstrumentScore(db *gorm.DB, checkin *models.Checkin, instrument *Instrument, score float64, subscores, items map[string]float64, completedAt time.Time) error {
+	band := instrument.band(score)
+	itemsJSON, err := models.NewJSONB(items)
+	if err != nil {
+		return err
+	}
+	var subscoresJSON models.JSONB
+	if len(subscores) > 0 {
+		if subscoresJSON, err = models.NewJSONB(subscores); err != nil {
+			return err
+		}
+	}
+
+	result := models.InstrumentScore{
+		PatientID:   checkin.PatientID,
+		CheckinID:   checkin.ID,
+		Instrument:  instrument.Code,
+		Score:       score,
+		Band:        band.Band,
+		Subscores:   subscoresJSON,
+		Items:       itemsJSON,
+		CompletedAt: completedAt,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "checkin_id"}, {Name: "instrument"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"score": score, "band": band.Band, "subscores": subscoresJSON, "items": itemsJSON, "completed_at": completedAt, "updated_at": time.Now()}),
+	}).Create(&result).Error; err != nil {
+		return err
+	}
+
+	var findings []instrumentFinding
+	if band.Alert != "" {
+		findings = append(findings, instrumentFinding{
+			rule:     "band",
+			severity: band.Alert,
+			message:  fmt.Sprintf("%s score %s (%s)", instrument.Code, formatValue(score), instrumentBandLabel(band.Band)),
+		})
+	}
+	if instrument.findings != nil {
+		findings = append(findings, instrument.findings(items)...)
+	}
+
+	var previous models.InstrumentScore
+	err = db.Where("patient_id = ? AND instrument = ? AND checkin_id <> ? AND completed_at < ?", checkin.PatientID, instrument.Code, checkin.ID, completedAt).
+		Order("completed_at DESC").First(&previous).Error
+	switch {
+	case err == nil:
+		if change := instrument.worsening(previous.Score, score); change >= instrument.MinimalChange {
+			findings = append(findings, instrumentFinding{
+				rule:     "worsened",
+				severity: enums.AlertSeverityMedium,
+				message:  fmt.Sprintf("%s score worsened by %s since %s, from %s to %s", instrument.Code, formatValue(change), previous.CompletedAt.Format("2006-01-02"), formatValue(previous.Score), formatValue(score)),
+			})
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	for _, f := range findings {
+		if err := raiseInstrumentAlert(db, checkin, instrument, &result, score, band.Band, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raiseInstrumentAlert raises the finding's alert unless one at least as
+// severe was already raised for the same instrument and rule in the checkin.
+func raiseInstrumentAlert(db *gorm.DB, checkin *models.Checkin, instrument *Instrument, result *models.InstrumentScore, score float64, band enums.InstrumentBand, f instrumentFinding) error {
+	var raised []enums.AlertSeverity
+	if err := db.Model(&models.Alert{}).
+		Where("checkin_id = ? AND alert_type = ? AND details->>'instrument' = ? AND details->>'rule' = ?",
+			checkin.ID, enums.AlertTypeInstrumentScore, instrument.Code, f.rule).
+		Pluck("severity", &raised).Error; err != nil {
+		return err
+	}
+	for _, s := range raised {
+		if severityRank(s) >= severityRank(f.severity) {
+			return nil
+		}
+	}
+
+	details, _ := models.NewJSONB(map[string]interface{}{
+		"instrument":          instrument.Code,
+		"instrument_score_id": result.ID,
+		"rule":                f.rule,
+		"score":               score,
+		"band":                band,
+	})
+	checkinID := checkin.ID
+	return db.Create(&models.Alert{
+		PatientID: checkin.PatientID,
+		CheckinID: &checkinID,
+		Severity:  f.severity,
+		AlertType: enums.AlertTypeInstrumentScore,
+		Title:     fmt.Sprintf("%s %s", instrument.Name, instrumentBandLabel(band)),
+		Message:   f.message,
+		Details:   details,
+	}).Error
+}
+
+func instrumentBandLabel(band enums.InstrumentBand) string {
+	return strings.ToLower(strings.ReplaceAll(string(band), "_", " "))
+}
+
+// itemValue reads an item score from an answer: a number, or a choice value
+// holding one.
+func itemValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
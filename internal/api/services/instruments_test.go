@@ -0,0 +1,226 @@
+package services
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestInstrumentBandEdges(t *testing.T) {
+	tests := []struct {
+		instrument enums.Instrument
+		score      float64
+		band       enums.InstrumentBand
+		alert      enums.AlertSeverity
+	}{
+		{enums.InstrumentPHQ9, 0, enums.InstrumentBandMinimal, ""},
+		{enums.InstrumentPHQ9, 4, enums.InstrumentBandMinimal, ""},
+		{enums.InstrumentPHQ9, 5, enums.InstrumentBandMild, ""},
+		{enums.InstrumentPHQ9, 9, enums.InstrumentBandMild, ""},
+		{enums.InstrumentPHQ9, 10, enums.InstrumentBandModerate, enums.AlertSeverityMedium},
+		{enums.InstrumentPHQ9, 14, enums.InstrumentBandModerate, enums.AlertSeverityMedium},
+		{enums.InstrumentPHQ9, 15, enums.InstrumentBandModeratelySevere, enums.AlertSeverityHigh},
+		{enums.InstrumentPHQ9, 19, enums.InstrumentBandModeratelySevere, enums.AlertSeverityHigh},
+		{enums.InstrumentPHQ9, 20, enums.InstrumentBandSevere, enums.AlertSeverityHigh},
+		{enums.InstrumentPHQ9, 27, enums.InstrumentBandSevere, enums.AlertSeverityHigh},
+
+		{enums.InstrumentGAD7, 4, enums.InstrumentBandMinimal, ""},
+		{enums.InstrumentGAD7, 5, enums.InstrumentBandMild, ""},
+		{enums.InstrumentGAD7, 9, enums.InstrumentBandMild, ""},
+		{enums.InstrumentGAD7, 10, enums.InstrumentBandModerate, enums.AlertSeverityMedium},
+		{enums.InstrumentGAD7, 14, enums.InstrumentBandModerate, enums.AlertSeverityMedium},
+		{enums.InstrumentGAD7, 15, enums.InstrumentBandSevere, enums.AlertSeverityHigh},
+		{enums.InstrumentGAD7, 21, enums.InstrumentBandSevere, enums.AlertSeverityHigh},
+
+		{enums.InstrumentKCCQ12, 0, enums.InstrumentBandVeryPoor, enums.AlertSeverityHigh},
+		{enums.InstrumentKCCQ12, 24.99, enums.InstrumentBandVeryPoor, enums.AlertSeverityHigh},
+		{enums.InstrumentKCCQ12, 25, enums.InstrumentBandPoor, enums.AlertSeverityMedium},
+		{enums.InstrumentKCCQ12, 49.99, enums.InstrumentBandPoor, enums.AlertSeverityMedium},
+		{enums.InstrumentKCCQ12, 50, enums.InstrumentBandFair, ""},
+		{enums.InstrumentKCCQ12, 74.99, enums.InstrumentBandFair, ""},
+		{enums.InstrumentKCCQ12, 75, enums.InstrumentBandGood, ""},
+		{enums.InstrumentKCCQ12, 100, enums.InstrumentBandGood, ""},
+
+		{enums.InstrumentCAT, 9, enums.InstrumentBandLow, ""},
+		{enums.InstrumentCAT, 10, enums.InstrumentBandMedium, ""},
+		{enums.InstrumentCAT, 20, enums.InstrumentBandMedium, ""},
+		{enums.InstrumentCAT, 21, enums.InstrumentBandHigh, enums.AlertSeverityMedium},
+		{enums.InstrumentCAT, 30, enums.InstrumentBandHigh, enums.AlertSeverityMedium},
+		{enums.InstrumentCAT, 31, enums.InstrumentBandVeryHigh, enums.AlertSeverityHigh},
+		{enums.InstrumentCAT, 40, enums.InstrumentBandVeryHigh, enums.AlertSeverityHigh},
+	}
+	for _, tt := range tests {
+		band := instrumentsByCode[tt.instrument].band(tt.score)
+		if band.Band != tt.band || band.Alert != tt.alert {
+			t.Errorf("%s %v is %s alerting %q, want %s alerting %q", tt.instrument, tt.score, band.Band, band.Alert, tt.band, tt.alert)
+		}
+	}
+}
+
+// instrumentValues answers items prefix_1 to prefix_n with value.
+func instrumentValues(prefix string, n int, value float64) map[string]float64 {
+	values := map[string]float64{}
+	for i := 1; i <= n; i++ {
+		values[fmt.Sprintf("%s_%d", prefix, i)] = value
+	}
+	return values
+}
+
+func TestSumScore(t *testing.T) {
+	values := instrumentValues("phq9", 9, 2)
+	values["phq9_9"] = 3
+	score, subscores, ok := instrumentsByCode[enums.InstrumentPHQ9].score(values)
+	if !ok || score != 19 || subscores != nil {
+		t.Errorf("score = %v, %v, %t; want 19 without subscores", score, subscores, ok)
+	}
+
+	delete(values, "phq9_5")
+	if _, _, ok := instrumentsByCode[enums.InstrumentPHQ9].score(values); ok {
+		t.Error("scored PHQ-9 with item 5 unanswered")
+	}
+
+	if score, _, ok := instrumentsByCode[enums.InstrumentCAT].score(instrumentValues("cat", 8, 5)); !ok || score != 40 {
+		t.Errorf("CAT score = %v, %t; want 40", score, ok)
+	}
+}
+
+func TestScoreKCCQ12(t *testing.T) {
+	answers := func(values map[string]float64) map[string]float64 {
+		all := map[string]float64{}
+		for _, d := range kccqDomains {
+			for key := range d.items {
+				all[key] = 1
+			}
+		}
+		for key, v := range values {
+			all[key] = v
+		}
+		return all
+	}
+	// every domain at the midpoint of its scale
+	middle := map[string]float64{
+		"kccq12_1a": 3, "kccq12_1b": 3, "kccq12_1c": 3,
+		"kccq12_2": 3, "kccq12_3": 4, "kccq12_4": 4, "kccq12_5": 3,
+		"kccq12_6": 3, "kccq12_7": 3,
+		"kccq12_8a": 3, "kccq12_8b": 3, "kccq12_8c": 3,
+	}
+	best := map[string]float64{}
+	for _, d := range kccqDomains {
+		for key, max := range d.items {
+			best[key] = max
+		}
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]float64
+		score   float64
+		domains map[string]float64
+	}{
+		{"worst", answers(nil), 0, map[string]float64{"physical_limitation": 0, "symptom_frequency": 0, "quality_of_life": 0, "social_limitation": 0}},
+		{"best", best, 100, map[string]float64{"physical_limitation": 100, "symptom_frequency": 100, "quality_of_life": 100, "social_limitation": 100}},
+		{"middle", middle, 50, map[string]float64{"physical_limitation": 50, "symptom_frequency": 50, "quality_of_life": 50, "social_limitation": 50}},
+		{
+			"does not apply leaves the domain on the items that apply",
+			answers(map[string]float64{"kccq12_1a": 6, "kccq12_1b": 5, "kccq12_1c": 3}),
+			18.75,
+			map[string]float64{"physical_limitation": 75, "symptom_frequency": 0, "quality_of_life": 0, "social_limitation": 0},
+		},
+		{
+			"a domain below its minimum items is left out of the summary",
+			answers(map[string]float64{"kccq12_8a": 6, "kccq12_8b": 6, "kccq12_8c": 5, "kccq12_6": 5, "kccq12_7": 5}),
+			33.33,
+			map[string]float64{"physical_limitation": 0, "symptom_frequency": 0, "quality_of_life": 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, domains, ok := scoreKCCQ12(tt.values)
+			if !ok || score != tt.score {
+				t.Fatalf("score = %v, %t; want %v", score, ok, tt.score)
+			}
+			if len(domains) != len(tt.domains) {
+				t.Fatalf("domains = %v, want %v", domains, tt.domains)
+			}
+			for name, want := range tt.domains {
+				if got, ok := domains[name]; !ok || got != want {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("waits for every item", func(t *testing.T) {
+		values := answers(nil)
+		delete(values, "kccq12_8c")
+		if _, _, ok := scoreKCCQ12(values); ok {
+			t.Error("scored KCCQ-12 with item 8c unanswered")
+		}
+	})
+
+	t.Run("no domain applies", func(t *testing.T) {
+		values := answers(nil)
+		for key := range values {
+			values[key] = 8
+		}
+		if score, domains, ok := scoreKCCQ12(values); ok {
+			t.Errorf("score = %v with domains %v, want none when nothing applies", score, domains)
+		}
+	})
+}
+
+func TestSaveInstrumentScorePHQ9Item9(t *testing.T) {
+	phq9 := instrumentsByCode[enums.InstrumentPHQ9]
+	tests := []struct {
+		name   string
+		item9  float64
+		score  float64
+		raised []string
+		want   []string
+	}{
+		{"positive item 9 with a minimal score", 1, 1, nil, []string{"CRITICAL"}},
+		{"positive item 9 with a severe score", 3, 22, nil, []string{"HIGH", "CRITICAL"}},
+		{"negative item 9", 0, 0, nil, nil},
+		{"already raised for the checkin", 2, 2, []string{"CRITICAL"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, func(query string, _ []driver.Value) *fakeRows {
+				if strings.Contains(query, `FROM "alerts"`) && strings.Contains(query, "details->>'rule' =") {
+					rows := &fakeRows{columns: []string{"severity"}}
+					for _, s := range tt.raised {
+						rows.values = append(rows.values, []driver.Value{s})
+					}
+					return rows
+				}
+				return nil
+			})
+			checkin := &models.Checkin{ID: uuid.New(), PatientID: uuid.New()}
+			items := instrumentValues("phq9", 8, 0)
+			items["phq9_9"] = tt.item9
+			if err := saveInstrumentScore(db, checkin, phq9, tt.score, nil, items, time.Now()); err != nil {
+				t.Fatalf("saveInstrumentScore: %v", err)
+			}
+
+			if scores := fake.insertedInto("instrument_scores"); len(scores) != 1 {
+				t.Errorf("instrument scores = %v, want one", scores)
+			}
+			var severities []string
+			for _, alert := range fake.insertedInto("alerts") {
+				if alert["alert_type"] != string(enums.AlertTypeInstrumentScore) || alert["checkin_id"] != checkin.ID.String() {
+					t.Errorf("alert = %v, want an INSTRUMENT_SCORE alert of the checkin", alert)
+				}
+				severities = append(severities, fmt.Sprint(alert["severity"]))
+			}
+			if strings.Join(severities, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("alert severities = %v, want %v", severities, tt.want)
+			}
+		})
+	}
+}
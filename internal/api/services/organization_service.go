@@ -2,6 +2,7 @@ package services
 
 import (
 	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -26,16 +27,22 @@ func (s *OrganizationService) GetByID(id uuid.UUID) (*models.Organization, error
 	return &organization, nil
 }
 
-func (s *OrganizationService) List(includeInactive bool) ([]models.Organization, error) {
-	var organizations []models.Organization
-	query := s.db
+var organizationPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+		"name":       {Expr: "name", Field: "name", Kind: pagination.KindString},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "created_at",
+	IDColumn:    "id",
+}
+
+func (s *OrganizationService) List(includeInactive bool, page pagination.Params) (*pagination.Page[models.Organization], error) {
+	query := s.db.Model(&models.Organization{})
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
 	}
-	if err := query.Order("created_at DESC").Find(&organizations).Error; err != nil {
-		return nil, err
-	}
-	return organizations, nil
+	return pagination.Paginate[models.Organization](query, page, organizationPageSpec)
 }
 
 type OrganizationUpdate struct {
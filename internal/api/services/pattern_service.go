@@ -0,0 +1,458 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPatternInterval = time.Hour
+
+	defaultPatternLookbackDays = 14
+	defaultWeightGain3DaysKg   = 2.0
+	defaultWeightGain7DaysKg   = 2.5
+	defaultRiskScoreIncrease   = 15
+	defaultTrendChangePercent  = 10.0
+	trendMinReadings           = 4
+	worseningMinCheckins       = 3
+	highRiskScore              = 70
+)
+
+// Pattern keys identify a detected pattern in Alert.Details.pattern_key.
+const (
+	PatternTrendUp         = "TREND_UP"
+	PatternTrendDown       = "TREND_DOWN"
+	PatternRapidWeightGain = "RAPID_WEIGHT_GAIN"
+	PatternStatusWorsening = "MEDICAL_STATUS_WORSENING"
+	PatternRiskScoreRising = "RISK_SCORE_RISING"
+)
+
+var medicalStatusRank = map[enums.MedicalStatus]int{
+	enums.MedicalStatusNormal:   0,
+	enums.MedicalStatusConcern:  1,
+	enums.MedicalStatusUrgent:   2,
+	enums.MedicalStatusCritical: 3,
+}
+
+// PatternService looks for multi-reading and multi-checkin patterns that no
+// single threshold catches, and raises PATTERN_DETECTED alerts for them.
+type PatternService struct {
+	db       *gorm.DB
+	catalog  *VitalCatalog
+	settings config.Patterns
+}
+
+func NewPatternService(db *gorm.DB, cfg *config.Config, catalog *VitalCatalog) *PatternService {
+	settings := cfg.Internal.Patterns
+	if settings.LookbackDays <= 0 {
+		settings.LookbackDays = defaultPatternLookbackDays
+	}
+	if settings.WeightGain3DaysKg <= 0 {
+		settings.WeightGain3DaysKg = defaultWeightGain3DaysKg
+	}
+	if settings.WeightGain7DaysKg <= 0 {
+		settings.WeightGain7DaysKg = defaultWeightGain7DaysKg
+	}
+	if settings.RiskScoreIncrease <= 0 {
+		settings.RiskScoreIncrease = defaultRiskScoreIncrease
+	}
+	if settings.TrendChangePercent <= 0 {
+		settings.TrendChangePercent = defaultTrendChangePercent
+	}
+	return &PatternService{db: db, catalog: catalog, settings: settings}
+}
+
+// Interval is how often the detector should scan.
+func (s *PatternService) Interval() time.Duration {
+	if s.settings.IntervalMinutes <= 0 {
+		return DefaultPatternInterval
+	}
+	return time.Duration(s.settings.IntervalMinutes) * time.Minute
+}
+
+// PatternEvidence is one data point supporting a detected pattern.
+type PatternEvidence struct {
+	VitalReadingID *uuid.UUID           `json:"vital_reading_id,omitempty"`
+	CheckinID      *uuid.UUID           `json:"checkin_id,omitempty"`
+	Value          *float64             `json:"value,omitempty"`
+	MedicalStatus  *enums.MedicalStatus `json:"medical_status,omitempty"`
+	RiskScore      *int                 `json:"risk_score,omitempty"`
+	At             time.Time            `json:"at"`
+}
+
+type detectedPattern struct {
+	key       string
+	vitalType enums.VitalType
+	severity  enums.AlertSeverity
+	title     string
+	message   string
+	checkinID *uuid.UUID
+	// anchor is the newest evidence; a pattern alerts once per anchor.
+	anchor   uuid.UUID
+	evidence []PatternEvidence
+	summary  map[string]interface{}
+}
+
+// Scan checks every monitored patient and returns the number of alerts raised.
+// A failure on one patient does not stop the others; the first error is returned.
+func (s *PatternService) Scan(now time.Time) (int, error) {
+	var patients []models.Patient
+	if err := s.db.Where("status IN ?", []enums.PatientStatus{enums.PatientStatusActive, enums.PatientStatusCritical}).
+		Find(&patients).Error; err != nil {
+		return 0, err
+	}
+
+	raised := 0
+	var firstErr error
+	for i := range patients {
+		alerts, err := s.DetectPatient(&patients[i], now)
+		raised += len(alerts)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("patient %s: %w", patients[i].ID, err)
+		}
+	}
+	return raised, firstErr
+}
+
+// DetectPatient runs every detector for one patient and stores alerts for new
+// patterns. A pattern is not raised again while an earlier alert for it is
+// unacknowledged, nor twice for the same newest evidence.
+func (s *PatternService) DetectPatient(patient *models.Patient, now time.Time) ([]models.Alert, error) {
+	since := now.AddDate(0, 0, -s.settings.LookbackDays)
+
+	var readings []models.VitalReading
+	if err := s.db.Where("patient_id = ? AND value_numeric IS NOT NULL AND measured_at >= ? AND measured_at <= ?", patient.ID, since, now).
+		Order("measured_at ASC").
+		Find(&readings).Error; err != nil {
+		return nil, err
+	}
+
+	var checkins []models.Checkin
+	if err := s.db.Where("patient_id = ? AND initiated_at >= ? AND initiated_at <= ? AND (medical_status IS NOT NULL OR risk_score IS NOT NULL)", patient.ID, since, now).
+		Order("initiated_at ASC").
+		Find(&checkins).Error; err != nil {
+		return nil, err
+	}
+
+	byType := map[enums.VitalType][]models.VitalReading{}
+	var types []enums.VitalType
+	for _, r := range readings {
+		if _, ok := byType[r.VitalType]; !ok {
+			types = append(types, r.VitalType)
+		}
+		byType[r.VitalType] = append(byType[r.VitalType], r)
+	}
+
+	var patterns []detectedPattern
+	for _, vt := range types {
+		if p := s.detectTrend(vt, byType[vt]); p != nil {
+			patterns = append(patterns, *p)
+		}
+	}
+	if p := s.detectWeightGain(byType[enums.VitalTypeWeight]); p != nil {
+		patterns = append(patterns, *p)
+	}
+	if p := detectStatusWorsening(checkins); p != nil {
+		patterns = append(patterns, *p)
+	}
+	if p := s.detectRiskScoreRising(checkins); p != nil {
+		patterns = append(patterns, *p)
+	}
+
+	var alerts []models.Alert
+	for _, p := range patterns {
+		alert, err := s.raise(patient, p)
+		if err != nil {
+			return alerts, err
+		}
+		if alert != nil {
+			alerts = append(alerts, *alert)
+		}
+	}
+	return alerts, nil
+}
+
+// detectTrend flags a type whose last trendMinReadings readings move strictly
+// in one direction and change by at least TrendChangePercent overall.
+func (s *PatternService) detectTrend(vt enums.VitalType, readings []models.VitalReading) *detectedPattern {
+	if len(readings) < trendMinReadings {
+		return nil
+	}
+	last := len(readings) - 1
+	if *readings[last].ValueNumeric == *readings[last-1].ValueNumeric {
+		return nil
+	}
+	rising := *readings[last].ValueNumeric > *readings[last-1].ValueNumeric
+	n := trailingRun(len(readings), func(i int) bool {
+		step := *readings[i].ValueNumeric - *readings[i-1].ValueNumeric
+		return (rising && step > 0) || (!rising && step < 0)
+	})
+	if n < trendMinReadings {
+		return nil
+	}
+	run := readings[len(readings)-n:]
+
+	first, latest := *run[0].ValueNumeric, *run[len(run)-1].ValueNumeric
+	change := latest - first
+	if first != 0 && math.Abs(change/first*100) < s.settings.TrendChangePercent {
+		return nil
+	}
+
+	key, direction := PatternTrendUp, "upward"
+	if !rising {
+		key, direction = PatternTrendDown, "downward"
+	}
+	days := run[len(run)-1].MeasuredAt.Sub(run[0].MeasuredAt).Hours() / 24
+
+	summary := map[string]interface{}{
+		"readings":      len(run),
+		"change":        round2(change),
+		"slope_per_day": roundPtr(readingSlope(run)),
+		"days":          round2(days),
+	}
+	if first != 0 {
+		summary["change_percent"] = round2(change / first * 100)
+	}
+
+	label := s.catalog.label(vt)
+	p := readingPattern(key, vt, enums.AlertSeverityMedium, run, summary)
+	p.title = fmt.Sprintf("Sustained %s trend in %s", direction, strings.ToLower(label))
+	p.message = fmt.Sprintf("%s moved %s across %d consecutive readings over %s days: %s to %s",
+		label, direction, len(run), formatValue(days), formatValue(first), formatValue(latest))
+	return p
+}
+
+// detectWeightGain flags fluid-retention weight gain: the latest weight against
+// the lowest weight in the 3 and 7 days before it.
+func (s *PatternService) detectWeightGain(readings []models.VitalReading) *detectedPattern {
+	if len(readings) < 2 {
+		return nil
+	}
+	latest := readings[len(readings)-1]
+
+	check := func(days int, limit float64) *detectedPattern {
+		from := latest.MeasuredAt.AddDate(0, 0, -days)
+		lowest := -1
+		for i, r := range readings[:len(readings)-1] {
+			if r.MeasuredAt.Before(from) {
+				continue
+			}
+			if lowest < 0 || *r.ValueNumeric < *readings[lowest].ValueNumeric {
+				lowest = i
+			}
+		}
+		if lowest < 0 {
+			return nil
+		}
+		gain := *latest.ValueNumeric - *readings[lowest].ValueNumeric
+		if gain < limit {
+			return nil
+		}
+
+		window := readings[lowest:]
+		p := readingPattern(PatternRapidWeightGain, enums.VitalTypeWeight, enums.AlertSeverityHigh, window, map[string]interface{}{
+			"gain_kg":     round2(gain),
+			"window_days": days,
+			"limit_kg":    limit,
+		})
+		p.title = "Rapid weight gain"
+		p.message = fmt.Sprintf("Weight rose %s kg within %d days (%s to %s kg), a possible sign of fluid retention",
+			formatValue(gain), days, formatValue(*readings[lowest].ValueNumeric), formatValue(*latest.ValueNumeric))
+		return p
+	}
+
+	if p := check(3, s.settings.WeightGain3DaysKg); p != nil {
+		return p
+	}
+	return check(7, s.settings.WeightGain7DaysKg)
+}
+
+// detectStatusWorsening flags medical statuses that got strictly worse across
+// at least worseningMinCheckins consecutive analyzed checkins.
+func detectStatusWorsening(checkins []models.Checkin) *detectedPattern {
+	var rated []models.Checkin
+	for _, c := range checkins {
+		if c.MedicalStatus != nil {
+			rated = append(rated, c)
+		}
+	}
+	run := trailingRun(len(rated), func(i int) bool {
+		return medicalStatusRank[*rated[i].MedicalStatus] > medicalStatusRank[*rated[i-1].MedicalStatus]
+	})
+	if run < worseningMinCheckins {
+		return nil
+	}
+	rated = rated[len(rated)-run:]
+
+	latest := *rated[len(rated)-1].MedicalStatus
+	severity := enums.AlertSeverityMedium
+	if medicalStatusRank[latest] >= medicalStatusRank[enums.MedicalStatusUrgent] {
+		severity = enums.AlertSeverityHigh
+	}
+
+	statuses := make([]string, len(rated))
+	for i, c := range rated {
+		statuses[i] = string(*c.MedicalStatus)
+	}
+	p := checkinPattern(PatternStatusWorsening, severity, rated, map[string]interface{}{"checkins": len(rated)})
+	p.title = "Worsening medical status"
+	p.message = fmt.Sprintf("Medical status worsened across %d consecutive checkins: %s", len(rated), strings.Join(statuses, " → "))
+	return p
+}
+
+// detectRiskScoreRising flags risk scores that rose across at least
+// worseningMinCheckins consecutive checkins by RiskScoreIncrease overall.
+func (s *PatternService) detectRiskScoreRising(checkins []models.Checkin) *detectedPattern {
+	var scored []models.Checkin
+	for _, c := range checkins {
+		if c.RiskScore != nil {
+			scored = append(scored, c)
+		}
+	}
+	run := trailingRun(len(scored), func(i int) bool {
+		return *scored[i].RiskScore > *scored[i-1].RiskScore
+	})
+	if run < worseningMinCheckins {
+		return nil
+	}
+	scored = scored[len(scored)-run:]
+
+	first, latest := *scored[0].RiskScore, *scored[len(scored)-1].RiskScore
+	if latest-first < s.settings.RiskScoreIncrease {
+		return nil
+	}
+	severity := enums.AlertSeverityMedium
+	if latest >= highRiskScore {
+		severity = enums.AlertSeverityHigh
+	}
+
+	p := checkinPattern(PatternRiskScoreRising, severity, scored, map[string]interface{}{
+		"checkins": len(scored),
+		"increase": latest - first,
+	})
+	p.title = "Rising risk score"
+	p.message = fmt.Sprintf("Risk score rose across %d consecutive checkins from %d to %d", len(scored), first, latest)
+	return p
+}
+
+// raise stores the alert for a pattern unless it is a duplicate.
+func (s *PatternService) raise(patient *models.Patient, p detectedPattern) (*models.Alert, error) {
+	query := s.db.Model(&models.Alert{}).
+		Where("patient_id = ? AND alert_type = ? AND details->>'pattern_key' = ?", patient.ID, enums.AlertTypePatternDetected, p.key)
+	if p.vitalType != "" {
+		query = query.Where("details->>'vital_type' = ?", p.vitalType)
+	}
+	var existing int64
+	if err := query.Where("is_acknowledged = ? OR details->>'anchor' = ?", false, p.anchor.String()).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, nil
+	}
+
+	fields := map[string]interface{}{
+		"pattern_key": p.key,
+		"anchor":      p.anchor.String(),
+		"evidence":    p.evidence,
+	}
+	if p.vitalType != "" {
+		fields["vital_type"] = p.vitalType
+	}
+	for k, v := range p.summary {
+		fields[k] = v
+	}
+	details, _ := models.NewJSONB(fields)
+
+	alert := models.Alert{
+		PatientID: patient.ID,
+		CheckinID: p.checkinID,
+		Severity:  p.severity,
+		AlertType: enums.AlertTypePatternDetected,
+		Title:     p.title,
+		Message:   p.message,
+		Details:   details,
+	}
+	if err := s.db.Create(&alert).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func readingPattern(key string, vt enums.VitalType, severity enums.AlertSeverity, readings []models.VitalReading, summary map[string]interface{}) *detectedPattern {
+	evidence := make([]PatternEvidence, len(readings))
+	for i, r := range readings {
+		evidence[i] = PatternEvidence{VitalReadingID: ptr(r.ID), Value: r.ValueNumeric, At: r.MeasuredAt}
+	}
+	latest := readings[len(readings)-1]
+	return &detectedPattern{
+		key:       key,
+		vitalType: vt,
+		severity:  severity,
+		checkinID: latest.CheckinID,
+		anchor:    latest.ID,
+		evidence:  evidence,
+		summary:   summary,
+	}
+}
+
+func checkinPattern(key string, severity enums.AlertSeverity, checkins []models.Checkin, summary map[string]interface{}) *detectedPattern {
+	evidence := make([]PatternEvidence, len(checkins))
+	for i, c := range checkins {
+		evidence[i] = PatternEvidence{CheckinID: ptr(c.ID), MedicalStatus: c.MedicalStatus, RiskScore: c.RiskScore, At: c.InitiatedAt}
+	}
+	latest := checkins[len(checkins)-1]
+	return &detectedPattern{
+		key:       key,
+		severity:  severity,
+		checkinID: ptr(latest.ID),
+		anchor:    latest.ID,
+		evidence:  evidence,
+		summary:   summary,
+	}
+}
+
+// trailingRun is the length of the longest run ending at the last of n items
+// in which step(i) holds for every item after the first.
+func trailingRun(n int, step func(i int) bool) int {
+	if n == 0 {
+		return 0
+	}
+	run := 1
+	for i := n - 1; i > 0 && step(i); i-- {
+		run++
+	}
+	return run
+}
+
+// readingSlope is the least-squares slope in units per day.
+func readingSlope(readings []models.VitalReading) *float64 {
+	if len(readings) < 2 {
+		return nil
+	}
+	origin := readings[0].MeasuredAt
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, r := range readings {
+		x := r.MeasuredAt.Sub(origin).Hours() / 24
+		y := *r.ValueNumeric
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	return &slope
+}
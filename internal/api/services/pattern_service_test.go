@@ -0,0 +1,236 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/google/uuid"
+)
+
+var patternStart = time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func newTestPatternService(t *testing.T, query func(sql string, args []driver.Value) *fakeRows) (*PatternService, *fakeDB) {
+	t.Helper()
+	db, fake := newFakeDB(t, query)
+	return NewPatternService(db, &config.Config{}, testVitalCatalog()), fake
+}
+
+// dailyReadings makes one reading a day of vt, starting at patternStart.
+func dailyReadings(vt enums.VitalType, values ...float64) []models.VitalReading {
+	readings := make([]models.VitalReading, len(values))
+	for i, v := range values {
+		value := v
+		readings[i] = models.VitalReading{ID: uuid.New(), VitalType: vt, ValueNumeric: &value, MeasuredAt: patternStart.AddDate(0, 0, i)}
+	}
+	return readings
+}
+
+func TestTrailingRun(t *testing.T) {
+	values := []int{5, 1, 2, 3, 3, 4, 5}
+	rising := func(i int) bool { return values[i] > values[i-1] }
+	tests := []struct {
+		n    int
+		want int
+	}{
+		{0, 0},
+		{1, 1},
+		{4, 3}, // 1 2 3 after the drop from 5
+		{5, 1}, // equal values end a run
+		{7, 3},
+	}
+	for _, tt := range tests {
+		if got := trailingRun(tt.n, rising); got != tt.want {
+			t.Errorf("trailingRun(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDetectTrend(t *testing.T) {
+	s, _ := newTestPatternService(t, nil)
+	tests := []struct {
+		name     string
+		values   []float64
+		key      string
+		readings int
+	}{
+		{"rising", []float64{100, 105, 110, 115}, PatternTrendUp, 4},
+		{"falling", []float64{98, 96, 92, 88}, PatternTrendDown, 4},
+		{"the whole run counts", []float64{120, 100, 104, 108, 112, 116}, PatternTrendUp, 5},
+		{"from zero", []float64{0, 1, 2, 3}, PatternTrendUp, 4},
+		{"too few readings", []float64{100, 110, 120}, "", 0},
+		{"latest equals the one before", []float64{100, 105, 110, 115, 115}, "", 0},
+		{"a tie breaks the run", []float64{100, 105, 105, 110, 115}, "", 0},
+		{"change below the percent", []float64{100, 101, 102, 103}, "", 0},
+		{"change at the percent", []float64{100, 103, 106, 110}, PatternTrendUp, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings := dailyReadings(enums.VitalTypeHeartRate, tt.values...)
+			p := s.detectTrend(enums.VitalTypeHeartRate, readings)
+			if tt.key == "" {
+				if p != nil {
+					t.Fatalf("pattern = %s over %d readings, want none", p.key, len(p.evidence))
+				}
+				return
+			}
+			if p == nil {
+				t.Fatalf("no pattern, want %s", tt.key)
+			}
+			if p.key != tt.key || len(p.evidence) != tt.readings || p.vitalType != enums.VitalTypeHeartRate {
+				t.Errorf("pattern = %s over %d readings, want %s over %d", p.key, len(p.evidence), tt.key, tt.readings)
+			}
+			if p.anchor != readings[len(readings)-1].ID {
+				t.Error("anchor is not the latest reading")
+			}
+			if _, ok := p.summary["change_percent"]; ok == (tt.values[len(tt.values)-tt.readings] == 0) {
+				t.Errorf("summary = %v, want a change percent unless the run starts at zero", p.summary)
+			}
+		})
+	}
+}
+
+func TestDetectWeightGain(t *testing.T) {
+	s, _ := newTestPatternService(t, nil)
+	at := func(day int, kg float64) models.VitalReading {
+		return models.VitalReading{ID: uuid.New(), VitalType: enums.VitalTypeWeight, ValueNumeric: &kg, MeasuredAt: patternStart.AddDate(0, 0, day)}
+	}
+	tests := []struct {
+		name     string
+		readings []models.VitalReading
+		window   int
+		evidence int
+	}{
+		{"2 kg in 3 days", []models.VitalReading{at(0, 80), at(1, 81), at(3, 82)}, 3, 3},
+		{"3 days back is inside the window", []models.VitalReading{at(0, 79.5), at(4, 80), at(7, 82)}, 3, 2},
+		{"2.5 kg in 7 days", []models.VitalReading{at(0, 80), at(4, 81), at(7, 82.5)}, 7, 3},
+		{"the lowest weight anchors the window", []models.VitalReading{at(0, 81), at(1, 79), at(4, 80.5), at(5, 81.6)}, 7, 3},
+		{"just under the 3 day limit", []models.VitalReading{at(0, 80), at(2, 81.99)}, 0, 0},
+		{"gain older than 7 days", []models.VitalReading{at(0, 78), at(8, 80), at(9, 81)}, 0, 0},
+		{"equal weights", []models.VitalReading{at(0, 80), at(1, 80), at(2, 80)}, 0, 0},
+		{"weight loss", []models.VitalReading{at(0, 84), at(2, 80)}, 0, 0},
+		{"one reading", []models.VitalReading{at(0, 90)}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := s.detectWeightGain(tt.readings)
+			if tt.window == 0 {
+				if p != nil {
+					t.Fatalf("pattern over %v days, want none", p.summary["window_days"])
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("no pattern, want rapid weight gain")
+			}
+			if p.key != PatternRapidWeightGain || p.severity != enums.AlertSeverityHigh || p.summary["window_days"] != tt.window || len(p.evidence) != tt.evidence {
+				t.Errorf("pattern = %s %s over %v days with %d readings, want %d days with %d", p.key, p.severity, p.summary["window_days"], len(p.evidence), tt.window, tt.evidence)
+			}
+			if p.anchor != tt.readings[len(tt.readings)-1].ID {
+				t.Error("anchor is not the latest weight")
+			}
+		})
+	}
+}
+
+func TestDetectStatusWorsening(t *testing.T) {
+	checkins := func(statuses ...enums.MedicalStatus) []models.Checkin {
+		out := make([]models.Checkin, len(statuses))
+		for i, status := range statuses {
+			out[i] = models.Checkin{ID: uuid.New(), InitiatedAt: patternStart.AddDate(0, 0, i)}
+			if status != "" {
+				out[i].MedicalStatus = ptr(status)
+			}
+		}
+		return out
+	}
+	normal, concern, urgent, critical := enums.MedicalStatusNormal, enums.MedicalStatusConcern, enums.MedicalStatusUrgent, enums.MedicalStatusCritical
+
+	tests := []struct {
+		name     string
+		checkins []models.Checkin
+		run      int
+	}{
+		{"three worsening checkins", checkins(normal, concern, urgent), 3},
+		{"four worsening checkins", checkins(critical, normal, concern, urgent, critical), 4},
+		{"unrated checkins are skipped", checkins(normal, "", concern, "", urgent), 3},
+		{"two are not enough", checkins(urgent, concern, critical), 0},
+		{"a repeated status breaks the run", checkins(normal, concern, concern, urgent), 0},
+		{"improving", checkins(critical, urgent, concern), 0},
+		{"none", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := detectStatusWorsening(tt.checkins)
+			if tt.run == 0 {
+				if p != nil {
+					t.Fatalf("pattern over %d checkins, want none", len(p.evidence))
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("no pattern, want worsening status")
+			}
+			latest := tt.checkins[len(tt.checkins)-1]
+			if p.key != PatternStatusWorsening || len(p.evidence) != tt.run || p.severity != enums.AlertSeverityHigh {
+				t.Errorf("pattern = %s %s over %d checkins, want HIGH over %d", p.key, p.severity, len(p.evidence), tt.run)
+			}
+			if p.anchor != latest.ID || p.checkinID == nil || *p.checkinID != latest.ID {
+				t.Error("pattern is not anchored on the latest checkin")
+			}
+		})
+	}
+}
+
+func TestPatternRaiseDedupesByAnchor(t *testing.T) {
+	for _, existing := range []int64{0, 1} {
+		var countArgs []driver.Value
+		s, fake := newTestPatternService(t, func(query string, args []driver.Value) *fakeRows {
+			if strings.Contains(query, "count(*)") && strings.Contains(query, `FROM "alerts"`) {
+				countArgs = args
+				return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{existing}}}
+			}
+			return nil
+		})
+		readings := dailyReadings(enums.VitalTypeHeartRate, 100, 105, 110, 115)
+		p := s.detectTrend(enums.VitalTypeHeartRate, readings)
+		patient := &models.Patient{ID: uuid.New()}
+
+		alert, err := s.raise(patient, *p)
+		if err != nil {
+			t.Fatalf("raise: %v", err)
+		}
+
+		anchor := readings[len(readings)-1].ID.String()
+		found := false
+		for _, arg := range countArgs {
+			found = found || arg == anchor
+		}
+		if !found {
+			t.Errorf("duplicate check args = %v, want the anchor %s", countArgs, anchor)
+		}
+
+		inserted := fake.insertedInto("alerts")
+		if existing > 0 {
+			if alert != nil || len(inserted) != 0 {
+				t.Errorf("raised %v with an alert already there", inserted)
+			}
+			continue
+		}
+		if alert == nil || len(inserted) != 1 {
+			t.Fatalf("alerts = %v, want one raised", inserted)
+		}
+		var details map[string]interface{}
+		if err := json.Unmarshal(inserted[0]["details"].([]byte), &details); err != nil {
+			t.Fatalf("details: %v", err)
+		}
+		if details["anchor"] != anchor || details["pattern_key"] != PatternTrendUp || details["vital_type"] != string(enums.VitalTypeHeartRate) {
+			t.Errorf("details = %v", details)
+		}
+	}
+}
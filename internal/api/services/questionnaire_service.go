@@ -0,0 +1,357 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxTemplateQuestions = 50
+
+type QuestionnaireService struct {
+	db      *gorm.DB
+	catalog *VitalCatalog
+}
+
+func NewQuestionnaireService(db *gorm.DB, catalog *VitalCatalog) *QuestionnaireService {
+	return &QuestionnaireService{db: db, catalog: catalog}
+}
+
+type CreateTemplateInput struct {
+	Key         string
+	Name        string
+	Description *string
+	Questions   []models.TemplateQuestion
+	CreatedBy   *uuid.UUID
+}
+
+// CreateTemplate publishes a template. The first template with a key is
+// version 1; each later one with the same key is the next version.
+func (s *QuestionnaireService) CreateTemplate(input CreateTemplateInput) (*models.QuestionnaireTemplate, error) {
+	if err := s.validateQuestions(input.Questions); err != nil {
+		return nil, err
+	}
+	questions, err := models.NewJSONB(input.Questions)
+	if err != nil {
+		return nil, err
+	}
+
+	template := models.QuestionnaireTemplate{
+		Key:         input.Key,
+		Name:        input.Name,
+		Description: input.Description,
+		Questions:   questions,
+		IsActive:    true,
+		CreatedBy:   input.CreatedBy,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// versions of a key are numbered one at a time
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "questionnaire_template:"+input.Key).Error; err != nil {
+			return err
+		}
+		var latest int
+		if err := tx.Model(&models.QuestionnaireTemplate{}).Where("key = ?", input.Key).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		template.Version = latest + 1
+		return tx.Create(&template).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (s *QuestionnaireService) GetTemplate(id uuid.UUID) (*models.QuestionnaireTemplate, error) {
+	var template models.QuestionnaireTemplate
+	if err := s.db.First(&template, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+var questionnaireTemplatePageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+		"key":        {Expr: "key", Field: "key", Kind: pagination.KindString},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "created_at",
+	IDColumn:    "id",
+}
+
+type ListTemplatesFilter struct {
+	Key             string
+	AllVersions     bool // every version instead of the latest one per key
+	IncludeInactive bool
+}
+
+func (s *QuestionnaireService) ListTemplates(filter ListTemplatesFilter, page pagination.Params) (*pagination.Page[models.QuestionnaireTemplate], error) {
+	query := s.db.Model(&models.QuestionnaireTemplate{})
+	if filter.Key != "" {
+		query = query.Where("key = ?", filter.Key)
+	}
+	if !filter.IncludeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	if !filter.AllVersions {
+		latest := s.db.Model(&models.QuestionnaireTemplate{}).Select("MAX(version)").Where("key = questionnaire_templates.key")
+		if !filter.IncludeInactive {
+			latest = latest.Where("is_active = ?", true)
+		}
+		query = query.Where("version = (?)", latest)
+	}
+	return pagination.Paginate[models.QuestionnaireTemplate](query, page, questionnaireTemplatePageSpec)
+}
+
+type UpdateTemplateInput struct {
+	Name        *string
+	Description *string
+	IsActive    *bool
+}
+
+// UpdateTemplate changes what describes a version, and retires or restores
+// it. Its questions never change; publish a new version instead.
+func (s *QuestionnaireService) UpdateTemplate(id uuid.UUID, input UpdateTemplateInput) (*models.QuestionnaireTemplate, error) {
+	var template models.QuestionnaireTemplate
+	if err := s.db.First(&template, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		updates["name"] = *input.Name
+	}
+	if input.Description != nil {
+		updates["description"] = input.Description
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+	if len(updates) == 0 {
+		return &template, nil
+	}
+
+	if err := s.db.Model(&template).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.First(&template, "id = ?", template.ID).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+type CreateAssignmentInput struct {
+	TemplateKey   string
+	PatientUserID *uuid.UUID
+	Condition     *string
+	Priority      int
+	CreatedBy     *uuid.UUID
+}
+
+func (s *QuestionnaireService) CreateAssignment(input CreateAssignmentInput) (*models.QuestionnaireAssignment, error) {
+	if input.Condition != nil {
+		trimmed := strings.TrimSpace(*input.Condition)
+		input.Condition = &trimmed
+		if trimmed == "" {
+			input.Condition = nil
+		}
+	}
+	if (input.PatientUserID == nil) == (input.Condition == nil) {
+		return nil, errs.ErrQuestionnaireAssignmentScope
+	}
+
+	var count int64
+	if err := s.db.Model(&models.QuestionnaireTemplate{}).Where("key = ?", input.TemplateKey).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errs.ErrQuestionnaireTemplateNotFound
+	}
+
+	assignment := models.QuestionnaireAssignment{
+		TemplateKey: input.TemplateKey,
+		Condition:   input.Condition,
+		Priority:    input.Priority,
+		CreatedBy:   input.CreatedBy,
+	}
+	if input.PatientUserID != nil {
+		var patient models.Patient
+		if err := s.db.First(&patient, "user_id = ?", *input.PatientUserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrPatientNotFound
+			}
+			return nil, err
+		}
+		assignment.PatientID = &patient.ID
+	}
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&assignment)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errs.ErrQuestionnaireAssignmentExists
+	}
+
+	return &assignment, nil
+}
+
+var questionnaireAssignmentPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "questionnaire_assignments.created_at", Field: "created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "questionnaire_assignments.created_at",
+	IDColumn:    "questionnaire_assignments.id",
+}
+
+type ListAssignmentsFilter struct {
+	TemplateKey   string
+	PatientUserID *uuid.UUID
+}
+
+func (s *QuestionnaireService) ListAssignments(filter ListAssignmentsFilter, page pagination.Params) (*pagination.Page[models.QuestionnaireAssignment], error) {
+	query := s.db.Model(&models.QuestionnaireAssignment{})
+	if filter.TemplateKey != "" {
+		query = query.Where("questionnaire_assignments.template_key = ?", filter.TemplateKey)
+	}
+	if filter.PatientUserID != nil {
+		query = query.Joins("JOIN patients p ON p.id = questionnaire_assignments.patient_id").Where("p.user_id = ?", *filter.PatientUserID)
+	}
+	return pagination.Paginate[models.QuestionnaireAssignment](query, page, questionnaireAssignmentPageSpec)
+}
+
+func (s *QuestionnaireService) DeleteAssignment(id uuid.UUID) error {
+	result := s.db.Delete(&models.QuestionnaireAssignment{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// EffectiveTemplate returns the template a patient's next checkin would ask
+// (by patient user id), or errs.ErrNoQuestionnaire when none is assigned.
+func (s *QuestionnaireService) EffectiveTemplate(patientUserID uuid.UUID) (*models.QuestionnaireTemplate, error) {
+	var patient models.Patient
+	if err := s.db.First(&patient, "user_id = ?", patientUserID).Error; err != nil {
+		return nil, err
+	}
+	template, err := resolveQuestionnaire(s.db, &patient)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, errs.ErrNoQuestionnaire
+	}
+	return template, nil
+}
+
+// CheckinTemplate returns the template version attached to a checkin.
+func (s *QuestionnaireService) CheckinTemplate(checkinID uuid.UUID) (*models.QuestionnaireTemplate, error) {
+	var checkin models.Checkin
+	if err := s.db.Preload("QuestionnaireTemplate").First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	if checkin.QuestionnaireTemplate == nil {
+		return nil, errs.ErrNoQuestionnaire
+	}
+	return checkin.QuestionnaireTemplate, nil
+}
+
+// resolveQuestionnaire picks the latest active version of the template
+// assigned to the patient: their own assignment first, then condition
+// assignments by priority, oldest first on a tie. An assignment whose
+// template has no active version is skipped. It returns nil when nothing
+// applies.
+func resolveQuestionnaire(db *gorm.DB, patient *models.Patient) (*models.QuestionnaireTemplate, error) {
+	var assignments []models.QuestionnaireAssignment
+	if err := db.Where("patient_id = ? OR condition IS NOT NULL", patient.ID).
+		Order("priority DESC, created_at ASC").
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	// the patient's own assignment goes first whatever its priority
+	sort.SliceStable(assignments, func(i, j int) bool {
+		return assignments[i].PatientID != nil && assignments[j].PatientID == nil
+	})
+
+	conditions := append([]string{patient.ConditionSummary}, patient.Comorbidities...)
+	for _, a := range assignments {
+		if a.Condition != nil && !matchesCondition(*a.Condition, conditions) {
+			continue
+		}
+		var template models.QuestionnaireTemplate
+		err := db.Where("key = ? AND is_active = ?", a.TemplateKey, true).Order("version DESC").First(&template).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &template, nil
+	}
+	return nil, nil
+}
+
+func matchesCondition(condition string, conditions []string) bool {
+	needle := strings.ToLower(condition)
+	for _, c := range conditions {
+		if strings.Contains(strings.ToLower(c), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateCheckinQuestions renders a template's questions as the checkin's
+// questions, numbered by seq in the order they are asked.
+func templateCheckinQuestions(template *models.QuestionnaireTemplate) (models.JSONB, error) {
+	questions, err := template.ParsedQuestions()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]models.CheckinQuestion, len(questions))
+	for i, q := range questions {
+		items[i] = models.CheckinQuestion{ID: uuid.New(), Seq: i + 1, TemplateQuestion: q}
+	}
+	return models.NewJSONB(items)
+}
+
+func (s *QuestionnaireService) validateQuestions(questions []models.TemplateQuestion) error {
+	var fields []errs.FieldError
+	if len(questions) == 0 {
+		fields = append(fields, errs.FieldError{Field: "questions", Message: "cannot be empty"})
+	}
+	if len(questions) > maxTemplateQuestions {
+		fields = append(fields, errs.FieldError{Field: "questions", Message: fmt.Sprintf("must have at most %d questions", maxTemplateQuestions)})
+	}
+
+	keys := map[string]bool{}
+	for i, q := range questions {
+		path := fmt.Sprintf("questions[%d]", i)
+		if q.Key == "" {
+			fields = append(fields, errs.FieldError{Field: path + ".key", Message: "is required"})
+		} else if keys[q.Key] {
+			fields = append(fields, errs.FieldError{Field: path + ".key", Message: "is used by another question"})
+		}
+		keys[q.Key] = true
+		fields = append(fields, questionFieldErrors(s.catalog, path, q)...)
+	}
+	if len(fields) > 0 {
+		return errs.Validation(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultClaimMinutes       = 30
+	defaultCriticalSLAMinutes = 60
+	defaultUrgentSLAMinutes   = 4 * 60
+	defaultConcernSLAMinutes  = 24 * 60
+	defaultNormalSLAMinutes   = 3 * 24 * 60
+)
+
+func reviewSettings(cfg *config.Config) config.Review {
+	var settings config.Review
+	if cfg != nil {
+		settings = cfg.Internal.Review
+	}
+	if settings.ClaimMinutes <= 0 {
+		settings.ClaimMinutes = defaultClaimMinutes
+	}
+	if settings.CriticalSLAMinutes <= 0 {
+		settings.CriticalSLAMinutes = defaultCriticalSLAMinutes
+	}
+	if settings.UrgentSLAMinutes <= 0 {
+		settings.UrgentSLAMinutes = defaultUrgentSLAMinutes
+	}
+	if settings.ConcernSLAMinutes <= 0 {
+		settings.ConcernSLAMinutes = defaultConcernSLAMinutes
+	}
+	if settings.NormalSLAMinutes <= 0 {
+		settings.NormalSLAMinutes = defaultNormalSLAMinutes
+	}
+	return settings
+}
+
+// ReviewScope is whose patients a doctor's review queue covers.
+type ReviewScope string
+
+const (
+	ReviewScopeOwn      ReviewScope = "own"      // patients the doctor attends
+	ReviewScopePractice ReviewScope = "practice" // also patients of the doctors sharing an organization with them
+)
+
+// ReviewQueueService lists analyzed checkins awaiting review and lets doctors
+// claim them, so colleagues in a practice do not review the same checkin.
+// Each checkin is due for review a set time after its analysis, by medical
+// status; checkins past it are overdue.
+type ReviewQueueService struct {
+	db       *gorm.DB
+	settings config.Review
+}
+
+func NewReviewQueueService(db *gorm.DB, cfg *config.Config) *ReviewQueueService {
+	return &ReviewQueueService{db: db, settings: reviewSettings(cfg)}
+}
+
+type ReviewQueueFilter struct {
+	DoctorID uuid.UUID
+	Scope    ReviewScope
+	// Unclaimed leaves out checkins other doctors hold claims on.
+	Unclaimed bool
+	Overdue   bool
+}
+
+// ReviewQueue is a page of a doctor's queue, with the size of all of it.
+type ReviewQueue struct {
+	Items   []ReviewQueueItem
+	Meta    pagination.Meta
+	Total   int64
+	Overdue int64
+}
+
+type ReviewQueueItem struct {
+	Checkin        models.Checkin
+	DueAt          time.Time
+	Overdue        bool
+	ClaimExpiresAt *time.Time // set while the claim holds
+}
+
+// reviewQueueRow is a checkin read with its sort values, so the queue can be
+// paged by them.
+type reviewQueueRow struct {
+	models.Checkin
+	DueAt        time.Time `gorm:"column:due_at;->"`
+	StatusRank   int       `gorm:"column:status_rank;->"`
+	RiskRank     int       `gorm:"column:risk_rank;->"`
+	WaitingSince time.Time `gorm:"column:waiting_since;->"`
+}
+
+func (reviewQueueRow) TableName() string { return "checkins" }
+
+// The queue's sort values as SQL over checkins, each ascending: the most
+// critical medical status first, then the highest risk score, unscored last,
+// then the longest waiting.
+const (
+	reviewStatusRankSQL   = "CASE checkins.medical_status WHEN 'CRITICAL' THEN 0 WHEN 'URGENT' THEN 1 WHEN 'NORMAL' THEN 3 ELSE 2 END"
+	reviewRiskRankSQL     = "-COALESCE(checkins.risk_score, -1)"
+	reviewWaitingSinceSQL = "COALESCE(checkins.analyzed_at, checkins.updated_at)"
+)
+
+// pageSpec pages the queue by priority: medical status, risk score, then
+// time waiting. sort=due_at pages it by review due time instead. from and to
+// filter on the analysis time.
+func (s *ReviewQueueService) pageSpec() pagination.Spec {
+	return pagination.Spec{
+		Sorts: map[string]pagination.Column{
+			"priority": {Expr: reviewStatusRankSQL, Field: "status_rank", Kind: pagination.KindNumber, Then: []pagination.Column{
+				{Expr: reviewRiskRankSQL, Field: "risk_rank", Kind: pagination.KindNumber},
+				{Expr: reviewWaitingSinceSQL, Field: "waiting_since", Kind: pagination.KindTime},
+			}},
+			"due_at": {Expr: s.dueAtSQL(), Field: "due_at", Kind: pagination.KindTime},
+		},
+		DefaultSort:      "priority",
+		DefaultDirection: pagination.Asc,
+		DateColumn:       reviewWaitingSinceSQL,
+		IDColumn:         "checkins.id",
+	}
+}
+
+// Queue returns a page of the doctor's analyzed, unreviewed checkins, the
+// most critical medical status first, then the highest risk score, then the
+// longest waiting.
+func (s *ReviewQueueService) Queue(filter ReviewQueueFilter, page pagination.Params) (*ReviewQueue, error) {
+	if err := s.db.First(&models.User{}, "id = ? AND role = ?", filter.DoctorID, enums.UserRoleDoctor).Error; err != nil {
+		return nil, err
+	}
+	scope := filter.Scope
+	if scope == "" {
+		scope = ReviewScopePractice
+	}
+	if scope != ReviewScopeOwn && scope != ReviewScopePractice {
+		return nil, errs.InvalidField("query.scope", "must be one of [own practice]")
+	}
+
+	now := time.Now()
+	query := s.db.Model(&reviewQueueRow{}).
+		Where("checkins.status = ? AND checkins.patient_id IN (?)", enums.CheckinStatusAnalyzed, reviewerPatients(s.db, filter.DoctorID, scope))
+	if filter.Unclaimed {
+		query = query.Where("(checkins.claimed_by IS NULL OR checkins.claimed_by = ? OR checkins.claimed_at <= ?)", filter.DoctorID, s.claimCutoff(now))
+	}
+	overdue := s.dueAtSQL() + " <= ?"
+	if filter.Overdue {
+		query = query.Where(overdue, now)
+	}
+
+	queue := ReviewQueue{Items: []ReviewQueueItem{}}
+	if err := query.Session(&gorm.Session{}).Count(&queue.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).Where(overdue, now).Count(&queue.Overdue).Error; err != nil {
+		return nil, err
+	}
+
+	rows, err := pagination.Paginate[reviewQueueRow](query, page, s.pageSpec(), func(db *gorm.DB) *gorm.DB {
+		return db.Select("checkins.*, " + s.dueAtSQL() + " AS due_at, " +
+			reviewStatusRankSQL + " AS status_rank, " + reviewRiskRankSQL + " AS risk_rank, " + reviewWaitingSinceSQL + " AS waiting_since").
+			Preload("Patient.User").Preload("Claimer")
+	})
+	if err != nil {
+		return nil, err
+	}
+	queue.Meta = rows.Meta
+	for _, row := range rows.Items {
+		c := row.Checkin
+		item := ReviewQueueItem{Checkin: c, DueAt: row.DueAt}
+		item.Overdue = !now.Before(item.DueAt)
+		if claimHolds(&c, s.settings, now) {
+			expires := c.ClaimedAt.Add(s.claimTTL())
+			item.ClaimExpiresAt = &expires
+		}
+		queue.Items = append(queue.Items, item)
+	}
+	return &queue, nil
+}
+
+// Claim takes an analyzed checkin for review by the doctor, or renews their
+// claim. It fails while another doctor's claim holds.
+func (s *ReviewQueueService) Claim(checkinID, doctorID uuid.UUID) (*models.Checkin, error) {
+	checkin, err := s.reviewable(checkinID, doctorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.Checkin{}).
+		Where("id = ? AND status = ?", checkinID, enums.CheckinStatusAnalyzed).
+		Where("(claimed_by IS NULL OR claimed_by = ? OR claimed_at <= ?)", doctorID, s.claimCutoff(now)).
+		UpdateColumns(map[string]interface{}{"claimed_by": doctorID, "claimed_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// analyzed a moment ago, so either reviewed or claimed since
+		if err := s.db.First(checkin, "id = ?", checkinID).Error; err != nil {
+			return nil, err
+		}
+		if checkin.Status == enums.CheckinStatusReviewed {
+			return nil, errs.ErrCheckinReviewed
+		}
+		return nil, errs.ErrCheckinClaimed
+	}
+
+	if err := s.db.First(checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	return checkin, nil
+}
+
+// Unclaim releases the doctor's claim. Releasing a checkin nobody holds is
+// a no-op; another doctor's claim cannot be released.
+func (s *ReviewQueueService) Unclaim(checkinID, doctorID uuid.UUID) (*models.Checkin, error) {
+	if err := s.db.First(&models.User{}, "id = ? AND role = ?", doctorID, enums.UserRoleDoctor).Error; err != nil {
+		return nil, err
+	}
+
+	result := s.db.Model(&models.Checkin{}).
+		Where("id = ? AND claimed_by = ?", checkinID, doctorID).
+		UpdateColumns(map[string]interface{}{"claimed_by": nil, "claimed_at": nil})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var checkin models.Checkin
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 && claimHolds(&checkin, s.settings, time.Now()) {
+		return nil, errs.ErrCheckinClaimed
+	}
+	return &checkin, nil
+}
+
+// reviewable loads an analyzed checkin of a patient in the doctor's practice.
+func (s *ReviewQueueService) reviewable(checkinID, doctorID uuid.UUID) (*models.Checkin, error) {
+	if err := s.db.First(&models.User{}, "id = ? AND role = ?", doctorID, enums.UserRoleDoctor).Error; err != nil {
+		return nil, err
+	}
+	var checkin models.Checkin
+	if err := s.db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+
+	switch checkin.Status {
+	case enums.CheckinStatusAnalyzed:
+	case enums.CheckinStatusReviewed:
+		return nil, errs.ErrCheckinReviewed
+	default:
+		return nil, errs.ErrCheckinNotAnalyzed
+	}
+
+	var inPractice int64
+	if err := s.db.Model(&models.Patient{}).
+		Where("id = ? AND id IN (?)", checkin.PatientID, reviewerPatients(s.db, doctorID, ReviewScopePractice)).
+		Count(&inPractice).Error; err != nil {
+		return nil, err
+	}
+	if inPractice == 0 {
+		return nil, errs.ErrCheckinNotInPractice
+	}
+	return &checkin, nil
+}
+
+func (s *ReviewQueueService) claimTTL() time.Duration {
+	return time.Duration(s.settings.ClaimMinutes) * time.Minute
+}
+
+// claimCutoff is the claim time at or before which a claim has lapsed.
+func (s *ReviewQueueService) claimCutoff(now time.Time) time.Time {
+	return now.Add(-s.claimTTL())
+}
+
+// dueAtSQL is when a checkin's review falls due, as SQL over checkins. The
+// clock of one without an analysis time starts at its last update.
+func (s *ReviewQueueService) dueAtSQL() string {
+	return "(" + reviewWaitingSinceSQL + " + make_interval(mins => " + s.slaMinutesSQL() + "))"
+}
+
+// slaMinutesSQL is the review time by medical status as SQL over checkins; the settings
+// are integers, so they are inlined.
+func (s *ReviewQueueService) slaMinutesSQL() string {
+	return "CASE checkins.medical_status" +
+		" WHEN 'CRITICAL' THEN " + strconv.Itoa(s.settings.CriticalSLAMinutes) +
+		" WHEN 'URGENT' THEN " + strconv.Itoa(s.settings.UrgentSLAMinutes) +
+		" WHEN 'NORMAL' THEN " + strconv.Itoa(s.settings.NormalSLAMinutes) +
+		" ELSE " + strconv.Itoa(s.settings.ConcernSLAMinutes) + " END"
+}
+
+// claimHolds reports whether the checkin is claimed and the claim has not
+// lapsed.
+func claimHolds(checkin *models.Checkin, settings config.Review, now time.Time) bool {
+	if checkin.ClaimedBy == nil || checkin.ClaimedAt == nil {
+		return false
+	}
+	return checkin.ClaimedAt.Add(time.Duration(settings.ClaimMinutes) * time.Minute).After(now)
+}
+
+// reviewerPatients selects the ids of the patients whose checkins the doctor
+// reviews in scope.
+func reviewerPatients(db *gorm.DB, doctorID uuid.UUID, scope ReviewScope) *gorm.DB {
+	if scope == ReviewScopeOwn {
+		return db.Model(&models.Patient{}).Select("id").Where("doctor_id = ?", doctorID)
+	}
+	colleagues := db.Table("organization_doctors od").
+		Select("colleague.doctor_id").
+		Joins("JOIN organization_doctors colleague ON colleague.organization_id = od.organization_id AND colleague.is_active").
+		Where("od.doctor_id = ? AND od.is_active", doctorID)
+	return db.Model(&models.Patient{}).Select("id").Where("doctor_id = ? OR doctor_id IN (?)", doctorID, colleagues)
+}
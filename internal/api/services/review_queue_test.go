@@ -0,0 +1,71 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+)
+
+func TestQueuePagesByPriority(t *testing.T) {
+	analyzed := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	columns := []string{"id", "patient_id", "status", "medical_status", "risk_score", "analyzed_at", "due_at", "status_rank", "risk_rank", "waiting_since"}
+	critical := func(risk int64, at time.Time) []driver.Value {
+		return []driver.Value{uuid.NewString(), uuid.NewString(), "ANALYZED", "CRITICAL", risk, at, at.Add(time.Hour), int64(0), -risk, at}
+	}
+	// of two critical checkins, the higher risk comes first though it waited less
+	rows := [][]driver.Value{critical(90, analyzed.Add(time.Minute)), critical(40, analyzed)}
+
+	var pageQuery string
+	var pageArgs []driver.Value
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "users"`) && strings.Contains(query, "role ="):
+			return &fakeRows{columns: []string{"id", "role"}, values: [][]driver.Value{{uuid.NewString(), "DOCTOR"}}}
+		case strings.Contains(query, `FROM "checkins"`) && strings.Contains(query, "ORDER BY"):
+			pageQuery, pageArgs = query, args
+			return &fakeRows{columns: columns, values: rows}
+		}
+		return nil
+	})
+	s := NewReviewQueueService(db, nil)
+	filter := ReviewQueueFilter{DoctorID: uuid.New()}
+
+	queue, err := s.Queue(filter, pagination.Params{Limit: 1})
+	if err != nil {
+		t.Fatalf("Queue: %v", err)
+	}
+	order := reviewStatusRankSQL + " ASC," + reviewRiskRankSQL + " ASC," + reviewWaitingSinceSQL + " ASC,checkins.id ASC"
+	if !strings.Contains(pageQuery, "ORDER BY "+order) {
+		t.Errorf("query = %s\nwant ORDER BY %s", pageQuery, order)
+	}
+	if len(queue.Items) != 1 || queue.Meta.NextCursor == nil {
+		t.Fatalf("queue = %+v, want one item and a next cursor", queue)
+	}
+	if risk := queue.Items[0].Checkin.RiskScore; risk == nil || *risk != 90 {
+		t.Errorf("first risk score = %v, want 90", risk)
+	}
+
+	rows = rows[1:]
+	if _, err := s.Queue(filter, pagination.Params{Limit: 1, Cursor: *queue.Meta.NextCursor}); err != nil {
+		t.Fatalf("Queue with cursor: %v", err)
+	}
+	after := "(" + reviewStatusRankSQL + ", " + reviewRiskRankSQL + ", " + reviewWaitingSinceSQL + ", checkins.id) > ("
+	if !strings.Contains(pageQuery, after) {
+		t.Fatalf("query = %s\nwant the page after %s", pageQuery, after)
+	}
+	// the cursor keeps the first item's status rank, risk rank and time
+	var keys []driver.Value
+	for _, arg := range pageArgs {
+		switch arg.(type) {
+		case float64, time.Time:
+			keys = append(keys, arg)
+		}
+	}
+	if len(keys) < 3 || keys[len(keys)-3] != 0.0 || keys[len(keys)-2] != -90.0 || !keys[len(keys)-1].(time.Time).Equal(analyzed.Add(time.Minute)) {
+		t.Errorf("cursor values = %v, want 0, -90 and the first item's analysis time", keys)
+	}
+}
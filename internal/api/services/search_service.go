@@ -0,0 +1,262 @@
+package services
+
+import (
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SearchKind is the kind of record a search hit is.
+type SearchKind string
+
+const (
+	SearchKindCheckin SearchKind = "checkin"
+	SearchKindAlert   SearchKind = "alert"
+)
+
+// Search snippet fields.
+const (
+	SearchFieldMessages    = "raw_messages"
+	SearchFieldAnswers     = "answers"
+	SearchFieldDoctorNotes = "doctor_notes"
+	SearchFieldTitle       = "title"
+	SearchFieldMessage     = "message"
+)
+
+// Highlighted words are wrapped in <mark> in snippets; the rest of the text
+// is HTML-escaped. Postgres marks them with these private-use characters
+// first, so markup the patient typed can never pass for a highlight.
+const (
+	searchStartSel = "\uE000"
+	searchStopSel  = "\uE001"
+)
+
+// SearchService runs full-text search over the checkin conversations, doctor
+// notes and alert messages of a doctor's patients. The indexes are set up
+// with the schema; see the database package.
+type SearchService struct {
+	db *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{db: db}
+}
+
+type SearchQuery struct {
+	DoctorID uuid.UUID
+	Scope    ReviewScope
+	Text     string
+	Kind     SearchKind // empty searches both
+	// PatientUserID narrows the search to one patient.
+	PatientUserID *uuid.UUID
+	// MedicalStatus keeps checkins with the status and alerts of such checkins.
+	MedicalStatus *enums.MedicalStatus
+}
+
+// SearchResult is a page of hits; Total counts every match.
+type SearchResult struct {
+	Hits  []SearchHit
+	Meta  pagination.Meta
+	Total int64
+}
+
+type SearchHit struct {
+	Kind          SearchKind
+	ID            uuid.UUID // the checkin or alert
+	CheckinID     *uuid.UUID
+	PatientID     uuid.UUID
+	PatientUserID uuid.UUID
+	PatientName   string
+	MedicalStatus *enums.MedicalStatus
+	OccurredAt    time.Time
+	Rank          float64
+	Snippets      []SearchSnippet
+}
+
+type SearchSnippet struct {
+	Field string
+	Text  string
+}
+
+// searchRow is a hit as selected, with a headline per searched field.
+type searchRow struct {
+	ID            uuid.UUID
+	Kind          SearchKind
+	CheckinID     *uuid.UUID
+	PatientID     uuid.UUID
+	PatientUserID uuid.UUID
+	FirstName     string
+	LastName      string
+	MedicalStatus *enums.MedicalStatus
+	OccurredAt    time.Time
+	Rank          float64
+	Headline1     string
+	Headline2     string
+	Headline3     string
+}
+
+// searchFields name each kind's headlines in order; an empty name has none.
+var searchFields = map[SearchKind][]string{
+	SearchKindCheckin: {SearchFieldMessages, SearchFieldAnswers, SearchFieldDoctorNotes},
+	SearchKindAlert:   {SearchFieldTitle, SearchFieldMessage, ""},
+}
+
+// searchSpec pages the checkin and alert hits together, best match first by
+// default or by time with sort=occurred_at. from and to filter on the checkin
+// start or alert time.
+var searchSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"rank":        {Expr: "hits.rank", Field: "rank", Kind: pagination.KindNumber},
+		"occurred_at": {Expr: "hits.occurred_at", Field: "occurred_at", Kind: pagination.KindTime},
+	},
+	DefaultSort:      "rank",
+	DefaultDirection: pagination.Desc,
+	IDColumn:         "hits.id",
+}
+
+// Search returns a page of the matching checkins and alerts, the best
+// matches first. Words may be quoted for phrases, joined with "or" and
+// excluded with a leading "-", as in web search.
+func (s *SearchService) Search(q SearchQuery, page pagination.Params) (*SearchResult, error) {
+	text := strings.TrimSpace(q.Text)
+	if len([]rune(text)) < 2 || len([]rune(text)) > 200 {
+		return nil, errs.InvalidField("query.q", "must be between 2 and 200 characters")
+	}
+	scope := q.Scope
+	if scope == "" {
+		scope = ReviewScopeOwn
+	}
+	if scope != ReviewScopeOwn && scope != ReviewScopePractice {
+		return nil, errs.InvalidField("query.scope", "must be one of [own practice]")
+	}
+	if q.Kind != "" && q.Kind != SearchKindCheckin && q.Kind != SearchKindAlert {
+		return nil, errs.InvalidField("query.type", "must be one of [checkin alert]")
+	}
+	if err := s.db.First(&models.User{}, "id = ? AND role = ?", q.DoctorID, enums.UserRoleDoctor).Error; err != nil {
+		return nil, err
+	}
+
+	patients := reviewerPatients(s.db, q.DoctorID, scope)
+	if q.PatientUserID != nil {
+		patients = patients.Where("user_id = ?", *q.PatientUserID)
+	}
+
+	// each kind selects the text of its searched fields, so the headlines
+	// are made only for the page
+	var kinds []interface{}
+	if q.Kind != SearchKindAlert {
+		checkins := s.db.Table("checkins c").
+			Select("c.id, CAST(? AS text) AS kind, c.id AS checkin_id, c.patient_id, p.user_id AS patient_user_id, u.first_name, u.last_name, c.medical_status, c.initiated_at AS occurred_at, "+
+				"ts_rank(c.search_vector, fts_query(?)) AS rank, "+
+				"checkin_messages_text(c.raw_messages) AS text1, checkin_answers_text(c.answers) AS text2, coalesce(c.doctor_notes, '') AS text3",
+				SearchKindCheckin, text).
+			Joins("JOIN patients p ON p.id = c.patient_id").
+			Joins("JOIN users u ON u.id = p.user_id").
+			Where("c.search_vector @@ fts_query(?) AND c.patient_id IN (?)", text, patients)
+		if q.MedicalStatus != nil {
+			checkins = checkins.Where("c.medical_status = ?", *q.MedicalStatus)
+		}
+		kinds = append(kinds, checkins)
+	}
+	if q.Kind != SearchKindCheckin {
+		alerts := s.db.Table("alerts a").
+			Select("a.id, CAST(? AS text) AS kind, a.checkin_id, a.patient_id, p.user_id AS patient_user_id, u.first_name, u.last_name, c.medical_status, a.created_at AS occurred_at, "+
+				"ts_rank(a.search_vector, fts_query(?)) AS rank, "+
+				"a.title AS text1, a.message AS text2, '' AS text3",
+				SearchKindAlert, text).
+			Joins("JOIN patients p ON p.id = a.patient_id").
+			Joins("JOIN users u ON u.id = p.user_id").
+			Joins("LEFT JOIN checkins c ON c.id = a.checkin_id").
+			Where("a.search_vector @@ fts_query(?) AND a.patient_id IN (?)", text, patients)
+		if q.MedicalStatus != nil {
+			alerts = alerts.Where("c.medical_status = ?", *q.MedicalStatus)
+		}
+		kinds = append(kinds, alerts)
+	}
+	union := strings.TrimSuffix(strings.Repeat("? UNION ALL ", len(kinds)), " UNION ALL ")
+	query := searchPeriod(s.db.Table("("+union+") AS hits", kinds...), "hits.occurred_at", page.From, page.To)
+
+	result := SearchResult{Hits: []SearchHit{}}
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	// the period is already applied, with the total
+	page.From, page.To = nil, nil
+	rows, err := pagination.Paginate[searchRow](query, page, searchSpec, func(db *gorm.DB) *gorm.DB {
+		return db.Select("hits.*, "+
+			"ts_headline(CAST(@config AS regconfig), hits.text1, fts_query(@q), @options) AS headline1, "+
+			"ts_headline(CAST(@config AS regconfig), hits.text2, fts_query(@q), @options) AS headline2, "+
+			"ts_headline(CAST(@config AS regconfig), hits.text3, fts_query(@q), @options) AS headline3",
+			map[string]interface{}{"q": text, "config": headlineConfig(text), "options": searchHeadlineOptions})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Meta = rows.Meta
+	for _, r := range rows.Items {
+		hit := SearchHit{
+			Kind:          r.Kind,
+			ID:            r.ID,
+			CheckinID:     r.CheckinID,
+			PatientID:     r.PatientID,
+			PatientUserID: r.PatientUserID,
+			PatientName:   strings.TrimSpace(r.FirstName + " " + r.LastName),
+			MedicalStatus: r.MedicalStatus,
+			OccurredAt:    r.OccurredAt,
+			Rank:          r.Rank,
+			Snippets:      []SearchSnippet{},
+		}
+		fields := searchFields[r.Kind]
+		for j, headline := range []string{r.Headline1, r.Headline2, r.Headline3} {
+			// a field without a match comes back from ts_headline unmarked
+			if j < len(fields) && fields[j] != "" && strings.Contains(headline, searchStartSel) {
+				hit.Snippets = append(hit.Snippets, SearchSnippet{Field: fields[j], Text: highlight(headline)})
+			}
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return &result, nil
+}
+
+var searchHeadlineOptions = `StartSel="` + searchStartSel + `", StopSel="` + searchStopSel +
+	`", MaxFragments=3, MaxWords=20, MinWords=5, FragmentDelimiter=" … "`
+
+func searchPeriod(query *gorm.DB, column string, from, to *time.Time) *gorm.DB {
+	if from != nil {
+		query = query.Where(column+" >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where(column+" < ?", *to)
+	}
+	return query
+}
+
+// headlineConfig picks the configuration snippets are highlighted with: the
+// Russian stemmer for Cyrillic queries, English otherwise. Matching itself
+// uses all configurations.
+func headlineConfig(text string) string {
+	for _, r := range text {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return "russian"
+		}
+	}
+	return "english"
+}
+
+// highlight escapes a headline for HTML and turns its selection markers into
+// <mark> tags.
+func highlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, searchStartSel, "<mark>")
+	return strings.ReplaceAll(escaped, searchStopSel, "</mark>")
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ThresholdRuleService struct {
+	db      *gorm.DB
+	catalog *VitalCatalog
+}
+
+func NewThresholdRuleService(db *gorm.DB, catalog *VitalCatalog) *ThresholdRuleService {
+	return &ThresholdRuleService{db: db, catalog: catalog}
+}
+
+type CreateThresholdRuleInput struct {
+	OrganizationID *uuid.UUID
+	PatientUserID  *uuid.UUID
+	Key            string
+	Name           string
+	Severity       enums.AlertSeverity
+	Conditions     []models.RuleCondition
+	IsActive       *bool
+	CreatedBy      *uuid.UUID
+}
+
+func (s *ThresholdRuleService) Create(input CreateThresholdRuleInput) (*models.ThresholdRule, error) {
+	if (input.OrganizationID == nil) == (input.PatientUserID == nil) {
+		return nil, errs.ErrRuleScope
+	}
+	if err := s.validateRule(input.Severity, input.Conditions); err != nil {
+		return nil, err
+	}
+
+	rule := models.ThresholdRule{
+		OrganizationID: input.OrganizationID,
+		Key:            input.Key,
+		Name:           input.Name,
+		Severity:       input.Severity,
+		IsActive:       true,
+		CreatedBy:      input.CreatedBy,
+	}
+	if input.IsActive != nil {
+		rule.IsActive = *input.IsActive
+	}
+
+	if input.OrganizationID != nil {
+		if err := s.db.First(&models.Organization{}, "id = ?", *input.OrganizationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrOrganizationNotFound
+			}
+			return nil, err
+		}
+	} else {
+		var patient models.Patient
+		if err := s.db.First(&patient, "user_id = ?", *input.PatientUserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrPatientNotFound
+			}
+			return nil, err
+		}
+		rule.PatientID = &patient.ID
+	}
+
+	conditions, err := models.NewJSONB(input.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	rule.Conditions = conditions
+
+	if err := s.ensureKeyFree(rule.OrganizationID, rule.PatientID, rule.Key, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (s *ThresholdRuleService) GetByID(id uuid.UUID) (*models.ThresholdRule, error) {
+	var rule models.ThresholdRule
+	if err := s.db.First(&rule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+var thresholdRulePageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+		"key":        {Expr: "key", Field: "key", Kind: pagination.KindString},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "created_at",
+	IDColumn:    "id",
+}
+
+type ListThresholdRulesFilter struct {
+	OrganizationID  *uuid.UUID
+	PatientID       *uuid.UUID
+	IncludeInactive bool
+}
+
+func (s *ThresholdRuleService) List(filter ListThresholdRulesFilter, page pagination.Params) (*pagination.Page[models.ThresholdRule], error) {
+	query := s.db.Model(&models.ThresholdRule{})
+	if filter.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *filter.OrganizationID)
+	}
+	if filter.PatientID != nil {
+		query = query.Where("patient_id = ?", *filter.PatientID)
+	}
+	if !filter.IncludeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	return pagination.Paginate[models.ThresholdRule](query, page, thresholdRulePageSpec)
+}
+
+type UpdateThresholdRuleInput struct {
+	Key        *string
+	Name       *string
+	Severity   *enums.AlertSeverity
+	Conditions *[]models.RuleCondition
+	IsActive   *bool
+}
+
+func (s *ThresholdRuleService) Update(id uuid.UUID, input UpdateThresholdRuleInput) (*models.ThresholdRule, error) {
+	var rule models.ThresholdRule
+	if err := s.db.First(&rule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if input.Key != nil {
+		if err := s.ensureKeyFree(rule.OrganizationID, rule.PatientID, *input.Key, rule.ID); err != nil {
+			return nil, err
+		}
+		updates["key"] = *input.Key
+	}
+	if input.Name != nil {
+		updates["name"] = *input.Name
+	}
+	if input.Severity != nil || input.Conditions != nil {
+		severity := rule.Severity
+		if input.Severity != nil {
+			severity = *input.Severity
+		}
+		conditions, err := rule.ParsedConditions()
+		if err != nil {
+			return nil, err
+		}
+		if input.Conditions != nil {
+			conditions = *input.Conditions
+		}
+		if err := s.validateRule(severity, conditions); err != nil {
+			return nil, err
+		}
+		if input.Severity != nil {
+			updates["severity"] = *input.Severity
+		}
+		if input.Conditions != nil {
+			encoded, err := models.NewJSONB(conditions)
+			if err != nil {
+				return nil, err
+			}
+			updates["conditions"] = encoded
+		}
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+
+	if len(updates) == 0 {
+		return &rule, nil
+	}
+
+	if err := s.db.Model(&rule).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(&rule, "id = ?", rule.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (s *ThresholdRuleService) Delete(id uuid.UUID) error {
+	result := s.db.Delete(&models.ThresholdRule{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// EffectiveRules returns the active rules that apply to a patient (by user id).
+func (s *ThresholdRuleService) EffectiveRules(patientUserID uuid.UUID) ([]models.ThresholdRule, error) {
+	var patient models.Patient
+	if err := s.db.First(&patient, "user_id = ?", patientUserID).Error; err != nil {
+		return nil, err
+	}
+	return s.effectiveRules(s.db, &patient)
+}
+
+// effectiveRules merges the patient's own rules with the defaults of every
+// organization the patient's doctor actively belongs to.
+func (s *ThresholdRuleService) effectiveRules(db *gorm.DB, patient *models.Patient) ([]models.ThresholdRule, error) {
+	var patientRules []models.ThresholdRule
+	if err := db.Where("patient_id = ?", patient.ID).Find(&patientRules).Error; err != nil {
+		return nil, err
+	}
+
+	var orgRules []models.ThresholdRule
+	if err := db.
+		Joins("JOIN organization_doctors od ON od.organization_id = threshold_rules.organization_id").
+		Where("od.doctor_id = ? AND od.is_active = ? AND threshold_rules.is_active = ?", patient.DoctorID, true, true).
+		Order("threshold_rules.created_at ASC").
+		Find(&orgRules).Error; err != nil {
+		return nil, err
+	}
+
+	overridden := make(map[string]bool, len(patientRules))
+	var rules []models.ThresholdRule
+	for _, r := range patientRules {
+		overridden[r.Key] = true
+		if r.IsActive {
+			rules = append(rules, r)
+		}
+	}
+	for _, r := range orgRules {
+		if overridden[r.Key] {
+			continue
+		}
+		// a doctor in several organizations gets the oldest rule for a key
+		overridden[r.Key] = true
+		rules = append(rules, r)
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Key < rules[j].Key })
+	return rules, nil
+}
+
+// RuleMatch is a condition that held, with the value that satisfied it.
+type RuleMatch struct {
+	Metric    enums.RuleMetric   `json:"metric"`
+	Operator  enums.RuleOperator `json:"operator"`
+	Threshold float64            `json:"threshold"`
+	Actual    float64            `json:"actual"`
+}
+
+// EvaluateCheckin runs the patient's effective rules against the latest
+// values recorded in a checkin and raises one THRESHOLD_RULE alert per
+// matching rule. Only rules that measure one of metrics are considered; pass
+// none to evaluate all. A rule alerts at most once per checkin. db may be a
+// transaction.
+func (s *ThresholdRuleService) EvaluateCheckin(db *gorm.DB, checkinID uuid.UUID, trigger enums.RuleTrigger, metrics ...enums.RuleMetric) ([]models.Alert, error) {
+	var checkin models.Checkin
+	if err := db.First(&checkin, "id = ?", checkinID).Error; err != nil {
+		return nil, err
+	}
+	var patient models.Patient
+	if err := db.First(&patient, "id = ?", checkin.PatientID).Error; err != nil {
+		return nil, err
+	}
+
+	rules, err := s.effectiveRules(db, &patient)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	values, err := checkinMetricValues(db, &checkin)
+	if err != nil {
+		return nil, err
+	}
+
+	return raiseRuleAlerts(db, patient.ID, &checkin.ID, matchRules(rules, values, metrics), trigger, func(rule *models.ThresholdRule) *gorm.DB {
+		return db.Model(&models.Alert{}).Where("checkin_id = ? AND details->'rule'->>'id' = ?", checkin.ID, rule.ID.String())
+	})
+}
+
+// EvaluatePatient runs the patient's effective rules against their values
+// outside a checkin: the latest reading of each vital type measured in the
+// last day, and the early warning score over them. It is EvaluateCheckin for
+// readings uploaded on their own, e.g. by a device; a rule alerts at most once
+// a day without a checkin. db may be a transaction.
+func (s *ThresholdRuleService) EvaluatePatient(db *gorm.DB, patientID uuid.UUID, trigger enums.RuleTrigger, metrics ...enums.RuleMetric) ([]models.Alert, error) {
+	var patient models.Patient
+	if err := db.First(&patient, "id = ?", patientID).Error; err != nil {
+		return nil, err
+	}
+
+	rules, err := s.effectiveRules(db, &patient)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	readings, err := recentPatientReadings(db, patient.ID)
+	if err != nil {
+		return nil, err
+	}
+	values := readingMetricValues(readings)
+	if ew := scoreEarlyWarning(readings); ew != nil {
+		values[enums.RuleMetricEarlyWarningScore] = float64(ew.Score)
+	}
+
+	since := time.Now().Add(-alertableReadingAge)
+	return raiseRuleAlerts(db, patient.ID, nil, matchRules(rules, values, metrics), trigger, func(rule *models.ThresholdRule) *gorm.DB {
+		return db.Model(&models.Alert{}).
+			Where("patient_id = ? AND checkin_id IS NULL AND created_at >= ? AND details->'rule'->>'id' = ?", patient.ID, since, rule.ID.String())
+	})
+}
+
+// ruleHit is a rule whose conditions all held.
+type ruleHit struct {
+	rule       models.ThresholdRule
+	conditions []models.RuleCondition
+	matches    []RuleMatch
+}
+
+// matchRules returns the rules whose conditions all hold for values. Only
+// rules that measure one of metrics are considered; pass none to match all.
+func matchRules(rules []models.ThresholdRule, values map[enums.RuleMetric]float64, metrics []enums.RuleMetric) []ruleHit {
+	var hits []ruleHit
+	for _, rule := range rules {
+		conditions, err := rule.ParsedConditions()
+		if err != nil || len(conditions) == 0 {
+			continue
+		}
+		if len(metrics) > 0 && !measuresAny(conditions, metrics) {
+			continue
+		}
+		if matches, ok := matchConditions(conditions, values); ok {
+			hits = append(hits, ruleHit{rule: rule, conditions: conditions, matches: matches})
+		}
+	}
+	return hits
+}
+
+// raiseRuleAlerts creates an alert for each hit, skipping rules for which
+// raised selects an earlier alert.
+func raiseRuleAlerts(db *gorm.DB, patientID uuid.UUID, checkinID *uuid.UUID, hits []ruleHit, trigger enums.RuleTrigger, raised func(*models.ThresholdRule) *gorm.DB) ([]models.Alert, error) {
+	var alerts []models.Alert
+	for _, hit := range hits {
+		var existing int64
+		if err := raised(&hit.rule).Count(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			continue
+		}
+
+		alert := ruleAlert(patientID, checkinID, &hit.rule, hit.conditions, hit.matches, trigger)
+		if err := db.Create(&alert).Error; err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// checkinMetricValues collects the latest numeric value per vital type in the
+// checkin, plus its analysis risk score and early warning score.
+func checkinMetricValues(db *gorm.DB, checkin *models.Checkin) (map[enums.RuleMetric]float64, error) {
+	var readings []models.VitalReading
+	if err := db.Where("checkin_id = ? AND value_numeric IS NOT NULL", checkin.ID).
+		Order("measured_at ASC").
+		Find(&readings).Error; err != nil {
+		return nil, err
+	}
+
+	values := readingMetricValues(readings)
+	if checkin.RiskScore != nil {
+		values[enums.RuleMetricRiskScore] = float64(*checkin.RiskScore)
+	}
+	if checkin.EarlyWarningScore != nil {
+		values[enums.RuleMetricEarlyWarningScore] = float64(*checkin.EarlyWarningScore)
+	}
+	return values, nil
+}
+
+// recentPatientReadings loads the patient's numeric readings measured in the
+// last day, in or outside checkins, oldest first.
+func recentPatientReadings(db *gorm.DB, patientID uuid.UUID) ([]models.VitalReading, error) {
+	var readings []models.VitalReading
+	err := db.Where("patient_id = ? AND value_numeric IS NOT NULL AND measured_at >= ?", patientID, time.Now().Add(-alertableReadingAge)).
+		Order("measured_at ASC").
+		Find(&readings).Error
+	return readings, err
+}
+
+// readingMetricValues takes the latest numeric value per vital type from
+// readings ordered oldest first, with the diastolic and mean arterial pressure
+// of the latest blood pressure.
+func readingMetricValues(readings []models.VitalReading) map[enums.RuleMetric]float64 {
+	values := make(map[enums.RuleMetric]float64, len(readings)+2)
+	for _, r := range readings {
+		if r.ValueNumeric == nil {
+			continue
+		}
+		values[enums.RuleMetric(r.VitalType)] = *r.ValueNumeric
+		if r.Diastolic != nil && r.MeanArterialPressure != nil {
+			values[enums.RuleMetricDiastolic] = *r.Diastolic
+			values[enums.RuleMetricMeanArterialPressure] = *r.MeanArterialPressure
+		}
+	}
+	return values
+}
+
+func matchConditions(conditions []models.RuleCondition, values map[enums.RuleMetric]float64) ([]RuleMatch, bool) {
+	matches := make([]RuleMatch, 0, len(conditions))
+	for _, c := range conditions {
+		actual, ok := values[c.Metric]
+		if !ok || !compare(actual, c.Operator, c.Value) {
+			return nil, false
+		}
+		matches = append(matches, RuleMatch{Metric: c.Metric, Operator: c.Operator, Threshold: c.Value, Actual: actual})
+	}
+	return matches, true
+}
+
+func compare(actual float64, op enums.RuleOperator, threshold float64) bool {
+	switch op {
+	case enums.RuleOperatorLT:
+		return actual < threshold
+	case enums.RuleOperatorLTE:
+		return actual <= threshold
+	case enums.RuleOperatorGT:
+		return actual > threshold
+	case enums.RuleOperatorGTE:
+		return actual >= threshold
+	case enums.RuleOperatorEQ:
+		return actual == threshold
+	case enums.RuleOperatorNEQ:
+		return actual != threshold
+	default:
+		return false
+	}
+}
+
+func measuresAny(conditions []models.RuleCondition, metrics []enums.RuleMetric) bool {
+	for _, c := range conditions {
+		for _, m := range metrics {
+			if c.Metric == m {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var operatorSymbols = map[enums.RuleOperator]string{
+	enums.RuleOperatorLT:  "<",
+	enums.RuleOperatorLTE: "<=",
+	enums.RuleOperatorGT:  ">",
+	enums.RuleOperatorGTE: ">=",
+	enums.RuleOperatorEQ:  "=",
+	enums.RuleOperatorNEQ: "!=",
+}
+
+func ruleAlert(patientID uuid.UUID, checkinID *uuid.UUID, rule *models.ThresholdRule, conditions []models.RuleCondition, matches []RuleMatch, trigger enums.RuleTrigger) models.Alert {
+	parts := make([]string, len(matches))
+	for i, m := range matches {
+		parts[i] = fmt.Sprintf("%s %s %s %s",
+			strings.ToLower(vitalLabel(enums.VitalType(m.Metric))), formatValue(m.Actual), operatorSymbols[m.Operator], formatValue(m.Threshold))
+	}
+
+	scope := "organization"
+	if rule.PatientID != nil {
+		scope = "patient"
+	}
+	details, _ := models.NewJSONB(map[string]interface{}{
+		"rule": map[string]interface{}{
+			"id":              rule.ID.String(),
+			"key":             rule.Key,
+			"name":            rule.Name,
+			"scope":           scope,
+			"organization_id": rule.OrganizationID,
+			"patient_id":      rule.PatientID,
+			"conditions":      conditions,
+		},
+		"matched": matches,
+		"trigger": trigger,
+	})
+
+	return models.Alert{
+		PatientID: patientID,
+		CheckinID: checkinID,
+		Severity:  rule.Severity,
+		AlertType: enums.AlertTypeThresholdRule,
+		Title:     rule.Name,
+		Message:   fmt.Sprintf("Rule %q matched: %s", rule.Name, strings.Join(parts, " and ")),
+		Details:   details,
+	}
+}
+
+func (s *ThresholdRuleService) validateRule(severity enums.AlertSeverity, conditions []models.RuleCondition) error {
+	var fields []errs.FieldError
+	if severityRank(severity) == 0 {
+		fields = append(fields, errs.FieldError{Field: "severity", Message: "must be one of [LOW MEDIUM HIGH CRITICAL]"})
+	}
+	if len(conditions) == 0 {
+		fields = append(fields, errs.FieldError{Field: "conditions", Message: "cannot be empty"})
+	}
+	for i, c := range conditions {
+		path := fmt.Sprintf("conditions[%d]", i)
+		if !s.isRuleMetric(c.Metric) {
+			fields = append(fields, errs.FieldError{Field: path + ".metric", Message: "must be a vital type, BLOOD_PRESSURE_DIASTOLIC, BLOOD_PRESSURE_MAP, RISK_SCORE or EARLY_WARNING_SCORE"})
+		}
+		if _, ok := operatorSymbols[c.Operator]; !ok {
+			fields = append(fields, errs.FieldError{Field: path + ".operator", Message: "must be one of [LT LTE GT GTE EQ NEQ]"})
+		}
+	}
+	if len(fields) > 0 {
+		return errs.Validation(fields...)
+	}
+	return nil
+}
+
+func (s *ThresholdRuleService) isRuleMetric(m enums.RuleMetric) bool {
+	switch m {
+	case enums.RuleMetricRiskScore, enums.RuleMetricEarlyWarningScore, enums.RuleMetricDiastolic, enums.RuleMetricMeanArterialPressure:
+		return true
+	}
+	return s.catalog.Known(enums.VitalType(m))
+}
+
+func (s *ThresholdRuleService) ensureKeyFree(orgID, patientID *uuid.UUID, key string, exceptID uuid.UUID) error {
+	query := s.db.Model(&models.ThresholdRule{}).Where("key = ? AND id <> ?", key, exceptID)
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	} else {
+		query = query.Where("patient_id = ?", *patientID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errs.ErrRuleKeyExists
+	}
+	return nil
+}
@@ -7,20 +7,24 @@ import (
 
 	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserService struct {
-	db   *gorm.DB
-	logg *slog.Logger
+	db      *gorm.DB
+	logg    *slog.Logger
+	catalog *VitalCatalog
 }
 
-func NewUserService(db *gorm.DB, lgr *slog.Logger) *UserService {
+func NewUserService(db *gorm.DB, lgr *slog.Logger, catalog *VitalCatalog) *UserService {
 	return &UserService{
-		db:   db,
-		logg: lgr,
+		db:      db,
+		logg:    lgr,
+		catalog: catalog,
 	}
 }
 
@@ -45,16 +49,23 @@ func (s *UserService) GetDoctorByID(id uuid.UUID) (*models.User, error) {
 	return &doctor, nil
 }
 
-func (s *UserService) ListDoctors(includeInactive bool) ([]models.User, error) {
-	var doctors []models.User
-	query := s.db.Where("role = ?", enums.UserRoleDoctor)
+var userPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"created_at": {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+		"last_name":  {Expr: "last_name", Field: "last_name", Kind: pagination.KindString},
+		"first_name": {Expr: "first_name", Field: "first_name", Kind: pagination.KindString},
+	},
+	DefaultSort: "created_at",
+	DateColumn:  "created_at",
+	IDColumn:    "id",
+}
+
+func (s *UserService) ListDoctors(includeInactive bool, page pagination.Params) (*pagination.Page[models.User], error) {
+	query := s.db.Model(&models.User{}).Where("role = ?", enums.UserRoleDoctor)
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
 	}
-	if err := query.Order("created_at DESC").Find(&doctors).Error; err != nil {
-		return nil, err
-	}
-	return doctors, nil
+	return pagination.Paginate[models.User](query, page, userPageSpec)
 }
 
 type DoctorUpdate struct {
@@ -201,16 +212,23 @@ func (s *UserService) UnassignFromOrganization(doctorID, organizationID uuid.UUI
 	})
 }
 
-func (s *UserService) ListDoctorOrganizations(doctorID uuid.UUID, includeInactive bool) ([]models.OrganizationDoctor, error) {
-	var relations []models.OrganizationDoctor
-	query := s.db.Preload("Organization").Where("doctor_id = ?", doctorID)
+var doctorOrganizationPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"joined_at": {Expr: "joined_at", Field: "joined_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "joined_at",
+	DateColumn:  "joined_at",
+	IDColumn:    "id",
+}
+
+func (s *UserService) ListDoctorOrganizations(doctorID uuid.UUID, includeInactive bool, page pagination.Params) (*pagination.Page[models.OrganizationDoctor], error) {
+	query := s.db.Model(&models.OrganizationDoctor{}).Where("doctor_id = ?", doctorID)
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
 	}
-	if err := query.Find(&relations).Error; err != nil {
-		return nil, err
-	}
-	return relations, nil
+	return pagination.Paginate[models.OrganizationDoctor](query, page, doctorOrganizationPageSpec, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Organization")
+	})
 }
 
 // Patient flows
@@ -314,7 +332,7 @@ type PatientMedicalInput struct {
 	Comorbidities            []string
 	CurrentMedications       models.JSONB
 	Allergies                []string
-	BaselineVitals           models.JSONB
+	BaselineVitals           models.BaselineVitals
 	RiskLevel                *enums.RiskLevel
 	MonitoringFrequency      *enums.MonitoringFrequency
 	Status                   *enums.PatientStatus
@@ -326,6 +344,17 @@ type PatientMedicalInput struct {
 }
 
 func (s *UserService) CreatePatientMedicalInfo(userID uuid.UUID, input PatientMedicalInput) (*models.Patient, error) {
+	if err := s.catalog.validateBaselines(input.BaselineVitals); err != nil {
+		return nil, err
+	}
+	var baselineVitals models.JSONB
+	if len(input.BaselineVitals) > 0 {
+		var err error
+		if baselineVitals, err = models.NewJSONB(input.BaselineVitals); err != nil {
+			return nil, err
+		}
+	}
+
 	var patient models.Patient
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
@@ -342,7 +371,7 @@ func (s *UserService) CreatePatientMedicalInfo(userID uuid.UUID, input PatientMe
 		// prevent duplicate patient record
 		var existing models.Patient
 		if err := tx.First(&existing, "user_id = ?", userID).Error; err == nil {
-			return errors.New("patient medical info already exists")
+			return errs.ErrPatientInfoExists
 		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
 		}
@@ -354,7 +383,7 @@ func (s *UserService) CreatePatientMedicalInfo(userID uuid.UUID, input PatientMe
 			Comorbidities:            models.StringArray(input.Comorbidities),
 			CurrentMedications:       input.CurrentMedications,
 			Allergies:                models.StringArray(input.Allergies),
-			BaselineVitals:           input.BaselineVitals,
+			BaselineVitals:           baselineVitals,
 			DischargeDate:            input.DischargeDate,
 			DischargeNotes:           input.DischargeNotes,
 			EmergencyContactName:     input.EmergencyContactName,
@@ -394,7 +423,7 @@ type PatientMedicalUpdate struct {
 	Comorbidities            *[]string
 	CurrentMedications       *models.JSONB
 	Allergies                *[]string
-	BaselineVitals           *models.JSONB
+	BaselineVitals           *models.BaselineVitals
 	RiskLevel                *enums.RiskLevel
 	MonitoringFrequency      *enums.MonitoringFrequency
 	Status                   *enums.PatientStatus
@@ -405,11 +434,15 @@ type PatientMedicalUpdate struct {
 	EmergencyContactRelation *string
 }
 
-func (s *UserService) UpdatePatientMedicalInfo(userID uuid.UUID, input PatientMedicalUpdate) (*models.Patient, error) {
+// UpdatePatientMedicalInfo applies input only if the record is still at version.
+func (s *UserService) UpdatePatientMedicalInfo(userID uuid.UUID, version int, input PatientMedicalUpdate) (*models.Patient, error) {
 	var patient models.Patient
 	if err := s.db.First(&patient, "user_id = ?", userID).Error; err != nil {
 		return nil, err
 	}
+	if err := checkVersion(patient.Version, version); err != nil {
+		return nil, err
+	}
 
 	updates := map[string]interface{}{}
 	if input.DoctorID != nil {
@@ -431,7 +464,14 @@ func (s *UserService) UpdatePatientMedicalInfo(userID uuid.UUID, input PatientMe
 		updates["allergies"] = models.StringArray(*input.Allergies)
 	}
 	if input.BaselineVitals != nil {
-		updates["baseline_vitals"] = *input.BaselineVitals
+		if err := s.catalog.validateBaselines(*input.BaselineVitals); err != nil {
+			return nil, err
+		}
+		baselineVitals, err := models.NewJSONB(*input.BaselineVitals)
+		if err != nil {
+			return nil, err
+		}
+		updates["baseline_vitals"] = baselineVitals
 	}
 	if input.RiskLevel != nil {
 		updates["risk_level"] = *input.RiskLevel
@@ -462,7 +502,7 @@ func (s *UserService) UpdatePatientMedicalInfo(userID uuid.UUID, input PatientMe
 		return &patient, nil
 	}
 
-	if err := s.db.Model(&patient).Updates(updates).Error; err != nil {
+	if err := updateVersioned(s.db, &models.Patient{}, patient.ID, &version, updates); err != nil {
 		return nil, err
 	}
 
@@ -481,16 +521,12 @@ func (s *UserService) GetPatientDetailsByUserID(userID uuid.UUID) (*models.Patie
 	return &patient, nil
 }
 
-func (s *UserService) ListPatientUsers(includeInactive bool) ([]models.User, error) {
-	var patients []models.User
-	query := s.db.Where("role = ?", enums.UserRolePatient)
+func (s *UserService) ListPatientUsers(includeInactive bool, page pagination.Params) (*pagination.Page[models.User], error) {
+	query := s.db.Model(&models.User{}).Where("role = ?", enums.UserRolePatient)
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
 	}
-	if err := query.Order("created_at DESC").Find(&patients).Error; err != nil {
-		return nil, err
-	}
-	return patients, nil
+	return pagination.Paginate[models.User](query, page, userPageSpec)
 }
 
 func (s *UserService) GetUserByTelegramUsername(username string) (*models.User, error) {
@@ -502,14 +538,16 @@ func (s *UserService) GetUserByTelegramUsername(username string) (*models.User,
 }
 
 type PatientCompleteData struct {
-	User          *models.User            `json:"user"`
-	Patient       *models.Patient         `json:"patient"`
-	Schedule      *models.CheckinSchedule `json:"schedule"`
-	Checkins      []models.Checkin        `json:"checkins"`
-	VitalReadings []models.VitalReading   `json:"vital_readings"`
+	User          *models.User                          `json:"user"`
+	Patient       *models.Patient                       `json:"patient"`
+	Schedule      *models.CheckinSchedule               `json:"schedule"`
+	Checkins      *pagination.Page[models.Checkin]      `json:"checkins"`
+	VitalReadings *pagination.Page[models.VitalReading] `json:"vital_readings"`
 }
 
-func (s *UserService) GetPatientCompleteData(userID uuid.UUID) (*PatientCompleteData, error) {
+// GetPatientCompleteData returns the patient's profile with the first page of
+// their checkins and vital readings; the same limit and date range apply to both.
+func (s *UserService) GetPatientCompleteData(userID uuid.UUID, page pagination.Params) (*PatientCompleteData, error) {
 	var user models.User
 	if err := s.db.First(&user, "id = ? AND role = ?", userID, enums.UserRolePatient).Error; err != nil {
 		return nil, err
@@ -520,31 +558,36 @@ func (s *UserService) GetPatientCompleteData(userID uuid.UUID) (*PatientComplete
 		return nil, err
 	}
 
-	var schedule models.CheckinSchedule
-	if err := s.db.First(&schedule, "patient_id = ? AND is_active = ?", patient.ID, true).Error; err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
+	var schedule *models.CheckinSchedule
+	var active models.CheckinSchedule
+	if err := s.db.First(&active, "patient_id = ? AND is_active = ?", patient.ID, true).Error; err == nil {
+		schedule = &active
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
 
-	var checkins []models.Checkin
-	if err := s.db.Where("patient_id = ?", patient.ID).
-		Order("created_at DESC").
-		Find(&checkins).Error; err != nil {
+	// cursors are per list, so only the first page is served from here
+	page.Cursor = ""
+	page.Sort = ""
+
+	checkins, err := pagination.Paginate[models.Checkin](
+		s.db.Model(&models.Checkin{}).Where("patient_id = ?", patient.ID), page, checkinPageSpec,
+	)
+	if err != nil {
 		return nil, err
 	}
 
-	var vitals []models.VitalReading
-	if err := s.db.Where("patient_id = ?", patient.ID).
-		Order("created_at DESC").
-		Find(&vitals).Error; err != nil {
+	vitals, err := pagination.Paginate[models.VitalReading](
+		s.db.Model(&models.VitalReading{}).Where("patient_id = ?", patient.ID), page, vitalReadingPageSpec,
+	)
+	if err != nil {
 		return nil, err
 	}
 
 	return &PatientCompleteData{
 		User:          &user,
 		Patient:       &patient,
-		Schedule:      &schedule,
+		Schedule:      schedule,
 		Checkins:      checkins,
 		VitalReadings: vitals,
 	}, nil
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// updateVersioned applies updates to the row with the given id and bumps its
+// version. With expectedVersion set, the row is only written while it is still
+// at that version, otherwise ErrVersionMismatch is returned. Internal writes
+// pass nil: they never conflict but still invalidate ETags held by clients.
+func updateVersioned(db *gorm.DB, model interface{}, id uuid.UUID, expectedVersion *int, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+
+	query := db.Model(model).Where("id = ?", id)
+	if expectedVersion != nil {
+		query = query.Where("version = ?", *expectedVersion)
+	}
+
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if expectedVersion != nil {
+			return errs.ErrVersionMismatch
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// checkVersion rejects a request made against a stale copy before any work is done.
+func checkVersion(current, expected int) error {
+	if current != expected {
+		return errs.ErrVersionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+)
+
+const defaultMaxDeviationPercent = 20
+
+// VitalRange bounds a vital type in its reference unit. Nil bounds are not checked.
+type VitalRange struct {
+	Unit                enums.VitalUnit
+	Low                 *float64
+	High                *float64
+	CriticalLow         *float64
+	CriticalHigh        *float64
+	MaxDeviationPercent *float64
+}
+
+// diastolicConfigKey addresses the diastolic range in config.Vitals.Ranges.
+const diastolicConfigKey = "BLOOD_PRESSURE_DIASTOLIC"
+
+var defaultDiastolicRange = VitalRange{Unit: enums.VitalUnitMmHg, Low: ptr(60.0), High: ptr(90.0), CriticalLow: ptr(40.0), CriticalHigh: ptr(120.0)}
+
+// VitalThresholds decides whether a reading is abnormal, from absolute ranges
+// and from how far it drifts from the patient's baseline. Ranges come from
+// the vital type catalog, with config overrides on top.
+type VitalThresholds struct {
+	catalog             *VitalCatalog
+	overrides           map[enums.VitalType]config.VitalRange
+	diastolic           VitalRange
+	maxDeviationPercent float64
+}
+
+func NewVitalThresholds(cfg config.Vitals, catalog *VitalCatalog) *VitalThresholds {
+	t := &VitalThresholds{
+		catalog:             catalog,
+		overrides:           map[enums.VitalType]config.VitalRange{},
+		diastolic:           defaultDiastolicRange,
+		maxDeviationPercent: defaultMaxDeviationPercent,
+	}
+	if cfg.MaxDeviationPercent > 0 {
+		t.maxDeviationPercent = cfg.MaxDeviationPercent
+	}
+
+	for key, override := range cfg.Ranges {
+		key = strings.ToUpper(key)
+		if key == diastolicConfigKey {
+			t.diastolic = overrideRange(t.diastolic, override)
+			continue
+		}
+		t.overrides[enums.VitalType(key)] = override
+	}
+
+	return t
+}
+
+func (t *VitalThresholds) rangeFor(vitalType enums.VitalType) (VitalRange, bool) {
+	e, ok := t.catalog.lookup(vitalType)
+	if !ok {
+		return VitalRange{}, false
+	}
+	r := e.reference()
+	if override, ok := t.overrides[vitalType]; ok {
+		r = overrideRange(r, override)
+	}
+	return r, true
+}
+
+func overrideRange(r VitalRange, override config.VitalRange) VitalRange {
+	if override.Low != nil {
+		r.Low = override.Low
+	}
+	if override.High != nil {
+		r.High = override.High
+	}
+	if override.CriticalLow != nil {
+		r.CriticalLow = override.CriticalLow
+	}
+	if override.CriticalHigh != nil {
+		r.CriticalHigh = override.CriticalHigh
+	}
+	if override.MaxDeviationPercent != nil {
+		r.MaxDeviationPercent = override.MaxDeviationPercent
+	}
+	return r
+}
+
+// VitalFinding is one crossed threshold. Rule is one of critical_low, low,
+// high, critical_high or baseline_deviation.
+type VitalFinding struct {
+	Rule    string  `json:"rule"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
+}
+
+type VitalAssessment struct {
+	// Evaluated is false when the reading has no numeric value to check.
+	Evaluated  bool
+	IsAbnormal bool
+	Severity   enums.AlertSeverity
+	// Deviation is the value minus the baseline, in the reading's unit.
+	Deviation        *float64
+	DeviationPercent *float64
+	Findings         []VitalFinding
+}
+
+// Assess checks value against the absolute range for vitalType and against
+// baseline. Absolute ranges and baselines are only compared when the reading
+// is in the range's reference unit (or has no unit).
+func (t *VitalThresholds) Assess(vitalType enums.VitalType, value *float64, unit *enums.VitalUnit, baseline *models.VitalBaseline) VitalAssessment {
+	var a VitalAssessment
+	if value == nil {
+		return a
+	}
+	a.Evaluated = true
+
+	r, known := t.rangeFor(vitalType)
+	if known && unit != nil && *unit != r.Unit {
+		return a
+	}
+
+	label := t.catalog.label(vitalType)
+	if known {
+		a.checkRange(label, *value, r)
+	}
+	if baseline != nil {
+		a.Deviation, a.DeviationPercent = a.checkDeviation(label, *value, baseline.Value, t.tolerance(r, baseline))
+	}
+
+	return a
+}
+
+// AssessBloodPressure checks systolic like Assess does for BLOOD_PRESSURE and
+// adds the diastolic range and, when the baseline has one, diastolic drift.
+func (t *VitalThresholds) AssessBloodPressure(systolic, diastolic float64, baseline *models.VitalBaseline) VitalAssessment {
+	unit := enums.VitalUnitMmHg
+	a := t.Assess(enums.VitalTypeBloodPressure, &systolic, &unit, baseline)
+
+	a.checkRange("Diastolic pressure", diastolic, t.diastolic)
+	if baseline != nil && baseline.Diastolic != nil {
+		a.checkDeviation("Diastolic pressure", diastolic, *baseline.Diastolic, t.tolerance(t.diastolic, baseline))
+	}
+
+	return a
+}
+
+func (t *VitalThresholds) tolerance(r VitalRange, baseline *models.VitalBaseline) float64 {
+	tolerance := t.maxDeviationPercent
+	if r.MaxDeviationPercent != nil {
+		tolerance = *r.MaxDeviationPercent
+	}
+	if baseline.MaxDeviationPercent != nil {
+		tolerance = *baseline.MaxDeviationPercent
+	}
+	return tolerance
+}
+
+func (a *VitalAssessment) checkRange(label string, v float64, r VitalRange) {
+	switch {
+	case r.CriticalLow != nil && v < *r.CriticalLow:
+		a.add(enums.AlertSeverityCritical, VitalFinding{Rule: "critical_low", Limit: *r.CriticalLow,
+			Message: fmt.Sprintf("%s %s is below the critical limit of %s", label, formatValue(v), formatValue(*r.CriticalLow))})
+	case r.CriticalHigh != nil && v > *r.CriticalHigh:
+		a.add(enums.AlertSeverityCritical, VitalFinding{Rule: "critical_high", Limit: *r.CriticalHigh,
+			Message: fmt.Sprintf("%s %s is above the critical limit of %s", label, formatValue(v), formatValue(*r.CriticalHigh))})
+	case r.Low != nil && v < *r.Low:
+		a.add(enums.AlertSeverityHigh, VitalFinding{Rule: "low", Limit: *r.Low,
+			Message: fmt.Sprintf("%s %s is below the normal range (%s)", label, formatValue(v), formatValue(*r.Low))})
+	case r.High != nil && v > *r.High:
+		a.add(enums.AlertSeverityHigh, VitalFinding{Rule: "high", Limit: *r.High,
+			Message: fmt.Sprintf("%s %s is above the normal range (%s)", label, formatValue(v), formatValue(*r.High))})
+	}
+}
+
+// checkDeviation flags drift from base beyond tolerance percent and returns
+// the signed deviation and its absolute percentage.
+func (a *VitalAssessment) checkDeviation(label string, v, base, tolerance float64) (*float64, *float64) {
+	deviation := round2(v - base)
+	if base == 0 {
+		return &deviation, nil
+	}
+
+	percent := round2(math.Abs(deviation) / base * 100)
+	if percent > tolerance {
+		a.add(enums.AlertSeverityMedium, VitalFinding{Rule: "baseline_deviation", Limit: tolerance,
+			Message: fmt.Sprintf("%s %s deviates %s%% from the baseline of %s (tolerance %s%%)",
+				label, formatValue(v), formatValue(percent), formatValue(base), formatValue(tolerance))})
+	}
+	return &deviation, &percent
+}
+
+func (a *VitalAssessment) add(severity enums.AlertSeverity, finding VitalFinding) {
+	a.IsAbnormal = true
+	a.Findings = append(a.Findings, finding)
+	if severityRank(severity) > severityRank(a.Severity) {
+		a.Severity = severity
+	}
+}
+
+// validateBaselines checks a baseline document before it is stored on a
+// patient, converting values given in another unit to the canonical one.
+func (c *VitalCatalog) validateBaselines(baselines models.BaselineVitals) error {
+	var fields []errs.FieldError
+	for vitalType, b := range baselines {
+		path := "baseline_vitals." + string(vitalType)
+		e, ok := c.lookup(vitalType)
+		if !ok {
+			fields = append(fields, errs.FieldError{Field: path, Message: "is not a known vital type"})
+			continue
+		}
+		if b.Unit != nil {
+			unit, conv, err := resolveUnit(path+".unit", e, b.Unit)
+			if err != nil {
+				fields = append(fields, errs.As(err).Fields...)
+				continue
+			}
+			if unit != e.CanonicalUnit {
+				b.Value = toCanonical(conv, b.Value)
+			}
+			b.Unit = nil
+			baselines[vitalType] = b
+		}
+		if b.Value <= 0 {
+			fields = append(fields, errs.FieldError{Field: path + ".value", Message: "must be greater than 0"})
+		}
+		if b.Diastolic != nil {
+			if vitalType != enums.VitalTypeBloodPressure {
+				fields = append(fields, errs.FieldError{Field: path + ".diastolic", Message: "only applies to BLOOD_PRESSURE"})
+			} else if *b.Diastolic <= 0 || *b.Diastolic >= b.Value {
+				fields = append(fields, errs.FieldError{Field: path + ".diastolic", Message: "must be greater than 0 and lower than value"})
+			}
+		}
+		if b.MaxDeviationPercent != nil && (*b.MaxDeviationPercent <= 0 || *b.MaxDeviationPercent > 100) {
+			fields = append(fields, errs.FieldError{Field: path + ".max_deviation_percent", Message: "must be greater than 0 and at most 100"})
+		}
+	}
+	if len(fields) > 0 {
+		return errs.Validation(fields...)
+	}
+	return nil
+}
+
+func severityRank(s enums.AlertSeverity) int {
+	switch s {
+	case enums.AlertSeverityLow:
+		return 1
+	case enums.AlertSeverityMedium:
+		return 2
+	case enums.AlertSeverityHigh:
+		return 3
+	case enums.AlertSeverityCritical:
+		return 4
+	default:
+		return 0
+	}
+}
+
+func vitalLabel(t enums.VitalType) string {
+	label := strings.ToLower(strings.ReplaceAll(string(t), "_", " "))
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+func formatValue(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,481 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// vitalCatalogTTL bounds how long a catalog change on another instance takes
+// to be picked up.
+const vitalCatalogTTL = time.Minute
+
+// builtinVitalTypes are seeded on startup. Reference ranges are for adults;
+// the blood pressure entry is for systolic, see defaultDiastolicRange.
+func builtinVitalTypes() []models.VitalTypeDefinition {
+	return []models.VitalTypeDefinition{
+		builtin(enums.VitalTypeBloodPressure, "Blood pressure", enums.VitalUnitMmHg, nil, nil,
+			VitalRange{Low: ptr(90.0), High: ptr(140.0), CriticalLow: ptr(80.0), CriticalHigh: ptr(180.0)}, minSystolic, maxSystolic),
+		builtin(enums.VitalTypeGlucose, "Blood glucose", enums.VitalUnitMgDL,
+			models.VitalUnitConversions{enums.VitalUnitMmolL: {Factor: 1 / 18.0182}},
+			[]string{"FASTING", "POST_MEAL", "RANDOM", "BEDTIME"},
+			VitalRange{Low: ptr(70.0), High: ptr(180.0), CriticalLow: ptr(54.0), CriticalHigh: ptr(300.0)}, 10, 1000),
+		builtin(enums.VitalTypeHeartRate, "Heart rate", enums.VitalUnitBPM, nil, nil,
+			VitalRange{Low: ptr(50.0), High: ptr(110.0), CriticalLow: ptr(40.0), CriticalHigh: ptr(130.0)}, 20, 250),
+		builtin(enums.VitalTypeTemperature, "Temperature", enums.VitalUnitCelsius,
+			models.VitalUnitConversions{enums.VitalUnitFahrenheit: {Factor: 1.8, Offset: 32}}, nil,
+			VitalRange{Low: ptr(35.5), High: ptr(38.0), CriticalLow: ptr(35.0), CriticalHigh: ptr(39.5)}, 30, 45),
+		builtin(enums.VitalTypeWeight, "Weight", enums.VitalUnitKg,
+			models.VitalUnitConversions{enums.VitalUnitLb: {Factor: 2.20462}}, nil,
+			VitalRange{MaxDeviationPercent: ptr(3.0)}, 1, 500),
+		builtin(enums.VitalTypeOxygenSaturation, "Oxygen saturation", enums.VitalUnitPercent, nil, nil,
+			VitalRange{Low: ptr(92.0), CriticalLow: ptr(88.0)}, 50, 100),
+		builtin(enums.VitalTypeRespiratoryRate, "Respiratory rate", enums.VitalUnitBreaths, nil, nil,
+			VitalRange{Low: ptr(12.0), High: ptr(20.0), CriticalLow: ptr(8.0), CriticalHigh: ptr(25.0)}, 2, 80),
+		builtin(enums.VitalTypePainScore, "Pain score", enums.VitalUnitScore, nil, nil,
+			VitalRange{High: ptr(4.0), CriticalHigh: ptr(7.0)}, 0, 10),
+		builtin(enums.VitalTypePeakFlow, "Peak flow", enums.VitalUnitLPerMin, nil, nil,
+			VitalRange{MaxDeviationPercent: ptr(20.0)}, 50, 900),
+		builtin(enums.VitalTypeHbA1c, "HbA1c", enums.VitalUnitPercent,
+			models.VitalUnitConversions{enums.VitalUnitMmolMol: {Factor: 10.929, Offset: -23.5}}, nil,
+			VitalRange{High: ptr(7.0), CriticalHigh: ptr(10.0)}, 3, 20),
+		builtin(enums.VitalTypeUrineOutput, "Urine output (24h)", enums.VitalUnitMl,
+			models.VitalUnitConversions{enums.VitalUnitLiter: {Factor: 0.001}}, nil,
+			VitalRange{Low: ptr(800.0), CriticalLow: ptr(400.0)}, 0, 10000),
+		builtin(enums.VitalTypeSleepHours, "Sleep", enums.VitalUnitHours,
+			models.VitalUnitConversions{enums.VitalUnitMinutes: {Factor: 60}}, nil,
+			VitalRange{Low: ptr(5.0), High: ptr(12.0)}, 0, 24),
+		builtin(enums.VitalTypeSteps, "Daily steps", enums.VitalUnitSteps, nil, nil,
+			VitalRange{MaxDeviationPercent: ptr(50.0)}, 0, 100000),
+	}
+}
+
+func builtin(code enums.VitalType, name string, canonical enums.VitalUnit, units models.VitalUnitConversions,
+	contexts []string, r VitalRange, min, max float64) models.VitalTypeDefinition {
+	encoded, _ := models.NewJSONB(units)
+	return models.VitalTypeDefinition{
+		Code:                code,
+		DisplayName:         name,
+		CanonicalUnit:       canonical,
+		Units:               encoded,
+		Contexts:            contexts,
+		MinValue:            &min,
+		MaxValue:            &max,
+		Low:                 r.Low,
+		High:                r.High,
+		CriticalLow:         r.CriticalLow,
+		CriticalHigh:        r.CriticalHigh,
+		MaxDeviationPercent: r.MaxDeviationPercent,
+		IsBuiltin:           true,
+		IsActive:            true,
+	}
+}
+
+// vitalTypeEntry is a catalog row with its units decoded; units includes the
+// canonical unit.
+type vitalTypeEntry struct {
+	models.VitalTypeDefinition
+	units map[enums.VitalUnit]models.UnitConversion
+}
+
+func newVitalTypeEntry(def models.VitalTypeDefinition) *vitalTypeEntry {
+	e := &vitalTypeEntry{VitalTypeDefinition: def, units: map[enums.VitalUnit]models.UnitConversion{}}
+	// a row with undecodable units still accepts its canonical unit
+	if conversions, err := def.Conversions(); err == nil {
+		for unit, conv := range conversions {
+			e.units[unit] = conv
+		}
+	}
+	e.units[def.CanonicalUnit] = identity
+	return e
+}
+
+func (e *vitalTypeEntry) reference() VitalRange {
+	return VitalRange{
+		Unit:                e.CanonicalUnit,
+		Low:                 e.Low,
+		High:                e.High,
+		CriticalLow:         e.CriticalLow,
+		CriticalHigh:        e.CriticalHigh,
+		MaxDeviationPercent: e.MaxDeviationPercent,
+	}
+}
+
+func (e *vitalTypeEntry) allowsContext(context string) bool {
+	for _, c := range e.Contexts {
+		if c == context {
+			return true
+		}
+	}
+	return false
+}
+
+// VitalCatalog serves the vital type catalog, cached in memory so readings do
+// not query it one by one.
+type VitalCatalog struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	entries  map[enums.VitalType]*vitalTypeEntry
+	loadedAt time.Time
+}
+
+func NewVitalCatalog(db *gorm.DB) *VitalCatalog {
+	return &VitalCatalog{db: db, entries: map[enums.VitalType]*vitalTypeEntry{}}
+}
+
+// Seed inserts missing built-in types and loads the catalog. Existing rows
+// are left alone so edits to built-in ranges survive restarts.
+func (c *VitalCatalog) Seed() error {
+	builtins := builtinVitalTypes()
+	if err := c.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&builtins).Error; err != nil {
+		return err
+	}
+	return c.reload()
+}
+
+func (c *VitalCatalog) reload() error {
+	var defs []models.VitalTypeDefinition
+	if err := c.db.Find(&defs).Error; err != nil {
+		return err
+	}
+
+	entries := make(map[enums.VitalType]*vitalTypeEntry, len(defs))
+	for _, def := range defs {
+		entries[def.Code] = newVitalTypeEntry(def)
+	}
+
+	c.mu.Lock()
+	c.entries = entries
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// lookup returns the entry for code, active or not. A failed refresh keeps
+// serving the previous snapshot.
+func (c *VitalCatalog) lookup(code enums.VitalType) (*vitalTypeEntry, bool) {
+	c.mu.RLock()
+	stale := time.Since(c.loadedAt) > vitalCatalogTTL
+	c.mu.RUnlock()
+	if stale {
+		_ = c.reload()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[code]
+	return e, ok
+}
+
+// snapshot returns every entry, refreshing a stale cache first.
+func (c *VitalCatalog) snapshot() map[enums.VitalType]*vitalTypeEntry {
+	c.lookup("")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.entries
+}
+
+// label is the display name of code, falling back to a humanised code.
+func (c *VitalCatalog) label(code enums.VitalType) string {
+	if e, ok := c.lookup(code); ok {
+		return e.DisplayName
+	}
+	return vitalLabel(code)
+}
+
+func (c *VitalCatalog) canonicalUnit(code enums.VitalType) (enums.VitalUnit, bool) {
+	if e, ok := c.lookup(code); ok {
+		return e.CanonicalUnit, true
+	}
+	return "", false
+}
+
+// Known reports whether code is a catalog entry.
+func (c *VitalCatalog) Known(code enums.VitalType) bool {
+	_, ok := c.lookup(code)
+	return ok
+}
+
+func (c *VitalCatalog) GetByCode(code enums.VitalType) (*models.VitalTypeDefinition, error) {
+	var def models.VitalTypeDefinition
+	if err := c.db.First(&def, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// List returns the catalog ordered by code; it is small enough not to page.
+func (c *VitalCatalog) List(includeInactive bool) ([]models.VitalTypeDefinition, error) {
+	query := c.db.Order("code")
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	var defs []models.VitalTypeDefinition
+	if err := query.Find(&defs).Error; err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+type VitalTypeInput struct {
+	Code          enums.VitalType
+	DisplayName   string
+	Description   *string
+	CanonicalUnit enums.VitalUnit
+	Units         models.VitalUnitConversions
+	Contexts      []string
+	MinValue      *float64
+	MaxValue      *float64
+	Range         VitalRange
+	IsActive      *bool
+}
+
+var (
+	vitalTypeCode   = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,49}$`)
+	vitalContextKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,29}$`)
+)
+
+func (c *VitalCatalog) Create(input VitalTypeInput) (*models.VitalTypeDefinition, error) {
+	def := models.VitalTypeDefinition{
+		Code:          enums.VitalType(strings.ToUpper(strings.TrimSpace(string(input.Code)))),
+		DisplayName:   input.DisplayName,
+		Description:   input.Description,
+		CanonicalUnit: normalizeUnit(input.CanonicalUnit),
+		Contexts:      input.Contexts,
+		MinValue:      input.MinValue,
+		MaxValue:      input.MaxValue,
+		IsActive:      true,
+	}
+	applyRange(&def, input.Range)
+	if input.IsActive != nil {
+		def.IsActive = *input.IsActive
+	}
+
+	units, err := normalizeConversions(input.Units)
+	if err != nil {
+		return nil, err
+	}
+	def.Units, err = models.NewJSONB(units)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateVitalType(&def, units); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := c.db.Model(&models.VitalTypeDefinition{}).Where("code = ?", def.Code).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errs.ErrVitalTypeExists
+	}
+
+	if err := c.db.Create(&def).Error; err != nil {
+		return nil, err
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return &def, nil
+}
+
+type UpdateVitalTypeInput struct {
+	DisplayName *string
+	Description *string
+	Units       *models.VitalUnitConversions
+	Contexts    *[]string
+	MinValue    *float64
+	MaxValue    *float64
+	Range       *VitalRange
+	IsActive    *bool
+}
+
+// Update changes a catalog entry. The code and canonical unit are fixed once
+// readings may have been stored against them.
+func (c *VitalCatalog) Update(code enums.VitalType, input UpdateVitalTypeInput) (*models.VitalTypeDefinition, error) {
+	def, err := c.GetByCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *def
+	if input.DisplayName != nil {
+		updated.DisplayName = *input.DisplayName
+	}
+	if input.Description != nil {
+		updated.Description = input.Description
+	}
+	if input.Contexts != nil {
+		updated.Contexts = *input.Contexts
+	}
+	if input.MinValue != nil {
+		updated.MinValue = input.MinValue
+	}
+	if input.MaxValue != nil {
+		updated.MaxValue = input.MaxValue
+	}
+	if input.Range != nil {
+		applyRange(&updated, *input.Range)
+	}
+	if input.IsActive != nil {
+		updated.IsActive = *input.IsActive
+	}
+
+	units, err := def.Conversions()
+	if err != nil {
+		return nil, err
+	}
+	if input.Units != nil {
+		if units, err = normalizeConversions(*input.Units); err != nil {
+			return nil, err
+		}
+		if updated.Units, err = models.NewJSONB(units); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateVitalType(&updated, units); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"display_name":          updated.DisplayName,
+		"description":           updated.Description,
+		"units":                 updated.Units,
+		"contexts":              updated.Contexts,
+		"min_value":             updated.MinValue,
+		"max_value":             updated.MaxValue,
+		"low":                   updated.Low,
+		"high":                  updated.High,
+		"critical_low":          updated.CriticalLow,
+		"critical_high":         updated.CriticalHigh,
+		"max_deviation_percent": updated.MaxDeviationPercent,
+		"is_active":             updated.IsActive,
+		"updated_at":            time.Now(),
+	}
+	if err := c.db.Model(def).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c.GetByCode(code)
+}
+
+// Delete removes a custom type that has never been used.
+func (c *VitalCatalog) Delete(code enums.VitalType) error {
+	def, err := c.GetByCode(code)
+	if err != nil {
+		return err
+	}
+	if def.IsBuiltin {
+		return errs.ErrVitalTypeBuiltin
+	}
+
+	var count int64
+	if err := c.db.Model(&models.VitalReading{}).Where("vital_type = ?", code).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errs.ErrVitalTypeInUse
+	}
+
+	if err := c.db.Delete(&models.VitalTypeDefinition{}, "code = ?", code).Error; err != nil {
+		return err
+	}
+	return c.reload()
+}
+
+// applyRange replaces the whole reference range; omitted bounds are cleared.
+func applyRange(def *models.VitalTypeDefinition, r VitalRange) {
+	def.Low = r.Low
+	def.High = r.High
+	def.CriticalLow = r.CriticalLow
+	def.CriticalHigh = r.CriticalHigh
+	def.MaxDeviationPercent = r.MaxDeviationPercent
+}
+
+func normalizeConversions(in models.VitalUnitConversions) (models.VitalUnitConversions, error) {
+	out := make(models.VitalUnitConversions, len(in))
+	for unit, conv := range in {
+		if conv.Factor == 0 {
+			return nil, errs.InvalidField("units."+string(unit)+".factor", "must not be 0")
+		}
+		out[normalizeUnit(unit)] = conv
+	}
+	return out, nil
+}
+
+func validateVitalType(def *models.VitalTypeDefinition, units models.VitalUnitConversions) error {
+	var fields []errs.FieldError
+	if !vitalTypeCode.MatchString(string(def.Code)) {
+		fields = append(fields, errs.FieldError{Field: "code", Message: "must be 2-50 upper case letters, digits or underscores, starting with a letter"})
+	}
+	if strings.TrimSpace(def.DisplayName) == "" {
+		fields = append(fields, errs.FieldError{Field: "display_name", Message: "is required"})
+	}
+	if def.CanonicalUnit == "" {
+		fields = append(fields, errs.FieldError{Field: "canonical_unit", Message: "is required"})
+	}
+	if _, ok := units[def.CanonicalUnit]; ok {
+		fields = append(fields, errs.FieldError{Field: "units", Message: "must not repeat the canonical unit"})
+	}
+	if def.MinValue != nil && def.MaxValue != nil && *def.MinValue >= *def.MaxValue {
+		fields = append(fields, errs.FieldError{Field: "max_value", Message: "must be greater than min_value"})
+	}
+
+	ordered := []struct {
+		field string
+		value *float64
+	}{
+		{"critical_low", def.CriticalLow}, {"low", def.Low}, {"high", def.High}, {"critical_high", def.CriticalHigh},
+	}
+	var prev *float64
+	var prevField string
+	for _, b := range ordered {
+		if b.value == nil {
+			continue
+		}
+		if prev != nil && *b.value <= *prev {
+			fields = append(fields, errs.FieldError{Field: b.field, Message: fmt.Sprintf("must be greater than %s", prevField)})
+		}
+		prev, prevField = b.value, b.field
+	}
+	if p := def.MaxDeviationPercent; p != nil && (*p <= 0 || *p > 100) {
+		fields = append(fields, errs.FieldError{Field: "max_deviation_percent", Message: "must be greater than 0 and at most 100"})
+	}
+
+	for i, context := range def.Contexts {
+		if !vitalContextKey.MatchString(context) {
+			fields = append(fields, errs.FieldError{Field: fmt.Sprintf("contexts[%d]", i), Message: "must be up to 30 upper case letters, digits or underscores"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return errs.Validation(fields...)
+	}
+	return nil
+}
+
+func sortedUnits(units map[enums.VitalUnit]models.UnitConversion) []string {
+	out := make([]string, 0, len(units))
+	for u := range units {
+		out = append(out, string(u))
+	}
+	sort.Strings(out)
+	return out
+}
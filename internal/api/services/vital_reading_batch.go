@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxVitalReadingBatch caps the rows accepted in one upload.
+const MaxVitalReadingBatch = 1000
+
+type BatchRowStatus string
+
+const (
+	BatchRowCreated   BatchRowStatus = "CREATED"
+	BatchRowDuplicate BatchRowStatus = "DUPLICATE"
+	BatchRowInvalid   BatchRowStatus = "INVALID"
+)
+
+// VitalReadingBatchRow is one reading of an upload. Row identifies it in the
+// results; Errors carries problems found while decoding it, which make the
+// row invalid without further checks.
+type VitalReadingBatchRow struct {
+	Row    int
+	Input  CreateVitalReadingInput
+	Errors []errs.FieldError
+}
+
+type VitalReadingRowResult struct {
+	Row            int
+	Status         BatchRowStatus
+	VitalReadingID *uuid.UUID
+	IsAbnormal     bool
+	Errors         []errs.FieldError
+}
+
+type VitalReadingBatchResult struct {
+	Created    int
+	Duplicates int
+	Invalid    int
+	Rows       []VitalReadingRowResult
+}
+
+// CreateBatch stores each row like Create, independently: an invalid row is
+// reported and skipped without affecting the others. A row matching a stored
+// reading of the same type, time, value and device is reported as a duplicate,
+// so a device export can be uploaded again safely.
+func (s *VitalReadingService) CreateBatch(patientUserID uuid.UUID, rows []VitalReadingBatchRow) (*VitalReadingBatchResult, error) {
+	if len(rows) == 0 {
+		return nil, errs.InvalidField("readings", "must not be empty")
+	}
+	if len(rows) > MaxVitalReadingBatch {
+		return nil, errs.InvalidField("readings", "must have at most 1000 rows")
+	}
+
+	var patient models.Patient
+	if err := s.db.First(&patient, "user_id = ?", patientUserID).Error; err != nil {
+		return nil, err
+	}
+
+	result := &VitalReadingBatchResult{Rows: make([]VitalReadingRowResult, 0, len(rows))}
+	for _, row := range rows {
+		res, err := s.createBatchRow(&patient, row)
+		if err != nil {
+			return nil, err
+		}
+		switch res.Status {
+		case BatchRowCreated:
+			result.Created++
+		case BatchRowDuplicate:
+			result.Duplicates++
+		case BatchRowInvalid:
+			result.Invalid++
+		}
+		result.Rows = append(result.Rows, res)
+	}
+	return result, nil
+}
+
+// createBatchRow returns an error only for failures unrelated to the row's content.
+func (s *VitalReadingService) createBatchRow(patient *models.Patient, row VitalReadingBatchRow) (VitalReadingRowResult, error) {
+	res := VitalReadingRowResult{Row: row.Row}
+	if len(row.Errors) > 0 {
+		res.Status, res.Errors = BatchRowInvalid, row.Errors
+		return res, nil
+	}
+
+	row.Input.PatientID = patient.UserID
+	reading, assessment, err := s.prepare(patient, row.Input)
+	if err != nil {
+		fields, ok := rowErrors(err)
+		if !ok {
+			return res, err
+		}
+		res.Status, res.Errors = BatchRowInvalid, fields
+		return res, nil
+	}
+
+	existing, err := s.findDuplicate(reading)
+	if err != nil {
+		return res, err
+	}
+	if existing != nil {
+		res.Status, res.VitalReadingID, res.IsAbnormal = BatchRowDuplicate, &existing.ID, existing.IsAbnormal
+		return res, nil
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.persist(tx, patient, reading, assessment)
+	}); err != nil {
+		return res, err
+	}
+	res.Status, res.VitalReadingID, res.IsAbnormal = BatchRowCreated, &reading.ID, reading.IsAbnormal
+	return res, nil
+}
+
+func (s *VitalReadingService) findDuplicate(reading *models.VitalReading) (*models.VitalReading, error) {
+	var existing models.VitalReading
+	err := s.db.Select("id", "is_abnormal").
+		Where("patient_id = ? AND vital_type = ? AND measured_at = ?", reading.PatientID, reading.VitalType, reading.MeasuredAt).
+		Where("value_numeric IS NOT DISTINCT FROM round(CAST(? AS numeric), 2) AND value_text IS NOT DISTINCT FROM ? AND device IS NOT DISTINCT FROM ?",
+			reading.ValueNumeric, reading.ValueText, reading.Device).
+		First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// rowErrors turns a validation failure of one row into field errors.
+func rowErrors(err error) ([]errs.FieldError, bool) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return []errs.FieldError{{Field: "checkin_id", Message: "no such checkin for this patient"}}, true
+	}
+	appErr := errs.As(err)
+	if appErr == nil || appErr.Status >= 500 {
+		return nil, false
+	}
+	if len(appErr.Fields) > 0 {
+		return appErr.Fields, true
+	}
+	return []errs.FieldError{{Field: "row", Message: appErr.Message}}, true
+}
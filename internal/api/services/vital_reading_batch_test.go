@@ -0,0 +1,185 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestCreateBatchEvaluatesRulesForDeviceRows(t *testing.T) {
+	patientID, patientUserID, ruleID := uuid.New(), uuid.New(), uuid.New()
+	conditions, _ := json.Marshal([]models.RuleCondition{{Metric: "HEART_RATE", Operator: enums.RuleOperatorGT, Value: 100}})
+
+	var fake *fakeDB
+	db, fake := newFakeDB(t, func(query string, _ []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "patients"`):
+			return &fakeRows{
+				columns: []string{"id", "user_id", "doctor_id"},
+				values:  [][]driver.Value{{patientID.String(), patientUserID.String(), uuid.NewString()}},
+			}
+		case strings.Contains(query, `FROM "threshold_rules"`) && strings.Contains(query, "patient_id ="):
+			return &fakeRows{
+				columns: []string{"id", "patient_id", "key", "name", "severity", "conditions", "is_active"},
+				values:  [][]driver.Value{{ruleID.String(), patientID.String(), "tachycardia", "Fast heart rate", "HIGH", conditions, true}},
+			}
+		case strings.Contains(query, `FROM "vital_readings"`) && strings.Contains(query, "measured_at >="):
+			return fake.rows("vital_readings")
+		}
+		return nil
+	})
+	catalog := testVitalCatalog()
+	s := &VitalReadingService{
+		db:         db,
+		catalog:    catalog,
+		thresholds: NewVitalThresholds(config.Vitals{}, catalog),
+		rules:      NewThresholdRuleService(db, catalog),
+	}
+
+	device, source := "Apple Watch", enums.VitalSourceDevice
+	heartRate := 104.0
+	measuredAt := time.Now().Add(-10 * time.Minute)
+	result, err := s.CreateBatch(patientUserID, []VitalReadingBatchRow{{
+		Row:   2,
+		Input: CreateVitalReadingInput{VitalType: enums.VitalTypeHeartRate, ValueNumeric: &heartRate, MeasuredAt: &measuredAt, Source: &source, Device: &device},
+	}})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if result.Created != 1 {
+		t.Fatalf("result = %+v, want the row created", result)
+	}
+
+	var ruleAlerts []map[string]driver.Value
+	for _, alert := range fake.insertedInto("alerts") {
+		if details, _ := alert["details"].([]byte); strings.Contains(string(details), ruleID.String()) {
+			ruleAlerts = append(ruleAlerts, alert)
+		}
+	}
+	if len(ruleAlerts) != 1 {
+		t.Fatalf("alerts = %v, want one for the rule", fake.insertedInto("alerts"))
+	}
+	alert := ruleAlerts[0]
+	if alert["checkin_id"] != nil || alert["patient_id"] != patientID.String() || alert["severity"] != "HIGH" || alert["alert_type"] != "THRESHOLD_RULE" {
+		t.Errorf("rule alert = %v, want a HIGH THRESHOLD_RULE alert of the patient without a checkin", alert)
+	}
+	if fake.rollbacks > 0 {
+		t.Errorf("%d transactions rolled back", fake.rollbacks)
+	}
+}
+
+func TestRowErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		fields []string
+		ok     bool
+	}{
+		{"unknown checkin", gorm.ErrRecordNotFound, []string{"checkin_id"}, true},
+		{"wrapped unknown checkin", fmt.Errorf("load checkin: %w", gorm.ErrRecordNotFound), []string{"checkin_id"}, true},
+		{"field errors", errs.Validation(errs.FieldError{Field: "systolic"}, errs.FieldError{Field: "diastolic"}), []string{"systolic", "diastolic"}, true},
+		{"client error without fields", errs.ErrCheckinNotActive, []string{"row"}, true},
+		{"server error", errs.ErrInternal, nil, false},
+		{"database error", errors.New("connection reset"), nil, false},
+	}
+	for _, tt := range tests {
+		fields, ok := rowErrors(tt.err)
+		var names []string
+		for _, f := range fields {
+			names = append(names, f.Field)
+		}
+		if ok != tt.ok || !slices.Equal(names, tt.fields) {
+			t.Errorf("%s: rowErrors = %v, %v; want %v, %v", tt.name, names, ok, tt.fields, tt.ok)
+		}
+	}
+	if fields, _ := rowErrors(errs.ErrCheckinNotActive); fields[0].Message != errs.ErrCheckinNotActive.Message {
+		t.Errorf("row error = %q, want the error message", fields[0].Message)
+	}
+}
+
+func TestCreateBatchReportsEachRow(t *testing.T) {
+	patientID, patientUserID, storedID := uuid.New(), uuid.New(), uuid.New()
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "patients"`):
+			return &fakeRows{
+				columns: []string{"id", "user_id", "doctor_id"},
+				values:  [][]driver.Value{{patientID.String(), patientUserID.String(), uuid.NewString()}},
+			}
+		case strings.Contains(query, `FROM "vital_readings"`) && strings.Contains(query, "IS NOT DISTINCT FROM") && slices.Contains(args, driver.Value(72.0)):
+			return &fakeRows{columns: []string{"id", "is_abnormal"}, values: [][]driver.Value{{storedID.String(), false}}}
+		}
+		return nil
+	})
+	catalog := testVitalCatalog()
+	s := &VitalReadingService{
+		db:         db,
+		catalog:    catalog,
+		thresholds: NewVitalThresholds(config.Vitals{}, catalog),
+		rules:      NewThresholdRuleService(db, catalog),
+	}
+
+	measuredAt := time.Now().Add(-time.Hour)
+	row := func(n int, vt enums.VitalType, value float64) VitalReadingBatchRow {
+		return VitalReadingBatchRow{Row: n, Input: CreateVitalReadingInput{VitalType: vt, ValueNumeric: &value, MeasuredAt: &measuredAt}}
+	}
+	decodeFailed := row(2, enums.VitalTypeHeartRate, 80)
+	decodeFailed.Errors = []errs.FieldError{{Field: "value_numeric", Message: "must be a number"}}
+	systolicOnly := VitalReadingBatchRow{Row: 5, Input: CreateVitalReadingInput{VitalType: enums.VitalTypeBloodPressure, Systolic: ptr(130.0), MeasuredAt: &measuredAt}}
+
+	result, err := s.CreateBatch(patientUserID, []VitalReadingBatchRow{
+		decodeFailed,
+		row(3, "GRIP_STRENGTH", 40),
+		row(4, enums.VitalTypeHeartRate, 72),
+		systolicOnly,
+		row(6, enums.VitalTypeHeartRate, 75),
+	})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if result.Created != 1 || result.Duplicates != 1 || result.Invalid != 3 {
+		t.Errorf("created, duplicates, invalid = %d, %d, %d; want 1, 1, 3", result.Created, result.Duplicates, result.Invalid)
+	}
+
+	want := []struct {
+		row    int
+		status BatchRowStatus
+		field  string
+	}{
+		{2, BatchRowInvalid, "value_numeric"},
+		{3, BatchRowInvalid, "vital_type"},
+		{4, BatchRowDuplicate, ""},
+		{5, BatchRowInvalid, "diastolic"},
+		{6, BatchRowCreated, ""},
+	}
+	if len(result.Rows) != len(want) {
+		t.Fatalf("rows = %+v", result.Rows)
+	}
+	for i, w := range want {
+		got := result.Rows[i]
+		if got.Row != w.row || got.Status != w.status {
+			t.Errorf("row %d = %d %s, want %s", i, got.Row, got.Status, w.status)
+		}
+		if w.field != "" && (len(got.Errors) != 1 || got.Errors[0].Field != w.field) {
+			t.Errorf("row %d errors = %v, want one on %s", w.row, got.Errors, w.field)
+		}
+	}
+	if id := result.Rows[2].VitalReadingID; id == nil || *id != storedID {
+		t.Errorf("duplicate row id = %v, want the stored reading %s", id, storedID)
+	}
+	if readings := fake.insertedInto("vital_readings"); len(readings) != 1 || readings[0]["value_numeric"] != 75.0 {
+		t.Errorf("inserted = %v, want only the new heart rate", readings)
+	}
+}
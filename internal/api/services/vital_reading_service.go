@@ -1,52 +1,131 @@
 package services
 
 import (
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+
+	"github.com/erkinov-wtf/vital-sync/internal/config"
 	"github.com/erkinov-wtf/vital-sync/internal/enums"
 	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/pagination"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type VitalReadingService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	catalog    *VitalCatalog
+	thresholds *VitalThresholds
+	rules      *ThresholdRuleService
+	timezone   string // default for patients without a schedule
 }
 
-func NewVitalReadingService(db *gorm.DB) *VitalReadingService {
-	return &VitalReadingService{db: db}
+func NewVitalReadingService(db *gorm.DB, cfg *config.Config, catalog *VitalCatalog, rules *ThresholdRuleService) *VitalReadingService {
+	return &VitalReadingService{
+		db:         db,
+		catalog:    catalog,
+		thresholds: NewVitalThresholds(cfg.Internal.Vitals, catalog),
+		rules:      rules,
+		timezone:   cfg.Timezone,
+	}
 }
 
+// alertableReadingAge bounds how old a measurement may be to raise alerts.
+// Older readings, e.g. a device backlog, are still flagged as abnormal.
+const alertableReadingAge = 24 * time.Hour
+
+// maxClockSkew tolerates device clocks running slightly ahead.
+const maxClockSkew = 5 * time.Minute
+
 type CreateVitalReadingInput struct {
-	CheckinID uuid.UUID
+	// CheckinID is optional; readings uploaded from devices are not part of a checkin.
+	CheckinID *uuid.UUID
 	PatientID uuid.UUID
 	VitalType enums.VitalType
-	Unit      *enums.VitalUnit
+	// Unit may be any unit registered for VitalType; the value is stored in
+	// the canonical unit with the reported one kept alongside.
+	Unit *enums.VitalUnit
 
+	// For BLOOD_PRESSURE, either Systolic and Diastolic or a ValueText such
+	// as "140/90" is required; ValueNumeric is then set to the systolic value.
 	ValueNumeric *float64
 	ValueText    *string
+	Systolic     *float64
+	Diastolic    *float64
+	Context      *string
+
+	// MeasuredAt defaults to now; Source defaults to MANUAL.
+	MeasuredAt *time.Time
+	Source     *enums.VitalSource
+	Device     *string
 
+	// IsAbnormal and DeviationFromBaseline are only used for readings that
+	// cannot be assessed, i.e. those without a numeric value.
 	IsAbnormal            *bool
 	DeviationFromBaseline *float64
 }
 
+// Create stores a reading, computes its abnormality against the patient's
+// baseline and the configured ranges, and raises a VITAL_ABNORMAL alert when
+// a threshold is crossed. The early warning score is then recomputed and
+// threshold rules measuring this vital type are evaluated, over the reading's
+// checkin or, without one, the patient's readings of the last day.
 func (s *VitalReadingService) Create(input CreateVitalReadingInput) (*models.VitalReading, error) {
 	var patient models.Patient
 	if err := s.db.First(&patient, "user_id = ?", input.PatientID).Error; err != nil {
 		return nil, err
 	}
 
-	if err := s.ensurePatientExists(patient.ID); err != nil {
+	reading, assessment, err := s.prepare(&patient, input)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := s.ensureCheckinExists(input.CheckinID); err != nil {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.persist(tx, &patient, reading, assessment)
+	}); err != nil {
 		return nil, err
 	}
 
+	return reading, nil
+}
+
+// prepare validates input and builds the assessed reading without storing it.
+func (s *VitalReadingService) prepare(patient *models.Patient, input CreateVitalReadingInput) (*models.VitalReading, VitalAssessment, error) {
+	if input.CheckinID != nil {
+		if err := s.ensureCheckinOf(*input.CheckinID, patient.ID); err != nil {
+			return nil, VitalAssessment{}, err
+		}
+	}
+
 	reading := models.VitalReading{
-		CheckinID: input.CheckinID,
-		PatientID: patient.ID,
-		VitalType: input.VitalType,
-		Unit:      input.Unit,
+		CheckinID:  input.CheckinID,
+		PatientID:  patient.ID,
+		VitalType:  input.VitalType,
+		Unit:       input.Unit,
+		Context:    normalizeContext(input.Context),
+		Source:     enums.VitalSourceManual,
+		Device:     trimmedOrNil(input.Device),
+		MeasuredAt: time.Now(),
+	}
+
+	if input.Source != nil {
+		if !isVitalSource(*input.Source) {
+			return nil, VitalAssessment{}, errs.InvalidField("source", "must be one of [MANUAL BOT DEVICE HL7]")
+		}
+		reading.Source = *input.Source
+	}
+	if input.MeasuredAt != nil {
+		if input.MeasuredAt.After(time.Now().Add(maxClockSkew)) {
+			return nil, VitalAssessment{}, errs.InvalidField("measured_at", "must not be in the future")
+		}
+		// Postgres keeps microseconds; truncating keeps re-uploads comparable
+		reading.MeasuredAt = input.MeasuredAt.Truncate(time.Microsecond)
+	}
+	if reading.Device != nil && len(*reading.Device) > 100 {
+		return nil, VitalAssessment{}, errs.InvalidField("device", "must be at most 100 characters")
 	}
 
 	if input.ValueNumeric != nil {
@@ -62,11 +141,135 @@ func (s *VitalReadingService) Create(input CreateVitalReadingInput) (*models.Vit
 		reading.DeviationFromBaseline = input.DeviationFromBaseline
 	}
 
-	if err := s.db.Create(&reading).Error; err != nil {
-		return nil, err
+	if err := s.catalog.normalizeReading(&reading); err != nil {
+		return nil, VitalAssessment{}, err
+	}
+	if err := resolveComponents(&reading, input.Systolic, input.Diastolic, false); err != nil {
+		return nil, VitalAssessment{}, err
 	}
 
-	return &reading, nil
+	return &reading, s.assess(patient, &reading), nil
+}
+
+// persist stores a prepared reading with its alert, refreshes the early
+// warning score and evaluates threshold rules: those of its checkin, or for a
+// reading without one, the patient's over the last day. Readings too old to
+// alert on are only stored.
+func (s *VitalReadingService) persist(tx *gorm.DB, patient *models.Patient, reading *models.VitalReading, assessment VitalAssessment) error {
+	if err := tx.Create(reading).Error; err != nil {
+		return err
+	}
+	alertable := time.Since(reading.MeasuredAt) <= alertableReadingAge
+	if assessment.IsAbnormal && alertable {
+		if err := tx.Create(abnormalVitalAlert(reading, patient, assessment, s.catalog.label(reading.VitalType))).Error; err != nil {
+			return err
+		}
+	}
+	if reading.CheckinID == nil {
+		if !alertable {
+			return nil
+		}
+		if err := patientEarlyWarning(tx, patient.ID); err != nil {
+			return err
+		}
+		_, err := s.rules.EvaluatePatient(tx, patient.ID, enums.RuleTriggerVitalReading, readingMetrics(reading)...)
+		return err
+	}
+	if err := refreshEarlyWarning(tx, *reading.CheckinID); err != nil {
+		return err
+	}
+	_, err := s.rules.EvaluateCheckin(tx, *reading.CheckinID, enums.RuleTriggerVitalReading, readingMetrics(reading)...)
+	return err
+}
+
+// PreferredUnits parses a client's units preference, see VitalCatalog.ParsePreferredUnits.
+func (s *VitalReadingService) PreferredUnits(raw string) (PreferredUnits, error) {
+	return s.catalog.ParsePreferredUnits(raw)
+}
+
+// assess applies the computed assessment to reading and returns it.
+func (s *VitalReadingService) assess(patient *models.Patient, reading *models.VitalReading) VitalAssessment {
+	var baseline *models.VitalBaseline
+	// baselines stored before the typed schema existed may not decode; they are ignored
+	if baselines, err := patient.Baselines(); err == nil {
+		if b, ok := baselines[reading.VitalType]; ok {
+			baseline = &b
+		}
+	}
+
+	var assessment VitalAssessment
+	if reading.Systolic != nil && reading.Diastolic != nil {
+		assessment = s.thresholds.AssessBloodPressure(*reading.Systolic, *reading.Diastolic, baseline)
+	} else {
+		assessment = s.thresholds.Assess(reading.VitalType, reading.ValueNumeric, reading.Unit, baseline)
+	}
+	if assessment.Evaluated {
+		reading.IsAbnormal = assessment.IsAbnormal
+		reading.DeviationFromBaseline = assessment.Deviation
+	}
+	return assessment
+}
+
+func abnormalVitalAlert(reading *models.VitalReading, patient *models.Patient, assessment VitalAssessment, label string) *models.Alert {
+	messages := make([]string, len(assessment.Findings))
+	for i, f := range assessment.Findings {
+		messages[i] = f.Message
+	}
+
+	fields := map[string]interface{}{
+		"vital_reading_id":  reading.ID,
+		"vital_type":        reading.VitalType,
+		"value":             reading.ValueNumeric,
+		"unit":              reading.Unit,
+		"baseline":          baselineValue(patient, reading.VitalType),
+		"deviation":         assessment.Deviation,
+		"deviation_percent": assessment.DeviationPercent,
+		"findings":          assessment.Findings,
+		"measured_at":       reading.MeasuredAt,
+	}
+	if reading.OriginalValue != nil {
+		fields["reported_value"] = reading.OriginalValue
+		fields["reported_unit"] = reading.OriginalUnit
+	}
+	if reading.Systolic != nil {
+		fields["systolic"] = reading.Systolic
+		fields["diastolic"] = reading.Diastolic
+		fields["mean_arterial_pressure"] = reading.MeanArterialPressure
+	}
+	details, _ := models.NewJSONB(fields)
+
+	return &models.Alert{
+		PatientID: patient.ID,
+		CheckinID: reading.CheckinID,
+		Severity:  assessment.Severity,
+		AlertType: enums.AlertTypeVitalAbnormal,
+		Title:     "Abnormal " + strings.ToLower(label),
+		Message:   strings.Join(messages, "; "),
+		Details:   details,
+	}
+}
+
+// readingMetrics lists the rule metrics a reading provides.
+func readingMetrics(reading *models.VitalReading) []enums.RuleMetric {
+	metrics := []enums.RuleMetric{enums.RuleMetric(reading.VitalType)}
+	if reading.Diastolic != nil {
+		metrics = append(metrics, enums.RuleMetricDiastolic, enums.RuleMetricMeanArterialPressure)
+	}
+	if isEarlyWarningParameter(reading.VitalType) {
+		metrics = append(metrics, enums.RuleMetricEarlyWarningScore)
+	}
+	return metrics
+}
+
+func baselineValue(patient *models.Patient, vitalType enums.VitalType) *float64 {
+	baselines, err := patient.Baselines()
+	if err != nil {
+		return nil
+	}
+	if b, ok := baselines[vitalType]; ok {
+		return &b.Value
+	}
+	return nil
 }
 
 func (s *VitalReadingService) GetByID(id uuid.UUID) (*models.VitalReading, error) {
@@ -77,27 +280,70 @@ func (s *VitalReadingService) GetByID(id uuid.UUID) (*models.VitalReading, error
 	return &reading, nil
 }
 
-func (s *VitalReadingService) List(patientID, checkinID *uuid.UUID, vitalType *enums.VitalType, onlyAbnormal bool) ([]models.VitalReading, error) {
+var vitalReadingPageSpec = pagination.Spec{
+	Sorts: map[string]pagination.Column{
+		"measured_at": {Expr: "measured_at", Field: "measured_at", Kind: pagination.KindTime},
+		"created_at":  {Expr: "created_at", Field: "created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "measured_at",
+	DateColumn:  "measured_at",
+	IDColumn:    "id",
+}
+
+type ListVitalReadingsFilter struct {
+	PatientID    *uuid.UUID
+	CheckinID    *uuid.UUID
+	VitalType    *enums.VitalType
+	OnlyAbnormal bool
+
+	// Component selects the value MinValue and MaxValue apply to; blood
+	// pressure components imply VitalType BLOOD_PRESSURE.
+	Component *enums.VitalComponent
+	MinValue  *float64
+	MaxValue  *float64
+}
+
+var vitalComponentColumns = map[enums.VitalComponent]string{
+	enums.VitalComponentValue:                "value_numeric",
+	enums.VitalComponentSystolic:             "systolic",
+	enums.VitalComponentDiastolic:            "diastolic",
+	enums.VitalComponentMeanArterialPressure: "mean_arterial_pressure",
+}
+
+func (s *VitalReadingService) List(filter ListVitalReadingsFilter, page pagination.Params) (*pagination.Page[models.VitalReading], error) {
 	query := s.db.Model(&models.VitalReading{})
-	if patientID != nil {
-		query = query.Where("patient_id = ?", *patientID)
+	if filter.PatientID != nil {
+		query = query.Where("patient_id = ?", *filter.PatientID)
 	}
-	if checkinID != nil {
-		query = query.Where("checkin_id = ?", *checkinID)
+	if filter.CheckinID != nil {
+		query = query.Where("checkin_id = ?", *filter.CheckinID)
 	}
-	if vitalType != nil {
-		query = query.Where("vital_type = ?", *vitalType)
+	if filter.VitalType != nil {
+		query = query.Where("vital_type = ?", *filter.VitalType)
 	}
-	if onlyAbnormal {
+	if filter.OnlyAbnormal {
 		query = query.Where("is_abnormal = ?", true)
 	}
 
-	var readings []models.VitalReading
-	if err := query.Order("created_at DESC").Find(&readings).Error; err != nil {
-		return nil, err
+	component := enums.VitalComponentValue
+	if filter.Component != nil {
+		component = *filter.Component
+	}
+	column, ok := vitalComponentColumns[component]
+	if !ok {
+		return nil, errs.InvalidField("query.component", "must be one of [VALUE SYSTOLIC DIASTOLIC MEAN_ARTERIAL_PRESSURE]")
+	}
+	if component != enums.VitalComponentValue {
+		query = query.Where("vital_type = ?", enums.VitalTypeBloodPressure)
+	}
+	if filter.MinValue != nil {
+		query = query.Where(column+" >= ?", *filter.MinValue)
+	}
+	if filter.MaxValue != nil {
+		query = query.Where(column+" <= ?", *filter.MaxValue)
 	}
 
-	return readings, nil
+	return pagination.Paginate[models.VitalReading](query, page, vitalReadingPageSpec)
 }
 
 type UpdateVitalReadingInput struct {
@@ -105,6 +351,9 @@ type UpdateVitalReadingInput struct {
 	Unit                  *enums.VitalUnit
 	ValueNumeric          *float64
 	ValueText             *string
+	Systolic              *float64
+	Diastolic             *float64
+	Context               *string
 	IsAbnormal            *bool
 	DeviationFromBaseline *float64
 }
@@ -115,31 +364,91 @@ func (s *VitalReadingService) Update(id uuid.UUID, input UpdateVitalReadingInput
 		return nil, err
 	}
 
-	updates := map[string]interface{}{}
+	corrected := reading
 	if input.VitalType != nil {
-		updates["vital_type"] = *input.VitalType
+		corrected.VitalType = *input.VitalType
 	}
 	if input.Unit != nil {
-		updates["unit"] = *input.Unit
+		corrected.Unit = input.Unit
 	}
 	if input.ValueNumeric != nil {
-		updates["value_numeric"] = input.ValueNumeric
+		corrected.ValueNumeric = input.ValueNumeric
 	}
 	if input.ValueText != nil {
-		updates["value_text"] = input.ValueText
+		corrected.ValueText = input.ValueText
+	}
+	if input.Context != nil {
+		corrected.Context = normalizeContext(input.Context)
 	}
 	if input.IsAbnormal != nil {
-		updates["is_abnormal"] = *input.IsAbnormal
+		corrected.IsAbnormal = *input.IsAbnormal
 	}
 	if input.DeviationFromBaseline != nil {
-		updates["deviation_from_baseline"] = input.DeviationFromBaseline
+		corrected.DeviationFromBaseline = input.DeviationFromBaseline
 	}
 
-	if len(updates) == 0 {
+	measured := input.VitalType != nil || input.Unit != nil || input.ValueNumeric != nil ||
+		input.ValueText != nil || input.Systolic != nil || input.Diastolic != nil
+	if !measured && input.Context == nil && input.IsAbnormal == nil && input.DeviationFromBaseline == nil {
 		return &reading, nil
 	}
 
-	if err := s.db.Model(&reading).Updates(updates).Error; err != nil {
+	renormalize := input.VitalType != nil || input.Unit != nil || input.ValueNumeric != nil
+	if input.Context != nil && !renormalize {
+		if err := s.catalog.checkReadingContext(&corrected); err != nil {
+			return nil, err
+		}
+	}
+
+	// re-assess when the measured value changes; no new alert is raised for corrections
+	if measured {
+		if renormalize {
+			if input.Unit == nil && input.VitalType != nil && *input.VitalType != reading.VitalType {
+				corrected.Unit = nil
+			}
+			// a unit correction alone re-reads the value as reported
+			if input.Unit != nil && input.ValueNumeric == nil && reading.OriginalValue != nil {
+				corrected.ValueNumeric = reading.OriginalValue
+			}
+			if err := s.catalog.normalizeReading(&corrected); err != nil {
+				return nil, err
+			}
+		}
+		if err := resolveComponents(&corrected, input.Systolic, input.Diastolic, input.ValueText != nil); err != nil {
+			return nil, err
+		}
+
+		var patient models.Patient
+		if err := s.db.First(&patient, "id = ?", reading.PatientID).Error; err != nil {
+			return nil, err
+		}
+		s.assess(&patient, &corrected)
+	}
+
+	updates := map[string]interface{}{
+		"vital_type":              corrected.VitalType,
+		"unit":                    corrected.Unit,
+		"value_numeric":           corrected.ValueNumeric,
+		"value_text":              corrected.ValueText,
+		"original_value":          corrected.OriginalValue,
+		"original_unit":           corrected.OriginalUnit,
+		"context":                 corrected.Context,
+		"systolic":                corrected.Systolic,
+		"diastolic":               corrected.Diastolic,
+		"mean_arterial_pressure":  corrected.MeanArterialPressure,
+		"is_abnormal":             corrected.IsAbnormal,
+		"deviation_from_baseline": corrected.DeviationFromBaseline,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&reading).Updates(updates).Error; err != nil {
+			return err
+		}
+		if measured && reading.CheckinID != nil {
+			return refreshEarlyWarning(tx, *reading.CheckinID)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -150,24 +459,69 @@ func (s *VitalReadingService) Update(id uuid.UUID, input UpdateVitalReadingInput
 	return &reading, nil
 }
 
-func (s *VitalReadingService) Delete(id uuid.UUID) error {
-	result := s.db.Delete(&models.VitalReading{}, "id = ?", id)
-	if result.Error != nil {
-		return result.Error
+// resolveComponents keeps the blood pressure columns consistent with the
+// reading's type. When the text changed without explicit components, the
+// components are parsed from the new text.
+func resolveComponents(reading *models.VitalReading, systolic, diastolic *float64, textChanged bool) error {
+	if reading.VitalType != enums.VitalTypeBloodPressure {
+		if systolic != nil || diastolic != nil {
+			return errs.InvalidField("vital_type", "must be BLOOD_PRESSURE when systolic or diastolic is set")
+		}
+		clearBloodPressure(reading)
+		return nil
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+
+	if textChanged && systolic == nil && diastolic == nil {
+		clearBloodPressure(reading)
 	}
-	return nil
+	return resolveBloodPressure(reading, systolic, diastolic)
 }
 
-func (s *VitalReadingService) ensurePatientExists(id uuid.UUID) error {
-	return s.db.First(&models.Patient{}, "id = ?", id).Error
+// Delete removes a reading and recomputes its checkin's early warning score.
+func (s *VitalReadingService) Delete(id uuid.UUID) error {
+	var reading models.VitalReading
+	if err := s.db.Select("id", "checkin_id").First(&reading, "id = ?", id).Error; err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.VitalReading{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if reading.CheckinID == nil {
+			return nil
+		}
+		return refreshEarlyWarning(tx, *reading.CheckinID)
+	})
 }
 
-func (s *VitalReadingService) ensureCheckinExists(id uuid.UUID) error {
-	if err := s.db.First(&models.Checkin{}, "id = ?", id).Error; err != nil {
+// ensureCheckinOf checks the checkin exists and belongs to the patient.
+func (s *VitalReadingService) ensureCheckinOf(id, patientID uuid.UUID) error {
+	if err := s.db.Select("id").First(&models.Checkin{}, "id = ? AND patient_id = ?", id, patientID).Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+func isVitalSource(source enums.VitalSource) bool {
+	switch source {
+	case enums.VitalSourceManual, enums.VitalSourceBot, enums.VitalSourceDevice, enums.VitalSourceHL7:
+		return true
+	}
+	return false
+}
+
+func trimmedOrNil(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	if t == "" {
+		return nil
+	}
+	return &t
+}
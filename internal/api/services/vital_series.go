@@ -0,0 +1,284 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SeriesBucket is a date_trunc field.
+type SeriesBucket string
+
+const (
+	SeriesBucketHour  SeriesBucket = "hour"
+	SeriesBucketDay   SeriesBucket = "day"
+	SeriesBucketWeek  SeriesBucket = "week"
+	SeriesBucketMonth SeriesBucket = "month"
+)
+
+var seriesBucketWidth = map[SeriesBucket]time.Duration{
+	SeriesBucketHour:  time.Hour,
+	SeriesBucketDay:   24 * time.Hour,
+	SeriesBucketWeek:  7 * 24 * time.Hour,
+	SeriesBucketMonth: 28 * 24 * time.Hour,
+}
+
+const (
+	defaultSeriesWindow = 30 * 24 * time.Hour
+	maxSeriesBuckets    = 1000
+)
+
+type VitalSeriesQuery struct {
+	VitalType *enums.VitalType
+	From      *time.Time
+	To        *time.Time
+	Bucket    SeriesBucket
+	Units     PreferredUnits
+}
+
+// VitalSeries holds bucketed aggregates per vital type. Buckets start at
+// local midnight (or the hour, week or month start) in Timezone.
+type VitalSeries struct {
+	PatientID uuid.UUID
+	Timezone  string
+	Bucket    SeriesBucket
+	From      time.Time
+	To        time.Time
+	Series    []VitalTypeSeries
+}
+
+type VitalTypeSeries struct {
+	VitalType   enums.VitalType
+	DisplayName string
+	Unit        enums.VitalUnit
+	Points      []SeriesPoint
+	Trend       VitalTrend
+}
+
+type SeriesStats struct {
+	Min  float64
+	Max  float64
+	Avg  float64
+	Last float64
+}
+
+type SeriesPoint struct {
+	BucketStart time.Time
+	Count       int
+	SeriesStats
+	// Diastolic is set for blood pressure, whose main stats are systolic.
+	Diastolic *SeriesStats
+}
+
+// VitalTrend summarises the readings up to the end of the window. Slopes are
+// least-squares fits in units per day; nil when fewer than two readings exist.
+type VitalTrend struct {
+	Slope7Days                *float64
+	Slope30Days               *float64
+	Latest                    *float64
+	Baseline                  *float64
+	ChangeFromBaselinePercent *float64
+}
+
+type seriesRow struct {
+	VitalType     enums.VitalType
+	BucketStart   time.Time
+	Count         int
+	Min           float64
+	Max           float64
+	Avg           float64
+	Last          float64
+	DiastolicMin  *float64
+	DiastolicMax  *float64
+	DiastolicAvg  *float64
+	DiastolicLast *float64
+}
+
+type trendRow struct {
+	VitalType enums.VitalType
+	Slope7    *float64
+	Slope30   *float64
+	Latest    *float64
+}
+
+// Series aggregates a patient's readings into time buckets in the patient's
+// timezone (their schedule's, or the server default).
+func (s *VitalReadingService) Series(patientUserID uuid.UUID, q VitalSeriesQuery) (*VitalSeries, error) {
+	var patient models.Patient
+	if err := s.db.First(&patient, "user_id = ?", patientUserID).Error; err != nil {
+		return nil, err
+	}
+
+	if q.Bucket == "" {
+		q.Bucket = SeriesBucketDay
+	}
+	width, ok := seriesBucketWidth[q.Bucket]
+	if !ok {
+		return nil, errs.InvalidField("query.bucket", "must be one of [hour day week month]")
+	}
+
+	to := time.Now()
+	if q.To != nil {
+		to = *q.To
+	}
+	from := to.Add(-defaultSeriesWindow)
+	if q.From != nil {
+		from = *q.From
+	}
+	if !from.Before(to) {
+		return nil, errs.InvalidField("query.from", "must be before to")
+	}
+	if to.Sub(from)/width > maxSeriesBuckets {
+		return nil, errs.InvalidField("query.bucket", fmt.Sprintf("range would exceed %d buckets; use a wider bucket", maxSeriesBuckets))
+	}
+
+	tz, err := s.patientTimezone(patient.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []seriesRow
+	query := s.db.Model(&models.VitalReading{}).
+		Select(`vital_type,
+			date_trunc(?, measured_at AT TIME ZONE ?) AT TIME ZONE ? AS bucket_start,
+			count(*) AS count,
+			min(value_numeric) AS min, max(value_numeric) AS max, avg(value_numeric) AS avg,
+			(array_agg(value_numeric ORDER BY measured_at DESC))[1] AS last,
+			min(diastolic) AS diastolic_min, max(diastolic) AS diastolic_max, avg(diastolic) AS diastolic_avg,
+			(array_agg(diastolic ORDER BY measured_at DESC))[1] AS diastolic_last`, string(q.Bucket), tz, tz).
+		Where("patient_id = ? AND value_numeric IS NOT NULL AND measured_at >= ? AND measured_at < ?", patient.ID, from, to)
+	if q.VitalType != nil {
+		query = query.Where("vital_type = ?", *q.VitalType)
+	}
+	if err := query.Group("vital_type, bucket_start").Order("vital_type, bucket_start").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	trends, err := s.trends(patient.ID, q.VitalType, to)
+	if err != nil {
+		return nil, err
+	}
+
+	baselines, _ := patient.Baselines()
+	series := &VitalSeries{PatientID: patientUserID, Timezone: tz, Bucket: q.Bucket, From: from, To: to}
+	index := map[enums.VitalType]int{}
+	entry := func(vt enums.VitalType) *VitalTypeSeries {
+		if i, ok := index[vt]; ok {
+			return &series.Series[i]
+		}
+		unit, _ := s.catalog.canonicalUnit(vt)
+		index[vt] = len(series.Series)
+		series.Series = append(series.Series, VitalTypeSeries{VitalType: vt, DisplayName: s.catalog.label(vt), Unit: unit, Points: []SeriesPoint{}})
+		return &series.Series[len(series.Series)-1]
+	}
+
+	for _, r := range rows {
+		point := SeriesPoint{
+			BucketStart: r.BucketStart,
+			Count:       r.Count,
+			SeriesStats: SeriesStats{Min: r.Min, Max: r.Max, Avg: round2(r.Avg), Last: r.Last},
+		}
+		if r.DiastolicLast != nil {
+			point.Diastolic = &SeriesStats{Min: *r.DiastolicMin, Max: *r.DiastolicMax, Avg: round2(*r.DiastolicAvg), Last: *r.DiastolicLast}
+		}
+		e := entry(r.VitalType)
+		e.Points = append(e.Points, point)
+	}
+
+	for _, t := range trends {
+		e := entry(t.VitalType)
+		e.Trend = VitalTrend{Slope7Days: roundPtr(t.Slope7), Slope30Days: roundPtr(t.Slope30), Latest: t.Latest}
+		if b, ok := baselines[t.VitalType]; ok && b.Value != 0 {
+			base := b.Value
+			e.Trend.Baseline = &base
+			if t.Latest != nil {
+				change := round2((*t.Latest - base) / base * 100)
+				e.Trend.ChangeFromBaselinePercent = &change
+			}
+		}
+	}
+
+	for i := range series.Series {
+		q.Units.applySeries(&series.Series[i])
+	}
+
+	return series, nil
+}
+
+// trends fits slopes over the 7 and 30 days before to.
+func (s *VitalReadingService) trends(patientID uuid.UUID, vitalType *enums.VitalType, to time.Time) ([]trendRow, error) {
+	var rows []trendRow
+	query := s.db.Model(&models.VitalReading{}).
+		Select(`vital_type,
+			regr_slope(value_numeric, extract(epoch FROM measured_at) / 86400) FILTER (WHERE measured_at >= ?) AS slope7,
+			regr_slope(value_numeric, extract(epoch FROM measured_at) / 86400) AS slope30,
+			(array_agg(value_numeric ORDER BY measured_at DESC))[1] AS latest`, to.AddDate(0, 0, -7)).
+		Where("patient_id = ? AND value_numeric IS NOT NULL AND measured_at >= ? AND measured_at < ?", patientID, to.AddDate(0, 0, -30), to)
+	if vitalType != nil {
+		query = query.Where("vital_type = ?", *vitalType)
+	}
+	if err := query.Group("vital_type").Order("vital_type").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// patientTimezone is the timezone of the patient's checkin schedule, falling
+// back to the server's.
+func (s *VitalReadingService) patientTimezone(patientID uuid.UUID) (string, error) {
+	var schedule models.CheckinSchedule
+	err := s.db.Select("timezone").First(&schedule, "patient_id = ?", patientID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err == nil {
+			return schedule.Timezone, nil
+		}
+	}
+	if s.timezone == "" {
+		return "UTC", nil
+	}
+	return s.timezone, nil
+}
+
+// applySeries converts a series to the preferred unit for its type.
+func (p PreferredUnits) applySeries(series *VitalTypeSeries) {
+	pref, ok := p[series.VitalType]
+	if !ok || pref.Unit == series.Unit {
+		return
+	}
+	convert := func(v float64) float64 { return fromCanonical(pref.Conversion, v) }
+
+	for i := range series.Points {
+		stats := &series.Points[i].SeriesStats
+		stats.Min, stats.Max, stats.Avg, stats.Last = convert(stats.Min), convert(stats.Max), convert(stats.Avg), convert(stats.Last)
+	}
+	t := &series.Trend
+	for _, v := range []*float64{t.Latest, t.Baseline} {
+		if v != nil {
+			*v = convert(*v)
+		}
+	}
+	// slopes are rates, so the offset does not apply
+	for _, v := range []*float64{t.Slope7Days, t.Slope30Days} {
+		if v != nil {
+			*v = round2(*v * pref.Conversion.Factor)
+		}
+	}
+	series.Unit = pref.Unit
+}
+
+func roundPtr(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	r := round2(*v)
+	return &r
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+)
+
+var identity = models.UnitConversion{Factor: 1}
+
+func toCanonical(c models.UnitConversion, v float64) float64 {
+	return round2((v - c.Offset) / c.Factor)
+}
+
+func fromCanonical(c models.UnitConversion, v float64) float64 {
+	return round2(v*c.Factor + c.Offset)
+}
+
+// unitAliases accepts the spellings patients and devices commonly send.
+var unitAliases = map[string]enums.VitalUnit{
+	"MM HG": enums.VitalUnitMmHg, "ММ РТ СТ": enums.VitalUnitMmHg,
+	"MGDL": enums.VitalUnitMgDL,
+	"MMOL": enums.VitalUnitMmolL, "ММОЛЬ/Л": enums.VitalUnitMmolL,
+	"/MIN": enums.VitalUnitBPM,
+	"C":    enums.VitalUnitCelsius, "CELSIUS": enums.VitalUnitCelsius,
+	"F": enums.VitalUnitFahrenheit, "FAHRENHEIT": enums.VitalUnitFahrenheit,
+	"КГ":  enums.VitalUnitKg,
+	"LBS": enums.VitalUnitLb,
+	"RPM": enums.VitalUnitBreaths, "BREATHS": enums.VitalUnitBreaths,
+	"HOURS": enums.VitalUnitHours, "HRS": enums.VitalUnitHours,
+	"MINUTES": enums.VitalUnitMinutes,
+	"STEP":    enums.VitalUnitSteps,
+}
+
+// normalizeUnit upper-cases u and resolves common aliases; whether the
+// result is accepted depends on the vital type's catalog entry.
+func normalizeUnit(u enums.VitalUnit) enums.VitalUnit {
+	key := strings.ToUpper(strings.TrimSpace(string(u)))
+	if unit, ok := unitAliases[key]; ok {
+		return unit
+	}
+	return enums.VitalUnit(key)
+}
+
+// resolveUnit returns the catalog unit of e matching unit, or a validation
+// error naming field. A nil unit means the canonical one.
+func resolveUnit(field string, e *vitalTypeEntry, unit *enums.VitalUnit) (enums.VitalUnit, models.UnitConversion, error) {
+	if unit == nil {
+		return e.CanonicalUnit, identity, nil
+	}
+	resolved := normalizeUnit(*unit)
+	conv, ok := e.units[resolved]
+	if !ok {
+		return "", identity, errs.InvalidField(field, fmt.Sprintf("must be one of %v for %s", sortedUnits(e.units), e.Code))
+	}
+	return resolved, conv, nil
+}
+
+// normalizeReading checks reading against its catalog entry and converts
+// ValueNumeric to the canonical unit, keeping what was reported in
+// OriginalValue and OriginalUnit.
+func (c *VitalCatalog) normalizeReading(reading *models.VitalReading) error {
+	e, ok := c.lookup(reading.VitalType)
+	if !ok || !e.IsActive {
+		return errs.InvalidField("vital_type", "is not an active vital type")
+	}
+
+	unit, conv, err := resolveUnit("unit", e, reading.Unit)
+	if err != nil {
+		return err
+	}
+
+	reading.OriginalValue = nil
+	reading.OriginalUnit = nil
+	if reading.ValueNumeric != nil && unit != e.CanonicalUnit {
+		original, originalUnit := *reading.ValueNumeric, unit
+		value := toCanonical(conv, original)
+		reading.OriginalValue = &original
+		reading.OriginalUnit = &originalUnit
+		reading.ValueNumeric = &value
+	}
+	canonical := e.CanonicalUnit
+	reading.Unit = &canonical
+
+	if v := reading.ValueNumeric; v != nil && reading.VitalType != enums.VitalTypeBloodPressure {
+		if (e.MinValue != nil && *v < *e.MinValue) || (e.MaxValue != nil && *v > *e.MaxValue) {
+			return errs.InvalidField("value_numeric", fmt.Sprintf("must be between %s and %s %s for %s",
+				formatBound(e.MinValue), formatBound(e.MaxValue), e.CanonicalUnit, e.Code))
+		}
+	}
+
+	return checkContext(e, reading.Context)
+}
+
+// checkReadingContext validates Context alone, for edits that keep the value.
+func (c *VitalCatalog) checkReadingContext(reading *models.VitalReading) error {
+	e, ok := c.lookup(reading.VitalType)
+	if !ok {
+		return errs.InvalidField("vital_type", "is not an active vital type")
+	}
+	return checkContext(e, reading.Context)
+}
+
+func checkContext(e *vitalTypeEntry, context *string) error {
+	if context == nil || e.allowsContext(*context) {
+		return nil
+	}
+	if len(e.Contexts) == 0 {
+		return errs.InvalidField("context", fmt.Sprintf("is not used for %s", e.Code))
+	}
+	return errs.InvalidField("context", fmt.Sprintf("must be one of %v for %s", []string(e.Contexts), e.Code))
+}
+
+// normalizeContext upper-cases a reading context, e.g. "post_meal".
+func normalizeContext(context *string) *string {
+	if context == nil {
+		return nil
+	}
+	normalized := strings.ToUpper(strings.TrimSpace(*context))
+	return &normalized
+}
+
+func formatBound(v *float64) string {
+	if v == nil {
+		return "any"
+	}
+	return formatValue(*v)
+}
+
+type preferredUnit struct {
+	Unit       enums.VitalUnit
+	Conversion models.UnitConversion
+}
+
+// PreferredUnits maps a vital type to the unit a client wants it shown in.
+type PreferredUnits map[enums.VitalType]preferredUnit
+
+// ParsePreferredUnits reads a comma separated unit list such as
+// "MMOL/L,°F,LB". A unit applies to every type that accepts it, so the list
+// needs no type prefixes.
+func (c *VitalCatalog) ParsePreferredUnits(raw string) (PreferredUnits, error) {
+	prefs := PreferredUnits{}
+	if strings.TrimSpace(raw) == "" {
+		return prefs, nil
+	}
+
+	entries := c.snapshot()
+	for _, part := range strings.Split(raw, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		unit := normalizeUnit(enums.VitalUnit(part))
+		matched := false
+		for code, e := range entries {
+			if conv, ok := e.units[unit]; ok {
+				prefs[code] = preferredUnit{Unit: unit, Conversion: conv}
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, errs.InvalidField("query.units", fmt.Sprintf("unknown unit %q", strings.TrimSpace(part)))
+		}
+	}
+	return prefs, nil
+}
+
+// Apply returns a copy of reading expressed in the preferred unit for its
+// type. The reported value is returned as is when it was already in that unit.
+func (p PreferredUnits) Apply(in *models.VitalReading) *models.VitalReading {
+	pref, ok := p[in.VitalType]
+	if !ok || in.Unit == nil || *in.Unit == pref.Unit {
+		return in
+	}
+
+	reading := *in
+	if reading.OriginalUnit != nil && *reading.OriginalUnit == pref.Unit && reading.OriginalValue != nil {
+		value := *reading.OriginalValue
+		reading.ValueNumeric = &value
+	} else if reading.ValueNumeric != nil {
+		value := fromCanonical(pref.Conversion, *reading.ValueNumeric)
+		reading.ValueNumeric = &value
+	}
+	if reading.DeviationFromBaseline != nil {
+		deviation := round2(*reading.DeviationFromBaseline * pref.Conversion.Factor)
+		reading.DeviationFromBaseline = &deviation
+	}
+	reading.Unit = &pref.Unit
+	return &reading
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/models"
+)
+
+func TestUnitConversionRoundTrip(t *testing.T) {
+	catalog := testVitalCatalog()
+	tests := []struct {
+		vitalType enums.VitalType
+		unit      enums.VitalUnit
+		reported  float64
+		canonical float64
+	}{
+		{enums.VitalTypeGlucose, enums.VitalUnitMmolL, 5.5, 99.1},
+		{enums.VitalTypeGlucose, enums.VitalUnitMmolL, 10, 180.18},
+		{enums.VitalTypeGlucose, enums.VitalUnitMmolL, 3, 54.05},
+		{enums.VitalTypeTemperature, enums.VitalUnitFahrenheit, 98.6, 37},
+		{enums.VitalTypeTemperature, enums.VitalUnitFahrenheit, 100.4, 38},
+		{enums.VitalTypeTemperature, enums.VitalUnitFahrenheit, 32, 0},
+		{enums.VitalTypeTemperature, enums.VitalUnitFahrenheit, -40, -40},
+		{enums.VitalTypeWeight, enums.VitalUnitLb, 176.37, 80},
+		{enums.VitalTypeWeight, enums.VitalUnitLb, 220.46, 100},
+		{enums.VitalTypeWeight, enums.VitalUnitLb, 150, 68.04},
+		{enums.VitalTypeHbA1c, enums.VitalUnitMmolMol, 53, 7},
+		{enums.VitalTypeSleepHours, enums.VitalUnitMinutes, 450, 7.5},
+	}
+	for _, tt := range tests {
+		e, _ := catalog.lookup(tt.vitalType)
+		conv := e.units[tt.unit]
+		if got := toCanonical(conv, tt.reported); got != tt.canonical {
+			t.Errorf("%v %s = %v %s, want %v", tt.reported, tt.unit, got, e.CanonicalUnit, tt.canonical)
+		}
+		if got := fromCanonical(conv, tt.canonical); got != tt.reported {
+			t.Errorf("%v %s = %v %s, want %v", tt.canonical, e.CanonicalUnit, got, tt.unit, tt.reported)
+		}
+	}
+
+	// the canonical unit converts to itself
+	for _, v := range []float64{0, 37.25, -3.5} {
+		if toCanonical(identity, v) != v || fromCanonical(identity, v) != v {
+			t.Errorf("identity changed %v", v)
+		}
+	}
+}
+
+func TestNormalizeUnit(t *testing.T) {
+	tests := map[enums.VitalUnit]enums.VitalUnit{
+		"mmHg":       enums.VitalUnitMmHg,
+		" mm hg ":    enums.VitalUnitMmHg,
+		"мм рт ст":   enums.VitalUnitMmHg,
+		"mgdl":       enums.VitalUnitMgDL,
+		"mmol":       enums.VitalUnitMmolL,
+		"ммоль/л":    enums.VitalUnitMmolL,
+		"c":          enums.VitalUnitCelsius,
+		"°c":         enums.VitalUnitCelsius,
+		"F":          enums.VitalUnitFahrenheit,
+		"fahrenheit": enums.VitalUnitFahrenheit,
+		"кг":         enums.VitalUnitKg,
+		"lbs":        enums.VitalUnitLb,
+		"lb":         enums.VitalUnitLb,
+		"furlongs":   "FURLONGS",
+	}
+	for in, want := range tests {
+		if got := normalizeUnit(in); got != want {
+			t.Errorf("normalizeUnit(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeReadingConvertsToCanonical(t *testing.T) {
+	catalog := testVitalCatalog()
+	tests := []struct {
+		name          string
+		vitalType     enums.VitalType
+		unit          *enums.VitalUnit
+		value         float64
+		want          float64
+		canonicalUnit enums.VitalUnit
+		converted     bool
+		field         string
+	}{
+		{name: "mmol/L", vitalType: enums.VitalTypeGlucose, unit: ptr(enums.VitalUnit("mmol/l")), value: 5.5, want: 99.1, canonicalUnit: enums.VitalUnitMgDL, converted: true},
+		{name: "fahrenheit", vitalType: enums.VitalTypeTemperature, unit: ptr(enums.VitalUnit("f")), value: 98.6, want: 37, canonicalUnit: enums.VitalUnitCelsius, converted: true},
+		{name: "pounds", vitalType: enums.VitalTypeWeight, unit: ptr(enums.VitalUnitLb), value: 176.37, want: 80, canonicalUnit: enums.VitalUnitKg, converted: true},
+		{name: "canonical unit", vitalType: enums.VitalTypeWeight, unit: ptr(enums.VitalUnit("kg")), value: 80, want: 80, canonicalUnit: enums.VitalUnitKg},
+		{name: "no unit", vitalType: enums.VitalTypeTemperature, value: 37.2, want: 37.2, canonicalUnit: enums.VitalUnitCelsius},
+		{name: "unit of another type", vitalType: enums.VitalTypeWeight, unit: ptr(enums.VitalUnitFahrenheit), value: 80, field: "unit"},
+		{name: "out of range once converted", vitalType: enums.VitalTypeTemperature, unit: ptr(enums.VitalUnitFahrenheit), value: 120, field: "value_numeric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := tt.value
+			reading := models.VitalReading{VitalType: tt.vitalType, ValueNumeric: &value, Unit: tt.unit}
+			err := catalog.normalizeReading(&reading)
+			if tt.field != "" {
+				if got := errorFields(err); !slices.Equal(got, []string{tt.field}) {
+					t.Fatalf("err = %v with fields %v, want %s", err, got, tt.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeReading: %v", err)
+			}
+			if *reading.ValueNumeric != tt.want || *reading.Unit != tt.canonicalUnit {
+				t.Errorf("reading = %v %s, want %v %s", *reading.ValueNumeric, *reading.Unit, tt.want, tt.canonicalUnit)
+			}
+			if !tt.converted {
+				if reading.OriginalValue != nil || reading.OriginalUnit != nil {
+					t.Errorf("original = %v %v, want none in the canonical unit", reading.OriginalValue, reading.OriginalUnit)
+				}
+				return
+			}
+			if reading.OriginalValue == nil || *reading.OriginalValue != tt.value || reading.OriginalUnit == nil || *reading.OriginalUnit != normalizeUnit(*tt.unit) {
+				t.Errorf("original = %v %v, want %v %s", reading.OriginalValue, reading.OriginalUnit, tt.value, normalizeUnit(*tt.unit))
+			}
+		})
+	}
+}
+
+func TestPreferredUnitsApply(t *testing.T) {
+	catalog := testVitalCatalog()
+	prefs, err := catalog.ParsePreferredUnits("mmol/l, °F,,lbs")
+	if err != nil {
+		t.Fatalf("ParsePreferredUnits: %v", err)
+	}
+	reading := func(vt enums.VitalType, value float64, unit enums.VitalUnit) *models.VitalReading {
+		return &models.VitalReading{VitalType: vt, ValueNumeric: &value, Unit: &unit}
+	}
+
+	tests := []struct {
+		name  string
+		in    *models.VitalReading
+		value float64
+		unit  enums.VitalUnit
+	}{
+		{"glucose", reading(enums.VitalTypeGlucose, 99.1, enums.VitalUnitMgDL), 5.5, enums.VitalUnitMmolL},
+		{"temperature", reading(enums.VitalTypeTemperature, 38, enums.VitalUnitCelsius), 100.4, enums.VitalUnitFahrenheit},
+		{"weight", reading(enums.VitalTypeWeight, 68.04, enums.VitalUnitKg), 150, enums.VitalUnitLb},
+		{"no preference", reading(enums.VitalTypeHeartRate, 72, enums.VitalUnitBPM), 72, enums.VitalUnitBPM},
+	}
+	for _, tt := range tests {
+		got := prefs.Apply(tt.in)
+		if *got.ValueNumeric != tt.value || *got.Unit != tt.unit {
+			t.Errorf("%s: %v %s, want %v %s", tt.name, *got.ValueNumeric, *got.Unit, tt.value, tt.unit)
+		}
+	}
+
+	// a value reported in the preferred unit comes back as reported
+	weight := reading(enums.VitalTypeWeight, 68.04, enums.VitalUnitKg)
+	weight.OriginalValue, weight.OriginalUnit = ptr(150.3), ptr(enums.VitalUnitLb)
+	weight.DeviationFromBaseline = ptr(1.5)
+	got := prefs.Apply(weight)
+	if *got.ValueNumeric != 150.3 || *got.DeviationFromBaseline != 3.31 {
+		t.Errorf("weight = %v with deviation %v, want 150.3 with 3.31", *got.ValueNumeric, *got.DeviationFromBaseline)
+	}
+	if *weight.ValueNumeric != 68.04 || *weight.Unit != enums.VitalUnitKg || *weight.DeviationFromBaseline != 1.5 {
+		t.Error("Apply changed the stored reading")
+	}
+
+	if _, err := catalog.ParsePreferredUnits("mmol/l,furlongs"); !slices.Equal(errorFields(err), []string{"query.units"}) {
+		t.Errorf("err = %v, want an unknown unit", err)
+	}
+}
@@ -25,6 +25,14 @@ type Internal struct {
 	Server   Server   `yaml:"server"`
 	Database Database `yaml:"database"`
 	Jwt      Jwt      `yaml:"jwt"`
+
+	Idempotency Idempotency `yaml:"idempotency"`
+	Vitals      Vitals      `yaml:"vitals"`
+	Patterns    Patterns    `yaml:"patterns"`
+	HL7         HL7         `yaml:"hl7"`
+	Review      Review      `yaml:"review"`
+	Analysis    Analysis    `yaml:"analysis"`
+	Checkins    Checkins    `yaml:"checkins"`
 }
 
 type Server struct {
@@ -51,6 +59,72 @@ type Jwt struct {
 	RefreshTokenTTL int    `yaml:"refresh_token_ttl"`
 }
 
+type Idempotency struct {
+	RetentionHours int `yaml:"retention_hours"` // how long responses are kept for replay; 0 means 24
+}
+
+// Vitals tunes abnormal-reading detection. Ranges are keyed by vital type and
+// override the built-in defaults field by field.
+type Vitals struct {
+	MaxDeviationPercent float64               `yaml:"max_deviation_percent"` // tolerance around a patient's baseline; 0 keeps the default
+	Ranges              map[string]VitalRange `yaml:"ranges"`
+}
+
+type VitalRange struct {
+	Low                 *float64 `yaml:"low"`
+	High                *float64 `yaml:"high"`
+	CriticalLow         *float64 `yaml:"critical_low"`
+	CriticalHigh        *float64 `yaml:"critical_high"`
+	MaxDeviationPercent *float64 `yaml:"max_deviation_percent"`
+}
+
+// Patterns tunes the background pattern detector. Zero values keep the defaults.
+type Patterns struct {
+	IntervalMinutes    int     `yaml:"interval_minutes"`      // how often patients are scanned; 0 means 60
+	LookbackDays       int     `yaml:"lookback_days"`         // window of readings and checkins considered; 0 means 14
+	WeightGain3DaysKg  float64 `yaml:"weight_gain_3_days_kg"` // 0 means 2
+	WeightGain7DaysKg  float64 `yaml:"weight_gain_7_days_kg"` // 0 means 2.5
+	RiskScoreIncrease  int     `yaml:"risk_score_increase"`   // minimum rise across consecutive checkins; 0 means 15
+	TrendChangePercent float64 `yaml:"trend_change_percent"`  // minimum change across a sustained trend; 0 means 10
+}
+
+// HL7 configures the HL7 v2 interface with hospital systems.
+type HL7 struct {
+	MLLPAddress        string `yaml:"mllp_address"`         // listen address such as ":2575"; empty disables the MLLP listener
+	Application        string `yaml:"application"`          // MSH-3 of acknowledgements; empty means the app name
+	Facility           string `yaml:"facility"`             // MSH-4 of acknowledgements
+	DefaultDoctorID    string `yaml:"default_doctor_id"`    // doctor of discharged patients whose attending doctor is unknown
+	MaxMessageKB       int    `yaml:"max_message_kb"`       // 0 means 1024
+	IdleTimeoutSeconds int    `yaml:"idle_timeout_seconds"` // MLLP connections without traffic are closed; 0 means 300
+}
+
+// Review tunes the doctors' review queue. Zero values keep the defaults.
+type Review struct {
+	ClaimMinutes       int `yaml:"claim_minutes"`        // how long a claim holds without the review; 0 means 30
+	CriticalSLAMinutes int `yaml:"critical_sla_minutes"` // time to review a CRITICAL checkin once analyzed; 0 means 60
+	UrgentSLAMinutes   int `yaml:"urgent_sla_minutes"`   // 0 means 240
+	ConcernSLAMinutes  int `yaml:"concern_sla_minutes"`  // also used for checkins without a medical status; 0 means 1440
+	NormalSLAMinutes   int `yaml:"normal_sla_minutes"`   // 0 means 4320
+}
+
+// Checkins tunes how active checkins end. Zero values keep the defaults.
+type Checkins struct {
+	InactivityMinutes int `yaml:"inactivity_minutes"` // an IN_PROGRESS checkin without new questions or answers for this long is completed as it is; 0 means 60
+}
+
+// Analysis configures how completed checkins are analyzed.
+type Analysis struct {
+	Provider       string `yaml:"provider"`        // "openai" for an OpenAI-compatible API, "rules" for rule-based triage; empty leaves analysis to an outside service
+	BaseURL        string `yaml:"base_url"`        // API root of the openai provider, e.g. "https://api.openai.com/v1"
+	APIKey         string `yaml:"api_key"`         // will be overwritten from os.Getenv()
+	Model          string `yaml:"model"`           // model of the openai provider
+	PromptVersion  string `yaml:"prompt_version"`  // empty means the current built-in prompt
+	TimeoutSeconds int    `yaml:"timeout_seconds"` // of each model request; 0 means 30
+	Fallback       bool   `yaml:"fallback"`        // fall back to rule-based triage when the model fails
+	SweepMinutes   int    `yaml:"sweep_minutes"`   // how often completed checkins left unanalyzed are retried; 0 means 5
+	MaxAttempts    int    `yaml:"max_attempts"`    // failed analyses of a checkin before the sweep gives up on it; 0 means 5
+}
+
 func MustLoad() *Config {
 	const configPath = "config/config.yml"
 
@@ -95,6 +169,7 @@ func MustLoad() *Config {
 			cfg.Internal = *envCfg.ProductionConfigs
 			updateDbCredentials(&cfg.Internal.Database)
 			updateJwtSecret(&cfg.Internal.Jwt)
+			updateAnalysisKey(&cfg.Internal.Analysis)
 		} else {
 			panic("production configs are not found")
 		}
@@ -109,6 +184,7 @@ func MustLoad() *Config {
 	// allow overriding DB (and JWT) via environment even in local mode for docker/devops flexibility
 	updateDbCredentials(&cfg.Internal.Database)
 	updateJwtSecret(&cfg.Internal.Jwt)
+	updateAnalysisKey(&cfg.Internal.Analysis)
 
 	log.Println("Configurations loaded")
 	setTimezone(&cfg)
@@ -139,3 +215,9 @@ func updateJwtSecret(currentSecret *Jwt) {
 		currentSecret.Secret = jwtSecret
 	}
 }
+
+func updateAnalysisKey(analysis *Analysis) {
+	if key := os.Getenv("ANALYSIS_API_KEY"); key != "" {
+		analysis.APIKey = key
+	}
+}
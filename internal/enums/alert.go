@@ -16,4 +16,8 @@ const (
 	AlertTypeNoResponse        AlertType = "NO_RESPONSE"
 	AlertTypeSentimentNegative AlertType = "SENTIMENT_NEGATIVE"
 	AlertTypePatternDetected   AlertType = "PATTERN_DETECTED"
+	AlertTypeEarlyWarning      AlertType = "EARLY_WARNING"
+	AlertTypeInstrumentScore   AlertType = "INSTRUMENT_SCORE"
+	AlertTypeAnalysisFinding   AlertType = "ANALYSIS_FINDING"
+	AlertTypeThresholdRule     AlertType = "THRESHOLD_RULE"
 )
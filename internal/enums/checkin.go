@@ -3,11 +3,24 @@ package enums
 type CheckinStatus string
 
 const (
-	CheckinStatusPending    CheckinStatus = "PENDING"
-	CheckinStatusInProgress CheckinStatus = "IN_PROGRESS"
-	CheckinStatusCompleted  CheckinStatus = "COMPLETED"
-	CheckinStatusFailed     CheckinStatus = "FAILED"
-	CheckinStatusMissed     CheckinStatus = "MISSED"
+	CheckinStatusPending    CheckinStatus = "PENDING"     // started, patient has not answered yet
+	CheckinStatusInProgress CheckinStatus = "IN_PROGRESS" // patient is answering
+	CheckinStatusCompleted  CheckinStatus = "COMPLETED"   // answers are in, waiting for analysis
+	CheckinStatusAnalyzed   CheckinStatus = "ANALYZED"
+	CheckinStatusReviewed   CheckinStatus = "REVIEWED"
+	CheckinStatusFailed     CheckinStatus = "FAILED"    // could not be carried out, e.g. the bot was unreachable
+	CheckinStatusMissed     CheckinStatus = "MISSED"    // never answered before the next one was due
+	CheckinStatusCancelled  CheckinStatus = "CANCELLED" // called off before it was completed
+)
+
+// CheckinTrigger is what caused a checkin status change.
+type CheckinTrigger string
+
+const (
+	CheckinTriggerAPI       CheckinTrigger = "API"  // an API call that names no user
+	CheckinTriggerUser      CheckinTrigger = "USER" // an API call on behalf of a user
+	CheckinTriggerScheduler CheckinTrigger = "SCHEDULER"
+	CheckinTriggerSystem    CheckinTrigger = "SYSTEM"
 )
 
 type MedicalStatus string
@@ -18,3 +31,13 @@ const (
 	MedicalStatusUrgent   MedicalStatus = "URGENT"
 	MedicalStatusCritical MedicalStatus = "CRITICAL"
 )
+
+// EarlyWarningRisk is the NEWS2 clinical risk band of a checkin's early warning score.
+type EarlyWarningRisk string
+
+const (
+	EarlyWarningRiskLow       EarlyWarningRisk = "LOW"
+	EarlyWarningRiskLowMedium EarlyWarningRisk = "LOW_MEDIUM" // aggregate 0-4 but a single parameter scored 3
+	EarlyWarningRiskMedium    EarlyWarningRisk = "MEDIUM"
+	EarlyWarningRiskHigh      EarlyWarningRisk = "HIGH"
+)
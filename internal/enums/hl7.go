@@ -0,0 +1,19 @@
+package enums
+
+// HL7Transport is how an HL7 v2 message reached vital-sync.
+type HL7Transport string
+
+const (
+	HL7TransportMLLP HL7Transport = "MLLP"
+	HL7TransportHTTP HL7Transport = "HTTP"
+)
+
+// HL7MessageStatus is the outcome of a logged HL7 v2 message.
+type HL7MessageStatus string
+
+const (
+	HL7MessageProcessed HL7MessageStatus = "PROCESSED" // applied and acknowledged with AA
+	HL7MessageDuplicate HL7MessageStatus = "DUPLICATE" // control id already processed; the stored ACK was replayed
+	HL7MessageFailed    HL7MessageStatus = "FAILED"    // acknowledged with AE, nothing applied
+	HL7MessageRejected  HL7MessageStatus = "REJECTED"  // acknowledged with AR: unreadable or unsupported
+)
@@ -0,0 +1,36 @@
+package enums
+
+// Instrument is a standard patient-reported outcome instrument.
+type Instrument string
+
+const (
+	InstrumentPHQ9   Instrument = "PHQ9"   // depression
+	InstrumentGAD7   Instrument = "GAD7"   // anxiety
+	InstrumentKCCQ12 Instrument = "KCCQ12" // heart failure health status
+	InstrumentCAT    Instrument = "CAT"    // COPD impact
+)
+
+// InstrumentBand is the severity band of an instrument score. Each
+// instrument uses its own bands.
+type InstrumentBand string
+
+const (
+	// PHQ-9 and GAD-7 severity
+	InstrumentBandMinimal          InstrumentBand = "MINIMAL"
+	InstrumentBandMild             InstrumentBand = "MILD"
+	InstrumentBandModerate         InstrumentBand = "MODERATE"
+	InstrumentBandModeratelySevere InstrumentBand = "MODERATELY_SEVERE"
+	InstrumentBandSevere           InstrumentBand = "SEVERE"
+
+	// CAT impact
+	InstrumentBandLow      InstrumentBand = "LOW"
+	InstrumentBandMedium   InstrumentBand = "MEDIUM"
+	InstrumentBandHigh     InstrumentBand = "HIGH"
+	InstrumentBandVeryHigh InstrumentBand = "VERY_HIGH"
+
+	// KCCQ-12 health status
+	InstrumentBandVeryPoor InstrumentBand = "VERY_POOR"
+	InstrumentBandPoor     InstrumentBand = "POOR"
+	InstrumentBandFair     InstrumentBand = "FAIR"
+	InstrumentBandGood     InstrumentBand = "GOOD"
+)
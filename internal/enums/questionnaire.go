@@ -0,0 +1,12 @@
+package enums
+
+// QuestionType is how a questionnaire question is answered.
+type QuestionType string
+
+const (
+	QuestionTypeNumericVital QuestionType = "NUMERIC_VITAL" // a vital sign measurement, stored as a reading
+	QuestionTypeScale        QuestionType = "SCALE"         // a number between the question's min and max
+	QuestionTypeYesNo        QuestionType = "YES_NO"
+	QuestionTypeFreeText     QuestionType = "FREE_TEXT"
+	QuestionTypeChoice       QuestionType = "CHOICE" // one, or with multiple several, of the question's choices
+)
@@ -0,0 +1,32 @@
+package enums
+
+// RuleMetric is what a threshold rule condition measures: any VitalType (blood
+// pressure meaning systolic), a blood pressure component, or a checkin
+// analysis field.
+type RuleMetric string
+
+const (
+	RuleMetricRiskScore            RuleMetric = "RISK_SCORE"
+	RuleMetricDiastolic            RuleMetric = "BLOOD_PRESSURE_DIASTOLIC"
+	RuleMetricMeanArterialPressure RuleMetric = "BLOOD_PRESSURE_MAP"
+	RuleMetricEarlyWarningScore    RuleMetric = "EARLY_WARNING_SCORE"
+)
+
+type RuleOperator string
+
+const (
+	RuleOperatorLT  RuleOperator = "LT"
+	RuleOperatorLTE RuleOperator = "LTE"
+	RuleOperatorGT  RuleOperator = "GT"
+	RuleOperatorGTE RuleOperator = "GTE"
+	RuleOperatorEQ  RuleOperator = "EQ"
+	RuleOperatorNEQ RuleOperator = "NEQ"
+)
+
+// RuleTrigger records what caused threshold rules to be evaluated.
+type RuleTrigger string
+
+const (
+	RuleTriggerVitalReading    RuleTrigger = "VITAL_READING"
+	RuleTriggerCheckinAnalysis RuleTrigger = "CHECKIN_ANALYSIS"
+)
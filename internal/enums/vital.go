@@ -1,5 +1,7 @@
 package enums
 
+// VitalType is the code of a vital type catalog entry. The constants are the
+// built-in types; clinics may define more.
 type VitalType string
 
 const (
@@ -9,15 +11,55 @@ const (
 	VitalTypeTemperature      VitalType = "TEMPERATURE"
 	VitalTypeWeight           VitalType = "WEIGHT"
 	VitalTypeOxygenSaturation VitalType = "OXYGEN_SATURATION"
+	VitalTypeRespiratoryRate  VitalType = "RESPIRATORY_RATE"
+	VitalTypePainScore        VitalType = "PAIN_SCORE"
+	VitalTypePeakFlow         VitalType = "PEAK_FLOW"
+	VitalTypeHbA1c            VitalType = "HBA1C"
+	VitalTypeUrineOutput      VitalType = "URINE_OUTPUT"
+	VitalTypeSleepHours       VitalType = "SLEEP_HOURS"
+	VitalTypeSteps            VitalType = "STEPS"
 )
 
 type VitalUnit string
 
 const (
-	VitalUnitMmHg    VitalUnit = "MMHG"
-	VitalUnitMgDL    VitalUnit = "MG/DL"
-	VitalUnitBPM     VitalUnit = "BPM"
-	VitalUnitCelsius VitalUnit = "°C"
-	VitalUnitKg      VitalUnit = "KG"
-	VitalUnitPercent VitalUnit = "%"
+	VitalUnitMmHg       VitalUnit = "MMHG"
+	VitalUnitMgDL       VitalUnit = "MG/DL"
+	VitalUnitMmolL      VitalUnit = "MMOL/L"
+	VitalUnitBPM        VitalUnit = "BPM"
+	VitalUnitCelsius    VitalUnit = "°C"
+	VitalUnitFahrenheit VitalUnit = "°F"
+	VitalUnitKg         VitalUnit = "KG"
+	VitalUnitLb         VitalUnit = "LB"
+	VitalUnitPercent    VitalUnit = "%"
+	VitalUnitBreaths    VitalUnit = "BREATHS/MIN"
+	VitalUnitScore      VitalUnit = "SCORE"
+	VitalUnitLPerMin    VitalUnit = "L/MIN"
+	VitalUnitMmolMol    VitalUnit = "MMOL/MOL"
+	VitalUnitMl         VitalUnit = "ML"
+	VitalUnitLiter      VitalUnit = "L"
+	VitalUnitHours      VitalUnit = "H"
+	VitalUnitMinutes    VitalUnit = "MIN"
+	VitalUnitSteps      VitalUnit = "STEPS"
+)
+
+// VitalComponent names one value of a reading. Single-value vitals only have
+// VALUE; blood pressure adds its components (VALUE is systolic).
+type VitalComponent string
+
+const (
+	VitalComponentValue                VitalComponent = "VALUE"
+	VitalComponentSystolic             VitalComponent = "SYSTOLIC"
+	VitalComponentDiastolic            VitalComponent = "DIASTOLIC"
+	VitalComponentMeanArterialPressure VitalComponent = "MEAN_ARTERIAL_PRESSURE"
+)
+
+// VitalSource records how a reading reached vital-sync.
+type VitalSource string
+
+const (
+	VitalSourceManual VitalSource = "MANUAL" // entered through the API by staff or the patient
+	VitalSourceBot    VitalSource = "BOT"    // reported in a checkin conversation
+	VitalSourceDevice VitalSource = "DEVICE" // exported by a home device such as a BP cuff or scale
+	VitalSourceHL7    VitalSource = "HL7"    // sent by a hospital system as an HL7 v2 result
 )
@@ -2,10 +2,16 @@ package http
 
 import (
 	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
 
+	"github.com/erkinov-wtf/vital-sync/internal/api/middlewares"
 	"github.com/erkinov-wtf/vital-sync/internal/api/services"
 	"github.com/erkinov-wtf/vital-sync/internal/config"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
 // Router wraps the gin engine
@@ -14,18 +20,25 @@ type Router struct {
 	config *config.Config
 }
 
-func NewRouter(cfg *config.Config, authSvc *services.AuthService) *Router {
+func NewRouter(cfg *config.Config, lgr *slog.Logger, authSvc *services.AuthService) *Router {
 	if cfg.Env == config.ReleaseEnv {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	useJSONFieldNames()
+
 	r := gin.New()
+	r.HandleMethodNotAllowed = true
 
 	// Middleware
 	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
+	r.Use(middlewares.ErrorHandler(lgr))
+	r.Use(middlewares.Recovery())
 	//r.Use(middlewares.Auth(authSvc))
 
+	r.NoRoute(middlewares.NoRoute)
+	r.NoMethod(middlewares.NoMethod)
+
 	return &Router{
 		engine: r,
 		config: cfg,
@@ -39,3 +52,21 @@ func (r *Router) Engine() *gin.Engine {
 func (r *Router) Run() error {
 	return r.engine.Run(fmt.Sprintf("%s:%d", r.config.Internal.Server.Host, r.config.Internal.Server.Port))
 }
+
+// useJSONFieldNames makes validation errors report json field names instead of Go ones.
+func useJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+}
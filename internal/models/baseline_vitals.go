@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+)
+
+// BaselineVitals is the schema of Patient.BaselineVitals: the patient's usual
+// value per vital type, e.g. {"HEART_RATE": {"value": 72}}.
+type BaselineVitals map[enums.VitalType]VitalBaseline
+
+type VitalBaseline struct {
+	Value float64 `json:"value"`
+	// Unit is only read on input; stored baselines are in the canonical unit.
+	Unit *enums.VitalUnit `json:"unit,omitempty"`
+	// Diastolic is the blood pressure baseline's second component; Value is systolic.
+	Diastolic *float64 `json:"diastolic,omitempty"`
+	// MaxDeviationPercent overrides the configured tolerance for this patient.
+	MaxDeviationPercent *float64 `json:"max_deviation_percent,omitempty"`
+}
+
+// Baselines decodes BaselineVitals. Records without baselines yield an empty map.
+func (p *Patient) Baselines() (BaselineVitals, error) {
+	baselines := BaselineVitals{}
+	if err := p.BaselineVitals.Unmarshal(&baselines); err != nil {
+		return nil, err
+	}
+	return baselines, nil
+}
@@ -14,11 +14,22 @@ type Checkin struct {
 	ScheduleID *uuid.UUID `gorm:"column:schedule_id;type:uuid"`
 
 	// Check-in Flow
-	Status      enums.CheckinStatus `gorm:"column:status;type:varchar(20);default:'pending';index"` // pending, in_progress, completed, failed, missed
+	// Status only changes through the checkin state machine; see CheckinTransition.
+	Status      enums.CheckinStatus `gorm:"column:status;type:varchar(20);default:'PENDING';index"`
 	InitiatedAt time.Time           `gorm:"column:initiated_at;type:timestamptz;default:now()"`
 	CompletedAt *time.Time          `gorm:"column:completed_at;type:timestamptz"`
+	// LastActivityAt is when questions or answers were last added; nil
+	// until the first are. Active checkins idle for too long are completed.
+	LastActivityAt *time.Time `gorm:"column:last_activity_at;type:timestamptz"`
+	// Partial is set on checkins completed with questions left unanswered,
+	// such as those completed for inactivity.
+	Partial bool `gorm:"column:partial;not null;default:false"`
 
-	// AI-Generated Questions
+	// Questionnaire the checkin asks, resolved when it starts; nil when no
+	// template is assigned to the patient
+	QuestionnaireTemplateID *uuid.UUID `gorm:"column:questionnaire_template_id;type:uuid;index"`
+
+	// Questions, seeded from the questionnaire template or added by the bot
 	Questions JSONB `gorm:"column:questions;type:jsonb;not null;default:'[]'"`
 
 	// Patient Responses
@@ -27,20 +38,45 @@ type Checkin struct {
 
 	// AI Analysis
 	AIAnalysis    JSONB                `gorm:"column:ai_analysis;type:jsonb"`
+	AnalyzedAt    *time.Time           `gorm:"column:analyzed_at;type:timestamptz"`          // when it became ANALYZED; the review SLA runs from here
 	MedicalStatus *enums.MedicalStatus `gorm:"column:medical_status;type:varchar(20);index"` // normal, concern, urgent, critical
 	RiskScore     *int                 `gorm:"column:risk_score;type:integer"`               // 0-100 scale
 
+	// Failed analyses by vital-sync's own analyzer while the checkin waits
+	// in COMPLETED. The sweep retries it from AnalysisRetryAt and gives up,
+	// setting AnalysisFailedAt, after too many. Bookkeeping; does not bump Version.
+	AnalysisAttempts int        `gorm:"column:analysis_attempts;not null;default:0"`
+	AnalysisRetryAt  *time.Time `gorm:"column:analysis_retry_at;type:timestamptz"`
+	AnalysisError    *string    `gorm:"column:analysis_error;type:text"`
+	AnalysisFailedAt *time.Time `gorm:"column:analysis_failed_at;type:timestamptz"`
+
+	// Early Warning Score, computed by vital-sync from the checkin's readings
+	EarlyWarningScore      *int                    `gorm:"column:early_warning_score;type:integer"` // NEWS2 aggregate, 0-20
+	EarlyWarningRisk       *enums.EarlyWarningRisk `gorm:"column:early_warning_risk;type:varchar(20);index"`
+	EarlyWarningBreakdown  JSONB                   `gorm:"column:early_warning_breakdown;type:jsonb"`
+	EarlyWarningComputedAt *time.Time              `gorm:"column:early_warning_computed_at;type:timestamptz"`
+
 	// Doctor Review
 	ReviewedBy  *uuid.UUID `gorm:"column:reviewed_by;type:uuid"`
 	ReviewedAt  *time.Time `gorm:"column:reviewed_at;type:timestamptz"`
 	DoctorNotes *string    `gorm:"column:doctor_notes;type:text"`
 
+	// Review queue claim: the doctor working on the review, until it is done
+	// or the claim lapses. Claims are bookkeeping and do not bump Version.
+	ClaimedBy *uuid.UUID `gorm:"column:claimed_by;type:uuid;index"`
+	ClaimedAt *time.Time `gorm:"column:claimed_at;type:timestamptz"`
+
+	// Version is bumped on every update except claims and exposed as the ETag.
+	Version int `gorm:"column:version;not null;default:1"`
+
 	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:now();index:idx_checkins_created_at,sort:desc"`
 	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamptz;default:now()"`
 
-	Patient  *Patient         `gorm:"foreignKey:PatientID"`
-	Schedule *CheckinSchedule `gorm:"foreignKey:ScheduleID"`
-	Reviewer *User            `gorm:"foreignKey:ReviewedBy"`
+	Patient               *Patient               `gorm:"foreignKey:PatientID"`
+	Schedule              *CheckinSchedule       `gorm:"foreignKey:ScheduleID"`
+	Reviewer              *User                  `gorm:"foreignKey:ReviewedBy"`
+	Claimer               *User                  `gorm:"foreignKey:ClaimedBy"`
+	QuestionnaireTemplate *QuestionnaireTemplate `gorm:"foreignKey:QuestionnaireTemplateID"`
 }
 
 func (c *Checkin) BeforeCreate(tx *gorm.DB) error {
@@ -49,3 +85,26 @@ func (c *Checkin) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// CheckinTransition is one status change of a checkin. FromStatus is nil for
+// the status a checkin was created with.
+type CheckinTransition struct {
+	ID         uuid.UUID            `gorm:"type:uuid;primaryKey"`
+	CheckinID  uuid.UUID            `gorm:"column:checkin_id;type:uuid;not null;index"`
+	FromStatus *enums.CheckinStatus `gorm:"column:from_status;type:varchar(20)"`
+	ToStatus   enums.CheckinStatus  `gorm:"column:to_status;type:varchar(20);not null"`
+	Trigger    enums.CheckinTrigger `gorm:"column:triggered_by;type:varchar(20);not null"`
+	UserID     *uuid.UUID           `gorm:"column:user_id;type:uuid"`
+	Reason     *string              `gorm:"column:reason;type:text"`
+	CreatedAt  time.Time            `gorm:"column:created_at;type:timestamptz;default:now();index"`
+
+	Checkin *Checkin `gorm:"foreignKey:CheckinID"`
+	User    *User    `gorm:"foreignKey:UserID"`
+}
+
+func (t *CheckinTransition) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
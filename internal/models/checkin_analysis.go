@@ -0,0 +1,20 @@
+package models
+
+// CheckinAnalysis is the schema of Checkin.AIAnalysis for analyses vital-sync
+// makes itself. Analyses stored through the API by an outside service may
+// have any shape.
+type CheckinAnalysis struct {
+	Summary         string   `json:"summary"`
+	Concerns        []string `json:"concerns"`
+	Recommendations []string `json:"recommendations"`
+
+	// Provenance: the analyzer, the model and prompt it used and how long
+	// the model took. PromptVersion is empty for rule-based analyses.
+	Provider      string `json:"provider"`
+	Model         string `json:"model"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	LatencyMS     int64  `json:"latency_ms"`
+	// FallbackReason is why the configured model was not used, when the
+	// analysis fell back to the rules.
+	FallbackReason string `json:"fallback_reason,omitempty"`
+}
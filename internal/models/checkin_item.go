@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/google/uuid"
+)
+
+// CheckinQuestion is an item of Checkin.Questions. Seq orders the questions
+// and is what answers and exports link them by; ID is assigned when the
+// question is added and kept when it is asked again under the same seq.
+// Key is set for questions that come from a questionnaire template.
+type CheckinQuestion struct {
+	ID  uuid.UUID `json:"id"`
+	Seq int       `json:"seq"`
+	TemplateQuestion
+
+	Locale        *string    `json:"locale,omitempty"`
+	AskedAt       *time.Time `json:"asked_at,omitempty"`       // nil for template questions not asked yet
+	SourceMessage *string    `json:"source_message,omitempty"` // the bot message that asked it
+}
+
+// CheckinAnswer is an item of Checkin.Answers, linked to the question it
+// answers by QuestionID and Seq. Value holds the answer by question type: a
+// number for NUMERIC_VITAL and SCALE (or a string such as "140/90" for a
+// blood pressure), a bool for YES_NO, a string for FREE_TEXT and CHOICE, and
+// a list of strings for a CHOICE question with Multiple.
+type CheckinAnswer struct {
+	ID         uuid.UUID          `json:"id"`
+	QuestionID uuid.UUID          `json:"question_id"`
+	Seq        int                `json:"seq"`
+	Type       enums.QuestionType `json:"type"`
+	Value      interface{}        `json:"value"`
+	Text       *string            `json:"text,omitempty"` // the reply as the patient wrote it
+
+	Locale        *string   `json:"locale,omitempty"`
+	AnsweredAt    time.Time `json:"answered_at"`
+	SourceMessage *string   `json:"source_message,omitempty"` // the patient message it was read from
+
+	// VitalReadingID is the reading a NUMERIC_VITAL answer was recorded as.
+	VitalReadingID *uuid.UUID `json:"vital_reading_id,omitempty"`
+}
+
+// CheckinItems splits a questions or answers array into its raw items,
+// keeping items in any shape so ones stored before the schema survive
+// being rewritten.
+func CheckinItems(data JSONB) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if len(data) == 0 || string(data) == "null" {
+		return items, nil
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ParsedQuestions returns the checkin's questions that follow the schema, in
+// stored order. Items stored before it, which have no id, are left out.
+func (c *Checkin) ParsedQuestions() ([]CheckinQuestion, error) {
+	return parseCheckinItems(c.Questions, func(q CheckinQuestion) bool { return q.ID != uuid.Nil })
+}
+
+// ParsedAnswers is ParsedQuestions for the checkin's answers.
+func (c *Checkin) ParsedAnswers() ([]CheckinAnswer, error) {
+	return parseCheckinItems(c.Answers, func(a CheckinAnswer) bool { return a.ID != uuid.Nil && a.QuestionID != uuid.Nil })
+}
+
+func parseCheckinItems[T any](data JSONB, valid func(T) bool) ([]T, error) {
+	raw, err := CheckinItems(data)
+	if err != nil {
+		return nil, err
+	}
+	var items []T
+	for _, r := range raw {
+		var item T
+		if json.Unmarshal(r, &item) != nil || !valid(item) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -16,6 +16,7 @@ type CheckinSchedule struct {
 	Timezone      string                  `gorm:"column:timezone;type:varchar(50);default:'Asia/Tashkent'"`
 	IsActive      bool                    `gorm:"column:is_active;default:true"`
 	NextCheckinAt *time.Time              `gorm:"column:next_checkin_at;type:timestamptz;index"`
+	Version       int                     `gorm:"column:version;not null;default:1"` // bumped on every update, exposed as the ETag
 	CreatedAt     time.Time               `gorm:"column:created_at;type:timestamptz;default:now()"`
 	UpdatedAt     time.Time               `gorm:"column:updated_at;type:timestamptz;default:now()"`
 
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HL7Message logs every HL7 v2 message received, with the acknowledgement
+// sent back. Replays of a processed message are logged too, as DUPLICATE.
+type HL7Message struct {
+	ID                 uuid.UUID              `gorm:"type:uuid;primaryKey"`
+	ControlID          string                 `gorm:"column:control_id;type:varchar(199);index"`
+	SendingApplication string                 `gorm:"column:sending_application;type:varchar(227)"`
+	SendingFacility    string                 `gorm:"column:sending_facility;type:varchar(227)"`
+	MessageType        string                 `gorm:"column:message_type;type:varchar(20);index"`
+	Transport          enums.HL7Transport     `gorm:"column:transport;type:varchar(10);not null"`
+	Status             enums.HL7MessageStatus `gorm:"column:status;type:varchar(20);not null;index"`
+	AckCode            string                 `gorm:"column:ack_code;type:varchar(2);not null"`
+	// Problems lists the ERR segments of the acknowledgement, warnings included.
+	Problems  StringArray `gorm:"column:problems;type:text[]"`
+	PatientID *uuid.UUID  `gorm:"column:patient_id;type:uuid;index"`
+	Raw       string      `gorm:"column:raw;type:text;not null"`
+	Ack       string      `gorm:"column:ack;type:text;not null"`
+
+	ReceivedAt  time.Time `gorm:"column:received_at;type:timestamptz;not null;index"`
+	ProcessedAt time.Time `gorm:"column:processed_at;type:timestamptz;not null"`
+
+	Patient *Patient `gorm:"foreignKey:PatientID"`
+}
+
+func (m *HL7Message) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// HL7ControlID records a message that was applied, keyed by its sender and
+// MSH-10, so a retransmission is acknowledged again instead of applied twice.
+// Messages that failed are not recorded and may be sent again.
+type HL7ControlID struct {
+	SendingApplication string    `gorm:"column:sending_application;type:varchar(227);primaryKey"`
+	SendingFacility    string    `gorm:"column:sending_facility;type:varchar(227);primaryKey"`
+	ControlID          string    `gorm:"column:control_id;type:varchar(199);primaryKey"`
+	MessageID          uuid.UUID `gorm:"column:message_id;type:uuid;not null"`
+	Ack                string    `gorm:"column:ack;type:text;not null"`
+	CreatedAt          time.Time `gorm:"column:created_at;type:timestamptz;default:now()"`
+}
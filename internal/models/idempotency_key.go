@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey remembers the outcome of a mutating request sent with an
+// Idempotency-Key header so a retry gets the same response instead of being
+// executed twice. Keys are scoped by caller and route, so different clients,
+// or one client on different endpoints, may use the same key. StatusCode is
+// zero while the first request is still running.
+type IdempotencyKey struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Caller      string    `gorm:"column:caller;type:varchar(100);not null;default:'';uniqueIndex:idx_idempotency_keys_scope,priority:1"`
+	Method      string    `gorm:"column:method;type:varchar(10);not null;uniqueIndex:idx_idempotency_keys_scope,priority:2"`
+	Route       string    `gorm:"column:route;type:text;not null;default:'';uniqueIndex:idx_idempotency_keys_scope,priority:3"` // gin route template, e.g. /api/v1/checkins/:id
+	Key         string    `gorm:"column:key;type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_scope,priority:4"`
+	Path        string    `gorm:"column:path;type:text;not null"`
+	RequestHash string    `gorm:"column:request_hash;type:char(64);not null"`
+
+	StatusCode   int    `gorm:"column:status_code;not null;default:0"`
+	ContentType  string `gorm:"column:content_type;type:varchar(255)"`
+	ETag         string `gorm:"column:etag;type:varchar(255)"`
+	Location     string `gorm:"column:location;type:text"`
+	ResponseBody []byte `gorm:"column:response_body;type:bytea"`
+
+	CreatedAt   time.Time  `gorm:"column:created_at;type:timestamptz;default:now()"`
+	CompletedAt *time.Time `gorm:"column:completed_at;type:timestamptz"`
+	ExpiresAt   time.Time  `gorm:"column:expires_at;type:timestamptz;not null;index"`
+}
+
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
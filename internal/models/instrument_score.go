@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InstrumentScore is the score of a patient-reported outcome instrument
+// answered in a checkin. It is recomputed when an answer is replaced.
+type InstrumentScore struct {
+	ID         uuid.UUID        `gorm:"type:uuid;primaryKey"`
+	PatientID  uuid.UUID        `gorm:"column:patient_id;type:uuid;not null;index"`
+	CheckinID  uuid.UUID        `gorm:"column:checkin_id;type:uuid;not null;uniqueIndex:idx_instrument_scores_checkin_instrument"`
+	Instrument enums.Instrument `gorm:"column:instrument;type:varchar(20);not null;uniqueIndex:idx_instrument_scores_checkin_instrument;index"`
+
+	Score     float64              `gorm:"column:score;type:decimal(6,2);not null"`
+	Band      enums.InstrumentBand `gorm:"column:band;type:varchar(30);not null"`
+	Subscores JSONB                `gorm:"column:subscores;type:jsonb"`      // domain scores, e.g. KCCQ-12's physical limitation
+	Items     JSONB                `gorm:"column:items;type:jsonb;not null"` // item key to the value scored
+
+	// CompletedAt is when the last item was answered.
+	CompletedAt time.Time `gorm:"column:completed_at;type:timestamptz;not null;index"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamptz;default:now()"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;type:timestamptz;default:now()"`
+
+	Patient *Patient `gorm:"foreignKey:PatientID"`
+	Checkin *Checkin `gorm:"foreignKey:CheckinID"`
+}
+
+func (s *InstrumentScore) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
@@ -36,6 +36,9 @@ type Patient struct {
 	EmergencyContactPhone    *string `gorm:"column:emergency_contact_phone;type:varchar(20)"`
 	EmergencyContactRelation *string `gorm:"column:emergency_contact_relation;type:varchar(50)"`
 
+	// Version is bumped on every update and exposed as the ETag.
+	Version int `gorm:"column:version;not null;default:1"`
+
 	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:now()"`
 	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamptz;default:now()"`
 
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PatientIdentifier is an id a hospital system knows the patient by, such as
+// a medical record number, scoped by the authority that assigned it.
+type PatientIdentifier struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	PatientID uuid.UUID `gorm:"column:patient_id;type:uuid;not null;index"`
+	System    string    `gorm:"column:system;type:varchar(227);not null;uniqueIndex:idx_patient_identifiers_system_value"`
+	Value     string    `gorm:"column:value;type:varchar(199);not null;uniqueIndex:idx_patient_identifiers_system_value"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:now()"`
+}
+
+func (i *PatientIdentifier) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
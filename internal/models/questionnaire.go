@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QuestionnaireTemplate is one version of a questionnaire. Versions share a
+// Key and are never edited: changing the questions publishes a new version,
+// so a checkin keeps pointing at the exact questions it was asked.
+type QuestionnaireTemplate struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Key         string    `gorm:"column:key;type:varchar(100);not null;uniqueIndex:idx_questionnaire_templates_key_version"`
+	Version     int       `gorm:"column:version;not null;uniqueIndex:idx_questionnaire_templates_key_version"`
+	Name        string    `gorm:"column:name;type:varchar(255);not null"`
+	Description *string   `gorm:"column:description;type:text"`
+	Questions   JSONB     `gorm:"column:questions;type:jsonb;not null"` // []TemplateQuestion, in the order they are asked
+	IsActive    bool      `gorm:"column:is_active;default:true;index"`
+
+	CreatedBy *uuid.UUID `gorm:"column:created_by;type:uuid"`
+	CreatedAt time.Time  `gorm:"column:created_at;type:timestamptz;default:now()"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;type:timestamptz;default:now()"`
+}
+
+// TemplateQuestion is a question of a template. Key identifies it within the
+// template and across its versions.
+type TemplateQuestion struct {
+	Key      string             `json:"key"`
+	Type     enums.QuestionType `json:"type"`
+	Text     string             `json:"text"`
+	Required bool               `json:"required"`
+
+	// NUMERIC_VITAL: the vital type measured and the unit it is asked in,
+	// its canonical unit when unset
+	VitalType *enums.VitalType `json:"vital_type,omitempty"`
+	Unit      *enums.VitalUnit `json:"unit,omitempty"`
+
+	// SCALE: the range of the answer, both required
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	// CHOICE: the options, and whether several may be picked
+	Choices  []QuestionChoice `json:"choices,omitempty"`
+	Multiple bool             `json:"multiple,omitempty"`
+}
+
+type QuestionChoice struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+func (t *QuestionnaireTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *QuestionnaireTemplate) ParsedQuestions() ([]TemplateQuestion, error) {
+	var questions []TemplateQuestion
+	if err := t.Questions.Unmarshal(&questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// QuestionnaireAssignment picks the template a patient's checkins use, by
+// template key so new versions apply as they are published. It names either
+// a patient or a condition, matched case-insensitively against patients'
+// condition summary and comorbidities. A patient's own assignment wins over
+// condition ones, which are tried by descending Priority.
+type QuestionnaireAssignment struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	TemplateKey string     `gorm:"column:template_key;type:varchar(100);not null;index"`
+	PatientID   *uuid.UUID `gorm:"column:patient_id;type:uuid;uniqueIndex"`
+	Condition   *string    `gorm:"column:condition;type:varchar(255)"`
+	Priority    int        `gorm:"column:priority;not null;default:0"`
+
+	CreatedBy *uuid.UUID `gorm:"column:created_by;type:uuid"`
+	CreatedAt time.Time  `gorm:"column:created_at;type:timestamptz;default:now()"`
+
+	Patient *Patient `gorm:"foreignKey:PatientID"`
+}
+
+func (a *QuestionnaireAssignment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ThresholdRule raises an alert when all of its conditions hold for a checkin.
+// Rules belong either to an organization (defaults for its doctors' patients)
+// or to a single patient; a patient rule overrides the organization rule with
+// the same Key, and an inactive patient rule switches that default off.
+type ThresholdRule struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	OrganizationID *uuid.UUID `gorm:"column:organization_id;type:uuid;uniqueIndex:idx_threshold_rules_org_key"`
+	PatientID      *uuid.UUID `gorm:"column:patient_id;type:uuid;uniqueIndex:idx_threshold_rules_patient_key"`
+	Key            string     `gorm:"column:key;type:varchar(100);not null;uniqueIndex:idx_threshold_rules_org_key;uniqueIndex:idx_threshold_rules_patient_key"`
+
+	Name       string              `gorm:"column:name;type:varchar(255);not null"`
+	Severity   enums.AlertSeverity `gorm:"column:severity;type:varchar(20);not null"`
+	Conditions JSONB               `gorm:"column:conditions;type:jsonb;not null"` // []RuleCondition, all must hold
+	IsActive   bool                `gorm:"column:is_active;default:true;index"`
+
+	CreatedBy *uuid.UUID `gorm:"column:created_by;type:uuid"`
+	CreatedAt time.Time  `gorm:"column:created_at;type:timestamptz;default:now()"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;type:timestamptz;default:now()"`
+
+	Organization *Organization `gorm:"foreignKey:OrganizationID"`
+	Patient      *Patient      `gorm:"foreignKey:PatientID"`
+}
+
+// RuleCondition compares the latest value of Metric in a checkin with Value.
+type RuleCondition struct {
+	Metric   enums.RuleMetric   `json:"metric"`
+	Operator enums.RuleOperator `json:"operator"`
+	Value    float64            `json:"value"`
+}
+
+func (r *ThresholdRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (r *ThresholdRule) ParsedConditions() ([]RuleCondition, error) {
+	var conditions []RuleCondition
+	if err := r.Conditions.Unmarshal(&conditions); err != nil {
+		return nil, err
+	}
+	return conditions, nil
+}
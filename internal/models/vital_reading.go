@@ -9,19 +9,40 @@ import (
 )
 
 type VitalReading struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
-	CheckinID uuid.UUID `gorm:"column:checkin_id;type:uuid;not null;index"`
-	PatientID uuid.UUID `gorm:"column:patient_id;type:uuid;not null;index"`
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	CheckinID *uuid.UUID `gorm:"column:checkin_id;type:uuid;index"` // nil for device uploads outside a checkin
+	PatientID uuid.UUID  `gorm:"column:patient_id;type:uuid;not null;index"`
 
-	VitalType    enums.VitalType  `gorm:"column:vital_type;type:varchar(50);not null;index"` // blood_pressure, glucose, heart_rate, temperature, weight, oxygen_saturation
+	VitalType    enums.VitalType  `gorm:"column:vital_type;type:varchar(50);not null;index"` // VitalTypeDefinition code
 	ValueNumeric *float64         `gorm:"column:value_numeric;type:decimal(10,2)"`
 	ValueText    *string          `gorm:"column:value_text;type:varchar(50)"`
-	Unit         *enums.VitalUnit `gorm:"column:unit;type:varchar(20)"` // mmHg, mg/dL, bpm, °C, kg, %
+	Unit         *enums.VitalUnit `gorm:"column:unit;type:varchar(20)"` // canonical unit for the vital type: mmHg, mg/dL, bpm, °C, kg, %
+
+	// What the patient reported, when it was in a unit other than the canonical one.
+	OriginalValue *float64         `gorm:"column:original_value;type:decimal(10,2)"`
+	OriginalUnit  *enums.VitalUnit `gorm:"column:original_unit;type:varchar(20)"`
+
+	// Context qualifies the measurement, e.g. FASTING or POST_MEAL for glucose;
+	// allowed values come from the type's catalog entry.
+	Context *string `gorm:"column:context;type:varchar(30)"`
+
+	// Blood pressure components; ValueNumeric mirrors Systolic so single-value
+	// checks keep working.
+	Systolic             *float64 `gorm:"column:systolic;type:decimal(6,2)"`
+	Diastolic            *float64 `gorm:"column:diastolic;type:decimal(6,2)"`
+	MeanArterialPressure *float64 `gorm:"column:mean_arterial_pressure;type:decimal(6,2)"`
 
 	IsAbnormal            bool     `gorm:"column:is_abnormal;default:false"`
 	DeviationFromBaseline *float64 `gorm:"column:deviation_from_baseline;type:decimal(10,2)"`
 
-	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:now();index:idx_vital_readings_created_at,sort:desc"`
+	// Provenance: who reported the reading and, for devices, which one.
+	Source enums.VitalSource `gorm:"column:source;type:varchar(20);not null;default:'MANUAL'"`
+	Device *string           `gorm:"column:device;type:varchar(100)"`
+
+	// MeasuredAt is when the measurement was taken, which for device uploads
+	// can be long before the row was created.
+	MeasuredAt time.Time `gorm:"column:measured_at;type:timestamptz;index:idx_vital_readings_measured_at,sort:desc"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:timestamptz;default:now();index:idx_vital_readings_created_at,sort:desc"`
 
 	Checkin *Checkin `gorm:"foreignKey:CheckinID"`
 	Patient *Patient `gorm:"foreignKey:PatientID"`
@@ -31,5 +52,8 @@ func (vr *VitalReading) BeforeCreate(tx *gorm.DB) error {
 	if vr.ID == uuid.Nil {
 		vr.ID = uuid.New()
 	}
+	if vr.MeasuredAt.IsZero() {
+		vr.MeasuredAt = time.Now()
+	}
 	return nil
 }
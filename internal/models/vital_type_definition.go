@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+)
+
+// VitalTypeDefinition describes a measurable vital: how it is shown, which
+// units it accepts and what counts as normal. Built-in types are seeded at
+// startup and clinics add their own, so VitalReading.VitalType refers to Code
+// rather than to a fixed enum.
+type VitalTypeDefinition struct {
+	Code        enums.VitalType `gorm:"column:code;type:varchar(50);primaryKey"`
+	DisplayName string          `gorm:"column:display_name;type:varchar(100);not null"`
+	Description *string         `gorm:"column:description;type:text"`
+
+	CanonicalUnit enums.VitalUnit `gorm:"column:canonical_unit;type:varchar(20);not null"`
+	Units         JSONB           `gorm:"column:units;type:jsonb"`     // VitalUnitConversions for the other accepted units
+	Contexts      StringArray     `gorm:"column:contexts;type:text[]"` // allowed VitalReading.Context values, e.g. FASTING
+
+	// Plausible input bounds in the canonical unit; readings outside them are rejected.
+	MinValue *float64 `gorm:"column:min_value;type:decimal(10,2)"`
+	MaxValue *float64 `gorm:"column:max_value;type:decimal(10,2)"`
+
+	// Reference range in the canonical unit; nil bounds are not checked.
+	Low                 *float64 `gorm:"column:low;type:decimal(10,2)"`
+	High                *float64 `gorm:"column:high;type:decimal(10,2)"`
+	CriticalLow         *float64 `gorm:"column:critical_low;type:decimal(10,2)"`
+	CriticalHigh        *float64 `gorm:"column:critical_high;type:decimal(10,2)"`
+	MaxDeviationPercent *float64 `gorm:"column:max_deviation_percent;type:decimal(5,2)"`
+
+	IsBuiltin bool `gorm:"column:is_builtin;default:false"`
+	IsActive  bool `gorm:"column:is_active;default:true;index"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamptz;default:now()"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamptz;default:now()"`
+}
+
+// UnitConversion maps a canonical value v to another unit as v*Factor + Offset.
+type UnitConversion struct {
+	Factor float64 `json:"factor"`
+	Offset float64 `json:"offset,omitempty"`
+}
+
+// VitalUnitConversions is the schema of VitalTypeDefinition.Units.
+type VitalUnitConversions map[enums.VitalUnit]UnitConversion
+
+// Conversions decodes Units. Types with only the canonical unit yield an empty map.
+func (d *VitalTypeDefinition) Conversions() (VitalUnitConversions, error) {
+	conversions := VitalUnitConversions{}
+	if err := d.Units.Unmarshal(&conversions); err != nil {
+		return nil, err
+	}
+	return conversions, nil
+}
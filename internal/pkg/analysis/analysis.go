@@ -0,0 +1,180 @@
+// Package analysis triages completed checkins: it reads what the patient
+// answered and measured against their condition, medications and baseline
+// vitals, and returns a medical status, a risk score and a summary for the
+// doctor. Analyzers are given everything they need in an Input and know
+// nothing of the database.
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Medical statuses, from least to most severe.
+const (
+	StatusNormal   = "NORMAL"
+	StatusConcern  = "CONCERN"
+	StatusUrgent   = "URGENT"
+	StatusCritical = "CRITICAL"
+)
+
+// Alert severities, from least to most severe.
+const (
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+var (
+	statuses   = []string{StatusNormal, StatusConcern, StatusUrgent, StatusCritical}
+	severities = []string{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+)
+
+// Analyzer analyzes one checkin. Implementations must be safe for concurrent
+// use.
+type Analyzer interface {
+	Analyze(ctx context.Context, in Input) (*Result, error)
+}
+
+// Input is a completed checkin with the patient context it is read against.
+type Input struct {
+	Patient Patient
+	Checkin Checkin
+}
+
+type Patient struct {
+	Gender           string
+	ConditionSummary string
+	Comorbidities    []string
+	Medications      []string // one line per medication, as recorded
+	Allergies        []string
+	RiskLevel        string
+	Baselines        []Baseline
+}
+
+// Baseline is the patient's usual value of a vital type, in its canonical unit.
+type Baseline struct {
+	VitalType string
+	Value     string // "120/80" for blood pressure
+	Unit      string
+}
+
+type Checkin struct {
+	StartedAt   time.Time
+	CompletedAt *time.Time
+	// Partial is set when the patient stopped before answering everything;
+	// Unanswered are the questions they left.
+	Partial    bool
+	Unanswered []string
+	Answers    []Answer
+	Messages   []string // the patient's messages as sent
+	Vitals     []Vital
+	// EarlyWarning is the NEWS2 score of the checkin's vitals, when enough
+	// of them were measured.
+	EarlyWarning *EarlyWarning
+	Instruments  []InstrumentScore
+	// Alerts are those vital-sync has already raised for the checkin.
+	Alerts []Alert
+}
+
+type Answer struct {
+	Question string
+	Answer   string
+}
+
+type Vital struct {
+	VitalType string
+	Value     string
+	Unit      string
+	Context   string
+	Abnormal  bool
+	// Deviation is the value minus the patient's baseline, in Unit.
+	Deviation *float64
+}
+
+type EarlyWarning struct {
+	Score int
+	Risk  string // LOW, LOW_MEDIUM, MEDIUM or HIGH
+}
+
+type InstrumentScore struct {
+	Instrument string // PHQ9, GAD7, KCCQ12 or CAT
+	Score      float64
+	Band       string
+}
+
+type Alert struct {
+	Severity string
+	Title    string
+	Message  string
+}
+
+// Result is the analysis of a checkin.
+type Result struct {
+	MedicalStatus   string
+	RiskScore       int // 0-100
+	Summary         string
+	Concerns        []string
+	Recommendations []string
+	// Alert is raised for the doctor on top of the alerts vital-sync raises
+	// itself, for findings only the analysis can make.
+	Alert *Alert
+
+	// Provenance
+	Provider      string
+	Model         string
+	PromptVersion string // empty for analyzers without prompts
+	Latency       time.Duration
+	// FallbackReason is why the primary analyzer was not used, when a
+	// fallback produced the result.
+	FallbackReason string
+}
+
+// Validate checks a result is one vital-sync can store.
+func (r *Result) Validate() error {
+	if !slices.Contains(statuses, r.MedicalStatus) {
+		return fmt.Errorf("medical status %q is not one of %v", r.MedicalStatus, statuses)
+	}
+	if r.RiskScore < 0 || r.RiskScore > 100 {
+		return fmt.Errorf("risk score %d is not between 0 and 100", r.RiskScore)
+	}
+	if strings.TrimSpace(r.Summary) == "" {
+		return fmt.Errorf("summary is empty")
+	}
+	if r.Alert != nil {
+		if !slices.Contains(severities, r.Alert.Severity) {
+			return fmt.Errorf("alert severity %q is not one of %v", r.Alert.Severity, severities)
+		}
+		if strings.TrimSpace(r.Alert.Title) == "" || strings.TrimSpace(r.Alert.Message) == "" {
+			return fmt.Errorf("alert needs a title and a message")
+		}
+	}
+	return nil
+}
+
+// WithFallback analyzes with primary and, when it fails, with fallback. The
+// result then records why primary was not used.
+func WithFallback(primary, fallback Analyzer) Analyzer {
+	return fallbackAnalyzer{primary: primary, fallback: fallback}
+}
+
+type fallbackAnalyzer struct {
+	primary, fallback Analyzer
+}
+
+func (a fallbackAnalyzer) Analyze(ctx context.Context, in Input) (*Result, error) {
+	result, err := a.primary.Analyze(ctx, in)
+	if err == nil {
+		return result, nil
+	}
+	result, fallbackErr := a.fallback.Analyze(ctx, in)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w; fallback: %v", err, fallbackErr)
+	}
+	result.FallbackReason = err.Error()
+	return result, nil
+}
@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProviderOpenAI names analyses by OpenAICompatible.
+const ProviderOpenAI = "openai"
+
+// maxResponseBytes bounds what is read of a completion response.
+const maxResponseBytes = 1 << 20
+
+// OpenAIConfig configures an OpenAICompatible analyzer.
+type OpenAIConfig struct {
+	// BaseURL is the API root the chat completions endpoint hangs off, such
+	// as "https://api.openai.com/v1" or that of a self-hosted server.
+	BaseURL       string
+	APIKey        string // sent as a bearer token when set
+	Model         string
+	PromptVersion string        // empty means DefaultPromptVersion
+	Timeout       time.Duration // of each request; 0 means no timeout besides the context's
+	Client        *http.Client  // empty means http.DefaultClient
+}
+
+// OpenAICompatible analyzes checkins with a chat model served over the OpenAI
+// chat completions API, which most hosted and self-hosted model servers
+// offer. The model is asked for a JSON object; replies that are not valid
+// results are errors.
+type OpenAICompatible struct {
+	cfg OpenAIConfig
+}
+
+func NewOpenAICompatible(cfg OpenAIConfig) (*OpenAICompatible, error) {
+	cfg.BaseURL = strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("analysis: base url is required")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("analysis: model is required")
+	}
+	if cfg.PromptVersion == "" {
+		cfg.PromptVersion = DefaultPromptVersion
+	}
+	if _, err := loadPrompt(cfg.PromptVersion); err != nil {
+		return nil, fmt.Errorf("analysis: %w", err)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &OpenAICompatible{cfg: cfg}, nil
+}
+
+type chatRequest struct {
+	Model          string         `json:"model"`
+	Messages       []chatMessage  `json:"messages"`
+	Temperature    float64        `json:"temperature"`
+	ResponseFormat map[string]any `json:"response_format"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// reply is the JSON object the prompt asks the model for.
+type reply struct {
+	MedicalStatus   string   `json:"medical_status"`
+	RiskScore       *float64 `json:"risk_score"`
+	Summary         string   `json:"summary"`
+	Concerns        []string `json:"concerns"`
+	Recommendations []string `json:"recommendations"`
+	Alert           *struct {
+		Severity string `json:"severity"`
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+	} `json:"alert"`
+}
+
+func (a *OpenAICompatible) Analyze(ctx context.Context, in Input) (*Result, error) {
+	prompt, err := RenderPrompt(a.cfg.PromptVersion, in)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(chatRequest{
+		Model: a.cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: prompt.System},
+			{Role: "user", Content: prompt.User},
+		},
+		Temperature:    0,
+		ResponseFormat: map[string]any{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if a.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.cfg.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.cfg.APIKey)
+	}
+
+	started := time.Now()
+	resp, err := a.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("analysis: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	latency := time.Since(started)
+	if err != nil {
+		return nil, fmt.Errorf("analysis: reading response: %w", err)
+	}
+
+	var completion chatResponse
+	decodeErr := json.Unmarshal(raw, &completion)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && completion.Error != nil && completion.Error.Message != "" {
+			return nil, fmt.Errorf("analysis: %s: %s", resp.Status, completion.Error.Message)
+		}
+		return nil, fmt.Errorf("analysis: %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("analysis: decoding response: %w", decodeErr)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("analysis: response has no choices")
+	}
+
+	result, err := parseReply(completion.Choices[0].Message.Content)
+	if err != nil {
+		return nil, fmt.Errorf("analysis: %w", err)
+	}
+	result.Provider = ProviderOpenAI
+	result.Model = completion.Model
+	if result.Model == "" {
+		result.Model = a.cfg.Model
+	}
+	result.PromptVersion = prompt.Version
+	result.Latency = latency
+	return result, nil
+}
+
+// parseReply reads the model's JSON object, which some models wrap in a
+// markdown code fence despite being asked not to.
+func parseReply(content string) (*Result, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+
+	var r reply
+	if err := json.Unmarshal([]byte(content), &r); err != nil {
+		return nil, fmt.Errorf("model reply is not a JSON object: %w", err)
+	}
+	if r.RiskScore == nil {
+		return nil, fmt.Errorf("model reply has no risk score")
+	}
+	result := Result{
+		MedicalStatus:   strings.ToUpper(strings.TrimSpace(r.MedicalStatus)),
+		RiskScore:       int(*r.RiskScore + 0.5),
+		Summary:         strings.TrimSpace(r.Summary),
+		Concerns:        nonEmpty(r.Concerns),
+		Recommendations: nonEmpty(r.Recommendations),
+	}
+	if r.Alert != nil {
+		result.Alert = &Alert{
+			Severity: strings.ToUpper(strings.TrimSpace(r.Alert.Severity)),
+			Title:    strings.TrimSpace(r.Alert.Title),
+			Message:  strings.TrimSpace(r.Alert.Message),
+		}
+	}
+	if err := result.Validate(); err != nil {
+		return nil, fmt.Errorf("model reply: %w", err)
+	}
+	return &result, nil
+}
+
+func nonEmpty(items []string) []string {
+	out := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,227 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubModel serves the chat completions API, replying to each request with
+// handle.
+func stubModel(t *testing.T, handle http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		handle(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// completion replies with a completion whose message is content.
+func completion(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model": "stub-model-1",
+			"choices": []map[string]any{{
+				"message":       map[string]string{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			}},
+		})
+	}
+}
+
+func newStubAnalyzer(t *testing.T, srv *httptest.Server, timeout time.Duration) *OpenAICompatible {
+	t.Helper()
+	a, err := NewOpenAICompatible(OpenAIConfig{
+		BaseURL: srv.URL + "/v1/",
+		APIKey:  "test-key",
+		Model:   "stub-model",
+		Timeout: timeout,
+		Client:  srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatible: %v", err)
+	}
+	return a
+}
+
+func testInput() Input {
+	return Input{
+		Patient: Patient{ConditionSummary: "Heart failure", RiskLevel: "HIGH"},
+		Checkin: Checkin{
+			StartedAt: time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC),
+			Answers:   []Answer{{Question: "How is your breathing?", Answer: "worse than yesterday"}},
+			Vitals:    []Vital{{VitalType: "OXYGEN_SATURATION", Value: "91", Unit: "%", Abnormal: true}},
+		},
+	}
+}
+
+func TestOpenAICompatibleAnalyze(t *testing.T) {
+	var got chatRequest
+	srv := stubModel(t, func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want the bearer API key", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		completion("```json\n"+`{
+			"medical_status": "urgent",
+			"risk_score": 61.6,
+			"summary": "Breathing worse with low oxygen saturation.",
+			"concerns": ["SpO2 91%", " "],
+			"recommendations": ["Call the patient today"],
+			"alert": {"severity": "high", "title": "Low SpO2", "message": "SpO2 fell to 91%."}
+		}`+"\n```")(w, r)
+	})
+
+	result, err := newStubAnalyzer(t, srv, time.Second).Analyze(context.Background(), testInput())
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if got.Model != "stub-model" || len(got.Messages) != 2 || got.Messages[0].Role != "system" {
+		t.Errorf("request = model %q with %d messages, want stub-model with a system and a user message", got.Model, len(got.Messages))
+	}
+	if len(got.Messages) == 2 && !strings.Contains(got.Messages[1].Content, "worse than yesterday") {
+		t.Errorf("user message does not carry the checkin's answers:\n%s", got.Messages[1].Content)
+	}
+	if result.MedicalStatus != StatusUrgent || result.RiskScore != 62 {
+		t.Errorf("status, risk = %s, %d; want URGENT, 62", result.MedicalStatus, result.RiskScore)
+	}
+	if len(result.Concerns) != 1 || len(result.Recommendations) != 1 {
+		t.Errorf("concerns, recommendations = %q, %q; want one each", result.Concerns, result.Recommendations)
+	}
+	if result.Alert == nil || result.Alert.Severity != SeverityHigh {
+		t.Errorf("alert = %+v, want a HIGH alert", result.Alert)
+	}
+	if result.Provider != ProviderOpenAI || result.Model != "stub-model-1" || result.PromptVersion != DefaultPromptVersion {
+		t.Errorf("provenance = %s/%s/%s, want %s/stub-model-1/%s", result.Provider, result.Model, result.PromptVersion, ProviderOpenAI, DefaultPromptVersion)
+	}
+	if result.FallbackReason != "" {
+		t.Errorf("FallbackReason = %q, want none", result.FallbackReason)
+	}
+}
+
+func TestOpenAICompatibleAnalyzeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  http.HandlerFunc
+		timeout time.Duration
+		want    string
+	}{
+		{
+			name:   "reply is not JSON",
+			handle: completion("The patient seems fine."),
+			want:   "not a JSON object",
+		},
+		{
+			name:   "reply without risk score",
+			handle: completion(`{"medical_status": "NORMAL", "summary": "Fine."}`),
+			want:   "no risk score",
+		},
+		{
+			name:   "reply with unknown status",
+			handle: completion(`{"medical_status": "GOOD", "risk_score": 10, "summary": "Fine."}`),
+			want:   "medical status",
+		},
+		{
+			name: "response is not JSON",
+			handle: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("<html>bad gateway</html>"))
+			},
+			want: "decoding response",
+		},
+		{
+			name: "response without choices",
+			handle: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"model": "stub-model-1", "choices": []}`))
+			},
+			want: "no choices",
+		},
+		{
+			name: "client error",
+			handle: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": {"message": "context length exceeded"}}`))
+			},
+			want: "context length exceeded",
+		},
+		{
+			name: "timeout",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				// the server sees the client give up only once the body is read
+				_, _ = io.Copy(io.Discard, r.Body)
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+				completion(`{"medical_status": "NORMAL", "risk_score": 5, "summary": "Fine."}`)(w, r)
+			},
+			timeout: 50 * time.Millisecond,
+			want:    "deadline exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = time.Second
+			}
+			srv := stubModel(t, tt.handle)
+
+			result, err := newStubAnalyzer(t, srv, timeout).Analyze(context.Background(), testInput())
+			if err == nil {
+				t.Fatalf("Analyze = %+v, want an error", result)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	srv := stubModel(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	analyzer := WithFallback(newStubAnalyzer(t, srv, time.Second), Rules{})
+
+	result, err := analyzer.Analyze(context.Background(), testInput())
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.Provider != ProviderRules || result.Model != RulesVersion {
+		t.Errorf("provenance = %s/%s, want the rule-based analyzer", result.Provider, result.Model)
+	}
+	if !strings.Contains(result.FallbackReason, "503") {
+		t.Errorf("FallbackReason = %q, want the model's failure", result.FallbackReason)
+	}
+	if err := result.Validate(); err != nil {
+		t.Errorf("fallback result is invalid: %v", err)
+	}
+}
+
+func TestWithFallbackKeepsPrimaryResult(t *testing.T) {
+	srv := stubModel(t, completion(`{"medical_status": "NORMAL", "risk_score": 5, "summary": "Stable."}`))
+	analyzer := WithFallback(newStubAnalyzer(t, srv, time.Second), Rules{})
+
+	result, err := analyzer.Analyze(context.Background(), testInput())
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.Provider != ProviderOpenAI || result.FallbackReason != "" {
+		t.Errorf("provider = %s with fallback reason %q, want the model's result", result.Provider, result.FallbackReason)
+	}
+}
@@ -0,0 +1,77 @@
+package analysis
+
+import (
+	"embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptVersion is the prompt analyzers use unless configured otherwise.
+const DefaultPromptVersion = "checkin-v2"
+
+// Prompts are versioned so every analysis records the wording it was made
+// with; a change in wording is a new file, never an edit of an old one. Each
+// defines a "system" and a "user" template over an Input.
+//
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+var promptFuncs = template.FuncMap{
+	"list": func(items []string) string {
+		if len(items) == 0 {
+			return "none recorded"
+		}
+		return strings.Join(items, ", ")
+	},
+	"signed": signed,
+}
+
+// signed formats a difference with its sign.
+func signed(v *float64) string {
+	if *v > 0 {
+		return "+" + strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// Prompt is a rendered prompt.
+type Prompt struct {
+	Version string
+	System  string
+	User    string
+}
+
+// RenderPrompt renders the prompt version for the input.
+func RenderPrompt(version string, in Input) (*Prompt, error) {
+	tmpl, err := loadPrompt(version)
+	if err != nil {
+		return nil, err
+	}
+	prompt := Prompt{Version: version}
+	for name, out := range map[string]*string{"system": &prompt.System, "user": &prompt.User} {
+		var b strings.Builder
+		if err := tmpl.ExecuteTemplate(&b, name, in); err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", version, err)
+		}
+		*out = strings.TrimSpace(b.String())
+	}
+	return &prompt, nil
+}
+
+func loadPrompt(version string) (*template.Template, error) {
+	if version == "" || strings.ContainsAny(version, "/\\.") {
+		return nil, fmt.Errorf("unknown prompt version %q", version)
+	}
+	tmpl, err := template.New(version).Funcs(promptFuncs).ParseFS(promptFiles, "prompts/"+version+".tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("unknown prompt version %q: %w", version, err)
+	}
+	for _, name := range []string{"system", "user"} {
+		if tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("prompt %s has no %q template", version, name)
+		}
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ProviderRules names analyses by Rules.
+const ProviderRules = "rules"
+
+// RulesVersion is recorded as the model of rule-based analyses; it changes
+// whenever the rules do.
+const RulesVersion = "rules-v2"
+
+var earlyWarningStatus = map[string]string{
+	"LOW":        StatusNormal,
+	"LOW_MEDIUM": StatusConcern,
+	"MEDIUM":     StatusUrgent,
+	"HIGH":       StatusCritical,
+}
+
+var alertStatus = map[string]string{
+	SeverityLow:      StatusNormal,
+	SeverityMedium:   StatusConcern,
+	SeverityHigh:     StatusUrgent,
+	SeverityCritical: StatusCritical,
+}
+
+// riskFloor is where each status's quarter of the risk scale starts.
+var riskFloor = map[string]int{
+	StatusNormal:   0,
+	StatusConcern:  25,
+	StatusUrgent:   50,
+	StatusCritical: 75,
+}
+
+var statusRecommendation = map[string]string{
+	StatusNormal:   "Routine review; no action needed.",
+	StatusConcern:  "Review within a day and consider contacting the patient.",
+	StatusUrgent:   "Contact the patient within hours to reassess.",
+	StatusCritical: "Contact the patient now and consider emergency care.",
+}
+
+// Rules is a deterministic analyzer. It reads only what vital-sync has
+// already measured and scored, so it never finds more than the abnormal
+// vitals, the early warning score and the alerts do, but it always
+// answers, the same way for the same checkin. It serves as the fallback of
+// model-based analyzers and where none is configured.
+//
+// The medical status is the most severe of those the findings map to: the
+// NEWS2 risk (LOW_MEDIUM is CONCERN, MEDIUM URGENT, HIGH CRITICAL), an
+// abnormal vital (CONCERN) and each alert (MEDIUM is CONCERN, HIGH URGENT,
+// CRITICAL CRITICAL). The risk score places the checkin within its status's
+// quarter of the scale by how many findings there are.
+type Rules struct{}
+
+func (Rules) Analyze(_ context.Context, in Input) (*Result, error) {
+	started := time.Now()
+	status := StatusNormal
+	raise := func(to string) {
+		if slices.Index(statuses, to) > slices.Index(statuses, status) {
+			status = to
+		}
+	}
+
+	points := 0
+	concerns := []string{}
+	if ew := in.Checkin.EarlyWarning; ew != nil {
+		if to, ok := earlyWarningStatus[ew.Risk]; ok {
+			raise(to)
+		}
+		points += 2 * ew.Score
+		if ew.Risk != "" && ew.Risk != "LOW" {
+			concerns = append(concerns, fmt.Sprintf("NEWS2 score %d (%s risk)", ew.Score, ew.Risk))
+		}
+	}
+
+	abnormal := 0
+	for _, v := range in.Checkin.Vitals {
+		if !v.Abnormal {
+			continue
+		}
+		abnormal++
+		points += 3
+		raise(StatusConcern)
+		concern := fmt.Sprintf("%s %s is abnormal", v.VitalType, strings.TrimSpace(v.Value+" "+v.Unit))
+		if v.Deviation != nil {
+			concern += fmt.Sprintf(" (%s from baseline)", strings.TrimSpace(signed(v.Deviation)+" "+v.Unit))
+		}
+		concerns = append(concerns, concern)
+	}
+
+	for _, a := range in.Checkin.Alerts {
+		if to, ok := alertStatus[a.Severity]; ok {
+			raise(to)
+		}
+		points += 2 * (slices.Index(severities, a.Severity) + 1)
+		concerns = append(concerns, fmt.Sprintf("%s alert: %s", a.Severity, a.Title))
+	}
+
+	risk := riskFloor[status] + min(points, 24)
+
+	summary := fmt.Sprintf("Rule-based triage: %s. The patient answered %d question(s) and reported %d vital(s), %d abnormal.",
+		status, len(in.Checkin.Answers), len(in.Checkin.Vitals), abnormal)
+	if ew := in.Checkin.EarlyWarning; ew != nil {
+		summary += fmt.Sprintf(" NEWS2 is %d (%s risk).", ew.Score, ew.Risk)
+	}
+	if n := len(in.Checkin.Alerts); n > 0 {
+		summary += fmt.Sprintf(" %d alert(s) raised.", n)
+	}
+	if in.Checkin.Partial {
+		summary += fmt.Sprintf(" The checkin is partial: %d question(s) were left unanswered.", len(in.Checkin.Unanswered))
+		concerns = append(concerns, "Checkin is partial; unanswered questions may hide symptoms")
+	}
+	summary += " Free-text answers were not assessed."
+
+	return &Result{
+		MedicalStatus:   status,
+		RiskScore:       risk,
+		Summary:         summary,
+		Concerns:        concerns,
+		Recommendations: []string{statusRecommendation[status]},
+		Provider:        ProviderRules,
+		Model:           RulesVersion,
+		Latency:         time.Since(started),
+	}, nil
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRulesAnalyze(t *testing.T) {
+	deviation := 40.0
+	tachycardia := Vital{VitalType: "HEART_RATE", Value: "118", Unit: "bpm", Abnormal: true, Deviation: &deviation}
+	checkin := func(c Checkin) Input {
+		return Input{Patient: Patient{ConditionSummary: "Heart failure"}, Checkin: c}
+	}
+
+	tests := []struct {
+		name    string
+		in      Input
+		status  string
+		risk    int
+		concern string
+	}{
+		{"nothing found", checkin(Checkin{Vitals: []Vital{{VitalType: "WEIGHT", Value: "80", Unit: "kg"}}}), StatusNormal, 0, ""},
+		{"abnormal vital", testInput(), StatusConcern, 28, "OXYGEN_SATURATION 91 % is abnormal"},
+		{"deviation from baseline", checkin(Checkin{Vitals: []Vital{tachycardia}}), StatusConcern, 28, "HEART_RATE 118 bpm is abnormal (+40 bpm from baseline)"},
+		{"low NEWS2 risk", checkin(Checkin{EarlyWarning: &EarlyWarning{Score: 2, Risk: "LOW"}}), StatusNormal, 4, ""},
+		{"low-medium NEWS2 risk", checkin(Checkin{EarlyWarning: &EarlyWarning{Score: 3, Risk: "LOW_MEDIUM"}}), StatusConcern, 31, "NEWS2 score 3 (LOW_MEDIUM risk)"},
+		{"medium NEWS2 risk", checkin(Checkin{EarlyWarning: &EarlyWarning{Score: 5, Risk: "MEDIUM"}}), StatusUrgent, 60, "NEWS2 score 5 (MEDIUM risk)"},
+		{
+			"alert outranks the vital",
+			checkin(Checkin{Vitals: []Vital{tachycardia}, Alerts: []Alert{{Severity: SeverityHigh, Title: "Heart rate above 110"}}}),
+			StatusUrgent, 59, "HIGH alert: Heart rate above 110",
+		},
+		{"low alert", checkin(Checkin{Alerts: []Alert{{Severity: SeverityLow, Title: "Missed dose"}}}), StatusNormal, 2, "LOW alert: Missed dose"},
+		{
+			"points stop at the top of the quarter",
+			checkin(Checkin{EarlyWarning: &EarlyWarning{Score: 9, Risk: "HIGH"}, Alerts: []Alert{{Severity: SeverityCritical, Title: "SpO2 below 88"}}}),
+			StatusCritical, 99, "CRITICAL alert: SpO2 below 88",
+		},
+		{"partial checkin", checkin(Checkin{Partial: true, Unanswered: []string{"Any chest pain?"}}), StatusNormal, 0, "Checkin is partial; unanswered questions may hide symptoms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Rules{}.Analyze(context.Background(), tt.in)
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if result.MedicalStatus != tt.status || result.RiskScore != tt.risk {
+				t.Errorf("status, risk = %s, %d; want %s, %d", result.MedicalStatus, result.RiskScore, tt.status, tt.risk)
+			}
+			if tt.concern == "" && len(result.Concerns) > 0 {
+				t.Errorf("concerns = %q, want none", result.Concerns)
+			}
+			if tt.concern != "" && !slices.Contains(result.Concerns, tt.concern) {
+				t.Errorf("concerns = %q, want %q", result.Concerns, tt.concern)
+			}
+			if len(result.Recommendations) != 1 || result.Recommendations[0] != statusRecommendation[tt.status] {
+				t.Errorf("recommendations = %q", result.Recommendations)
+			}
+			if result.Provider != ProviderRules || result.Model != RulesVersion || result.PromptVersion != "" || result.Alert != nil {
+				t.Errorf("result = %+v, want a rules analysis without its own alert", result)
+			}
+		})
+	}
+}
+
+func TestRulesAnalyzeSummary(t *testing.T) {
+	in := testInput()
+	in.Checkin.EarlyWarning = &EarlyWarning{Score: 4, Risk: "LOW_MEDIUM"}
+	in.Checkin.Alerts = []Alert{{Severity: SeverityMedium, Title: "SpO2 below 92"}}
+	in.Checkin.Partial, in.Checkin.Unanswered = true, []string{"Any swelling?", "Any chest pain?"}
+
+	first, err := Rules{}.Analyze(context.Background(), in)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	for _, part := range []string{
+		"Rule-based triage: CONCERN.",
+		"answered 1 question(s) and reported 1 vital(s), 1 abnormal.",
+		"NEWS2 is 4 (LOW_MEDIUM risk).",
+		"1 alert(s) raised.",
+		"2 question(s) were left unanswered.",
+		"Free-text answers were not assessed.",
+	} {
+		if !strings.Contains(first.Summary, part) {
+			t.Errorf("summary %q does not say %q", first.Summary, part)
+		}
+	}
+
+	// the same checkin is always analyzed the same way
+	again, err := Rules{}.Analyze(context.Background(), in)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if again.MedicalStatus != first.MedicalStatus || again.RiskScore != first.RiskScore || again.Summary != first.Summary || !slices.Equal(again.Concerns, first.Concerns) {
+		t.Errorf("second analysis = %+v, want the same as %+v", again, first)
+	}
+}
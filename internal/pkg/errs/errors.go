@@ -1,16 +1,176 @@
 package errs
 
-import "errors"
-
-var (
-	ErrInvalidToken        = errors.New("invalid token format")
-	ErrExpiredToken        = errors.New("token has expired")
-	ErrActiveCheckinExists = errors.New("active checkin already exists for this patient")
-	ErrNoActiveCheckin     = errors.New("no active checkin found for this patient")
-	ErrCheckinNotActive    = errors.New("checkin is not active")
-	ErrScheduleExists      = errors.New("checkin schedule already exists for this patient")
-	ErrCheckinNotCompleted = errors.New("checkin is not completed yet")
-	ErrMissingAlertFields  = errors.New("alert input missing required fields")
-	ErrCheckinNotAnalyzed  = errors.New("checkin has not been analyzed yet")
-	ErrNoActiveSchedule    = errors.New("no active checkin schedule found for this patient")
+import (
+	"errors"
+	"net/http"
+)
+
+// AppError is an error that knows how it should be presented to API clients:
+// an HTTP status, a stable machine-readable code and a human-readable message.
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+
+	cause error
+}
+
+// FieldError points at a single invalid input, e.g. "items[0].seq" or "query.limit".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(status int, code, message string) *AppError {
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+func (e *AppError) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches any AppError with the same code, so copies made by WithMessage,
+// WithFields or Wrap still satisfy errors.Is against the sentinel.
+func (e *AppError) Is(target error) bool {
+	var t *AppError
+	if !errors.As(target, &t) {
+		return false
+	}
+	return t.Code == e.Code
+}
+
+func (e *AppError) WithMessage(message string) *AppError {
+	cp := *e
+	cp.Message = message
+	return &cp
+}
+
+func (e *AppError) WithFields(fields ...FieldError) *AppError {
+	cp := *e
+	cp.Fields = append(append([]FieldError{}, e.Fields...), fields...)
+	return &cp
+}
+
+// Wrap attaches an underlying cause. The cause is logged but never rendered.
+func (e *AppError) Wrap(cause error) *AppError {
+	cp := *e
+	cp.cause = cause
+	return &cp
+}
+
+// Validation builds a validation error for the given fields.
+func Validation(fields ...FieldError) *AppError {
+	return ErrValidation.WithFields(fields...)
+}
+
+// InvalidField is a shorthand for a validation error on one field.
+func InvalidField(field, message string) *AppError {
+	return Validation(FieldError{Field: field, Message: message})
+}
+
+// As returns the AppError carried by err, or nil if there is none.
+func As(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return nil
+}
+
+// generic errors
+var (
+	ErrInternal             = New(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+	ErrValidation           = New(http.StatusBadRequest, "VALIDATION_FAILED", "request validation failed")
+	ErrMalformedBody        = New(http.StatusBadRequest, "MALFORMED_BODY", "request body is not valid JSON")
+	ErrNotFound             = New(http.StatusNotFound, "NOT_FOUND", "resource not found")
+	ErrRouteNotFound        = New(http.StatusNotFound, "ROUTE_NOT_FOUND", "route not found")
+	ErrMethodNotAllowed     = New(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed")
+	ErrUnsupportedMediaType = New(http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "request body must be application/json")
+)
+
+// auth errors
+var (
+	ErrMissingAuthHeader = New(http.StatusUnauthorized, "AUTH_HEADER_MISSING", "authorization header is required")
+	ErrInvalidToken      = New(http.StatusUnauthorized, "INVALID_TOKEN", "invalid token format")
+	ErrExpiredToken      = New(http.StatusUnauthorized, "TOKEN_EXPIRED", "token has expired")
+)
+
+// not found errors per resource
+var (
+	ErrOrganizationNotFound = New(http.StatusNotFound, "ORGANIZATION_NOT_FOUND", "organization not found")
+	ErrDoctorNotFound       = New(http.StatusNotFound, "DOCTOR_NOT_FOUND", "doctor not found")
+	ErrPatientNotFound      = New(http.StatusNotFound, "PATIENT_NOT_FOUND", "patient not found")
+	ErrUserNotFound         = New(http.StatusNotFound, "USER_NOT_FOUND", "user not found")
+	ErrAssignmentNotFound   = New(http.StatusNotFound, "ASSIGNMENT_NOT_FOUND", "organization assignment not found")
+	ErrCheckinNotFound      = New(http.StatusNotFound, "CHECKIN_NOT_FOUND", "checkin not found")
+	ErrScheduleNotFound     = New(http.StatusNotFound, "SCHEDULE_NOT_FOUND", "checkin schedule not found")
+	ErrVitalReadingNotFound = New(http.StatusNotFound, "VITAL_READING_NOT_FOUND", "vital reading not found")
+	ErrRuleNotFound         = New(http.StatusNotFound, "THRESHOLD_RULE_NOT_FOUND", "threshold rule not found")
+	ErrVitalTypeNotFound    = New(http.StatusNotFound, "VITAL_TYPE_NOT_FOUND", "vital type not found")
+	ErrAlertNotFound        = New(http.StatusNotFound, "ALERT_NOT_FOUND", "alert not found")
+	ErrHL7MessageNotFound   = New(http.StatusNotFound, "HL7_MESSAGE_NOT_FOUND", "hl7 message not found")
+)
+
+// domain errors
+var (
+	ErrActiveCheckinExists = New(http.StatusConflict, "ACTIVE_CHECKIN_EXISTS", "active checkin already exists for this patient")
+	ErrNoActiveCheckin     = New(http.StatusNotFound, "NO_ACTIVE_CHECKIN", "no active checkin found for this patient")
+	ErrCheckinNotActive    = New(http.StatusConflict, "CHECKIN_NOT_ACTIVE", "checkin is not active")
+	ErrScheduleExists      = New(http.StatusConflict, "SCHEDULE_EXISTS", "checkin schedule already exists for this patient")
+	ErrCheckinNotCompleted = New(http.StatusConflict, "CHECKIN_NOT_COMPLETED", "checkin is not completed yet")
+	ErrMissingAlertFields  = New(http.StatusBadRequest, "ALERT_FIELDS_MISSING", "alert input missing required fields")
+	ErrCheckinNotAnalyzed  = New(http.StatusConflict, "CHECKIN_NOT_ANALYZED", "checkin has not been analyzed yet")
+	ErrCheckinReviewed     = New(http.StatusConflict, "CHECKIN_REVIEWED", "checkin has been reviewed; its analysis can no longer change")
+	ErrCheckinTransition   = New(http.StatusConflict, "CHECKIN_TRANSITION_NOT_ALLOWED", "checkin cannot move to this status")
+	ErrNoActiveSchedule    = New(http.StatusNotFound, "NO_ACTIVE_SCHEDULE", "no active checkin schedule found for this patient")
+	ErrPatientInfoExists   = New(http.StatusConflict, "PATIENT_INFO_EXISTS", "patient medical info already exists")
+	ErrUserExists          = New(http.StatusConflict, "USER_EXISTS", "a user with this phone number or telegram username already exists")
+	ErrBotUnavailable      = New(http.StatusBadGateway, "BOT_UNAVAILABLE", "bot service failed to start the checkin")
+	ErrRuleScope           = New(http.StatusBadRequest, "THRESHOLD_RULE_SCOPE", "threshold rule needs exactly one of organization_id and patient_id")
+	ErrRuleKeyExists       = New(http.StatusConflict, "THRESHOLD_RULE_KEY_EXISTS", "a threshold rule with this key already exists in the same scope")
+	ErrVitalTypeExists     = New(http.StatusConflict, "VITAL_TYPE_EXISTS", "a vital type with this code already exists")
+	ErrVitalTypeBuiltin    = New(http.StatusConflict, "VITAL_TYPE_BUILTIN", "built-in vital types cannot be deleted; deactivate them instead")
+	ErrVitalTypeInUse      = New(http.StatusConflict, "VITAL_TYPE_IN_USE", "vital type has readings; deactivate it instead")
+)
+
+// questionnaire errors
+var (
+	ErrQuestionnaireTemplateNotFound   = New(http.StatusNotFound, "QUESTIONNAIRE_TEMPLATE_NOT_FOUND", "questionnaire template not found")
+	ErrQuestionnaireAssignmentNotFound = New(http.StatusNotFound, "QUESTIONNAIRE_ASSIGNMENT_NOT_FOUND", "questionnaire assignment not found")
+	ErrQuestionnaireAssignmentScope    = New(http.StatusBadRequest, "QUESTIONNAIRE_ASSIGNMENT_SCOPE", "questionnaire assignment needs exactly one of patient_id and condition")
+	ErrQuestionnaireAssignmentExists   = New(http.StatusConflict, "QUESTIONNAIRE_ASSIGNMENT_EXISTS", "the patient already has a questionnaire assignment")
+	ErrNoQuestionnaire                 = New(http.StatusNotFound, "NO_QUESTIONNAIRE", "checkin has no questionnaire template")
+	ErrInstrumentNotFound              = New(http.StatusNotFound, "INSTRUMENT_NOT_FOUND", "instrument not found")
+)
+
+// review queue errors
+var (
+	ErrCheckinClaimed       = New(http.StatusConflict, "CHECKIN_CLAIMED", "another doctor has claimed this checkin for review")
+	ErrCheckinNotInPractice = New(http.StatusForbidden, "CHECKIN_NOT_IN_PRACTICE", "checkin belongs to a patient outside the doctor's practice")
+)
+
+// analysis errors
+var (
+	ErrAnalysisDisabled = New(http.StatusConflict, "ANALYSIS_DISABLED", "no analyzer is configured; checkins are analyzed by the outside analysis service")
+	ErrAnalysisFailed   = New(http.StatusBadGateway, "ANALYSIS_FAILED", "checkin analysis failed")
+)
+
+// concurrency errors
+var (
+	ErrIfMatchRequired = New(http.StatusPreconditionRequired, "IF_MATCH_REQUIRED", "If-Match header with the resource ETag is required")
+	ErrVersionMismatch = New(http.StatusPreconditionFailed, "VERSION_MISMATCH", "resource was modified by another request; reload it and retry")
+)
+
+// idempotency errors
+var (
+	ErrIdempotencyKeyReused  = New(http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "idempotency key was already used for a different request")
+	ErrIdempotencyInProgress = New(http.StatusConflict, "IDEMPOTENCY_REQUEST_IN_PROGRESS", "a request with this idempotency key is still being processed")
 )
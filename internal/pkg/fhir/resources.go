@@ -0,0 +1,339 @@
+// Package fhir holds the subset of FHIR R4 resources vital-sync exports and a
+// structural check of their JSON representation.
+package fhir
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	ContentType = "application/fhir+json"
+	Version     = "4.0.1"
+)
+
+// Code systems used by the export. Codes without a standard equivalent use
+// the urn:vital-sync systems.
+const (
+	SystemLOINC               = "http://loinc.org"
+	SystemUCUM                = "http://unitsofmeasure.org"
+	SystemObservationCategory = "http://terminology.hl7.org/CodeSystem/observation-category"
+	SystemObservationInterp   = "http://terminology.hl7.org/CodeSystem/v3-ObservationInterpretation"
+	SystemFlagCategory        = "http://terminology.hl7.org/CodeSystem/flag-category"
+	SystemContactRelationship = "http://terminology.hl7.org/CodeSystem/v2-0131"
+	SystemVitalType           = "urn:vital-sync:vital-type"
+	SystemAlertType           = "urn:vital-sync:alert-type"
+	SystemUserID              = "urn:vital-sync:user-id"
+	SystemMedicalStatus       = "urn:vital-sync:medical-status"
+)
+
+type Meta struct {
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+type Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type HumanName struct {
+	Use    string   `json:"use,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type ContactPoint struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Use    string `json:"use,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type Quantity struct {
+	Value  *float64 `json:"value,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
+	System string   `json:"system,omitempty"`
+	Code   string   `json:"code,omitempty"`
+}
+
+type Period struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+type Patient struct {
+	ResourceType        string           `json:"resourceType"`
+	ID                  string           `json:"id,omitempty"`
+	Meta                *Meta            `json:"meta,omitempty"`
+	Identifier          []Identifier     `json:"identifier,omitempty"`
+	Active              *bool            `json:"active,omitempty"`
+	Name                []HumanName      `json:"name,omitempty"`
+	Telecom             []ContactPoint   `json:"telecom,omitempty"`
+	Gender              string           `json:"gender,omitempty"`
+	Contact             []PatientContact `json:"contact,omitempty"`
+	GeneralPractitioner []Reference      `json:"generalPractitioner,omitempty"`
+}
+
+type PatientContact struct {
+	Relationship []CodeableConcept `json:"relationship,omitempty"`
+	Name         *HumanName        `json:"name,omitempty"`
+	Telecom      []ContactPoint    `json:"telecom,omitempty"`
+}
+
+type Observation struct {
+	ResourceType      string                 `json:"resourceType"`
+	ID                string                 `json:"id,omitempty"`
+	Meta              *Meta                  `json:"meta,omitempty"`
+	Status            string                 `json:"status"`
+	Category          []CodeableConcept      `json:"category,omitempty"`
+	Code              CodeableConcept        `json:"code"`
+	Subject           *Reference             `json:"subject,omitempty"`
+	EffectiveDateTime string                 `json:"effectiveDateTime,omitempty"`
+	Issued            string                 `json:"issued,omitempty"`
+	ValueQuantity     *Quantity              `json:"valueQuantity,omitempty"`
+	ValueString       string                 `json:"valueString,omitempty"`
+	Interpretation    []CodeableConcept      `json:"interpretation,omitempty"`
+	Note              []Annotation           `json:"note,omitempty"`
+	Device            *Reference             `json:"device,omitempty"`
+	DerivedFrom       []Reference            `json:"derivedFrom,omitempty"`
+	Component         []ObservationComponent `json:"component,omitempty"`
+}
+
+type ObservationComponent struct {
+	Code          CodeableConcept `json:"code"`
+	ValueQuantity *Quantity       `json:"valueQuantity,omitempty"`
+}
+
+type Flag struct {
+	ResourceType string            `json:"resourceType"`
+	ID           string            `json:"id,omitempty"`
+	Meta         *Meta             `json:"meta,omitempty"`
+	Status       string            `json:"status"`
+	Category     []CodeableConcept `json:"category,omitempty"`
+	Code         CodeableConcept   `json:"code"`
+	Subject      Reference         `json:"subject"`
+	Period       *Period           `json:"period,omitempty"`
+}
+
+type DetectedIssue struct {
+	ResourceType       string                    `json:"resourceType"`
+	ID                 string                    `json:"id,omitempty"`
+	Meta               *Meta                     `json:"meta,omitempty"`
+	Status             string                    `json:"status"`
+	Code               *CodeableConcept          `json:"code,omitempty"`
+	Severity           string                    `json:"severity,omitempty"`
+	Patient            *Reference                `json:"patient,omitempty"`
+	IdentifiedDateTime string                    `json:"identifiedDateTime,omitempty"`
+	Evidence           []DetectedIssueEvidence   `json:"evidence,omitempty"`
+	Detail             string                    `json:"detail,omitempty"`
+	Mitigation         []DetectedIssueMitigation `json:"mitigation,omitempty"`
+}
+
+type DetectedIssueEvidence struct {
+	Detail []Reference `json:"detail,omitempty"`
+}
+
+type DetectedIssueMitigation struct {
+	Action CodeableConcept `json:"action"`
+	Date   string          `json:"date,omitempty"`
+	Author *Reference      `json:"author,omitempty"`
+}
+
+type QuestionnaireResponse struct {
+	ResourceType string                      `json:"resourceType"`
+	ID           string                      `json:"id,omitempty"`
+	Meta         *Meta                       `json:"meta,omitempty"`
+	Status       string                      `json:"status"`
+	Subject      *Reference                  `json:"subject,omitempty"`
+	Authored     string                      `json:"authored,omitempty"`
+	Item         []QuestionnaireResponseItem `json:"item,omitempty"`
+}
+
+type QuestionnaireResponseItem struct {
+	LinkID string                        `json:"linkId"`
+	Text   string                        `json:"text,omitempty"`
+	Answer []QuestionnaireResponseAnswer `json:"answer,omitempty"`
+}
+
+// QuestionnaireResponseAnswer carries exactly one of its value fields.
+type QuestionnaireResponseAnswer struct {
+	ValueString  string   `json:"valueString,omitempty"`
+	ValueDecimal *float64 `json:"valueDecimal,omitempty"`
+	ValueBoolean *bool    `json:"valueBoolean,omitempty"`
+}
+
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	ID           string        `json:"id,omitempty"`
+	Meta         *Meta         `json:"meta,omitempty"`
+	Type         string        `json:"type"`
+	Timestamp    string        `json:"timestamp,omitempty"`
+	Total        *int          `json:"total,omitempty"`
+	Link         []BundleLink  `json:"link,omitempty"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+type BundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+type BundleEntry struct {
+	FullURL  string        `json:"fullUrl,omitempty"`
+	Resource interface{}   `json:"resource,omitempty"`
+	Search   *BundleSearch `json:"search,omitempty"`
+}
+
+type BundleSearch struct {
+	Mode string `json:"mode,omitempty"`
+}
+
+type CapabilityStatement struct {
+	ResourceType string           `json:"resourceType"`
+	Status       string           `json:"status"`
+	Date         string           `json:"date"`
+	Kind         string           `json:"kind"`
+	FHIRVersion  string           `json:"fhirVersion"`
+	Format       []string         `json:"format"`
+	Rest         []CapabilityRest `json:"rest,omitempty"`
+}
+
+type CapabilityRest struct {
+	Mode     string               `json:"mode"`
+	Resource []CapabilityResource `json:"resource,omitempty"`
+}
+
+type CapabilityResource struct {
+	Type        string                `json:"type"`
+	Interaction []CapabilityCode      `json:"interaction,omitempty"`
+	SearchParam []CapabilitySearch    `json:"searchParam,omitempty"`
+	Operation   []CapabilityOperation `json:"operation,omitempty"`
+}
+
+type CapabilityCode struct {
+	Code string `json:"code"`
+}
+
+type CapabilitySearch struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type CapabilityOperation struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// Resources below are only read, by the onboarding import.
+
+// RawBundle is a Bundle whose entries are decoded one at a time, by type.
+type RawBundle struct {
+	ResourceType string           `json:"resourceType"`
+	Type         string           `json:"type"`
+	Entry        []RawBundleEntry `json:"entry"`
+}
+
+type RawBundleEntry struct {
+	FullURL  string          `json:"fullUrl"`
+	Resource json.RawMessage `json:"resource"`
+}
+
+// ResourceHeader is the part every resource shares.
+type ResourceHeader struct {
+	ResourceType string `json:"resourceType"`
+	ID           string `json:"id"`
+}
+
+type Condition struct {
+	ResourceType       string            `json:"resourceType"`
+	ID                 string            `json:"id,omitempty"`
+	ClinicalStatus     *CodeableConcept  `json:"clinicalStatus,omitempty"`
+	VerificationStatus *CodeableConcept  `json:"verificationStatus,omitempty"`
+	Category           []CodeableConcept `json:"category,omitempty"`
+	Code               *CodeableConcept  `json:"code,omitempty"`
+	Subject            *Reference        `json:"subject,omitempty"`
+}
+
+type MedicationStatement struct {
+	ResourceType              string           `json:"resourceType"`
+	ID                        string           `json:"id,omitempty"`
+	Status                    string           `json:"status"`
+	MedicationCodeableConcept *CodeableConcept `json:"medicationCodeableConcept,omitempty"`
+	MedicationReference       *Reference       `json:"medicationReference,omitempty"`
+	Subject                   *Reference       `json:"subject,omitempty"`
+	EffectiveDateTime         string           `json:"effectiveDateTime,omitempty"`
+	EffectivePeriod           *Period          `json:"effectivePeriod,omitempty"`
+	Dosage                    []Dosage         `json:"dosage,omitempty"`
+}
+
+type Dosage struct {
+	Text string `json:"text,omitempty"`
+}
+
+type AllergyIntolerance struct {
+	ResourceType       string            `json:"resourceType"`
+	ID                 string            `json:"id,omitempty"`
+	ClinicalStatus     *CodeableConcept  `json:"clinicalStatus,omitempty"`
+	VerificationStatus *CodeableConcept  `json:"verificationStatus,omitempty"`
+	Code               *CodeableConcept  `json:"code,omitempty"`
+	Patient            *Reference        `json:"patient,omitempty"`
+	Reaction           []AllergyReaction `json:"reaction,omitempty"`
+}
+
+type AllergyReaction struct {
+	Manifestation []CodeableConcept `json:"manifestation,omitempty"`
+}
+
+// Label is the concept's text, else the display or code of its first coding.
+func (c *CodeableConcept) Label() string {
+	if c == nil {
+		return ""
+	}
+	if text := strings.TrimSpace(c.Text); text != "" {
+		return text
+	}
+	for _, coding := range c.Coding {
+		if display := strings.TrimSpace(coding.Display); display != "" {
+			return display
+		}
+	}
+	for _, coding := range c.Coding {
+		if code := strings.TrimSpace(coding.Code); code != "" {
+			return code
+		}
+	}
+	return ""
+}
+
+// Code returns the concept's code in system, if it has one.
+func (c *CodeableConcept) Code(system string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	for _, coding := range c.Coding {
+		if coding.System == system && coding.Code != "" {
+			return coding.Code, true
+		}
+	}
+	return "", false
+}
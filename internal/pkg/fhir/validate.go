@@ -0,0 +1,322 @@
+package fhir
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	idPattern        = regexp.MustCompile(`^[A-Za-z0-9\-.]{1,64}$`)
+	dateTimePattern  = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2}(T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2}))?)?)?$`)
+	instantPattern   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+	codePattern      = regexp.MustCompile(`^[^\s]+( [^\s]+)*$`)
+	referencePattern = regexp.MustCompile(`^[A-Z][A-Za-z]+/[A-Za-z0-9\-.]{1,64}$`)
+)
+
+// resourceRule lists what the R4 specification requires of an exported
+// resource: mandatory elements and the value sets of its required bindings.
+type resourceRule struct {
+	required []string
+	codes    map[string][]string
+}
+
+var resourceRules = map[string]resourceRule{
+	"Patient": {
+		codes: map[string][]string{"gender": {"male", "female", "other", "unknown"}},
+	},
+	"Observation": {
+		required: []string{"status", "code"},
+		codes:    map[string][]string{"status": {"registered", "preliminary", "final", "amended", "corrected", "cancelled", "entered-in-error", "unknown"}},
+	},
+	"Flag": {
+		required: []string{"status", "code", "subject"},
+		codes:    map[string][]string{"status": {"active", "inactive", "entered-in-error"}},
+	},
+	"DetectedIssue": {
+		required: []string{"status"},
+		codes: map[string][]string{
+			"status":   {"registered", "preliminary", "final", "amended", "corrected", "cancelled", "entered-in-error", "unknown"},
+			"severity": {"high", "moderate", "low"},
+		},
+	},
+	"QuestionnaireResponse": {
+		required: []string{"status"},
+		codes:    map[string][]string{"status": {"in-progress", "completed", "amended", "entered-in-error", "stopped"}},
+	},
+	"Bundle": {
+		required: []string{"type"},
+		codes:    map[string][]string{"type": {"document", "message", "transaction", "transaction-response", "batch", "batch-response", "history", "searchset", "collection"}},
+	},
+	"CapabilityStatement": {
+		required: []string{"status", "date", "kind", "fhirVersion", "format"},
+		codes: map[string][]string{
+			"status": {"draft", "active", "retired", "unknown"},
+			"kind":   {"instance", "capability", "requirements"},
+		},
+	},
+}
+
+// dateTimeElements and instantElements name the primitive elements checked
+// against the dateTime and instant formats wherever they appear.
+var (
+	dateTimeElements = map[string]bool{
+		"effectiveDateTime": true, "identifiedDateTime": true, "authored": true,
+		"date": true, "start": true, "end": true,
+	}
+	instantElements = map[string]bool{"issued": true, "lastUpdated": true, "timestamp": true}
+)
+
+var contactPointSystems = []string{"phone", "fax", "email", "pager", "url", "sms", "other"}
+
+// ValidationError lists every structural problem found in a resource.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid FHIR resource: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the JSON form of a resource against the R4 rules the export
+// relies on: a known resourceType, required elements and codes, valid ids,
+// references and dates, at most one value[x] per element, and no empty or null
+// properties, which FHIR JSON forbids. Bundle entries are validated as well.
+func Validate(resource interface{}) error {
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	return ValidateJSON(raw)
+}
+
+// ValidateJSON is Validate for an encoded resource.
+func ValidateJSON(raw []byte) error {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	v := &validator{}
+	v.resource("", doc)
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "$"
+	}
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) resource(path string, node interface{}) {
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		v.fail(path, "resource must be a JSON object")
+		return
+	}
+	resourceType, _ := obj["resourceType"].(string)
+	rule, known := resourceRules[resourceType]
+	if !known {
+		v.fail(join(path, "resourceType"), "unsupported resource type %q", resourceType)
+		return
+	}
+
+	for _, name := range rule.required {
+		if _, ok := obj[name]; !ok {
+			v.fail(join(path, name), "is required for %s", resourceType)
+		}
+	}
+	for name, allowed := range rule.codes {
+		raw, ok := obj[name]
+		if !ok {
+			continue
+		}
+		if code, _ := raw.(string); !contains(allowed, code) {
+			v.fail(join(path, name), "must be one of %v", allowed)
+		}
+	}
+	if choices := valueChoices(obj); len(choices) > 1 {
+		v.fail(path, "has more than one value[x]: %s", strings.Join(choices, ", "))
+	}
+	if id, ok := obj["id"]; ok {
+		if s, _ := id.(string); !idPattern.MatchString(s) {
+			v.fail(join(path, "id"), "is not a valid resource id")
+		}
+	}
+
+	switch resourceType {
+	case "Observation":
+		if code, ok := obj["code"].(map[string]interface{}); ok {
+			for i, c := range asArray(obj["component"]) {
+				if comp, ok := c.(map[string]interface{}); ok && sameConcept(comp["code"], code) {
+					v.fail(join(path, fmt.Sprintf("component[%d].code", i)), "must differ from the observation code")
+				}
+			}
+		}
+	case "QuestionnaireResponse":
+		v.items(join(path, "item"), obj["item"])
+	case "Bundle":
+		for i, e := range asArray(obj["entry"]) {
+			entry, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if res, ok := entry["resource"]; ok {
+				v.resource(join(path, fmt.Sprintf("entry[%d].resource", i)), res)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(obj) {
+		if name == "resourceType" || (resourceType == "Bundle" && name == "entry") {
+			continue
+		}
+		v.element(join(path, name), name, obj[name])
+	}
+	if resourceType == "Bundle" {
+		// entries are walked as resources above; check the entry wrappers only
+		for i, e := range asArray(obj["entry"]) {
+			entry, ok := e.(map[string]interface{})
+			if !ok {
+				v.fail(join(path, fmt.Sprintf("entry[%d]", i)), "must be a JSON object")
+				continue
+			}
+			for _, name := range sortedKeys(entry) {
+				if name != "resource" {
+					v.element(join(path, fmt.Sprintf("entry[%d].%s", i, name)), name, entry[name])
+				}
+			}
+		}
+	}
+}
+
+// element checks a property value and everything below it.
+func (v *validator) element(path, name string, node interface{}) {
+	switch val := node.(type) {
+	case nil:
+		v.fail(path, "must not be null")
+	case string:
+		switch {
+		case val == "":
+			v.fail(path, "must not be empty")
+		case strings.TrimSpace(val) != val:
+			v.fail(path, "must not have leading or trailing whitespace")
+		case dateTimeElements[name] && !dateTimePattern.MatchString(val):
+			v.fail(path, "is not a valid dateTime")
+		case instantElements[name] && !instantPattern.MatchString(val):
+			v.fail(path, "is not a valid instant")
+		case (name == "code" || name == "status") && !codePattern.MatchString(val):
+			v.fail(path, "is not a valid code")
+		case name == "system" && !strings.Contains(val, ":"):
+			v.fail(path, "must be an absolute URI")
+		case name == "reference" && !referencePattern.MatchString(val) && !strings.Contains(val, ":"):
+			v.fail(path, "must be a relative reference Type/id or an absolute URL")
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			v.fail(path, "must not be an empty array")
+		}
+		for i, item := range val {
+			v.element(fmt.Sprintf("%s[%d]", path, i), name, item)
+		}
+	case map[string]interface{}:
+		if len(val) == 0 {
+			v.fail(path, "must not be an empty object")
+		}
+		if _, nested := val["resourceType"]; nested {
+			v.fail(path, "resources may only appear in Bundle entries")
+			return
+		}
+		if choices := valueChoices(val); len(choices) > 1 {
+			v.fail(path, "has more than one value[x]: %s", strings.Join(choices, ", "))
+		}
+		for _, key := range sortedKeys(val) {
+			// ContactPoint.system is a code, unlike the URI of codings and identifiers
+			if name == "telecom" && key == "system" {
+				if system, _ := val[key].(string); !contains(contactPointSystems, system) {
+					v.fail(path+"."+key, "must be one of %v", contactPointSystems)
+				}
+				continue
+			}
+			v.element(path+"."+key, key, val[key])
+		}
+	}
+}
+
+// items checks QuestionnaireResponse items: each has a linkId and every
+// answer carries exactly one value.
+func (v *validator) items(path string, node interface{}) {
+	for i, it := range asArray(node) {
+		item, ok := it.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if _, ok := item["linkId"]; !ok {
+			v.fail(itemPath+".linkId", "is required")
+		}
+		for j, a := range asArray(item["answer"]) {
+			if answer, ok := a.(map[string]interface{}); ok && len(valueChoices(answer)) != 1 {
+				v.fail(fmt.Sprintf("%s.answer[%d]", itemPath, j), "must have exactly one value[x]")
+			}
+		}
+		v.items(itemPath+".item", item["item"])
+	}
+}
+
+// valueChoices returns the value[x] properties of an object.
+func valueChoices(obj map[string]interface{}) []string {
+	var out []string
+	for key := range obj {
+		if len(key) > 5 && strings.HasPrefix(key, "value") && key[5] >= 'A' && key[5] <= 'Z' {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sameConcept(a interface{}, b map[string]interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
+
+func asArray(node interface{}) []interface{} {
+	arr, _ := node.([]interface{})
+	return arr
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,102 @@
+package fhir
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsResources(t *testing.T) {
+	value := 72.0
+	answer := 4.0
+	yes := true
+	resources := map[string]interface{}{
+		"Patient": &Patient{
+			ResourceType: "Patient",
+			ID:           "0b8f4d8e-6c1f-4a53-9a33-2f0c6a1d7b10",
+			Meta:         &Meta{LastUpdated: "2026-03-01T08:30:00Z"},
+			Identifier:   []Identifier{{System: SystemUserID, Value: "0b8f4d8e-6c1f-4a53-9a33-2f0c6a1d7b10"}},
+			Active:       &yes,
+			Name:         []HumanName{{Use: "official", Text: "Aziza Karimova", Family: "Karimova", Given: []string{"Aziza"}}},
+			Telecom:      []ContactPoint{{System: "phone", Value: "+998901234567", Use: "mobile"}},
+			Gender:       "female",
+		},
+		"Observation": &Observation{
+			ResourceType:      "Observation",
+			ID:                "5d7c",
+			Status:            "final",
+			Code:              CodeableConcept{Coding: []Coding{{System: SystemLOINC, Code: "8867-4", Display: "Heart rate"}}},
+			Subject:           &Reference{Reference: "Patient/0b8f4d8e"},
+			EffectiveDateTime: "2026-03-01T08:00:00.5+05:00",
+			Issued:            "2026-03-01T03:00:01Z",
+			ValueQuantity:     &Quantity{Value: &value, Unit: "bpm", System: SystemUCUM, Code: "/min"},
+		},
+		"QuestionnaireResponse": &QuestionnaireResponse{
+			ResourceType: "QuestionnaireResponse",
+			ID:           "9a1e",
+			Status:       "completed",
+			Subject:      &Reference{Reference: "Patient/0b8f4d8e"},
+			Authored:     "2026-03-01",
+			Item: []QuestionnaireResponseItem{
+				{LinkID: "1", Text: "How many pillows?", Answer: []QuestionnaireResponseAnswer{{ValueDecimal: &answer}}},
+				{LinkID: "2", Answer: []QuestionnaireResponseAnswer{{ValueBoolean: &yes}}},
+			},
+		},
+		"Bundle": &Bundle{
+			ResourceType: "Bundle",
+			Type:         "searchset",
+			Timestamp:    "2026-03-01T08:30:00Z",
+			Entry: []BundleEntry{{
+				FullURL:  "http://localhost/api/v1/fhir/Flag/77aa",
+				Resource: &Flag{ResourceType: "Flag", ID: "77aa", Status: "active", Code: CodeableConcept{Text: "High heart rate"}, Subject: Reference{Reference: "Patient/0b8f4d8e"}},
+				Search:   &BundleSearch{Mode: "include"},
+			}},
+		},
+	}
+	for name, resource := range resources {
+		t.Run(name, func(t *testing.T) {
+			if err := Validate(resource); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsResources(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"unknown resource type", `{"resourceType": "Encounter"}`, `unsupported resource type "Encounter"`},
+		{"missing required element", `{"resourceType": "Observation", "code": {"text": "Heart rate"}}`, "status: is required for Observation"},
+		{"code outside its value set", `{"resourceType": "Patient", "gender": "M"}`, "gender: must be one of"},
+		{"invalid id", `{"resourceType": "Patient", "id": "not/an id"}`, "id: is not a valid resource id"},
+		{"empty string", `{"resourceType": "Patient", "name": [{"text": ""}]}`, "name[0].text: must not be empty"},
+		{"null property", `{"resourceType": "Patient", "gender": null}`, "gender: must not be null"},
+		{"empty array", `{"resourceType": "Patient", "identifier": []}`, "identifier: must not be an empty array"},
+		{"invalid dateTime", `{"resourceType": "QuestionnaireResponse", "status": "completed", "authored": "01/03/2026"}`, "authored: is not a valid dateTime"},
+		{"instant without a time zone", `{"resourceType": "Patient", "meta": {"lastUpdated": "2026-03-01T08:30:00"}}`, "meta.lastUpdated: is not a valid instant"},
+		{"relative system", `{"resourceType": "Patient", "identifier": [{"system": "user-id", "value": "1"}]}`, "system: must be an absolute URI"},
+		{"bad reference", `{"resourceType": "Flag", "status": "active", "code": {"text": "x"}, "subject": {"reference": "patient 1"}}`, "subject.reference: must be a relative reference"},
+		{"unknown telecom system", `{"resourceType": "Patient", "telecom": [{"system": "telegram", "value": "@a"}]}`, "telecom[0].system: must be one of"},
+		{"two values", `{"resourceType": "Observation", "status": "final", "code": {"text": "x"}, "valueString": "a", "valueQuantity": {"value": 1}}`, "more than one value[x]"},
+		{"component coded as the observation", `{"resourceType": "Observation", "status": "final", "code": {"text": "BP"}, "component": [{"code": {"text": "BP"}}]}`, "component[0].code: must differ"},
+		{"item without linkId", `{"resourceType": "QuestionnaireResponse", "status": "completed", "item": [{"text": "q"}]}`, "item[0].linkId: is required"},
+		{"answer without value", `{"resourceType": "QuestionnaireResponse", "status": "completed", "item": [{"linkId": "1", "answer": [{}]}]}`, "answer[0]: must have exactly one value[x]"},
+		{"nested resource", `{"resourceType": "Patient", "contact": [{"name": {"resourceType": "Patient"}}]}`, "resources may only appear in Bundle entries"},
+		{"invalid bundle entry", `{"resourceType": "Bundle", "type": "searchset", "entry": [{"resource": {"resourceType": "Observation", "code": {"text": "x"}}}]}`, "entry[0].resource.status: is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJSON([]byte(tt.json))
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("ValidateJSON = %v, want a ValidationError", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package hl7
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AckCode is MSA-1, the outcome of an original mode acknowledgement.
+type AckCode string
+
+const (
+	AckAccept AckCode = "AA" // processed
+	AckError  AckCode = "AE" // rejected by the application; may be sent again once fixed
+	AckReject AckCode = "AR" // unreadable or unsupported; sending it again will not help
+)
+
+// ErrorCode is an HL7 table 0357 message error condition code.
+type ErrorCode int
+
+const (
+	ErrSegmentSequence  ErrorCode = 100
+	ErrRequiredField    ErrorCode = 101
+	ErrDataType         ErrorCode = 102
+	ErrTableValue       ErrorCode = 103
+	ErrUnsupportedType  ErrorCode = 200
+	ErrUnsupportedEvent ErrorCode = 201
+	ErrUnknownKey       ErrorCode = 204
+	ErrDuplicateKey     ErrorCode = 205
+	ErrInternal         ErrorCode = 207
+)
+
+var errorCodeText = map[ErrorCode]string{
+	ErrSegmentSequence:  "Segment sequence error",
+	ErrRequiredField:    "Required field missing",
+	ErrDataType:         "Data type error",
+	ErrTableValue:       "Table value not found",
+	ErrUnsupportedType:  "Unsupported message type",
+	ErrUnsupportedEvent: "Unsupported event code",
+	ErrUnknownKey:       "Unknown key identifier",
+	ErrDuplicateKey:     "Duplicate key identifier",
+	ErrInternal:         "Application internal error",
+}
+
+// Severity is ERR-4: whether the problem failed the message or is a warning.
+type Severity string
+
+const (
+	SeverityError   Severity = "E"
+	SeverityWarning Severity = "W"
+)
+
+// Problem is one ERR segment of an acknowledgement. Segment and Field locate
+// it in the original message, e.g. OBX 3 field 5; both are optional.
+type Problem struct {
+	Code     ErrorCode
+	Severity Severity
+	Segment  string
+	Sequence int
+	Field    int
+	Text     string
+}
+
+func (p Problem) Error() string {
+	if loc := p.location(); loc != "" {
+		return loc + ": " + p.Text
+	}
+	return p.Text
+}
+
+// location renders the problem position the way people read it, e.g. "PID-5".
+func (p Problem) location() string {
+	if p.Segment == "" {
+		return ""
+	}
+	loc := p.Segment
+	if p.Sequence > 1 {
+		loc += "(" + strconv.Itoa(p.Sequence) + ")"
+	}
+	if p.Field > 0 {
+		loc += "-" + strconv.Itoa(p.Field)
+	}
+	return loc
+}
+
+// Application names a sending or receiving application in MSH-3 to MSH-6.
+type Application struct {
+	Name     string
+	Facility string
+}
+
+// NewACK builds the acknowledgement of orig, which is nil when the message
+// could not be parsed. The ACK is written in the original's encoding and
+// addressed back to its sender; controlID is the ACK's own MSH-10.
+func NewACK(orig *Message, code AckCode, from Application, controlID string, at time.Time, problems ...Problem) []byte {
+	enc := DefaultEncoding
+	var toApp, toFacility, trigger, origControlID, processingID, version string
+	processingID, version = "P", "2.5"
+	if orig != nil {
+		enc = orig.Encoding
+		msh := orig.Header()
+		toApp, toFacility = msh.Field(3).raw, msh.Field(4).raw
+		trigger = enc.EscapeText(msh.Field(9).Component(2))
+		origControlID = enc.EscapeText(orig.ControlID())
+		if p := msh.Field(11); !p.Empty() {
+			processingID = p.raw
+		}
+		if v := msh.Field(12); !v.Empty() {
+			version = v.raw
+		}
+	}
+
+	sep := string(enc.Field)
+	comp := string(enc.Component)
+	messageType := "ACK"
+	if trigger != "" {
+		messageType = "ACK" + comp + trigger + comp + "ACK"
+	}
+	segments := []string{
+		strings.Join([]string{"MSH" + sep + enc.characters(), enc.EscapeText(from.Name), enc.EscapeText(from.Facility), toApp, toFacility,
+			FormatTime(at), "", messageType, enc.EscapeText(controlID), processingID, version}, sep),
+	}
+
+	msa := []string{"MSA", string(code), origControlID}
+	for _, p := range problems {
+		if p.Severity != SeverityWarning {
+			msa = append(msa, enc.EscapeText(truncate(p.Error(), 80)))
+			break
+		}
+	}
+	segments = append(segments, strings.Join(msa, sep))
+
+	for _, p := range problems {
+		severity := p.Severity
+		if severity == "" {
+			severity = SeverityError
+		}
+		var location string
+		if p.Segment != "" {
+			sequence := p.Sequence
+			if sequence == 0 {
+				sequence = 1
+			}
+			location = p.Segment + comp + strconv.Itoa(sequence)
+			if p.Field > 0 {
+				location += comp + strconv.Itoa(p.Field)
+			}
+		}
+		condition := strconv.Itoa(int(p.Code)) + comp + enc.EscapeText(errorCodeText[p.Code]) + comp + "HL70357"
+		segments = append(segments, strings.Join([]string{"ERR", "", location, condition, string(severity), "", "", "", enc.EscapeText(p.Text)}, sep))
+	}
+	return []byte(strings.Join(segments, "\r") + "\r")
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,116 @@
+package hl7
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var ackTime = time.Date(2026, 3, 1, 8, 30, 0, 0, time.FixedZone("", 5*3600))
+
+func ackSegments(t *testing.T, ack []byte) *Message {
+	t.Helper()
+	if !strings.HasSuffix(string(ack), "\r") {
+		t.Errorf("ACK %q does not end with a segment terminator", ack)
+	}
+	msg, err := Parse(ack)
+	if err != nil {
+		t.Fatalf("ACK does not parse: %v\n%s", err, ack)
+	}
+	return msg
+}
+
+func TestNewACKAccept(t *testing.T) {
+	orig, err := Parse([]byte(a03))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	ack := NewACK(orig, AckAccept, Application{Name: "VITALSYNC", Facility: "CLINIC"}, "ACK1", ackTime)
+
+	want := "MSH|^~\\&|VITALSYNC|CLINIC|EPIC|CITYHOSP|20260301083000+0500||ACK^A03^ACK|ACK1|P|2.5\r" +
+		"MSA|AA|MSG00042\r"
+	if string(ack) != want {
+		t.Errorf("ACK =\n%q\nwant\n%q", ack, want)
+	}
+}
+
+func TestNewACKErrors(t *testing.T) {
+	orig, err := Parse([]byte(a03))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	ack := ackSegments(t, NewACK(orig, AckError, Application{Name: "VITALSYNC"}, "ACK2", ackTime,
+		Problem{Code: ErrTableValue, Severity: SeverityWarning, Segment: "OBX", Sequence: 2, Field: 3, Text: "not a vital sign; skipped"},
+		Problem{Code: ErrDuplicateKey, Segment: "PID", Field: 3, Text: "identifier MRN123 belongs to another patient"},
+	))
+
+	msa, _ := ack.Segment("MSA")
+	if msa.Field(1).Value() != "AE" || msa.Field(2).Value() != "MSG00042" {
+		t.Errorf("MSA = %s %s", msa.Field(1).Value(), msa.Field(2).Value())
+	}
+	// MSA-3 carries the first error, not the warning before it
+	if got := msa.Field(3).Value(); got != "PID-3: identifier MRN123 belongs to another patient" {
+		t.Errorf("MSA-3 = %q", got)
+	}
+
+	errs := ack.All("ERR")
+	if len(errs) != 2 {
+		t.Fatalf("%d ERR segments, want 2", len(errs))
+	}
+	warning, dup := errs[0], errs[1]
+	if loc := warning.Field(2); loc.Component(1) != "OBX" || loc.Component(2) != "2" || loc.Component(3) != "3" || warning.Field(4).Value() != "W" {
+		t.Errorf("warning ERR = %s %s", warning.Field(2).raw, warning.Field(4).Value())
+	}
+	if loc := dup.Field(2); loc.Component(1) != "PID" || loc.Component(2) != "1" || loc.Component(3) != "3" {
+		t.Errorf("ERR-2 = %q, want PID^1^3", dup.Field(2).raw)
+	}
+	condition := dup.Field(3)
+	if condition.Component(1) != "205" || condition.Component(2) != "Duplicate key identifier" || condition.Component(3) != "HL70357" {
+		t.Errorf("ERR-3 = %q", condition.raw)
+	}
+	if dup.Field(4).Value() != "E" || dup.Field(8).Value() != "identifier MRN123 belongs to another patient" {
+		t.Errorf("ERR-4, 8 = %q, %q", dup.Field(4).Value(), dup.Field(8).Value())
+	}
+}
+
+func TestNewACKWithoutMessage(t *testing.T) {
+	ack := ackSegments(t, NewACK(nil, AckReject, Application{Name: "VITAL|SYNC"}, "ACK3", ackTime,
+		Problem{Code: ErrSegmentSequence, Text: "message must start with an MSH segment"}))
+
+	msh := ack.Header()
+	if msh.Field(3).Value() != "VITAL|SYNC" || msh.Field(9).Value() != "ACK" || msh.Field(11).Value() != "P" || msh.Field(12).Value() != "2.5" {
+		t.Errorf("MSH = %+v", msh)
+	}
+	msa, _ := ack.Segment("MSA")
+	if msa.Field(1).Value() != "AR" || !msa.Field(2).Empty() {
+		t.Errorf("MSA-1, 2 = %q, %q", msa.Field(1).Value(), msa.Field(2).Value())
+	}
+	err, _ := ack.Segment("ERR")
+	if !err.Field(2).Empty() || err.Field(3).Component(1) != "100" {
+		t.Errorf("ERR = %q %q, want no location and code 100", err.Field(2).raw, err.Field(3).raw)
+	}
+}
+
+func TestNewACKKeepsEncoding(t *testing.T) {
+	orig, err := Parse([]byte("MSH#:*!$#LAB#ICU#####ORU:R01#C!F!42#T#2.3\r"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	raw := NewACK(orig, AckError, Application{Name: "VS"}, "ACK4", ackTime,
+		Problem{Code: ErrDataType, Segment: "OBX", Field: 5, Text: "value 1#2 is not a number"})
+	if !strings.HasPrefix(string(raw), "MSH#:*!$#VS##LAB#ICU#") {
+		t.Errorf("ACK = %q, want the original delimiters", raw)
+	}
+	ack := ackSegments(t, raw)
+	if ack.Header().Field(11).Value() != "T" || ack.Header().Field(12).Value() != "2.3" || ack.Header().Field(9).raw != "ACK:R01:ACK" {
+		t.Errorf("MSH-9, 11, 12 = %q, %q, %q", ack.Header().Field(9).raw, ack.Header().Field(11).Value(), ack.Header().Field(12).Value())
+	}
+	msa, _ := ack.Segment("MSA")
+	if msa.Field(2).Value() != "C#42" {
+		t.Errorf("MSA-2 = %q, want the escaped control id read back", msa.Field(2).Value())
+	}
+	errSegment, _ := ack.Segment("ERR")
+	if got := errSegment.Field(8).Value(); got != "value 1#2 is not a number" {
+		t.Errorf("ERR-8 = %q", got)
+	}
+}
@@ -0,0 +1,302 @@
+// Package hl7 parses HL7 v2 messages in their pipe-delimited (ER7) encoding,
+// builds acknowledgements and frames them for MLLP.
+package hl7
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of ER7 encoded messages sent over HTTP.
+const ContentType = "x-application/hl7-v2+er7"
+
+// Encoding holds the delimiters a message declares in MSH-1 and MSH-2.
+type Encoding struct {
+	Field        byte
+	Component    byte
+	Repetition   byte
+	Escape       byte
+	Subcomponent byte
+}
+
+// DefaultEncoding is the recommended set, used for messages vital-sync writes.
+var DefaultEncoding = Encoding{Field: '|', Component: '^', Repetition: '~', Escape: '\\', Subcomponent: '&'}
+
+func (e Encoding) characters() string {
+	return string([]byte{e.Component, e.Repetition, e.Escape, e.Subcomponent})
+}
+
+// Message is a parsed message. Field values keep their escape sequences until
+// they are read through Field, so delimiters inside data never split them.
+type Message struct {
+	Segments []Segment
+	Encoding Encoding
+}
+
+// Segment is one line of a message. Field numbers follow the standard, so
+// for MSH Field(1) is the field separator and Field(2) the encoding characters.
+type Segment struct {
+	Name   string
+	fields []string
+	enc    Encoding
+}
+
+// Field is a field value with its repetitions, components and subcomponents.
+type Field struct {
+	raw string
+	enc Encoding
+}
+
+// ParseError reports a message that cannot be read at all.
+type ParseError struct {
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return "invalid HL7 message: " + e.Message
+}
+
+// Parse reads a message. Segments may end with CR, LF or CRLF.
+func Parse(raw []byte) (*Message, error) {
+	text := strings.ReplaceAll(string(raw), "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	text = strings.Trim(text, "\r")
+
+	if !strings.HasPrefix(text, "MSH") || len(text) < 8 {
+		return nil, &ParseError{Message: "message must start with an MSH segment"}
+	}
+	enc := Encoding{Field: text[3], Component: text[4], Repetition: text[5], Escape: text[6], Subcomponent: text[7]}
+	seen := map[byte]bool{}
+	for _, c := range []byte{enc.Field, enc.Component, enc.Repetition, enc.Escape, enc.Subcomponent} {
+		if seen[c] || c == '\r' || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			return nil, &ParseError{Message: "MSH-1 and MSH-2 must declare five distinct delimiters"}
+		}
+		seen[c] = true
+	}
+	if len(text) > 8 && text[8] != enc.Field {
+		return nil, &ParseError{Message: "MSH-2 must have four encoding characters"}
+	}
+
+	msg := &Message{Encoding: enc}
+	for i, line := range strings.Split(text, "\r") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.Split(line, string(enc.Field))
+		name := parts[0]
+		if len(name) != 3 || !isSegmentName(name) {
+			return nil, &ParseError{Message: fmt.Sprintf("segment %d has an invalid name %q", i+1, name)}
+		}
+		var fields []string
+		if name == "MSH" {
+			// MSH-1 is the separator itself, so the fields after it shift by one
+			fields = append([]string{name, string(enc.Field)}, parts[1:]...)
+		} else {
+			fields = parts
+		}
+		msg.Segments = append(msg.Segments, Segment{Name: name, fields: fields, enc: enc})
+	}
+	return msg, nil
+}
+
+func isSegmentName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Segment returns the first segment with the given name.
+func (m *Message) Segment(name string) (*Segment, bool) {
+	for i := range m.Segments {
+		if m.Segments[i].Name == name {
+			return &m.Segments[i], true
+		}
+	}
+	return nil, false
+}
+
+// All returns every segment with the given name, in order.
+func (m *Message) All(name string) []*Segment {
+	var out []*Segment
+	for i := range m.Segments {
+		if m.Segments[i].Name == name {
+			out = append(out, &m.Segments[i])
+		}
+	}
+	return out
+}
+
+// Header returns the MSH segment; Parse guarantees there is one.
+func (m *Message) Header() *Segment {
+	msh, _ := m.Segment("MSH")
+	return msh
+}
+
+// Type is the message code and trigger event of MSH-9, e.g. "ADT^A03".
+func (m *Message) Type() string {
+	f := m.Header().Field(9)
+	if f.Component(2) == "" {
+		return f.Component(1)
+	}
+	return f.Component(1) + "^" + f.Component(2)
+}
+
+// ControlID is MSH-10, the sender's unique id of the message.
+func (m *Message) ControlID() string {
+	return m.Header().Field(10).Value()
+}
+
+// Field returns field n, or an empty field when the segment is shorter.
+func (s *Segment) Field(n int) Field {
+	if n < 1 || n >= len(s.fields) {
+		return Field{enc: s.enc}
+	}
+	if s.Name == "MSH" && n <= 2 {
+		// the delimiters are never escaped
+		return Field{raw: s.fields[n], enc: Encoding{Field: s.enc.Field}}
+	}
+	return Field{raw: s.fields[n], enc: s.enc}
+}
+
+// Empty reports whether the field has no value.
+func (f Field) Empty() bool {
+	return f.raw == "" || f.raw == `""`
+}
+
+// Repetitions splits a repeating field.
+func (f Field) Repetitions() []Field {
+	if f.Empty() {
+		return nil
+	}
+	if f.enc.Repetition == 0 {
+		return []Field{f}
+	}
+	var out []Field
+	for _, r := range strings.Split(f.raw, string(f.enc.Repetition)) {
+		out = append(out, Field{raw: r, enc: f.enc})
+	}
+	return out
+}
+
+// Component returns component n (from 1) of the first repetition, unescaped.
+// Subcomponents are kept, joined by the subcomponent separator.
+func (f Field) Component(n int) string {
+	parts := f.components()
+	if n < 1 || n > len(parts) {
+		return ""
+	}
+	return f.enc.Unescape(parts[n-1])
+}
+
+// Subcomponent returns subcomponent sub of component n, unescaped.
+func (f Field) Subcomponent(n, sub int) string {
+	parts := f.components()
+	if n < 1 || n > len(parts) {
+		return ""
+	}
+	if f.enc.Subcomponent == 0 {
+		if sub == 1 {
+			return f.enc.Unescape(parts[n-1])
+		}
+		return ""
+	}
+	subs := strings.Split(parts[n-1], string(f.enc.Subcomponent))
+	if sub < 1 || sub > len(subs) {
+		return ""
+	}
+	return f.enc.Unescape(subs[sub-1])
+}
+
+// Value is the first component of the first repetition, the whole value of
+// simple fields.
+func (f Field) Value() string {
+	return strings.TrimSpace(f.Component(1))
+}
+
+func (f Field) components() []string {
+	if f.Empty() {
+		return nil
+	}
+	first := f.raw
+	if f.enc.Repetition != 0 {
+		first, _, _ = strings.Cut(first, string(f.enc.Repetition))
+	}
+	if f.enc.Component == 0 {
+		return []string{first}
+	}
+	return strings.Split(first, string(f.enc.Component))
+}
+
+// Unescape replaces the delimiter escape sequences \F\ \S\ \T\ \R\ \E\ and
+// hexadecimal \Xhh\, turns \.br\ into a line break and drops the other
+// formatting sequences, which plain text cannot carry.
+func (e Encoding) Unescape(s string) string {
+	if e.Escape == 0 || strings.IndexByte(s, e.Escape) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != e.Escape {
+			b.WriteByte(s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i+1:], e.Escape)
+		if end < 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		seq := s[i+1 : i+1+end]
+		switch seq {
+		case "F":
+			b.WriteByte(e.Field)
+		case "S":
+			b.WriteByte(e.Component)
+		case "T":
+			b.WriteByte(e.Subcomponent)
+		case "R":
+			b.WriteByte(e.Repetition)
+		case "E":
+			b.WriteByte(e.Escape)
+		case ".br":
+			b.WriteByte('\n')
+		default:
+			if strings.HasPrefix(seq, "X") {
+				if v, err := strconv.ParseUint(seq[1:], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+				}
+			}
+		}
+		i += end + 1
+	}
+	return b.String()
+}
+
+// EscapeText encodes the delimiters and line breaks in s so it can be written
+// as a value.
+func (e Encoding) EscapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case e.Field:
+			b.WriteString(string(e.Escape) + "F" + string(e.Escape))
+		case e.Component:
+			b.WriteString(string(e.Escape) + "S" + string(e.Escape))
+		case e.Subcomponent:
+			b.WriteString(string(e.Escape) + "T" + string(e.Escape))
+		case e.Repetition:
+			b.WriteString(string(e.Escape) + "R" + string(e.Escape))
+		case e.Escape:
+			b.WriteString(string(e.Escape) + "E" + string(e.Escape))
+		case '\r', '\n':
+			b.WriteString(string(e.Escape) + ".br" + string(e.Escape))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
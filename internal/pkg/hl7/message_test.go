@@ -0,0 +1,206 @@
+package hl7
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// a03 is a discharge as the partner's interface engine sends it, with LF
+// segment terminators.
+const a03 = "MSH|^~\\&|EPIC|CITYHOSP|VITALSYNC|CLINIC|20260301083000+0500||ADT^A03^ADT_A03|MSG00042|P|2.5\n" +
+	"EVN|A03|20260301083000\n" +
+	"PID|1||MRN123^^^CITYHOSP^MR~998877^^^NATIONAL&2.16.860&ISO||Karimova^Aziza^B||19600412|F|||||^PRN^CP^^998^90^1234567~^NET^Internet^aziza@example.com\n" +
+	"PV1|1|I|CARD^101^A||||0b8f4d8e-6c1f-4a53-9a33-2f0c6a1d7b10^House^Greg\n" +
+	"DG1|1||I50.9^Heart failure, unspecified^I10||||F\n" +
+	"NTE|1||Low salt diet~Weigh daily\n"
+
+func TestParseA03(t *testing.T) {
+	msg, err := Parse([]byte(a03))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if msg.Encoding != DefaultEncoding {
+		t.Errorf("encoding = %+v, want the default", msg.Encoding)
+	}
+	var names []string
+	for _, s := range msg.Segments {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, " "); got != "MSH EVN PID PV1 DG1 NTE" {
+		t.Errorf("segments = %s", got)
+	}
+	if msg.Type() != "ADT^A03" || msg.ControlID() != "MSG00042" {
+		t.Errorf("type, control id = %s, %s", msg.Type(), msg.ControlID())
+	}
+
+	msh := msg.Header()
+	if msh.Field(1).Value() != "|" || msh.Field(2).Value() != `^~\&` || msh.Field(3).Value() != "EPIC" || msh.Field(12).Value() != "2.5" {
+		t.Errorf("MSH-1, 2, 3, 12 = %q, %q, %q, %q", msh.Field(1).Value(), msh.Field(2).Value(), msh.Field(3).Value(), msh.Field(12).Value())
+	}
+
+	pid, ok := msg.Segment("PID")
+	if !ok {
+		t.Fatal("no PID segment")
+	}
+	ids := pid.Field(3).Repetitions()
+	if len(ids) != 2 || ids[0].Value() != "MRN123" || ids[0].Component(4) != "CITYHOSP" {
+		t.Fatalf("PID-3 = %+v", ids)
+	}
+	if ids[1].Subcomponent(4, 1) != "NATIONAL" || ids[1].Subcomponent(4, 2) != "2.16.860" || ids[1].Subcomponent(4, 4) != "" {
+		t.Errorf("PID-3(2) assigning authority = %q, %q", ids[1].Subcomponent(4, 1), ids[1].Subcomponent(4, 2))
+	}
+	name := pid.Field(5)
+	if name.Component(1) != "Karimova" || name.Component(2) != "Aziza" || name.Component(3) != "B" {
+		t.Errorf("PID-5 = %q %q %q", name.Component(1), name.Component(2), name.Component(3))
+	}
+	if phones := pid.Field(13).Repetitions(); len(phones) != 2 || phones[0].Component(3) != "CP" || phones[0].Component(7) != "1234567" {
+		t.Errorf("PID-13 = %+v", phones)
+	}
+	if dg1 := msg.All("DG1"); len(dg1) != 1 || dg1[0].Field(3).Component(2) != "Heart failure, unspecified" {
+		t.Errorf("DG1 = %+v", dg1)
+	}
+}
+
+func TestParseSegmentTerminators(t *testing.T) {
+	for name, sep := range map[string]string{"CR": "\r", "LF": "\n", "CRLF": "\r\n"} {
+		msg, err := Parse([]byte(strings.ReplaceAll(a03, "\n", sep) + sep + sep))
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", name, err)
+		}
+		if len(msg.Segments) != 6 {
+			t.Errorf("%s: %d segments, want 6", name, len(msg.Segments))
+		}
+	}
+}
+
+func TestParseR01Observations(t *testing.T) {
+	msg, err := Parse([]byte("MSH|^~\\&|MONITOR|ICU|||20260301090000||ORU^R01|77|P|2.5\r" +
+		"PID|1||MRN123^^^CITYHOSP\r" +
+		"OBR|1|||vitals|||20260301085500\r" +
+		"OBX|1|NM|8867-4^Heart rate^LN||72|/min^beats per minute^UCUM|||||F\r" +
+		"OBX|2|NM|8480-6^Systolic^LN|1|128|mm[Hg]|||||F\r" +
+		"OBX|3|NM|8462-4^Diastolic^LN|1|82|mm[Hg]|||||F\r" +
+		"OBX|4|SN|59408-5^SpO2^LN||=^97|%|||||F\r"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if msg.Type() != "ORU^R01" {
+		t.Errorf("type = %s", msg.Type())
+	}
+	obx := msg.All("OBX")
+	if len(obx) != 4 {
+		t.Fatalf("%d OBX segments, want 4", len(obx))
+	}
+	want := []struct{ code, subID, value, unit string }{
+		{"8867-4", "", "72", "/min"},
+		{"8480-6", "1", "128", "mm[Hg]"},
+		{"8462-4", "1", "82", "mm[Hg]"},
+		{"59408-5", "", "=", "%"},
+	}
+	for i, w := range want {
+		s := obx[i]
+		if s.Field(3).Component(1) != w.code || s.Field(4).Value() != w.subID || s.Field(5).Value() != w.value || s.Field(6).Value() != w.unit {
+			t.Errorf("OBX %d = %s %s %s %s, want %+v", i+1, s.Field(3).Component(1), s.Field(4).Value(), s.Field(5).Value(), s.Field(6).Value(), w)
+		}
+	}
+	if obx[3].Field(5).Component(2) != "97" {
+		t.Errorf("OBX 4 structured value = %q, want 97", obx[3].Field(5).Component(2))
+	}
+}
+
+func TestParseCustomDelimiters(t *testing.T) {
+	msg, err := Parse([]byte("MSH#:*!$#LAB#ICU#####ORU:R01#9#P#2.5\r" +
+		"PID#1##A1:::HOSP*B2:::HOSP##Doe:John\r" +
+		"NTE#1##fever 38!F! on day 2$ morning*next line"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Encoding{Field: '#', Component: ':', Repetition: '*', Escape: '!', Subcomponent: '$'}
+	if msg.Encoding != want {
+		t.Fatalf("encoding = %+v, want %+v", msg.Encoding, want)
+	}
+	if msg.Type() != "ORU^R01" || msg.ControlID() != "9" || msg.Header().Field(1).Value() != "#" {
+		t.Errorf("type, control id, MSH-1 = %s, %s, %s", msg.Type(), msg.ControlID(), msg.Header().Field(1).Value())
+	}
+	pid, _ := msg.Segment("PID")
+	if ids := pid.Field(3).Repetitions(); len(ids) != 2 || ids[1].Value() != "B2" || ids[1].Component(4) != "HOSP" {
+		t.Errorf("PID-3 = %+v", ids)
+	}
+	if pid.Field(5).Component(2) != "John" {
+		t.Errorf("PID-5.2 = %q", pid.Field(5).Component(2))
+	}
+	nte, _ := msg.Segment("NTE")
+	if got := nte.Field(3).Subcomponent(1, 1); got != "fever 38# on day 2" {
+		t.Errorf("NTE-3 = %q, want the escaped field separators read back", got)
+	}
+}
+
+func TestParseEscapeSequences(t *testing.T) {
+	msg, err := Parse([]byte("MSH|^~\\&|A|B|||||ADT^A03|1|P|2.5\r" +
+		`NTE|1||a\F\b\S\c\T\d\R\e\E\f\.br\g\X41\h\H\i\N\j|x\F\y^z` + "\r"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	nte, _ := msg.Segment("NTE")
+	if got, want := nte.Field(3).Value(), "a|b^c&d~e\\f\ngAhij"; got != want {
+		t.Errorf("NTE-3 = %q, want %q", got, want)
+	}
+	// an escaped delimiter does not split the field
+	if f := nte.Field(4); f.Component(1) != "x|y" || f.Component(2) != "z" {
+		t.Errorf("NTE-4 components = %q, %q", f.Component(1), f.Component(2))
+	}
+	if got := DefaultEncoding.Unescape(`open \F`); got != `open \F` {
+		t.Errorf("unterminated escape = %q, want it kept", got)
+	}
+
+	text := "a|b^c&d~e\\f\ng"
+	if got := DefaultEncoding.Unescape(DefaultEncoding.EscapeText(text)); got != text {
+		t.Errorf("escape round trip = %q, want %q", got, text)
+	}
+}
+
+func TestParseTruncatedSegments(t *testing.T) {
+	msg, err := Parse([]byte("MSH|^~\\&|A\rPID|1\rPV1\rOBX|1|NM|"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if msg.ControlID() != "" || msg.Type() != "" {
+		t.Errorf("control id, type = %q, %q; want empty for a short MSH", msg.ControlID(), msg.Type())
+	}
+	pid, _ := msg.Segment("PID")
+	if f := pid.Field(5); !f.Empty() || f.Component(1) != "" || f.Repetitions() != nil || f.Subcomponent(1, 1) != "" {
+		t.Errorf("PID-5 = %+v, want an empty field past the end of the segment", f)
+	}
+	pv1, _ := msg.Segment("PV1")
+	if !pv1.Field(1).Empty() || !pv1.Field(0).Empty() {
+		t.Error("PV1 without fields has a value")
+	}
+	obx, _ := msg.Segment("OBX")
+	if obx.Field(2).Value() != "NM" || !obx.Field(3).Empty() {
+		t.Errorf("OBX-2, 3 = %q, %q", obx.Field(2).Value(), obx.Field(3).Value())
+	}
+	if f := (Field{raw: `""`, enc: DefaultEncoding}); !f.Empty() {
+		t.Error(`"" is not empty`)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := map[string]string{
+		"empty":                     "",
+		"no MSH":                    "PID|1||MRN123\r",
+		"MSH cut in the delimiters": "MSH|^~",
+		"repeated delimiter":        "MSH|^~^&|A\r",
+		"letter as delimiter":       "MSH|^~\\A|A\r",
+		"long encoding characters":  "MSH|^~\\&#|A\r",
+		"bad segment name":          "MSH|^~\\&|A\rpid|1\r",
+		"short segment name":        "MSH|^~\\&|A\rPI|1\r",
+	}
+	for name, raw := range tests {
+		_, err := Parse([]byte(raw))
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: err = %v, want a ParseError", name, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// MLLP frames a message as <VT> message <FS><CR>.
+const (
+	startBlock     = 0x0b
+	endBlock       = 0x1c
+	carriageReturn = 0x0d
+)
+
+// ErrFrameTooLarge is returned for a frame longer than the reader's limit.
+var ErrFrameTooLarge = errors.New("MLLP frame exceeds the size limit")
+
+// FrameReader reads MLLP frames from a connection.
+type FrameReader struct {
+	r       *bufio.Reader
+	maxSize int
+}
+
+func NewFrameReader(r io.Reader, maxSize int) *FrameReader {
+	return &FrameReader{r: bufio.NewReader(r), maxSize: maxSize}
+}
+
+// ReadFrame returns the content of the next frame. Bytes outside of a frame,
+// such as stray line breaks between messages, are skipped. io.EOF means the
+// peer closed the connection between frames. A frame over the size limit is
+// read to its end, keeping the connection usable, and its first maxSize
+// bytes are returned with ErrFrameTooLarge.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == startBlock {
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	tooLarge := false
+	write := func(p []byte) {
+		if room := f.maxSize + 1 - buf.Len(); len(p) > room {
+			p, tooLarge = p[:room], true
+		}
+		buf.Write(p)
+	}
+	for {
+		chunk, err := f.r.ReadSlice(endBlock)
+		write(chunk)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		next, err := f.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if next != carriageReturn {
+			// a lone FS inside the frame is data
+			write([]byte{next})
+			continue
+		}
+		frame := bytes.TrimSuffix(buf.Bytes(), []byte{endBlock})
+		if tooLarge || len(frame) > f.maxSize {
+			return frame[:min(len(frame), f.maxSize)], ErrFrameTooLarge
+		}
+		return frame, nil
+	}
+}
+
+// WriteFrame writes msg as one MLLP frame.
+func WriteFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, 0, len(msg)+3)
+	frame = append(frame, startBlock)
+	frame = append(frame, msg...)
+	frame = append(frame, endBlock, carriageReturn)
+	_, err := w.Write(frame)
+	return err
+}
@@ -0,0 +1,66 @@
+package hl7
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the TS/DTM precisions, from the full one down to the year.
+var timeLayouts = []string{"20060102150405", "200601021504", "2006010215", "20060102", "200601", "2006"}
+
+// ParseTime reads a TS or DTM value, YYYY[MM[DD[HH[MM[SS[.S...]]]]]][+/-ZZZZ].
+// Values without an offset are in loc, the sender's local time.
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if i := strings.IndexAny(value, "+-"); i >= 0 {
+		offset := value[i:]
+		value = value[:i]
+		if len(offset) != 5 {
+			return time.Time{}, fmt.Errorf("invalid time zone offset %q", offset)
+		}
+		hours, err1 := strconv.Atoi(offset[1:3])
+		minutes, err2 := strconv.Atoi(offset[3:5])
+		if err1 != nil || err2 != nil || hours > 14 || minutes > 59 {
+			return time.Time{}, fmt.Errorf("invalid time zone offset %q", offset)
+		}
+		seconds := hours*3600 + minutes*60
+		if offset[0] == '-' {
+			seconds = -seconds
+		}
+		loc = time.FixedZone("", seconds)
+	}
+
+	var fraction time.Duration
+	if whole, frac, ok := strings.Cut(value, "."); ok {
+		if len(whole) != 14 || frac == "" || len(frac) > 4 {
+			return time.Time{}, fmt.Errorf("invalid fractional seconds in %q", value)
+		}
+		n, err := strconv.Atoi(frac)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid fractional seconds in %q", value)
+		}
+		for i := len(frac); i < 9; i++ {
+			n *= 10
+		}
+		value, fraction = whole, time.Duration(n)
+	}
+
+	for _, layout := range timeLayouts {
+		if len(value) != len(layout) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", value)
+		}
+		return t.Add(fraction), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// FormatTime writes t as a DTM with seconds and offset.
+func FormatTime(t time.Time) string {
+	return t.Format("20060102150405-0700")
+}
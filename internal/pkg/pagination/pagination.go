@@ -0,0 +1,298 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type Direction string
+
+const (
+	Asc  Direction = "ASC"
+	Desc Direction = "DESC"
+)
+
+// Params are the paging, sorting and date-range options shared by every list endpoint.
+type Params struct {
+	Limit        int
+	Cursor       string
+	Sort         string
+	Direction    Direction
+	From         *time.Time
+	To           *time.Time
+	IncludeTotal bool
+}
+
+type Kind int
+
+const (
+	KindTime Kind = iota
+	KindString
+	KindNumber
+)
+
+// Column is a sortable, non-nullable column.
+type Column struct {
+	Expr  string // SQL expression used in WHERE and ORDER BY, e.g. "alerts.created_at"
+	Field string // column name on the model, used to read the cursor value back
+	Kind  Kind
+	// Then breaks ties on Expr, in the same direction; negate an expression
+	// to sort it the other way.
+	Then []Column
+}
+
+// parts lists the column followed by its tie-breakers.
+func (c Column) parts() []Column {
+	parts := []Column{c}
+	for _, t := range c.Then {
+		parts = append(parts, t.parts()...)
+	}
+	return parts
+}
+
+// Spec describes how a particular list can be paged.
+type Spec struct {
+	Sorts            map[string]Column
+	DefaultSort      string
+	DefaultDirection Direction
+	DateColumn       string // filtered by Params.From/To; empty disables the filter
+	IDColumn         string // unique tie-breaker, e.g. "alerts.id"
+}
+
+type Meta struct {
+	Limit      int     `json:"limit"`
+	NextCursor *string `json:"next_cursor"`
+	HasMore    bool    `json:"has_more"`
+	Total      *int64  `json:"total,omitempty"`
+}
+
+type Page[T any] struct {
+	Items []T  `json:"data"`
+	Meta  Meta `json:"pagination"`
+}
+
+type cursor struct {
+	Sort  string          `json:"s"`
+	Dir   Direction       `json:"d"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// Paginate runs query with keyset pagination over the sort column, its
+// tie-breakers and the id. Scopes are applied only to the page query (not the total count),
+// which is where preloads belong.
+func Paginate[T any](query *gorm.DB, params Params, spec Spec, scopes ...func(*gorm.DB) *gorm.DB) (*Page[T], error) {
+	sortKey, col, dir, err := spec.resolve(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.DateColumn != "" {
+		if params.From != nil {
+			query = query.Where(spec.DateColumn+" >= ?", *params.From)
+		}
+		if params.To != nil {
+			query = query.Where(spec.DateColumn+" < ?", *params.To)
+		}
+	}
+
+	page := &Page[T]{Items: []T{}}
+	page.Meta.Limit = params.limit()
+
+	if params.IncludeTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Meta.Total = &total
+	}
+
+	parts := col.parts()
+	if params.Cursor != "" {
+		values, id, err := decodeCursor(params.Cursor, sortKey, dir, parts)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if dir == Desc {
+			op = "<"
+		}
+		exprs := make([]string, len(parts))
+		for i, p := range parts {
+			exprs[i] = p.Expr
+		}
+		placeholders := strings.Repeat("?, ", len(parts))
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (%s?)", strings.Join(exprs, ", "), spec.IDColumn, op, placeholders), append(values, id)...)
+	}
+
+	query = query.Scopes(scopes...)
+	for _, p := range parts {
+		query = query.Order(p.Expr + " " + string(dir))
+	}
+	result := query.
+		Order(spec.IDColumn + " " + string(dir)).
+		Limit(page.Meta.Limit + 1).
+		Find(&page.Items)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(page.Items) > page.Meta.Limit {
+		page.Items = page.Items[:page.Meta.Limit]
+		next, err := encodeCursor(result, &page.Items[len(page.Items)-1], sortKey, dir, parts)
+		if err != nil {
+			return nil, err
+		}
+		page.Meta.HasMore = true
+		page.Meta.NextCursor = &next
+	}
+
+	return page, nil
+}
+
+func (p Params) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultLimit
+	case p.Limit > MaxLimit:
+		return MaxLimit
+	default:
+		return p.Limit
+	}
+}
+
+func (s Spec) resolve(params Params) (string, Column, Direction, error) {
+	sortKey := params.Sort
+	if sortKey == "" {
+		sortKey = s.DefaultSort
+	}
+	col, ok := s.Sorts[sortKey]
+	if !ok {
+		return "", Column{}, "", errs.InvalidField("query.sort", "must be one of ["+strings.Join(s.sortKeys(), " ")+"]")
+	}
+
+	dir := params.Direction
+	if dir == "" {
+		dir = s.DefaultDirection
+	}
+	if dir == "" {
+		dir = Desc
+	}
+
+	return sortKey, col, dir, nil
+}
+
+func (s Spec) sortKeys() []string {
+	keys := make([]string, 0, len(s.Sorts))
+	for k := range s.Sorts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeCursor keeps the item's sort values; a composite sort stores them as
+// an array.
+func encodeCursor(result *gorm.DB, item interface{}, sortKey string, dir Direction, parts []Column) (string, error) {
+	sch := result.Statement.Schema
+	if sch == nil {
+		return "", fmt.Errorf("pagination: schema not resolved")
+	}
+	if sch.PrioritizedPrimaryField == nil {
+		return "", fmt.Errorf("pagination: no primary key")
+	}
+
+	rv := reflect.ValueOf(item).Elem()
+	values := make([]interface{}, len(parts))
+	for i, p := range parts {
+		field := sch.LookUpField(p.Field)
+		if field == nil {
+			return "", fmt.Errorf("pagination: unknown cursor field %q", p.Field)
+		}
+		values[i], _ = field.ValueOf(result.Statement.Context, rv)
+	}
+	id, _ := sch.PrioritizedPrimaryField.ValueOf(result.Statement.Context, rv)
+
+	uid, ok := id.(uuid.UUID)
+	if !ok {
+		return "", fmt.Errorf("pagination: primary key is not a uuid")
+	}
+
+	var value interface{} = values
+	if len(values) == 1 {
+		value = values[0]
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(cursor{Sort: sortKey, Dir: dir, Value: raw, ID: uid})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(raw, sortKey string, dir Direction, parts []Column) ([]interface{}, uuid.UUID, error) {
+	invalid := errs.InvalidField("query.cursor", "is invalid or does not match the requested sort")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, uuid.Nil, invalid
+	}
+	var cur cursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, uuid.Nil, invalid
+	}
+	if cur.Sort != sortKey || cur.Dir != dir {
+		return nil, uuid.Nil, invalid
+	}
+
+	raws := []json.RawMessage{cur.Value}
+	if len(parts) > 1 {
+		if err := json.Unmarshal(cur.Value, &raws); err != nil || len(raws) != len(parts) {
+			return nil, uuid.Nil, invalid
+		}
+	}
+	values := make([]interface{}, len(parts))
+	for i, p := range parts {
+		v, err := decodeValue(p.Kind, raws[i])
+		if err != nil {
+			return nil, uuid.Nil, invalid
+		}
+		values[i] = v
+	}
+	return values, cur.ID, nil
+}
+
+func decodeValue(kind Kind, raw json.RawMessage) (interface{}, error) {
+	switch kind {
+	case KindTime:
+		var t time.Time
+		err := json.Unmarshal(raw, &t)
+		return t, err
+	case KindNumber:
+		var f float64
+		err := json.Unmarshal(raw, &f)
+		return f, err
+	default:
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+}
@@ -0,0 +1,147 @@
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type testItem struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Title     string
+	Score     int
+}
+
+// testResult stands in for the result of a Find over testItem.
+func testResult(t *testing.T) *gorm.DB {
+	t.Helper()
+	sch, err := schema.Parse(&testItem{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	return &gorm.DB{Statement: &gorm.Statement{Schema: sch, Context: context.Background()}}
+}
+
+var (
+	createdAt = Column{Expr: "items.created_at", Field: "created_at", Kind: KindTime}
+	title     = Column{Expr: "items.title", Field: "title", Kind: KindString}
+	score     = Column{Expr: "items.score", Field: "score", Kind: KindNumber}
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	item := testItem{
+		ID:        uuid.New(),
+		CreatedAt: time.Date(2026, 3, 1, 8, 30, 15, 123456789, time.FixedZone("+05", 5*3600)),
+		Title:     "Heart rate above 110",
+		Score:     42,
+	}
+	tests := []struct {
+		name string
+		col  Column
+		want []interface{}
+	}{
+		{"time", createdAt, []interface{}{item.CreatedAt}},
+		{"string", title, []interface{}{item.Title}},
+		{"number", score, []interface{}{float64(42)}},
+		{
+			"composite",
+			Column{Expr: score.Expr, Field: score.Field, Kind: KindNumber, Then: []Column{{Expr: title.Expr, Field: title.Field, Kind: KindString, Then: []Column{createdAt}}}},
+			[]interface{}{float64(42), item.Title, item.CreatedAt},
+		},
+	}
+	for _, tt := range tests {
+		for _, dir := range []Direction{Asc, Desc} {
+			t.Run(tt.name+" "+string(dir), func(t *testing.T) {
+				parts := tt.col.parts()
+				raw, err := encodeCursor(testResult(t), &item, "sorted", dir, parts)
+				if err != nil {
+					t.Fatalf("encodeCursor: %v", err)
+				}
+				values, id, err := decodeCursor(raw, "sorted", dir, parts)
+				if err != nil {
+					t.Fatalf("decodeCursor: %v", err)
+				}
+				if id != item.ID {
+					t.Errorf("id = %s, want %s", id, item.ID)
+				}
+				if len(values) != len(tt.want) {
+					t.Fatalf("values = %v, want %v", values, tt.want)
+				}
+				for i, want := range tt.want {
+					if wantTime, ok := want.(time.Time); ok {
+						if got, ok := values[i].(time.Time); !ok || !got.Equal(wantTime) {
+							t.Errorf("value %d = %v, want %v", i, values[i], want)
+						}
+						continue
+					}
+					if values[i] != want {
+						t.Errorf("value %d = %#v, want %#v", i, values[i], want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	item := testItem{ID: uuid.New(), CreatedAt: time.Now(), Title: "a", Score: 1}
+	composite := Column{Expr: score.Expr, Field: score.Field, Kind: KindNumber, Then: []Column{createdAt}}
+	valid, err := encodeCursor(testResult(t), &item, "score", Desc, composite.parts())
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+	encode := func(v string) string { return base64.RawURLEncoding.EncodeToString([]byte(v)) }
+	id := item.ID.String()
+
+	tests := []struct {
+		name  string
+		raw   string
+		sort  string
+		dir   Direction
+		parts []Column
+	}{
+		{"not base64", "%%%", "score", Desc, composite.parts()},
+		{"not json", encode("cursor"), "score", Desc, composite.parts()},
+		{"other sort", valid, "title", Desc, composite.parts()},
+		{"other direction", valid, "score", Asc, composite.parts()},
+		{"more tie-breakers than the cursor", valid, "score", Desc, []Column{score, createdAt, title}},
+		{"single value for a composite sort", encode(`{"s":"score","d":"DESC","v":1,"id":"` + id + `"}`), "score", Desc, composite.parts()},
+		{"string for a number", encode(`{"s":"score","d":"DESC","v":"1","id":"` + id + `"}`), "score", Desc, []Column{score}},
+		{"number for a time", encode(`{"s":"at","d":"DESC","v":1,"id":"` + id + `"}`), "at", Desc, []Column{createdAt}},
+		{"number for a string", encode(`{"s":"title","d":"DESC","v":1,"id":"` + id + `"}`), "title", Desc, []Column{title}},
+		{"bad id", encode(`{"s":"title","d":"DESC","v":"a","id":"nope"}`), "title", Desc, []Column{title}},
+	}
+	for _, tt := range tests {
+		if _, _, err := decodeCursor(tt.raw, tt.sort, tt.dir, tt.parts); err == nil {
+			t.Errorf("%s: decoded, want an invalid cursor", tt.name)
+		}
+	}
+}
+
+func TestEncodeCursorSingleValue(t *testing.T) {
+	item := testItem{ID: uuid.New(), Title: "a"}
+	raw, err := encodeCursor(testResult(t), &item, "title", Asc, []Column{title})
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(raw)
+	var cur cursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	// only a composite sort stores its values as an array
+	if string(cur.Value) != `"a"` {
+		t.Errorf("value = %s, want a bare string", cur.Value)
+	}
+	if _, err := encodeCursor(testResult(t), &item, "title", Asc, []Column{{Field: "missing"}}); err == nil {
+		t.Error("encoded an unknown field")
+	}
+}
@@ -37,11 +37,21 @@ func LoadDB(cfg *config.Config, logger *slog.Logger) (*PostgresDB, error) {
 		&models.Alert{},
 		&models.Checkin{},
 		&models.CheckinSchedule{},
+		&models.CheckinTransition{},
+		&models.HL7ControlID{},
+		&models.HL7Message{},
+		&models.IdempotencyKey{},
+		&models.InstrumentScore{},
 		&models.Organization{},
 		&models.OrganizationDoctor{},
 		&models.Patient{},
+		&models.PatientIdentifier{},
+		&models.QuestionnaireAssignment{},
+		&models.QuestionnaireTemplate{},
+		&models.ThresholdRule{},
 		&models.User{},
 		&models.VitalReading{},
+		&models.VitalTypeDefinition{},
 	}
 
 	if len(allModels) > 0 {
@@ -51,9 +61,68 @@ func LoadDB(cfg *config.Config, logger *slog.Logger) (*PostgresDB, error) {
 		logger.Info("auto migration completed")
 	}
 
+	if err := backfillBloodPressure(db); err != nil {
+		return nil, fmt.Errorf("blood pressure backfill failed: %v", err)
+	}
+	if err := backfillMeasuredAt(db); err != nil {
+		return nil, fmt.Errorf("measured_at backfill failed: %v", err)
+	}
+	if err := backfillCheckinStatus(db); err != nil {
+		return nil, fmt.Errorf("checkin status backfill failed: %v", err)
+	}
+	if err := backfillAnalyzedAt(db); err != nil {
+		return nil, fmt.Errorf("analyzed_at backfill failed: %v", err)
+	}
+	if err := ensureSearchIndexes(db); err != nil {
+		return nil, fmt.Errorf("search index setup failed: %v", err)
+	}
+
 	return &PostgresDB{DB: db}, nil
 }
 
+// backfillBloodPressure splits legacy "S/D" text readings into their
+// components. It only touches rows that were never split, so it is a no-op
+// once the data is migrated.
+func backfillBloodPressure(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE vital_readings v
+		SET systolic = p.s, diastolic = p.d, value_numeric = p.s,
+			mean_arterial_pressure = round((p.s + 2 * p.d) / 3, 2)
+		FROM (
+			SELECT id, m[1]::numeric AS s, m[2]::numeric AS d
+			FROM vital_readings, regexp_match(value_text, '^\s*(\d{2,3})\s*/\s*(\d{2,3})') AS m
+			WHERE vital_type = 'BLOOD_PRESSURE' AND systolic IS NULL AND m IS NOT NULL
+		) p
+		WHERE v.id = p.id AND p.d < p.s`).Error
+}
+
+// backfillMeasuredAt dates readings stored before measured_at existed by
+// when they were recorded.
+func backfillMeasuredAt(db *gorm.DB) error {
+	return db.Exec(`UPDATE vital_readings SET measured_at = created_at WHERE measured_at IS NULL`).Error
+}
+
+// backfillCheckinStatus moves checkins analyzed or reviewed before those
+// were statuses of their own out of COMPLETED.
+func backfillCheckinStatus(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE checkins SET status = 'REVIEWED' WHERE status = 'COMPLETED' AND reviewed_at IS NOT NULL`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE checkins SET status = 'ANALYZED' WHERE status = 'COMPLETED' AND ai_analysis IS NOT NULL`).Error
+}
+
+// backfillAnalyzedAt dates analyses stored before analyzed_at existed by
+// their transition to ANALYZED, or by the last update of checkins analyzed
+// before transitions were recorded.
+func backfillAnalyzedAt(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE checkins c
+		SET analyzed_at = COALESCE(
+			(SELECT MAX(t.created_at) FROM checkin_transitions t WHERE t.checkin_id = c.id AND t.to_status = 'ANALYZED'),
+			c.updated_at)
+		WHERE c.analyzed_at IS NULL AND c.status IN ('ANALYZED', 'REVIEWED')`).Error
+}
+
 func (p *PostgresDB) Close() error {
 	sqlDB, err := p.DB.DB()
 	if err != nil {
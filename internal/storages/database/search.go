@@ -0,0 +1,54 @@
+package database
+
+import "gorm.io/gorm"
+
+// searchDDL sets up full-text search over checkins and alerts. Postgres has
+// no Uzbek dictionary, so text is indexed three times: with the English and
+// Russian stemmers and with the simple configuration, which keeps words as
+// written and so also matches Uzbek in either script. Queries are parsed the
+// same three ways and match any of them.
+//
+// The vectors are generated columns kept up to date by Postgres itself,
+// which needs immutable expressions; array_to_string and the jsonb walk are
+// wrapped in functions declared immutable for that.
+var searchDDL = []string{
+	`CREATE OR REPLACE FUNCTION fts_vector(content text) RETURNS tsvector
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+		SELECT to_tsvector('simple', coalesce(content, ''))
+			|| to_tsvector('english', coalesce(content, ''))
+			|| to_tsvector('russian', coalesce(content, ''))
+	$$`,
+	`CREATE OR REPLACE FUNCTION fts_query(query text) RETURNS tsquery
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+		SELECT websearch_to_tsquery('simple', query)
+			|| websearch_to_tsquery('english', query)
+			|| websearch_to_tsquery('russian', query)
+	$$`,
+	// the patient's replies: the text as written, or the value of free-text
+	// answers stored without it
+	`CREATE OR REPLACE FUNCTION checkin_answers_text(answers jsonb) RETURNS text
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+		SELECT coalesce(string_agg(coalesce(a->>'text', CASE WHEN jsonb_typeof(a->'value') = 'string' THEN a->>'value' END), E'\n'), '')
+		FROM jsonb_array_elements(CASE WHEN jsonb_typeof(answers) = 'array' THEN answers ELSE '[]'::jsonb END) AS a
+	$$`,
+	`CREATE OR REPLACE FUNCTION checkin_messages_text(raw_messages text[]) RETURNS text
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+		SELECT coalesce(array_to_string(raw_messages, E'\n'), '')
+	$$`,
+	`ALTER TABLE checkins ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (fts_vector(
+			checkin_messages_text(raw_messages) || E'\n' || checkin_answers_text(answers) || E'\n' || coalesce(doctor_notes, ''))) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_checkins_search_vector ON checkins USING GIN (search_vector)`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (fts_vector(title || E'\n' || message)) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_alerts_search_vector ON alerts USING GIN (search_vector)`,
+}
+
+func ensureSearchIndexes(db *gorm.DB) error {
+	for _, stmt := range searchDDL {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/google/uuid"
+)
+
+// CheckinAnalyzer analyzes checkins as they complete and periodically sweeps
+// up those left unanalyzed. It does nothing when no analyzer is configured.
+type CheckinAnalyzer struct {
+	logger       *slog.Logger
+	analysisSvc  *services.AnalysisService
+	pollInterval time.Duration
+}
+
+func NewCheckinAnalyzer(logger *slog.Logger, analysisSvc *services.AnalysisService) *CheckinAnalyzer {
+	return &CheckinAnalyzer{
+		logger:       logger,
+		analysisSvc:  analysisSvc,
+		pollInterval: analysisSvc.Interval(),
+	}
+}
+
+func (w *CheckinAnalyzer) Start(ctx context.Context) {
+	if !w.analysisSvc.Enabled() {
+		w.logger.Info("checkin analyzer disabled; analyses come from the analysis service")
+		return
+	}
+	w.logger.Info("starting checkin analyzer", "interval", w.pollInterval.String())
+	go w.run(ctx)
+}
+
+func (w *CheckinAnalyzer) run(ctx context.Context) {
+	w.sweep(ctx)
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-w.analysisSvc.Queue():
+			w.analyze(ctx, id)
+		case <-ticker.C:
+			w.sweep(ctx)
+		}
+	}
+}
+
+func (w *CheckinAnalyzer) analyze(ctx context.Context, checkinID uuid.UUID) {
+	if _, err := w.analysisSvc.Analyze(ctx, checkinID); err != nil {
+		// left COMPLETED, so the next sweep retries it
+		w.logger.Error("failed to analyze checkin", "checkin_id", checkinID, "error", err)
+		return
+	}
+	w.logger.Info("analyzed checkin", "checkin_id", checkinID)
+}
+
+func (w *CheckinAnalyzer) sweep(ctx context.Context) {
+	analyzed, err := w.analysisSvc.Sweep(ctx)
+	if err != nil {
+		w.logger.Error("failed to analyze waiting checkins", "error", err)
+	}
+	if analyzed > 0 {
+		w.logger.Info("analyzed waiting checkins", "count", analyzed)
+	}
+}
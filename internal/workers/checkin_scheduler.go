@@ -56,6 +56,16 @@ func (s *CheckinScheduler) run(ctx context.Context) {
 }
 
 func (s *CheckinScheduler) processTick(now time.Time) {
+	// checkins the bot left open are completed first, so they neither go
+	// unanalyzed nor hold up the next scheduled checkin
+	completed, err := s.checkinSvc.CompleteInactive(now, services.CheckinActor{Trigger: enums.CheckinTriggerScheduler})
+	if err != nil {
+		s.logger.Error("failed to complete inactive checkins", "error", err)
+	}
+	if completed > 0 {
+		s.logger.Info("completed inactive checkins", "count", completed)
+	}
+
 	var schedules []models.CheckinSchedule
 	if err := s.db.Where("is_active = ?", true).Find(&schedules).Error; err != nil {
 		s.logger.Error("failed to load checkin schedules", "error", err)
@@ -103,10 +113,22 @@ func (s *CheckinScheduler) handleSchedule(schedule models.CheckinSchedule, tickT
 		return fmt.Errorf("get patient user id: %w", err)
 	}
 
-	if _, err := s.checkinSvc.GetActiveCheckin(patientUserID); err == nil {
+	active, err := s.checkinSvc.GetActiveCheckin(patientUserID)
+	if err == nil && active.Status == enums.CheckinStatusPending {
+		// the patient never answered the previous checkin; it is missed and
+		// this one starts in its place
+		reason := "not answered before the next scheduled checkin"
+		if _, err := s.checkinSvc.Miss(active.ID, services.CheckinActor{Trigger: enums.CheckinTriggerScheduler, Reason: &reason}); err != nil {
+			return fmt.Errorf("mark checkin missed: %w", err)
+		}
+		s.logger.Info("unanswered checkin missed", "checkin_id", active.ID, "patient_id", patientUserID, "schedule_id", schedule.ID)
+		err = errs.ErrNoActiveCheckin
+	}
+
+	if err == nil {
 		s.logger.Info("active checkin already in progress, skipping scheduled start", "patient_id", patientUserID, "schedule_id", schedule.ID)
 	} else if errors.Is(err, errs.ErrNoActiveCheckin) {
-		checkin, err := s.checkinSvc.StartManualCheckin(patientUserID, "text")
+		checkin, err := s.checkinSvc.StartManualCheckin(patientUserID, "text", services.CheckinActor{Trigger: enums.CheckinTriggerScheduler})
 		if err != nil {
 			return fmt.Errorf("start manual checkin: %w", err)
 		}
@@ -141,7 +163,10 @@ func (s *CheckinScheduler) getPatientUserID(patientID uuid.UUID) (uuid.UUID, err
 func (s *CheckinScheduler) updateNextCheckinAt(scheduleID uuid.UUID, nextAt *time.Time) error {
 	return s.db.Model(&models.CheckinSchedule{}).
 		Where("id = ?", scheduleID).
-		Update("next_checkin_at", nextAt).
+		Updates(map[string]interface{}{
+			"next_checkin_at": nextAt,
+			"version":         gorm.Expr("version + 1"),
+		}).
 		Error
 }
 
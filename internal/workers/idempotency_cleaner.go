@@ -0,0 +1,56 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+)
+
+// IdempotencyCleaner periodically deletes idempotency keys past their retention window.
+type IdempotencyCleaner struct {
+	logger         *slog.Logger
+	idempotencySvc *services.IdempotencyService
+	pollInterval   time.Duration
+}
+
+func NewIdempotencyCleaner(logger *slog.Logger, idempotencySvc *services.IdempotencyService) *IdempotencyCleaner {
+	return &IdempotencyCleaner{
+		logger:         logger,
+		idempotencySvc: idempotencySvc,
+		pollInterval:   time.Hour,
+	}
+}
+
+func (w *IdempotencyCleaner) Start(ctx context.Context) {
+	w.logger.Info("starting idempotency key cleaner", "interval", w.pollInterval.String())
+	go w.run(ctx)
+}
+
+func (w *IdempotencyCleaner) run(ctx context.Context) {
+	w.purge(time.Now())
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.purge(now)
+		}
+	}
+}
+
+func (w *IdempotencyCleaner) purge(now time.Time) {
+	deleted, err := w.idempotencySvc.PurgeExpired(now)
+	if err != nil {
+		w.logger.Error("failed to purge idempotency keys", "error", err)
+		return
+	}
+	if deleted > 0 {
+		w.logger.Info("purged expired idempotency keys", "count", deleted)
+	}
+}
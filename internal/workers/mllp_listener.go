@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+	"github.com/erkinov-wtf/vital-sync/internal/enums"
+	"github.com/erkinov-wtf/vital-sync/internal/pkg/hl7"
+)
+
+const mllpWriteTimeout = 30 * time.Second
+
+// MLLPListener accepts HL7 v2 messages over MLLP. Each connection is served
+// in turn: a message is processed and acknowledged before the next is read,
+// as MLLP senders expect.
+type MLLPListener struct {
+	logger *slog.Logger
+	hl7Svc *services.HL7Service
+	addr   string
+}
+
+func NewMLLPListener(logger *slog.Logger, hl7Svc *services.HL7Service, addr string) *MLLPListener {
+	return &MLLPListener{
+		logger: logger,
+		hl7Svc: hl7Svc,
+		addr:   addr,
+	}
+}
+
+// Start listens on the configured address; an empty address disables the
+// listener. It stops accepting connections when ctx is done.
+func (l *MLLPListener) Start(ctx context.Context) {
+	if l.addr == "" {
+		l.logger.Info("mllp listener disabled")
+		return
+	}
+
+	ln, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		l.logger.Error("failed to start mllp listener", "address", l.addr, "error", err)
+		return
+	}
+	l.logger.Info("starting mllp listener", "address", ln.Addr().String())
+
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	go l.accept(ctx, ln)
+}
+
+func (l *MLLPListener) accept(ctx context.Context, ln net.Listener) {
+	var conns sync.Map
+	defer conns.Range(func(key, _ any) bool {
+		_ = key.(net.Conn).Close()
+		return true
+	})
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			l.logger.Error("failed to accept mllp connection", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		conns.Store(conn, struct{}{})
+		go func() {
+			defer conns.Delete(conn)
+			l.serve(conn)
+		}()
+	}
+}
+
+func (l *MLLPListener) serve(conn net.Conn) {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	l.logger.Info("mllp connection opened", "remote", remote)
+
+	reader := hl7.NewFrameReader(conn, l.hl7Svc.MaxMessageSize())
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(l.hl7Svc.IdleTimeout()))
+		frame, err := reader.ReadFrame()
+
+		var ack []byte
+		switch {
+		case errors.Is(err, hl7.ErrFrameTooLarge):
+			l.logger.Warn("mllp message too large", "remote", remote)
+			ack = l.hl7Svc.Oversized(frame, enums.HL7TransportMLLP)
+		case err != nil:
+			var netErr net.Error
+			switch {
+			case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+				l.logger.Info("mllp connection closed", "remote", remote)
+			case errors.As(err, &netErr) && netErr.Timeout():
+				l.logger.Info("mllp connection idle, closing", "remote", remote)
+			default:
+				l.logger.Warn("mllp connection failed", "remote", remote, "error", err)
+			}
+			return
+		default:
+			ack = l.hl7Svc.Handle(frame, enums.HL7TransportMLLP)
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(mllpWriteTimeout))
+		if err := hl7.WriteFrame(conn, ack); err != nil {
+			l.logger.Warn("failed to send mllp ack", "remote", remote, "error", err)
+			return
+		}
+	}
+}
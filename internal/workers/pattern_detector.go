@@ -0,0 +1,56 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/erkinov-wtf/vital-sync/internal/api/services"
+)
+
+// PatternDetector periodically scans monitored patients for trends across
+// readings and checkins and raises PATTERN_DETECTED alerts.
+type PatternDetector struct {
+	logger       *slog.Logger
+	patternSvc   *services.PatternService
+	pollInterval time.Duration
+}
+
+func NewPatternDetector(logger *slog.Logger, patternSvc *services.PatternService) *PatternDetector {
+	return &PatternDetector{
+		logger:       logger,
+		patternSvc:   patternSvc,
+		pollInterval: patternSvc.Interval(),
+	}
+}
+
+func (w *PatternDetector) Start(ctx context.Context) {
+	w.logger.Info("starting pattern detector", "interval", w.pollInterval.String())
+	go w.run(ctx)
+}
+
+func (w *PatternDetector) run(ctx context.Context) {
+	w.scan(time.Now())
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.scan(now)
+		}
+	}
+}
+
+func (w *PatternDetector) scan(now time.Time) {
+	raised, err := w.patternSvc.Scan(now)
+	if err != nil {
+		w.logger.Error("failed to detect patterns", "error", err)
+	}
+	if raised > 0 {
+		w.logger.Info("raised pattern alerts", "count", raised)
+	}
+}